@@ -5,6 +5,11 @@
  * Outputs a summary of the images found.
  *
  * Run as: go run pdf_summarize_images.go ~/testdata/*.pdf
+ *
+ * For corpora of untrusted files, -isolate processes each file in a subprocess so that a file that
+ * crashes or hangs the library does not stop the run. -timeout and -max-mem set per file limits and
+ * imply -isolate. The files that fail are listed at the end with their outcome, and with -crash-dir
+ * the stack traces of the crashing, timing out and memory exceeding files are saved.
  */
 
 package main
@@ -16,9 +21,10 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
-	"time"
 
+	"github.com/unidoc/unidoc-examples/testing/corpus"
 	"github.com/unidoc/unipdf/v4/common"
 	"github.com/unidoc/unipdf/v4/common/license"
 	"github.com/unidoc/unipdf/v4/contentstream"
@@ -47,6 +53,15 @@ func main() {
 	flag.StringVar(&csvPath, "o", "results.csv", "CSV results file.")
 	flag.BoolVar(&byDoc, "p", false, "No page numbers specified in CSV file rows.")
 	flag.BoolVar(&noDims, "w", false, "No widths and heights specified in CSV file rows.")
+	var isolate bool
+	var limits corpus.Limits
+	var maxMemMiB uint64
+	var crashDir, jsonPath string
+	flag.BoolVar(&isolate, "isolate", false, "Process each file in a subprocess.")
+	flag.DurationVar(&limits.Timeout, "timeout", 0, "Time limit per file, e.g. 2m (implies -isolate).")
+	flag.Uint64Var(&maxMemMiB, "max-mem", 0, "Heap limit per file in MiB (implies -isolate).")
+	flag.StringVar(&crashDir, "crash-dir", "", "Save the stack traces of the crashing files in this directory.")
+	flag.StringVar(&jsonPath, "json", "", "Machine readable results file (see testing/corpus_compare.go).")
 	makeUsage(usage)
 
 	flag.Parse()
@@ -63,13 +78,31 @@ func main() {
 	} else {
 		common.SetLogger(common.NewConsoleLogger(common.LogLevelInfo))
 	}
+	limits.MaxMemory = maxMemMiB << 20
+	if limits.Timeout > 0 || limits.MaxMemory > 0 {
+		isolate = true
+	}
+
+	// With -isolate, the subprocesses processing the files stop here. They pass the images found
+	// to the parent as CSV rows.
+	corpus.ServeWorker(func(inputPath string) (corpus.Outcome, any, error) {
+		fileInfo, err := fileImages(inputPath)
+		if err != nil {
+			return corpus.OutcomeBad, nil, err
+		}
+		rows := make([][]string, len(fileInfo))
+		for i, info := range fileInfo {
+			rows[i] = info.asStrings()
+		}
+		return corpus.OutcomePass, rows, nil
+	})
 
-	corpus := args[:]
-	if len(corpus) > 1000 {
-		corpus = corpus[:1000]
+	inputPaths := args[:]
+	if len(inputPaths) > 1000 {
+		inputPaths = inputPaths[:1000]
 	}
-	sort.Slice(corpus, func(i, j int) bool {
-		fi, fj := corpus[i], corpus[j]
+	sort.Slice(inputPaths, func(i, j int) bool {
+		fi, fj := inputPaths[i], inputPaths[j]
 		si, sj := fileSizeMB(fi), fileSizeMB(fj)
 		if si != sj {
 			return si < sj
@@ -77,23 +110,71 @@ func main() {
 		return fi < fj
 	})
 
+	run := corpus.NewRun("summarize_images")
+	run.CrashDir = crashDir
 	corpusInfo := map[string][]imageInfo{}
-	for i, inputPath := range corpus {
-		fmt.Fprintf(os.Stderr, "%4d of %d %q %.1f MB,", i, len(corpus), filepath.Base(inputPath),
+	for i, inputPath := range inputPaths {
+		fmt.Fprintf(os.Stderr, "%4d of %d %q %.1f MB,", i, len(inputPaths), filepath.Base(inputPath),
 			fileSizeMB(inputPath))
-		t0 := time.Now()
-		fileInfo, err := fileImages(inputPath)
-		if err != nil {
-			fmt.Fprintf(os.Stderr, " ERROR: %v\n", err)
+		var fileInfo []imageInfo
+		var res corpus.Result
+		if isolate {
+			var rows [][]string
+			res = run.MeasureIsolated(inputPath, limits, &rows)
+			for _, row := range rows {
+				info, err := parseImageInfo(row)
+				if err != nil {
+					res.Outcome, res.Error = corpus.OutcomeBad, err.Error()
+					break
+				}
+				fileInfo = append(fileInfo, info)
+			}
+		} else {
+			res = run.Measure(inputPath, func() (corpus.Outcome, error) {
+				var err error
+				fileInfo, err = fileImages(inputPath)
+				if err != nil {
+					return corpus.OutcomeBad, err
+				}
+				return corpus.OutcomePass, nil
+			})
+		}
+		run.Add(res)
+		if res.Outcome != corpus.OutcomePass {
+			fmt.Fprintf(os.Stderr, " %s: %s\n", strings.ToUpper(string(res.Outcome)), res.Error)
 			continue
 		}
-		dt := time.Now().Sub(t0)
 		corpusInfo[inputPath] = fileInfo
-		fmt.Fprintf(os.Stderr, ", %.1f sec\n", dt.Seconds())
+		fmt.Fprintf(os.Stderr, ", %.1f sec\n", res.Seconds)
 	}
 
-	showSummary(corpus, corpusInfo)
-	saveAsCsv(csvPath, corpus, corpusInfo, doSort, byDoc, noDims)
+	showSummary(inputPaths, corpusInfo)
+	showFailures(run)
+	saveAsCsv(csvPath, inputPaths, corpusInfo, doSort, byDoc, noDims)
+	if len(jsonPath) > 0 {
+		if err := run.WriteFile(jsonPath); err != nil {
+			fmt.Fprintf(os.Stderr, "Couldn't write %q. %v\n", jsonPath, err)
+		}
+	}
+}
+
+// showFailures lists the files of `run` that were not processed.
+func showFailures(run *corpus.Run) {
+	s := run.Summary()
+	if s.Pass == s.Files {
+		return
+	}
+	fmt.Println("-----------------------------------------")
+	fmt.Printf("Failures: %s\n", s)
+	for _, res := range run.Results {
+		if res.Outcome == corpus.OutcomePass {
+			continue
+		}
+		fmt.Printf("\t%-8s %s: %s\n", res.Outcome, res.File, res.Error)
+		if len(res.CrashReport) > 0 {
+			fmt.Printf("\t         crash report: %s\n", res.CrashReport)
+		}
+	}
 }
 
 // fileImages returns a list of imageInfo entries for the images in the PDF file `inputPath`.
@@ -334,6 +415,33 @@ func (info imageInfo) asStrings() []string {
 	return parts
 }
 
+// parseImageInfo returns the imageInfo of the CSV row `parts` returned by asStrings.
+func parseImageInfo(parts []string) (imageInfo, error) {
+	if len(parts) != len(header) {
+		return imageInfo{}, fmt.Errorf("bad image row %q", parts)
+	}
+	var ints [6]int
+	for i, k := range []int{1, 2, 5, 6, 7, 9} {
+		v, err := strconv.Atoi(parts[k])
+		if err != nil {
+			return imageInfo{}, fmt.Errorf("bad image row %q: %v", parts, err)
+		}
+		ints[i] = v
+	}
+	return imageInfo{
+		path:       parts[0],
+		page:       ints[0],
+		count:      ints[1],
+		inline:     parts[3] == "Inline image",
+		filter:     parts[4],
+		width:      ints[2],
+		height:     ints[3],
+		cpts:       ints[4],
+		colorspace: parts[8],
+		bpc:        ints[5],
+	}, nil
+}
+
 var header = []string{
 	"Path",
 	"Page number",
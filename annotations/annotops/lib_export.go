@@ -0,0 +1,302 @@
+/*
+ * Export of the markup annotations of PDF files (comments, highlights, shapes...) with the text
+ * they cover, for review workflows.
+ *
+ * The covered text of text markup annotations (Highlight, Underline, StrikeOut, Squiggly) is the
+ * text under their QuadPoints, of Square and Circle annotations the text inside their rectangle.
+ */
+
+package annotops
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/unidoc/unipdf/v4/core"
+	"github.com/unidoc/unipdf/v4/extractor"
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// Annotation is an exported markup annotation.
+type Annotation struct {
+	File     string     `json:"file"`
+	Page     int        `json:"page"`
+	Type     string     `json:"type"` // Subtype, e.g. Highlight, Text or Square.
+	Author   string     `json:"author"`
+	Subject  string     `json:"subject"`
+	Comment  string     `json:"comment"`
+	Text     string     `json:"text"` // Covered text.
+	Color    string     `json:"color"`
+	Created  string     `json:"created"`
+	Modified string     `json:"modified"`
+	Rect     [4]float64 `json:"rect"`
+}
+
+// markupAnnotation is the markup part of an annotation.
+type markupAnnotation struct {
+	subtype    string
+	markup     *model.PdfAnnotationMarkup
+	quadPoints core.PdfObject
+	coversRect bool // The covered text is the text inside the rectangle.
+}
+
+// ExportMarkup returns the markup annotations of `inputPath` in page order.
+func ExportMarkup(inputPath string) ([]Annotation, error) {
+	pdfReader, f, err := model.NewPdfReaderFromFile(inputPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	numPages, err := pdfReader.GetNumPages()
+	if err != nil {
+		return nil, err
+	}
+
+	annotations := []Annotation{}
+	for i := 0; i < numPages; i++ {
+		pageNum := i + 1
+		page, err := pdfReader.GetPage(pageNum)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+		pageAnnotations, err := page.GetAnnotations()
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+
+		var marks []extractor.TextMark
+		extracted := false
+		for _, a := range pageAnnotations {
+			ma, ok := markupOf(a)
+			if !ok {
+				continue
+			}
+			exported := Annotation{
+				File:     inputPath,
+				Page:     pageNum,
+				Type:     ma.subtype,
+				Author:   stringVal(ma.markup.T),
+				Subject:  stringVal(ma.markup.Subj),
+				Comment:  stringVal(a.Contents),
+				Color:    colorHex(a.C),
+				Created:  dateVal(ma.markup.CreationDate),
+				Modified: dateVal(a.M),
+				Rect:     rectVal(a.Rect),
+			}
+
+			boxes := quadBoxes(ma.quadPoints)
+			if len(boxes) == 0 && ma.coversRect {
+				boxes = [][4]float64{exported.Rect}
+			}
+			if len(boxes) > 0 {
+				if !extracted {
+					marks, err = pageMarks(page)
+					if err != nil {
+						return nil, fmt.Errorf("page %d: %w", pageNum, err)
+					}
+					extracted = true
+				}
+				exported.Text = coveredText(marks, boxes)
+			}
+			annotations = append(annotations, exported)
+		}
+	}
+	return annotations, nil
+}
+
+// WriteJSON writes `annotations` as indented JSON to `w`.
+func WriteJSON(w io.Writer, annotations []Annotation) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(annotations)
+}
+
+// WriteCSV writes `annotations` as CSV with a header row to `w`.
+func WriteCSV(w io.Writer, annotations []Annotation) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"file", "page", "type", "author", "subject", "comment", "text", "color",
+		"created", "modified", "llx", "lly", "urx", "ury"})
+	for _, a := range annotations {
+		record := []string{a.File, strconv.Itoa(a.Page), a.Type, a.Author, a.Subject, a.Comment, a.Text,
+			a.Color, a.Created, a.Modified}
+		for _, v := range a.Rect {
+			record = append(record, strconv.FormatFloat(v, 'f', 2, 64))
+		}
+		cw.Write(record)
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// markupOf returns the markup part of `a`, false if `a` is not a markup annotation, e.g. a link, a
+// form field or a popup.
+func markupOf(a *model.PdfAnnotation) (markupAnnotation, bool) {
+	switch t := a.GetContext().(type) {
+	case *model.PdfAnnotationHighlight:
+		return markupAnnotation{"Highlight", t.PdfAnnotationMarkup, t.QuadPoints, false}, true
+	case *model.PdfAnnotationUnderline:
+		return markupAnnotation{"Underline", t.PdfAnnotationMarkup, t.QuadPoints, false}, true
+	case *model.PdfAnnotationStrikeOut:
+		return markupAnnotation{"StrikeOut", t.PdfAnnotationMarkup, t.QuadPoints, false}, true
+	case *model.PdfAnnotationSquiggly:
+		return markupAnnotation{"Squiggly", t.PdfAnnotationMarkup, t.QuadPoints, false}, true
+	case *model.PdfAnnotationSquare:
+		return markupAnnotation{"Square", t.PdfAnnotationMarkup, nil, true}, true
+	case *model.PdfAnnotationCircle:
+		return markupAnnotation{"Circle", t.PdfAnnotationMarkup, nil, true}, true
+	case *model.PdfAnnotationText:
+		return markupAnnotation{"Text", t.PdfAnnotationMarkup, nil, false}, true
+	case *model.PdfAnnotationFreeText:
+		return markupAnnotation{"FreeText", t.PdfAnnotationMarkup, nil, false}, true
+	case *model.PdfAnnotationInk:
+		return markupAnnotation{"Ink", t.PdfAnnotationMarkup, nil, false}, true
+	case *model.PdfAnnotationLine:
+		return markupAnnotation{"Line", t.PdfAnnotationMarkup, nil, false}, true
+	case *model.PdfAnnotationPolygon:
+		return markupAnnotation{"Polygon", t.PdfAnnotationMarkup, nil, false}, true
+	case *model.PdfAnnotationPolyLine:
+		return markupAnnotation{"PolyLine", t.PdfAnnotationMarkup, nil, false}, true
+	case *model.PdfAnnotationStamp:
+		return markupAnnotation{"Stamp", t.PdfAnnotationMarkup, nil, false}, true
+	case *model.PdfAnnotationCaret:
+		return markupAnnotation{"Caret", t.PdfAnnotationMarkup, nil, false}, true
+	case *model.PdfAnnotationFileAttachment:
+		return markupAnnotation{"FileAttachment", t.PdfAnnotationMarkup, nil, false}, true
+	case *model.PdfAnnotationSound:
+		return markupAnnotation{"Sound", t.PdfAnnotationMarkup, nil, false}, true
+	}
+	return markupAnnotation{}, false
+}
+
+// pageMarks returns the text marks of `page`.
+func pageMarks(page *model.PdfPage) ([]extractor.TextMark, error) {
+	ex, err := extractor.New(page)
+	if err != nil {
+		return nil, err
+	}
+	pageText, _, _, err := ex.ExtractPageText()
+	if err != nil {
+		return nil, err
+	}
+	return pageText.Marks().Elements(), nil
+}
+
+// coveredText returns the text of the `marks` whose centers are inside one of `boxes`, with a
+// space wherever text outside of the boxes or a word break was skipped.
+func coveredText(marks []extractor.TextMark, boxes [][4]float64) string {
+	var sb strings.Builder
+	gap := false
+	for _, tm := range marks {
+		x, y := (tm.BBox.Llx+tm.BBox.Urx)/2, (tm.BBox.Lly+tm.BBox.Ury)/2
+		if tm.Meta || strings.TrimSpace(tm.Text) == "" || !inBoxes(x, y, boxes) {
+			gap = sb.Len() > 0
+			continue
+		}
+		if gap {
+			sb.WriteByte(' ')
+			gap = false
+		}
+		sb.WriteString(tm.Text)
+	}
+	return sb.String()
+}
+
+func inBoxes(x, y float64, boxes [][4]float64) bool {
+	for _, b := range boxes {
+		if x >= b[0] && x <= b[2] && y >= b[1] && y <= b[3] {
+			return true
+		}
+	}
+	return false
+}
+
+// quadBoxes returns the bounding boxes of the quadrilaterals of `quadPoints`.
+func quadBoxes(quadPoints core.PdfObject) [][4]float64 {
+	arr, ok := core.GetArray(quadPoints)
+	if !ok {
+		return nil
+	}
+	v, err := arr.ToFloat64Array()
+	if err != nil {
+		return nil
+	}
+
+	var boxes [][4]float64
+	for i := 0; i+8 <= len(v); i += 8 {
+		b := [4]float64{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+		for j := i; j < i+8; j += 2 {
+			b[0], b[1] = math.Min(b[0], v[j]), math.Min(b[1], v[j+1])
+			b[2], b[3] = math.Max(b[2], v[j]), math.Max(b[3], v[j+1])
+		}
+		boxes = append(boxes, b)
+	}
+	return boxes
+}
+
+// rectVal returns the normalized rectangle of `obj`.
+func rectVal(obj core.PdfObject) [4]float64 {
+	arr, ok := core.GetArray(obj)
+	if !ok {
+		return [4]float64{}
+	}
+	v, err := arr.ToFloat64Array()
+	if err != nil || len(v) != 4 {
+		return [4]float64{}
+	}
+	return [4]float64{math.Min(v[0], v[2]), math.Min(v[1], v[3]), math.Max(v[0], v[2]), math.Max(v[1], v[3])}
+}
+
+// colorHex returns the "#rrggbb" form of the gray, RGB or CMYK color array `obj`.
+func colorHex(obj core.PdfObject) string {
+	arr, ok := core.GetArray(obj)
+	if !ok {
+		return ""
+	}
+	v, err := arr.ToFloat64Array()
+	if err != nil {
+		return ""
+	}
+
+	var r, g, b float64
+	switch len(v) {
+	case 1:
+		r, g, b = v[0], v[0], v[0]
+	case 3:
+		r, g, b = v[0], v[1], v[2]
+	case 4:
+		k := 1 - v[3]
+		r, g, b = (1-v[0])*k, (1-v[1])*k, (1-v[2])*k
+	default:
+		return ""
+	}
+	c := func(x float64) int { return int(math.Round(math.Max(0, math.Min(1, x)) * 255)) }
+	return fmt.Sprintf("#%02x%02x%02x", c(r), c(g), c(b))
+}
+
+// stringVal returns the decoded text of the string `obj`.
+func stringVal(obj core.PdfObject) string {
+	if s, ok := core.GetString(obj); ok {
+		return s.Decoded()
+	}
+	return ""
+}
+
+// dateVal returns the PDF date string `obj` in RFC 3339 format, or as is if it is not a valid date.
+func dateVal(obj core.PdfObject) string {
+	s := stringVal(obj)
+	if s == "" {
+		return ""
+	}
+	d, err := model.NewPdfDate(s)
+	if err != nil {
+		return s
+	}
+	return d.ToGoTime().Format(time.RFC3339)
+}
@@ -0,0 +1,259 @@
+/*
+ * Text markup annotations (Highlight, Underline, StrikeOut, Squiggly) over search matches.
+ *
+ * The annotations cover each line of a match with a quadrilateral (QuadPoints) and carry the
+ * author, subject and comment shown in the comment panel of PDF viewers. An appearance stream is
+ * generated for each annotation so that they look the same in all viewers.
+ */
+
+package annotops
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/unidoc/unidoc-examples/pages/pageops"
+	"github.com/unidoc/unidoc-examples/search-and-replace/searchops"
+	"github.com/unidoc/unipdf/v4/contentstream"
+	"github.com/unidoc/unipdf/v4/core"
+	"github.com/unidoc/unipdf/v4/extractor"
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// MarkupType is the type of a text markup annotation.
+type MarkupType string
+
+// Text markup annotation types.
+const (
+	MarkupHighlight MarkupType = "highlight"
+	MarkupUnderline MarkupType = "underline"
+	MarkupStrikeOut MarkupType = "strikeout"
+	MarkupSquiggly  MarkupType = "squiggly"
+)
+
+// defaultColors are the default colors of the markup types, as used by common PDF viewers.
+var defaultColors = map[MarkupType]string{
+	MarkupHighlight: "#ffff00",
+	MarkupUnderline: "#00c000",
+	MarkupStrikeOut: "#ff0000",
+	MarkupSquiggly:  "#0070ff",
+}
+
+// ParseMarkupType parses a markup type name: highlight, underline, strikeout or squiggly.
+func ParseMarkupType(s string) (MarkupType, error) {
+	t := MarkupType(strings.ToLower(s))
+	if _, ok := defaultColors[t]; !ok {
+		return "", fmt.Errorf("invalid markup type %q (expected highlight, underline, strikeout or squiggly)", s)
+	}
+	return t, nil
+}
+
+// MarkupOptions configures the markup annotations.
+type MarkupOptions struct {
+	Type MarkupType
+	// Color is the "#rrggbb" color of the annotations. Defaults to the usual color of the type.
+	Color string
+	// Opacity of the annotations, 0 for opaque.
+	Opacity float64
+	// Author, Subject and Comment are shown in the comment panel of PDF viewers.
+	Author  string
+	Subject string
+	Comment string
+}
+
+// AnnotateMatches adds a markup annotation over each match of `m` on the pages `pages` of
+// `inputPath`, all pages if `pages` is empty, writes the result to `outputPath` and returns the
+// matches.
+func AnnotateMatches(inputPath, outputPath string, m *searchops.Matcher, pages pageops.PageRanges,
+	opts MarkupOptions) ([]searchops.Match, error) {
+	pdfReader, f, err := model.NewPdfReaderFromFile(inputPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	matches := []searchops.Match{}
+	pdfWriter, err := pdfReader.ToWriter(&model.ReaderToWriterOpts{
+		PageProcessCallback: func(pageNum int, page *model.PdfPage) error {
+			if !pages.Contains(pageNum) {
+				return nil
+			}
+			ex, err := extractor.New(page)
+			if err != nil {
+				return fmt.Errorf("page %d: %w", pageNum, err)
+			}
+			pageText, _, _, err := ex.ExtractPageText()
+			if err != nil {
+				return fmt.Errorf("page %d: %w", pageNum, err)
+			}
+
+			for _, match := range m.FindPage(pageNum, pageText.Text(), pageText.Marks()) {
+				if len(match.Boxes) == 0 {
+					continue
+				}
+				annotation, err := NewMarkup(match.Boxes, opts)
+				if err != nil {
+					return err
+				}
+				page.AddAnnotation(annotation)
+				matches = append(matches, match)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pdfWriter.WriteToFile(outputPath); err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// NewMarkup returns a markup annotation covering the line boxes `boxes` ([llx lly urx ury] in page
+// coordinates).
+func NewMarkup(boxes [][4]float64, opts MarkupOptions) (*model.PdfAnnotation, error) {
+	if len(boxes) == 0 {
+		return nil, fmt.Errorf("no boxes to mark up")
+	}
+	if opts.Type == "" {
+		opts.Type = MarkupHighlight
+	}
+	if opts.Color == "" {
+		opts.Color = defaultColors[opts.Type]
+	}
+	r, g, b, err := parseHexColor(opts.Color)
+	if err != nil {
+		return nil, err
+	}
+
+	// QuadPoints list the upper left, upper right, lower left and lower right corners of each box,
+	// the order used by Acrobat.
+	var quads []float64
+	rect := boxes[0]
+	for _, box := range boxes {
+		quads = append(quads, box[0], box[3], box[2], box[3], box[0], box[1], box[2], box[1])
+		rect = [4]float64{
+			math.Min(rect[0], box[0]), math.Min(rect[1], box[1]),
+			math.Max(rect[2], box[2]), math.Max(rect[3], box[3]),
+		}
+	}
+
+	var annotation *model.PdfAnnotation
+	var markup *model.PdfAnnotationMarkup
+	quadPoints := core.MakeArrayFromFloats(quads)
+	switch opts.Type {
+	case MarkupHighlight:
+		a := model.NewPdfAnnotationHighlight()
+		a.QuadPoints = quadPoints
+		annotation, markup = a.PdfAnnotation, a.PdfAnnotationMarkup
+	case MarkupUnderline:
+		a := model.NewPdfAnnotationUnderline()
+		a.QuadPoints = quadPoints
+		annotation, markup = a.PdfAnnotation, a.PdfAnnotationMarkup
+	case MarkupStrikeOut:
+		a := model.NewPdfAnnotationStrikeOut()
+		a.QuadPoints = quadPoints
+		annotation, markup = a.PdfAnnotation, a.PdfAnnotationMarkup
+	case MarkupSquiggly:
+		a := model.NewPdfAnnotationSquiggly()
+		a.QuadPoints = quadPoints
+		annotation, markup = a.PdfAnnotation, a.PdfAnnotationMarkup
+	default:
+		return nil, fmt.Errorf("invalid markup type %q", opts.Type)
+	}
+
+	annotation.Rect = core.MakeArrayFromFloats(rect[:])
+	annotation.C = core.MakeArrayFromFloats([]float64{r, g, b})
+	annotation.F = core.MakeInteger(4) // Print.
+	if opts.Comment != "" {
+		annotation.Contents = core.MakeString(opts.Comment)
+	}
+	if opts.Author != "" {
+		markup.T = core.MakeString(opts.Author)
+	}
+	if opts.Subject != "" {
+		markup.Subj = core.MakeString(opts.Subject)
+	}
+	if opts.Opacity > 0 && opts.Opacity < 1 {
+		markup.CA = core.MakeFloat(opts.Opacity)
+	}
+	if now, err := model.NewPdfDateFromTime(time.Now()); err == nil {
+		markup.CreationDate = now.ToPdfObject()
+		annotation.M = now.ToPdfObject()
+	}
+
+	ap, err := markupAppearance(opts, rect, boxes, [3]float64{r, g, b})
+	if err != nil {
+		return nil, err
+	}
+	apDict := core.MakeDict()
+	apDict.Set("N", ap.ToPdfObject())
+	annotation.AP = apDict
+	return annotation, nil
+}
+
+// markupAppearance returns the normal appearance of a markup annotation of bounding box `rect`
+// over `boxes` with color `rgb`.
+func markupAppearance(opts MarkupOptions, rect [4]float64, boxes [][4]float64, rgb [3]float64) (*model.XObjectForm, error) {
+	form := model.NewXObjectForm()
+	form.BBox = core.MakeArrayFromFloats(rect[:])
+	form.Resources = model.NewPdfPageResources()
+
+	// Highlights multiply their color with the page so that the text stays readable.
+	gs := core.MakeDict()
+	if opts.Type == MarkupHighlight {
+		gs.Set("BM", core.MakeName("Multiply"))
+	}
+	if opts.Opacity > 0 && opts.Opacity < 1 {
+		gs.Set("ca", core.MakeFloat(opts.Opacity))
+		gs.Set("CA", core.MakeFloat(opts.Opacity))
+	}
+	if err := form.Resources.AddExtGState("GS0", gs); err != nil {
+		return nil, err
+	}
+
+	cc := contentstream.NewContentCreator()
+	cc.Add_q().Add_gs("GS0").Add_rg(rgb[0], rgb[1], rgb[2]).Add_RG(rgb[0], rgb[1], rgb[2])
+	for _, b := range boxes {
+		width, height := b[2]-b[0], b[3]-b[1]
+		switch opts.Type {
+		case MarkupHighlight:
+			cc.Add_re(b[0], b[1], width, height).Add_f()
+		case MarkupUnderline:
+			y := b[1] + height/7
+			cc.Add_w(math.Max(height/16, 0.5)).Add_m(b[0], y).Add_l(b[2], y).Add_S()
+		case MarkupStrikeOut:
+			y := b[1] + height*0.45
+			cc.Add_w(math.Max(height/16, 0.5)).Add_m(b[0], y).Add_l(b[2], y).Add_S()
+		case MarkupSquiggly:
+			step := math.Max(height/6, 1)
+			y := b[1] + height/10
+			cc.Add_w(math.Max(height/20, 0.5)).Add_m(b[0], y)
+			for i, x := 1, b[0]+step; x <= b[2]; i, x = i+1, x+step {
+				cc.Add_l(x, y+step*float64(i%2))
+			}
+			cc.Add_S()
+		}
+	}
+	cc.Add_Q()
+
+	if err := form.SetContentStream(cc.Bytes(), core.NewFlateEncoder()); err != nil {
+		return nil, err
+	}
+	return form, nil
+}
+
+// parseHexColor returns the components in [0, 1] of the "#rrggbb" color `s`.
+func parseHexColor(s string) (float64, float64, float64, error) {
+	hex := strings.TrimPrefix(s, "#")
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if len(hex) != 6 || err != nil {
+		return 0, 0, 0, fmt.Errorf("invalid color %q (expected #rrggbb)", s)
+	}
+	return float64(v>>16&0xff) / 255, float64(v>>8&0xff) / 255, float64(v&0xff) / 255, nil
+}
@@ -0,0 +1,80 @@
+/*
+ * Annotate search hits: adds a Highlight, Underline, StrikeOut or Squiggly markup annotation over
+ * each match of a search pattern. Unlike drawing rectangles on the page (see
+ * text/pdf_highlight_text.go), the annotations cover each line of a match with QuadPoints, carry an
+ * author, subject and comment, and can be reviewed, edited or removed in PDF viewers.
+ *
+ * Run as: go run pdf_annotate_search_hits.go [-type highlight] [-color #ffff00] [-author name] [-subject text] [-comment text] [-regex] [-i] [-word] [-pages 1-5,9,12-] <pattern> <input.pdf> <output.pdf>
+ *
+ * Example: go run pdf_annotate_search_hits.go -type underline -author Legal -comment "Check term" -i "force majeure" contract.pdf reviewed.pdf
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/unidoc/unidoc-examples/annotations/annotops"
+	"github.com/unidoc/unidoc-examples/pages/pageops"
+	"github.com/unidoc/unidoc-examples/search-and-replace/searchops"
+	"github.com/unidoc/unipdf/v4/common/license"
+)
+
+func init() {
+	// Make sure to load your metered License API key prior to using the library.
+	// If you need a key, you can sign up and create a free one at https://cloud.unidoc.io
+	err := license.SetMeteredKey(os.Getenv(`UNIDOC_LICENSE_API_KEY`))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func main() {
+	var opts annotops.MarkupOptions
+	var searchOpts searchops.Options
+	markupType := flag.String("type", "highlight", "markup type: highlight, underline, strikeout or squiggly")
+	flag.StringVar(&opts.Color, "color", "", "#rrggbb color of the annotations (default: depends on the type)")
+	flag.StringVar(&opts.Author, "author", "", "author of the annotations")
+	flag.StringVar(&opts.Subject, "subject", "", "subject of the annotations")
+	flag.StringVar(&opts.Comment, "comment", "", "comment of the annotations")
+	flag.BoolVar(&searchOpts.Regex, "regex", false, "the pattern is a regular expression")
+	flag.BoolVar(&searchOpts.IgnoreCase, "i", false, "ignore case")
+	flag.BoolVar(&searchOpts.WholeWord, "word", false, "match whole words only")
+	pagesArg := flag.String("pages", "", "pages to annotate, e.g. 1-5,9,12- (default: all)")
+	flag.Parse()
+
+	if flag.NArg() < 3 {
+		fmt.Printf("Usage: go run pdf_annotate_search_hits.go [-type highlight] [-color #ffff00] [-author name] [-subject text] [-comment text] [-regex] [-i] [-word] [-pages 1-5,9,12-] pattern input.pdf output.pdf\n")
+		os.Exit(1)
+	}
+	pattern, inputPath, outputPath := flag.Arg(0), flag.Arg(1), flag.Arg(2)
+
+	var err error
+	opts.Type, err = annotops.ParseMarkupType(*markupType)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	pages, err := pageops.ParsePageRanges(*pagesArg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	m, err := searchops.Compile(pattern, searchOpts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	matches, err := annotops.AnnotateMatches(inputPath, outputPath, m, pages, opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	for _, match := range matches {
+		fmt.Printf("page %d: %q\n", match.Page, match.Text)
+	}
+	fmt.Printf("Added %d %s annotations, see output file: %s\n", len(matches), opts.Type, outputPath)
+}
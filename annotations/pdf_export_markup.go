@@ -0,0 +1,67 @@
+/*
+ * Exports the markup annotations (comments, highlights, underlines, shapes...) of PDF files with
+ * the text they cover to CSV or JSON, e.g. to collect the comments of reviewers in a spreadsheet.
+ *
+ * Run as: go run pdf_export_markup.go [-format csv|json] <output> <input.pdf> [input2.pdf] ...
+ *
+ * Example: go run pdf_export_markup.go -format csv comments.csv reviewed.pdf
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/unidoc/unidoc-examples/annotations/annotops"
+	"github.com/unidoc/unipdf/v4/common/license"
+)
+
+func init() {
+	// Make sure to load your metered License API key prior to using the library.
+	// If you need a key, you can sign up and create a free one at https://cloud.unidoc.io
+	err := license.SetMeteredKey(os.Getenv(`UNIDOC_LICENSE_API_KEY`))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func main() {
+	format := flag.String("format", "csv", "output format: csv or json")
+	flag.Parse()
+
+	if flag.NArg() < 2 || (*format != "csv" && *format != "json") {
+		fmt.Printf("Usage: go run pdf_export_markup.go [-format csv|json] output input.pdf [input2.pdf] ...\n")
+		os.Exit(1)
+	}
+	outputPath := flag.Arg(0)
+
+	annotations := []annotops.Annotation{}
+	for _, inputPath := range flag.Args()[1:] {
+		fileAnnotations, err := annotops.ExportMarkup(inputPath)
+		if err != nil {
+			fmt.Printf("Error: %s: %v\n", inputPath, err)
+			os.Exit(1)
+		}
+		annotations = append(annotations, fileAnnotations...)
+	}
+
+	f, err := os.Create(outputPath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	if *format == "json" {
+		err = annotops.WriteJSON(f, annotations)
+	} else {
+		err = annotops.WriteCSV(f, annotations)
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	fmt.Printf("Exported %d annotations to %s\n", len(annotations), outputPath)
+}
@@ -0,0 +1,241 @@
+/*
+ * Subcommand dispatching, flag parsing and exit codes for the unipdf command line tool.
+ */
+
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+
+	"github.com/unidoc/unidoc-examples/pages/pageops"
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// Exit codes returned by Main.
+const (
+	ExitOK    = 0 // The command completed successfully.
+	ExitError = 1 // The command failed while processing.
+	ExitUsage = 2 // The command line could not be parsed.
+)
+
+// Command describes a unipdf subcommand. A command either runs an action or groups
+// further subcommands, e.g. `unipdf extract text`.
+type Command struct {
+	// Name is the word selecting the command on the command line.
+	Name string
+	// Args is the synopsis of the positional arguments shown in the help output.
+	Args string
+	// Summary is a one line description of the command.
+	Summary string
+	// MinArgs and MaxArgs bound the number of positional arguments. A negative MaxArgs
+	// means no upper bound.
+	MinArgs, MaxArgs int
+	// Flags registers the command flags on `fs`.
+	Flags func(fs *flag.FlagSet)
+	// Run executes the command with the positional arguments left after flag parsing.
+	Run func(args []string) error
+	// Subcommands are the commands grouped under this one.
+	Subcommands []*Command
+}
+
+// usageError is returned for invalid command lines and results in ExitUsage.
+type usageError struct {
+	msg string
+}
+
+func (e usageError) Error() string {
+	return e.msg
+}
+
+// UsageErrorf returns an error reporting invalid command line arguments.
+func UsageErrorf(format string, args ...interface{}) error {
+	return usageError{msg: fmt.Sprintf(format, args...)}
+}
+
+// Main runs the command selected by `args` among `cmds` and returns the process exit code.
+// `prog` is the program name shown in usage messages.
+func Main(prog string, cmds []*Command, args []string) int {
+	root := &Command{Name: prog, Subcommands: cmds}
+	return dispatch(root, prog, args, os.Stdout, os.Stderr)
+}
+
+// dispatch parses `args` for `cmd` and either runs it or descends into the selected subcommand.
+func dispatch(cmd *Command, path string, args []string, stdout, stderr io.Writer) int {
+	if len(cmd.Subcommands) > 0 {
+		if len(args) == 0 {
+			printGroupUsage(stderr, cmd, path)
+			return ExitUsage
+		}
+
+		name := args[0]
+		switch name {
+		case "-h", "-help", "--help", "help":
+			if len(args) > 1 {
+				if sub := findCommand(cmd.Subcommands, args[1]); sub != nil {
+					return dispatch(sub, path+" "+sub.Name, []string{"-help"}, stdout, stdout)
+				}
+			}
+			printGroupUsage(stdout, cmd, path)
+			return ExitOK
+		}
+
+		sub := findCommand(cmd.Subcommands, name)
+		if sub == nil {
+			fmt.Fprintf(stderr, "%s: unknown command %q\n", path, name)
+			printGroupUsage(stderr, cmd, path)
+			return ExitUsage
+		}
+		return dispatch(sub, path+" "+sub.Name, args[1:], stdout, stderr)
+	}
+
+	fs := flag.NewFlagSet(path, flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() {
+		printCommandUsage(fs.Output(), cmd, path, fs)
+	}
+	if cmd.Flags != nil {
+		cmd.Flags(fs)
+	}
+
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return ExitOK
+		}
+		return ExitUsage
+	}
+
+	positional := fs.Args()
+	if len(positional) < cmd.MinArgs || (cmd.MaxArgs >= 0 && len(positional) > cmd.MaxArgs) {
+		fmt.Fprintf(stderr, "%s: wrong number of arguments\n", path)
+		printCommandUsage(stderr, cmd, path, fs)
+		return ExitUsage
+	}
+
+	err := cmd.Run(positional)
+	if err != nil {
+		fmt.Fprintf(stderr, "%s: %v\n", path, err)
+		var uerr usageError
+		if errors.As(err, &uerr) {
+			return ExitUsage
+		}
+		return ExitError
+	}
+
+	return ExitOK
+}
+
+// findCommand returns the command called `name` in `cmds` or nil if there is none.
+func findCommand(cmds []*Command, name string) *Command {
+	for _, cmd := range cmds {
+		if cmd.Name == name {
+			return cmd
+		}
+	}
+	return nil
+}
+
+// printGroupUsage prints the list of subcommands of `cmd`.
+func printGroupUsage(w io.Writer, cmd *Command, path string) {
+	fmt.Fprintf(w, "Usage: %s <command> [flags] [arguments]\n\n", path)
+	fmt.Fprintf(w, "Commands:\n")
+
+	subs := make([]*Command, len(cmd.Subcommands))
+	copy(subs, cmd.Subcommands)
+	sort.Slice(subs, func(i, j int) bool { return subs[i].Name < subs[j].Name })
+	for _, sub := range subs {
+		fmt.Fprintf(w, "  %-12s %s\n", sub.Name, sub.Summary)
+	}
+
+	fmt.Fprintf(w, "\nRun '%s help <command>' for details on a command.\n", path)
+}
+
+// printCommandUsage prints the synopsis and flags of `cmd`.
+func printCommandUsage(w io.Writer, cmd *Command, path string, fs *flag.FlagSet) {
+	fmt.Fprintf(w, "Usage: %s [flags] %s\n\n", path, cmd.Args)
+	if cmd.Summary != "" {
+		fmt.Fprintf(w, "%s\n\n", cmd.Summary)
+	}
+
+	hasFlags := false
+	fs.VisitAll(func(*flag.Flag) { hasFlags = true })
+	if hasFlags {
+		fmt.Fprintf(w, "Flags:\n")
+		fs.PrintDefaults()
+	}
+}
+
+// ParsePageList parses a comma separated list of page numbers and ranges, e.g. "1,3-5".
+// An empty string or "all" results in an empty list, which selects all pages. Ranges open to
+// the end of the document such as "12-" need the document, see ParsePages.
+func ParsePageList(s string) ([]int, error) {
+	ranges, err := pageops.ParsePageRanges(s)
+	if err != nil {
+		return nil, UsageErrorf("%v", err)
+	}
+	if ranges.Open() {
+		return nil, UsageErrorf("open page range in %q is not supported here", s)
+	}
+
+	last := 0
+	for _, r := range ranges {
+		last = max(last, r.To)
+	}
+	return ranges.Pages(last), nil
+}
+
+// ParsePages parses the page selection `s` of the document `inputPath`: page numbers and ranges
+// such as "1-5,9,12-", where "12-" runs to the last page. An empty string or "all" results in an
+// empty list, which selects all pages.
+func ParsePages(inputPath, s string) ([]int, error) {
+	ranges, err := pageops.ParsePageRanges(s)
+	if err != nil {
+		return nil, UsageErrorf("%v", err)
+	}
+	if !ranges.Open() {
+		return ParsePageList(s)
+	}
+
+	pdfReader, f, err := model.NewPdfReaderFromFile(inputPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	numPages, err := pdfReader.GetNumPages()
+	if err != nil {
+		return nil, err
+	}
+	return ranges.Pages(numPages), nil
+}
+
+// ParseInt parses the positional argument `s` called `name` as an integer.
+func ParseInt(name, s string) (int64, error) {
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return 0, UsageErrorf("invalid %s %q", name, s)
+	}
+	return v, nil
+}
+
+// writeOutput calls `write` with the file at `outputPath`, or stdout if `outputPath` is empty.
+func writeOutput(outputPath string, write func(w io.Writer) error) error {
+	if outputPath == "" {
+		return write(os.Stdout)
+	}
+
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
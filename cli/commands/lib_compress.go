@@ -0,0 +1,44 @@
+/*
+ * Optimization commands of the unipdf command line tool.
+ */
+
+package commands
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/unidoc/unidoc-examples/compress/compressops"
+)
+
+// Optimize returns the `optimize` command which compresses a document.
+func Optimize() *Command {
+	opts := compressops.DefaultOptions()
+	var quiet bool
+	return &Command{
+		Name:    "optimize",
+		Args:    "input.pdf output.pdf",
+		Summary: "Optimize (compress) a PDF file.",
+		MinArgs: 2,
+		MaxArgs: 2,
+		Flags: func(fs *flag.FlagSet) {
+			fs.IntVar(&opts.ImageQuality, "image-quality", opts.ImageQuality, "JPEG quality of recompressed images (1-100)")
+			fs.Float64Var(&opts.ImageUpperPPI, "image-ppi", opts.ImageUpperPPI, "downsample images above this resolution")
+			fs.BoolVar(&opts.SubsetFonts, "subset-fonts", opts.SubsetFonts, "subset embedded fonts to the glyphs used")
+			fs.BoolVar(&quiet, "q", false, "do not print optimization statistics")
+		},
+		Run: func(args []string) error {
+			stats, err := compressops.OptimizePdf(args[0], args[1], opts)
+			if err != nil {
+				return err
+			}
+			if !quiet {
+				fmt.Printf("Original size: %d bytes\n", stats.InputSize)
+				fmt.Printf("Optimized size: %d bytes\n", stats.OutputSize)
+				fmt.Printf("Compression ratio: %.2f%%\n", stats.Ratio())
+				fmt.Printf("Processing time: %.2f ms\n", float64(stats.Duration.Microseconds())/1000.0)
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,71 @@
+/*
+ * Diff command of the unipdf command line tool.
+ */
+
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/unidoc/unidoc-examples/diff/diffops"
+)
+
+// Diff returns the `diff` command which compares two documents page by page, as rendered images
+// and as text, and writes the report as JSON.
+func Diff() *Command {
+	var opts diffops.Options
+	var tolerance uint
+	var highlightPath, outputPath string
+	var failOnChange bool
+	return &Command{
+		Name:    "diff",
+		Args:    "old.pdf new.pdf",
+		Summary: "Compare two documents with per-page pixel diffs, similarity scores and word changes.",
+		MinArgs: 2,
+		MaxArgs: 2,
+		Flags: func(fs *flag.FlagSet) {
+			fs.Float64Var(&opts.DPI, "dpi", 100, "rendering resolution")
+			fs.UintVar(&tolerance, "tolerance", 16, "largest color component difference (0-255) of unchanged pixels")
+			fs.StringVar(&opts.ImageDir, "images", "", "directory of the diff images of the changed pages")
+			fs.BoolVar(&opts.SkipRaster, "no-raster", false, "skip the pixel comparison")
+			fs.BoolVar(&opts.SkipText, "no-text", false, "skip the text comparison")
+			fs.StringVar(&highlightPath, "highlight", "", "write a copy of new.pdf with the text changes highlighted")
+			fs.BoolVar(&failOnChange, "fail", false, "fail if the documents differ")
+			fs.StringVar(&outputPath, "o", "", "output file (default: stdout)")
+		},
+		Run: func(args []string) error {
+			if tolerance > 255 {
+				return UsageErrorf("invalid tolerance %d (expected 0-255)", tolerance)
+			}
+			if opts.SkipRaster && opts.SkipText {
+				return UsageErrorf("-no-raster and -no-text are exclusive")
+			}
+			opts.Tolerance = uint8(tolerance)
+
+			report, err := diffops.Compare(args[0], args[1], opts)
+			if err != nil {
+				return err
+			}
+			if highlightPath != "" {
+				if err := report.WriteHighlighted(highlightPath); err != nil {
+					return err
+				}
+			}
+			err = writeOutput(outputPath, func(w io.Writer) error {
+				return report.WriteJSON(w)
+			})
+			if err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "similarity %.2f%%, %d text changes\n", 100*report.Similarity, len(report.Changes))
+			if failOnChange && !report.Identical {
+				return fmt.Errorf("%s and %s differ", args[0], args[1])
+			}
+			return nil
+		},
+	}
+}
@@ -0,0 +1,324 @@
+/*
+ * Extraction commands of the unipdf command line tool.
+ */
+
+package commands
+
+import (
+	"context"
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"path/filepath"
+
+	"github.com/unidoc/unidoc-examples/concurrent-processing/batch"
+	"github.com/unidoc/unidoc-examples/extract/extractops"
+	"github.com/unidoc/unidoc-examples/extract/layout"
+	"github.com/unidoc/unidoc-examples/extract/markdown"
+	"github.com/unidoc/unidoc-examples/extract/tables"
+)
+
+// Extract returns the `extract` command group.
+func Extract() *Command {
+	return &Command{
+		Name:    "extract",
+		Summary: "Extract content from PDF files.",
+		Subcommands: []*Command{
+			extractText(),
+			extractJSON(),
+			extractLayout(),
+			extractTables(),
+			extractMarkdown(),
+			extractBatch(),
+		},
+	}
+}
+
+// extractText returns the `extract text` command which prints the text of a document.
+func extractText() *Command {
+	var pages, outputPath, repeatedMode string
+	var workers int
+	var readingOrder bool
+	return &Command{
+		Name:    "text",
+		Args:    "input.pdf",
+		Summary: "Extract the text of a PDF file. Pages are separated by form feeds.",
+		MinArgs: 1,
+		MaxArgs: 1,
+		Flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&pages, "pages", "", "pages to extract, e.g. 1-5,9,12- (default: all)")
+			fs.StringVar(&outputPath, "o", "", "output file (default: stdout)")
+			fs.IntVar(&workers, "workers", 1, "number of pages extracted concurrently, results are streamed in page order")
+			fs.BoolVar(&readingOrder, "layout", false, "read multi-column pages column by column, separating blocks with empty lines")
+			fs.StringVar(&repeatedMode, "repeated", "keep", "running headers, footers and page numbers repeated across pages: keep, strip or tag")
+		},
+		Run: func(args []string) error {
+			pageNums, err := ParsePages(args[0], pages)
+			if err != nil {
+				return err
+			}
+			repeated, err := extractops.ParseRepeated(repeatedMode)
+			if err != nil {
+				return UsageErrorf("%v", err)
+			}
+
+			if workers > 1 || readingOrder || repeated != extractops.RepeatedKeep {
+				opts := extractops.ParallelOptions{Workers: max(workers, 1), PageNums: pageNums, Layout: readingOrder, Repeated: repeated}
+				return writeOutput(outputPath, func(w io.Writer) error {
+					_, err := extractops.StreamText(context.Background(), args[0], w, opts)
+					return err
+				})
+			}
+
+			pageTexts, err := extractops.ExtractText(args[0], pageNums)
+			if err != nil {
+				return err
+			}
+
+			return writeOutput(outputPath, func(w io.Writer) error {
+				return extractops.WriteText(w, pageTexts)
+			})
+		},
+	}
+}
+
+// extractJSON returns the `extract json` command which writes the structured text of a document.
+func extractJSON() *Command {
+	var pages, outputPath, format string
+	var noMarks bool
+	return &Command{
+		Name:    "json",
+		Args:    "input.pdf",
+		Summary: "Extract text with page geometry, lines, words and marks as JSON or JSON Lines.",
+		MinArgs: 1,
+		MaxArgs: 1,
+		Flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&pages, "pages", "", "pages to extract, e.g. 1-5,9,12- (default: all)")
+			fs.StringVar(&outputPath, "o", "", "output file (default: stdout)")
+			fs.StringVar(&format, "format", extractops.FormatJSON, "output format: json (one document) or jsonl (one page per line)")
+			fs.BoolVar(&noMarks, "no-marks", false, "omit the per glyph marks")
+		},
+		Run: func(args []string) error {
+			pageNums, err := ParsePages(args[0], pages)
+			if err != nil {
+				return err
+			}
+			if format != extractops.FormatJSON && format != extractops.FormatJSONL {
+				return UsageErrorf("unsupported format %q", format)
+			}
+
+			return writeOutput(outputPath, func(w io.Writer) error {
+				return extractops.WriteStructured(w, args[0], pageNums, format, !noMarks)
+			})
+		},
+	}
+}
+
+// extractLayout returns the `extract layout` command which writes the layout of the pages of a
+// document as JSON.
+func extractLayout() *Command {
+	var pages, outputPath string
+	var tagRepeated bool
+	return &Command{
+		Name:    "layout",
+		Args:    "input.pdf",
+		Summary: "Write the blocks, columns, headers, footers and sidebars of each page in reading order as JSON.",
+		MinArgs: 1,
+		MaxArgs: 1,
+		Flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&pages, "pages", "", "pages to analyze, e.g. 1-5,9,12- (default: all)")
+			fs.StringVar(&outputPath, "o", "", "output file (default: stdout)")
+			fs.BoolVar(&tagRepeated, "repeated", false, "tag the lines repeated across the analyzed pages as header, footer or page_number blocks")
+		},
+		Run: func(args []string) error {
+			pageNums, err := ParsePages(args[0], pages)
+			if err != nil {
+				return err
+			}
+
+			pageLayouts, err := layout.AnalyzeFile(args[0], pageNums)
+			if err != nil {
+				return err
+			}
+			if tagRepeated {
+				repeated := layout.DetectRepeated(pageLayouts, layout.RepeatedOptions{})
+				for _, pl := range pageLayouts {
+					repeated.Tag(pl)
+				}
+			}
+
+			return writeOutput(outputPath, func(w io.Writer) error {
+				return layout.WriteJSON(w, args[0], pageLayouts)
+			})
+		},
+	}
+}
+
+// extractTables returns the `extract tables` command which writes the tables of a document as
+// JSON, XLSX or CSV.
+func extractTables() *Command {
+	var pages, outputPath, format string
+	var mergeAcrossPages bool
+	return &Command{
+		Name:    "tables",
+		Args:    "input.pdf",
+		Summary: "Extract tables with cell geometry, merged cells and header rows as JSON, XLSX or CSV.",
+		MinArgs: 1,
+		MaxArgs: 1,
+		Flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&pages, "pages", "", "pages to extract, e.g. 1-5,9,12- (default: all)")
+			fs.StringVar(&outputPath, "o", "", "output file (default: stdout, required for xlsx)")
+			fs.StringVar(&format, "format", "json", "output format: json, xlsx (one sheet per table) or csv (tables separated by blank lines)")
+			fs.BoolVar(&mergeAcrossPages, "merge-across-pages", false, "stitch tables continuing over page breaks")
+		},
+		Run: func(args []string) error {
+			pageNums, err := ParsePages(args[0], pages)
+			if err != nil {
+				return err
+			}
+			switch format {
+			case "json", "csv":
+			case "xlsx":
+				if outputPath == "" {
+					return UsageErrorf("-o is required for the xlsx format")
+				}
+			default:
+				return UsageErrorf("unsupported format %q", format)
+			}
+
+			docTables, err := tables.ExtractTables(args[0], pageNums, mergeAcrossPages)
+			if err != nil {
+				return err
+			}
+
+			switch format {
+			case "xlsx":
+				if len(docTables) == 0 {
+					return fmt.Errorf("no tables found in %s", args[0])
+				}
+				return tables.WriteXLSXFile(outputPath, docTables)
+			case "csv":
+				return writeOutput(outputPath, func(w io.Writer) error {
+					for i, t := range docTables {
+						if i > 0 {
+							fmt.Fprintln(w)
+						}
+						cw := csv.NewWriter(w)
+						if err := cw.WriteAll(t.Strings()); err != nil {
+							return err
+						}
+					}
+					return nil
+				})
+			}
+			return writeOutput(outputPath, func(w io.Writer) error {
+				return tables.WriteJSON(w, args[0], docTables)
+			})
+		},
+	}
+}
+
+// extractMarkdown returns the `extract markdown` command which converts a document to Markdown.
+func extractMarkdown() *Command {
+	var pages, outputPath string
+	var opts markdown.Options
+	return &Command{
+		Name:    "markdown",
+		Args:    "input.pdf",
+		Summary: "Convert a PDF file to Markdown with headings, lists, tables and paragraphs (pdf2md).",
+		MinArgs: 1,
+		MaxArgs: 1,
+		Flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&pages, "pages", "", "pages to convert, e.g. 1-5,9,12- (default: all)")
+			fs.StringVar(&outputPath, "o", "", "output file (default: stdout)")
+			fs.BoolVar(&opts.PageMarkers, "page-markers", false, "add a <!-- page N --> comment before each page")
+			fs.IntVar(&opts.MaxHeadingLevel, "max-heading-level", 3, "deepest heading level given to text larger than the body text")
+			fs.BoolVar(&opts.DisableDocumentTags, "no-tags", false, "detect lists from the text only, ignoring the structure tags")
+			fs.BoolVar(&opts.Layout, "layout", false, "read multi-column pages column by column and leave out running headers and footers")
+		},
+		Run: func(args []string) error {
+			pageNums, err := ParsePages(args[0], pages)
+			if err != nil {
+				return err
+			}
+			if opts.MaxHeadingLevel < 1 || opts.MaxHeadingLevel > 6 {
+				return UsageErrorf("-max-heading-level must be between 1 and 6")
+			}
+
+			return writeOutput(outputPath, func(w io.Writer) error {
+				return markdown.Convert(w, args[0], pageNums, opts)
+			})
+		},
+	}
+}
+
+// extractBatch returns the `extract batch` command which extracts the text of many documents
+// with a bounded pool of workers.
+func extractBatch() *Command {
+	var opts batch.Options
+	var summaryPath, repeatedMode string
+	return &Command{
+		Name:    "batch",
+		Args:    "output_dir input.pdf|input_dir...",
+		Summary: "Extract the text of many PDF files concurrently and write a JSON summary.",
+		MinArgs: 2,
+		MaxArgs: -1,
+		Flags: func(fs *flag.FlagSet) {
+			fs.IntVar(&opts.Workers, "workers", 0, "number of files processed concurrently (default: number of CPUs)")
+			fs.DurationVar(&opts.Timeout, "timeout", 0, "maximum processing time per file (0: no limit)")
+			fs.IntVar(&opts.Retries, "retries", 1, "number of retries after a transient failure")
+			fs.DurationVar(&opts.RetryDelay, "retry-delay", 0, "pause before retrying a file")
+			fs.StringVar(&summaryPath, "summary", "", "JSON summary file (default: output_dir/summary.json)")
+			fs.StringVar(&repeatedMode, "repeated", "keep", "running headers, footers and page numbers repeated across pages: keep, strip or tag")
+		},
+		Run: func(args []string) error {
+			outputDir := args[0]
+			repeated, err := extractops.ParseRepeated(repeatedMode)
+			if err != nil {
+				return UsageErrorf("%v", err)
+			}
+			if summaryPath == "" {
+				summaryPath = filepath.Join(outputDir, "summary.json")
+			}
+
+			files, err := batch.CollectFiles(args[1:], ".pdf")
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(outputDir, 0755); err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+
+			opts.OnResult = func(res batch.FileResult) {
+				if res.Error != "" {
+					fmt.Fprintf(os.Stderr, "FAIL %s: %s\n", res.File, res.Error)
+				}
+			}
+			task := func(ctx context.Context, path string) (int, error) {
+				outputPath := batch.OutputPath(args[1:], path, outputDir, ".txt")
+				if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+					return 0, err
+				}
+				return extractops.ExtractTextToFile(ctx, path, outputPath, repeated)
+			}
+
+			summary, runErr := batch.Run(ctx, files, task, opts)
+			if err := summary.WriteJSON(summaryPath); err != nil {
+				return err
+			}
+			if runErr != nil {
+				return runErr
+			}
+			if summary.Failed > 0 {
+				return fmt.Errorf("%d of %d files failed, see %s", summary.Failed, summary.Files, summaryPath)
+			}
+			return nil
+		},
+	}
+}
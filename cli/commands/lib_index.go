@@ -0,0 +1,116 @@
+/*
+ * Full-text index commands of the unipdf command line tool.
+ */
+
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+
+	"github.com/unidoc/unidoc-examples/concurrent-processing/batch"
+	"github.com/unidoc/unidoc-examples/search-and-replace/searchindex"
+)
+
+// Index returns the `index` command group.
+func Index() *Command {
+	return &Command{
+		Name:    "index",
+		Summary: "Build and query a full-text index over collections of PDF files.",
+		Subcommands: []*Command{
+			indexBuild(),
+			indexQuery(),
+		},
+	}
+}
+
+// indexBuild returns the `index build` command which creates or incrementally updates an index.
+func indexBuild() *Command {
+	var opts batch.Options
+	var indexDir string
+	return &Command{
+		Name:    "build",
+		Args:    "input.pdf|input_dir...",
+		Summary: "Index the PDF files, only extracting new and changed files of an existing index.",
+		MinArgs: 1,
+		MaxArgs: -1,
+		Flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&indexDir, "index", "index", "index directory")
+			fs.IntVar(&opts.Workers, "workers", 0, "number of files extracted concurrently (default: number of CPUs)")
+			fs.DurationVar(&opts.Timeout, "timeout", 0, "maximum extraction time per file (0: no limit)")
+		},
+		Run: func(args []string) error {
+			files, err := batch.CollectFiles(args, ".pdf")
+			if err != nil {
+				return err
+			}
+			ix, err := searchindex.Open(indexDir)
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+
+			opts.OnResult = func(res batch.FileResult) {
+				if res.Error != "" {
+					fmt.Fprintf(os.Stderr, "FAIL %s: %s\n", res.File, res.Error)
+				}
+			}
+			stats, runErr := ix.Update(ctx, files, opts)
+			if err := ix.Save(indexDir); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "%d files indexed: %d added, %d updated, %d unchanged, %d removed, %d failed\n",
+				len(ix.Docs), stats.Added, stats.Updated, stats.Unchanged, stats.Removed, stats.Failed)
+			if runErr != nil {
+				return runErr
+			}
+			if stats.Failed > 0 {
+				return fmt.Errorf("%d files failed", stats.Failed)
+			}
+			return nil
+		},
+	}
+}
+
+// indexQuery returns the `index query` command which lists the pages matching a query.
+func indexQuery() *Command {
+	var indexDir, outputPath string
+	var limit int
+	return &Command{
+		Name:    "query",
+		Args:    "query",
+		Summary: `List the pages containing all terms and "quoted phrases" of a query, ranked, as JSON.`,
+		MinArgs: 1,
+		MaxArgs: 1,
+		Flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&indexDir, "index", "index", "index directory")
+			fs.StringVar(&outputPath, "o", "", "output file (default: stdout)")
+			fs.IntVar(&limit, "limit", 20, "maximum number of pages listed (0: all)")
+		},
+		Run: func(args []string) error {
+			ix, err := searchindex.Open(indexDir)
+			if err != nil {
+				return err
+			}
+			if len(ix.Docs) == 0 {
+				return fmt.Errorf("no files indexed in %s, run `index build` first", indexDir)
+			}
+
+			results, err := ix.Search(args[0], limit)
+			if err != nil {
+				return UsageErrorf("%v", err)
+			}
+
+			return writeOutput(outputPath, func(w io.Writer) error {
+				return searchindex.WriteJSON(w, args[0], results)
+			})
+		},
+	}
+}
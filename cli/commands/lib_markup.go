@@ -0,0 +1,116 @@
+/*
+ * Markup annotation commands of the unipdf command line tool.
+ */
+
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/unidoc/unidoc-examples/annotations/annotops"
+	"github.com/unidoc/unidoc-examples/pages/pageops"
+	"github.com/unidoc/unidoc-examples/search-and-replace/searchops"
+)
+
+// Markup returns the `markup` command group.
+func Markup() *Command {
+	return &Command{
+		Name:    "markup",
+		Summary: "Annotate search matches with markup annotations and export markup annotations.",
+		Subcommands: []*Command{
+			markupAdd(),
+			markupExport(),
+		},
+	}
+}
+
+// markupAdd returns the `markup add` command which adds a markup annotation over each match of a
+// pattern.
+func markupAdd() *Command {
+	var opts annotops.MarkupOptions
+	var searchOpts searchops.Options
+	var markupType, pagesArg string
+	return &Command{
+		Name:    "add",
+		Args:    "pattern input.pdf output.pdf",
+		Summary: "Highlight, underline, strike out or squiggle the matches of a pattern with annotations.",
+		MinArgs: 3,
+		MaxArgs: 3,
+		Flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&markupType, "type", "highlight", "markup type: highlight, underline, strikeout or squiggly")
+			fs.StringVar(&opts.Color, "color", "", "#rrggbb color of the annotations (default: depends on the type)")
+			fs.Float64Var(&opts.Opacity, "opacity", 0, "opacity of the annotations between 0 and 1 (0: opaque)")
+			fs.StringVar(&opts.Author, "author", "", "author of the annotations")
+			fs.StringVar(&opts.Subject, "subject", "", "subject of the annotations")
+			fs.StringVar(&opts.Comment, "comment", "", "comment of the annotations")
+			fs.BoolVar(&searchOpts.Regex, "regex", false, "the pattern is a regular expression")
+			fs.BoolVar(&searchOpts.WholeWord, "word", false, "match whole words only")
+			fs.BoolVar(&searchOpts.IgnoreCase, "i", false, "ignore case")
+			fs.BoolVar(&searchOpts.IgnoreDiacritics, "a", false, "ignore accents and other diacritics")
+			fs.StringVar(&pagesArg, "pages", "", "pages to annotate, e.g. 1-5,9,12- (default: all)")
+		},
+		Run: func(args []string) error {
+			var err error
+			opts.Type, err = annotops.ParseMarkupType(markupType)
+			if err != nil {
+				return UsageErrorf("%v", err)
+			}
+			pages, err := pageops.ParsePageRanges(pagesArg)
+			if err != nil {
+				return UsageErrorf("%v", err)
+			}
+			m, err := searchops.Compile(args[0], searchOpts)
+			if err != nil {
+				return UsageErrorf("%v", err)
+			}
+
+			matches, err := annotops.AnnotateMatches(args[1], args[2], m, pages, opts)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "%d %s annotations added\n", len(matches), opts.Type)
+			return nil
+		},
+	}
+}
+
+// markupExport returns the `markup export` command which exports the markup annotations of PDF
+// files with the text they cover.
+func markupExport() *Command {
+	var format, outputPath string
+	return &Command{
+		Name:    "export",
+		Args:    "input.pdf...",
+		Summary: "Export the markup annotations with the text they cover to CSV or JSON.",
+		MinArgs: 1,
+		MaxArgs: -1,
+		Flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&format, "format", "csv", "output format: csv or json")
+			fs.StringVar(&outputPath, "o", "", "output file (default: stdout)")
+		},
+		Run: func(args []string) error {
+			if format != "csv" && format != "json" {
+				return UsageErrorf("invalid format %q (expected csv or json)", format)
+			}
+
+			annotations := []annotops.Annotation{}
+			for _, inputPath := range args {
+				fileAnnotations, err := annotops.ExportMarkup(inputPath)
+				if err != nil {
+					return fmt.Errorf("%s: %w", inputPath, err)
+				}
+				annotations = append(annotations, fileAnnotations...)
+			}
+
+			return writeOutput(outputPath, func(w io.Writer) error {
+				if format == "json" {
+					return annotops.WriteJSON(w, annotations)
+				}
+				return annotops.WriteCSV(w, annotations)
+			})
+		},
+	}
+}
@@ -0,0 +1,113 @@
+/*
+ * Page manipulation commands of the unipdf command line tool.
+ */
+
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"strconv"
+
+	"github.com/unidoc/unidoc-examples/pages/pageops"
+)
+
+// Merge returns the `merge` command which concatenates the pages of several PDF files.
+func Merge() *Command {
+	var forms bool
+	return &Command{
+		Name:    "merge",
+		Args:    "output.pdf input1.pdf input2.pdf ...",
+		Summary: "Merge the pages of PDF files into a single file.",
+		MinArgs: 3,
+		MaxArgs: -1,
+		Flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&forms, "forms", false, "merge form fields (AcroForms) as well")
+		},
+		Run: func(args []string) error {
+			return pageops.MergePdf(args[1:], args[0], forms)
+		},
+	}
+}
+
+// Split returns the `split` command which extracts a page range into a new file.
+func Split() *Command {
+	return &Command{
+		Name:    "split",
+		Args:    "input.pdf <page_from> <page_to> output.pdf",
+		Summary: "Extract a page range of a PDF file into a new file.",
+		MinArgs: 4,
+		MaxArgs: 4,
+		Run: func(args []string) error {
+			pageFrom, err := ParseInt("page_from", args[1])
+			if err != nil {
+				return err
+			}
+			pageTo, err := ParseInt("page_to", args[2])
+			if err != nil {
+				return err
+			}
+			return pageops.SplitPdf(args[0], args[3], int(pageFrom), int(pageTo))
+		},
+	}
+}
+
+// Burst returns the `burst` command which splits a file into single page documents.
+func Burst() *Command {
+	return &Command{
+		Name:    "burst",
+		Args:    "input.pdf output_dir",
+		Summary: "Split a PDF file into single page files, removing unused resources.",
+		MinArgs: 2,
+		MaxArgs: 2,
+		Run: func(args []string) error {
+			outputPaths, err := pageops.SplitPdfFile(args[0], args[1])
+			if err != nil {
+				return err
+			}
+			for _, outputPath := range outputPaths {
+				fmt.Println(outputPath)
+			}
+			return nil
+		},
+	}
+}
+
+// Crop returns the `crop` command which trims a percentage off all pages.
+func Crop() *Command {
+	return &Command{
+		Name:    "crop",
+		Args:    "input.pdf <percentage> output.pdf",
+		Summary: "Crop all pages by trimming off a percentage of the width and height.",
+		MinArgs: 3,
+		MaxArgs: 3,
+		Run: func(args []string) error {
+			percentage, err := strconv.ParseFloat(args[1], 64)
+			if err != nil || percentage < 0 || percentage > 100 {
+				return UsageErrorf("percentage should be in the range 0 - 100, got %q", args[1])
+			}
+			return pageops.CropPdf(args[0], args[2], percentage)
+		},
+	}
+}
+
+// Rotate returns the `rotate` command which rotates all pages.
+func Rotate() *Command {
+	return &Command{
+		Name:    "rotate",
+		Args:    "input.pdf <angle> output.pdf",
+		Summary: "Rotate all pages by a multiple of 90 degrees.",
+		MinArgs: 3,
+		MaxArgs: 3,
+		Run: func(args []string) error {
+			degrees, err := ParseInt("angle", args[1])
+			if err != nil {
+				return err
+			}
+			if degrees%90 != 0 {
+				return UsageErrorf("angle needs to be a multiple of 90, got %d", degrees)
+			}
+			return pageops.RotatePdf(args[0], degrees, args[2])
+		},
+	}
+}
@@ -0,0 +1,48 @@
+/*
+ * Pipeline command of the unipdf command line tool.
+ */
+
+package commands
+
+import (
+	"flag"
+
+	"github.com/unidoc/unidoc-examples/pages/pipeline"
+)
+
+// Pipeline returns the `pipeline` command which applies several operations in a single
+// load/write pass.
+func Pipeline() *Command {
+	var recipePath string
+	return &Command{
+		Name:    "pipeline",
+		Args:    "input.pdf output.pdf [step...]",
+		Summary: "Apply a chain of operations (rotate, crop, watermark, optimize...) in one pass.",
+		MinArgs: 2,
+		MaxArgs: -1,
+		Flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&recipePath, "recipe", "", "JSON or YAML recipe file listing the steps")
+		},
+		Run: func(args []string) error {
+			inputPath, outputPath, chain := args[0], args[1], args[2:]
+
+			var recipe *pipeline.Recipe
+			var err error
+			switch {
+			case recipePath != "" && len(chain) > 0:
+				return UsageErrorf("use either -recipe or command line steps, not both")
+			case recipePath != "":
+				recipe, err = pipeline.LoadRecipe(recipePath)
+			case len(chain) > 0:
+				recipe, err = pipeline.ParseChain(chain)
+			default:
+				return UsageErrorf("no steps given, use -recipe or list steps such as rotate=90 crop=10 watermark=DRAFT optimize")
+			}
+			if err != nil {
+				return UsageErrorf("%v", err)
+			}
+
+			return pipeline.Run(inputPath, outputPath, recipe)
+		},
+	}
+}
@@ -0,0 +1,62 @@
+/*
+ * Redaction command of the unipdf command line tool.
+ */
+
+package commands
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/unidoc/unidoc-examples/redact/redactops"
+)
+
+// Redact returns the `redact` command which redacts the PII matched by a rules file.
+func Redact() *Command {
+	var rulesPath, auditPath string
+	return &Command{
+		Name:    "redact",
+		Args:    "input.pdf output.pdf",
+		Summary: "Redact the PII matched by the detectors of a rules file and write a JSON audit report.",
+		MinArgs: 2,
+		MaxArgs: 2,
+		Flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&rulesPath, "rules", "", "JSON or YAML rules file (see redact/redaction_rules.yaml)")
+			fs.StringVar(&auditPath, "audit", "", "JSON audit report (default: output.audit.json)")
+		},
+		Run: func(args []string) error {
+			inputPath, outputPath := args[0], args[1]
+			if rulesPath == "" {
+				return UsageErrorf("-rules is required")
+			}
+			if auditPath == "" {
+				auditPath = strings.TrimSuffix(outputPath, ".pdf") + ".audit.json"
+			}
+
+			rules, err := redactops.LoadRules(rulesPath)
+			if err != nil {
+				return UsageErrorf("%v", err)
+			}
+
+			report, err := redactops.RedactFile(inputPath, outputPath, rules)
+			if errors.Is(err, redactops.ErrNotRedacted) {
+				// Keep the report listing the texts left in the output.
+				if werr := report.WriteJSON(auditPath); werr == nil {
+					fmt.Fprintf(os.Stderr, "audit report: %s\n", auditPath)
+				}
+			}
+			if err != nil {
+				return err
+			}
+			if err := report.WriteJSON(auditPath); err != nil {
+				return err
+			}
+
+			fmt.Fprintf(os.Stderr, "%d redactions, audit report: %s\n", len(report.Redactions), auditPath)
+			return nil
+		},
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * Batch find and replace command of the unipdf command line tool.
+ */
+
+package commands
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"github.com/unidoc/unidoc-examples/concurrent-processing/batch"
+	"github.com/unidoc/unidoc-examples/search-and-replace/replaceops"
+)
+
+// Replace returns the `replace` command which applies a mapping file of search to replacement
+// pairs to many files.
+func Replace() *Command {
+	var opts batch.Options
+	var mappingPath, reportPath string
+	var dryRun bool
+	return &Command{
+		Name:    "replace",
+		Args:    "output_dir input.pdf|input_dir...",
+		Summary: "Replace text in many PDF files using a CSV or JSON mapping file, reporting missing glyphs.",
+		MinArgs: 2,
+		MaxArgs: -1,
+		Flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&mappingPath, "map", "", "CSV or JSON file of search to replacement mappings (required)")
+			fs.BoolVar(&dryRun, "dry-run", false, "list the changes with their page and bounding box without writing any file")
+			fs.StringVar(&reportPath, "report", "", "JSON report of the changes (default: stdout)")
+			fs.IntVar(&opts.Workers, "workers", 0, "number of files processed concurrently (default: number of CPUs)")
+			fs.DurationVar(&opts.Timeout, "timeout", 0, "maximum processing time per file (0: no limit)")
+		},
+		Run: func(args []string) error {
+			if mappingPath == "" {
+				return UsageErrorf("missing -map")
+			}
+			mappings, err := replaceops.LoadMappings(mappingPath)
+			if err != nil {
+				return err
+			}
+
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			defer stop()
+
+			opts.OnResult = func(res batch.FileResult) {
+				if res.Error != "" {
+					fmt.Fprintf(os.Stderr, "FAIL %s: %s\n", res.File, res.Error)
+				}
+			}
+			report, runErr := replaceops.ReplaceFiles(ctx, args[1:], args[0], mappings, dryRun, opts)
+			if report == nil {
+				return runErr
+			}
+			if err := writeOutput(reportPath, report.WriteJSON); err != nil {
+				return err
+			}
+
+			for font, glyphs := range report.MissingGlyphs() {
+				fmt.Fprintf(os.Stderr, "font %q is missing glyphs %q\n", font, strings.Join(glyphs, ""))
+			}
+			verb := "applied"
+			if dryRun {
+				verb = "to apply"
+			}
+			fmt.Fprintf(os.Stderr, "%d files: %d changes %s, %d skipped\n",
+				len(report.Files), report.Applied, verb, report.Skipped)
+			if runErr != nil {
+				return runErr
+			}
+			if report.Summary.Failed > 0 {
+				return fmt.Errorf("%d of %d files failed", report.Summary.Failed, report.Summary.Files)
+			}
+			return nil
+		},
+	}
+}
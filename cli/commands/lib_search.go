@@ -0,0 +1,59 @@
+/*
+ * Search command of the unipdf command line tool.
+ */
+
+package commands
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/unidoc/unidoc-examples/pages/pageops"
+	"github.com/unidoc/unidoc-examples/search-and-replace/searchops"
+)
+
+// Search returns the `search` command which lists the matches of a pattern with their snippets and
+// bounding boxes as JSON.
+func Search() *Command {
+	var opts searchops.Options
+	var pagesArg, outputPath string
+	return &Command{
+		Name:    "search",
+		Args:    "pattern input.pdf",
+		Summary: "Search text, optionally as a regular expression, whole words, ignoring case or diacritics.",
+		MinArgs: 2,
+		MaxArgs: 2,
+		Flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&opts.Regex, "regex", false, "the pattern is a regular expression")
+			fs.BoolVar(&opts.WholeWord, "word", false, "match whole words only")
+			fs.BoolVar(&opts.IgnoreCase, "i", false, "ignore case")
+			fs.BoolVar(&opts.IgnoreDiacritics, "a", false, "ignore accents and other diacritics")
+			fs.IntVar(&opts.Context, "context", 40, "number of characters of the snippets around the matches")
+			fs.StringVar(&pagesArg, "pages", "", "pages to search, e.g. 1-5,9,12- (default: all)")
+			fs.StringVar(&outputPath, "o", "", "output file (default: stdout)")
+		},
+		Run: func(args []string) error {
+			pattern, inputPath := args[0], args[1]
+			pages, err := pageops.ParsePageRanges(pagesArg)
+			if err != nil {
+				return UsageErrorf("%v", err)
+			}
+			m, err := searchops.Compile(pattern, opts)
+			if err != nil {
+				return UsageErrorf("%v", err)
+			}
+
+			matches, err := searchops.SearchFile(inputPath, m, pages)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "%d matches\n", len(matches))
+
+			return writeOutput(outputPath, func(w io.Writer) error {
+				return searchops.WriteJSON(w, inputPath, pattern, opts, matches)
+			})
+		},
+	}
+}
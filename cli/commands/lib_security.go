@@ -0,0 +1,51 @@
+/*
+ * Document security commands of the unipdf command line tool.
+ */
+
+package commands
+
+import (
+	"flag"
+
+	"github.com/unidoc/unidoc-examples/security/securityops"
+)
+
+// Protect returns the `protect` command which encrypts a document with passwords.
+func Protect() *Command {
+	var userPassword, ownerPassword string
+	return &Command{
+		Name:    "protect",
+		Args:    "input.pdf output.pdf",
+		Summary: "Protect a PDF file with a user and owner password.",
+		MinArgs: 2,
+		MaxArgs: 2,
+		Flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&userPassword, "user-password", "", "password required to open the document (empty: anyone can open it)")
+			fs.StringVar(&ownerPassword, "owner-password", "", "password granting full access to the document")
+		},
+		Run: func(args []string) error {
+			if ownerPassword == "" {
+				return UsageErrorf("-owner-password is required")
+			}
+			return securityops.ProtectPdf(args[0], args[1], userPassword, ownerPassword, securityops.DefaultPermissions)
+		},
+	}
+}
+
+// Unlock returns the `unlock` command which decrypts a protected document.
+func Unlock() *Command {
+	var password string
+	return &Command{
+		Name:    "unlock",
+		Args:    "input.pdf output.pdf",
+		Summary: "Remove the password protection of a PDF file.",
+		MinArgs: 2,
+		MaxArgs: 2,
+		Flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&password, "password", "", "user or owner password of the document")
+		},
+		Run: func(args []string) error {
+			return securityops.UnlockPdf(args[0], args[1], password)
+		},
+	}
+}
@@ -0,0 +1,51 @@
+/*
+ * unipdf: a single command line tool bundling the most common document operations
+ * shown in the examples (merge, split, crop, rotate, protect, optimize, extract...).
+ *
+ * Run as: go run unipdf.go <command> [flags] [arguments]
+ * Run `go run unipdf.go help` for the list of commands and
+ * `go run unipdf.go help <command>` for the usage of a command.
+ *
+ * Exit codes: 0 on success, 1 if the command failed and 2 on invalid usage.
+ */
+
+package main
+
+import (
+	"os"
+
+	"github.com/unidoc/unidoc-examples/cli/commands"
+	"github.com/unidoc/unipdf/v4/common/license"
+)
+
+func init() {
+	// Make sure to load your metered License API key prior to using the library.
+	// If you need a key, you can sign up and create a free one at https://cloud.unidoc.io
+	err := license.SetMeteredKey(os.Getenv(`UNIDOC_LICENSE_API_KEY`))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func main() {
+	cmds := []*commands.Command{
+		commands.Merge(),
+		commands.Split(),
+		commands.Burst(),
+		commands.Crop(),
+		commands.Rotate(),
+		commands.Protect(),
+		commands.Unlock(),
+		commands.Redact(),
+		commands.Optimize(),
+		commands.Pipeline(),
+		commands.Extract(),
+		commands.Search(),
+		commands.Replace(),
+		commands.Markup(),
+		commands.Index(),
+		commands.Diff(),
+	}
+
+	os.Exit(commands.Main("unipdf", cmds, os.Args[1:]))
+}
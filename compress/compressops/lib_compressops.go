@@ -0,0 +1,82 @@
+/*
+ * Reusable optimization (compression) helpers shared by the compress examples and the unipdf command line tool.
+ */
+
+package compressops
+
+import (
+	"os"
+	"time"
+
+	"github.com/unidoc/unipdf/v4/model"
+	"github.com/unidoc/unipdf/v4/model/optimize"
+)
+
+// DefaultOptions returns the typical optimization options used in pdf_optimize.go.
+func DefaultOptions() optimize.Options {
+	return optimize.Options{
+		CombineDuplicateDirectObjects:   true,
+		CombineIdenticalIndirectObjects: true,
+		CombineDuplicateStreams:         true,
+		CompressStreams:                 true,
+		UseObjectStreams:                true,
+		ImageQuality:                    80,
+		ImageUpperPPI:                   100,
+		CleanUnusedResources:            true,
+	}
+}
+
+// Stats holds basic optimization statistics.
+type Stats struct {
+	InputSize  int64
+	OutputSize int64
+	Duration   time.Duration
+}
+
+// Ratio returns the compression ratio in percent.
+func (s Stats) Ratio() float64 {
+	if s.InputSize == 0 {
+		return 0
+	}
+	return 100.0 - (float64(s.OutputSize) / float64(s.InputSize) * 100.0)
+}
+
+// OptimizePdf writes an optimized copy of `inputPath` to `outputPath` using `opts`.
+func OptimizePdf(inputPath string, outputPath string, opts optimize.Options) (Stats, error) {
+	var stats Stats
+	start := time.Now()
+
+	inputFileInfo, err := os.Stat(inputPath)
+	if err != nil {
+		return stats, err
+	}
+	stats.InputSize = inputFileInfo.Size()
+
+	reader, f, err := model.NewPdfReaderFromFile(inputPath, nil)
+	if err != nil {
+		return stats, err
+	}
+	defer f.Close()
+
+	// Generate a PDFWriter from PDFReader.
+	pdfWriter, err := reader.ToWriter(nil)
+	if err != nil {
+		return stats, err
+	}
+
+	// Set optimizer.
+	pdfWriter.SetOptimizer(optimize.New(opts))
+
+	if err := pdfWriter.WriteToFile(outputPath); err != nil {
+		return stats, err
+	}
+
+	outputFileInfo, err := os.Stat(outputPath)
+	if err != nil {
+		return stats, err
+	}
+	stats.OutputSize = outputFileInfo.Size()
+	stats.Duration = time.Since(start)
+
+	return stats, nil
+}
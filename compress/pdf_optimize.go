@@ -12,6 +12,7 @@ import (
 	"os"
 	"time"
 
+	"github.com/unidoc/unidoc-examples/compress/compressops"
 	"github.com/unidoc/unipdf/v4/common/license"
 	"github.com/unidoc/unipdf/v4/model"
 	"github.com/unidoc/unipdf/v4/model/optimize"
@@ -63,16 +64,7 @@ func main() {
 	}
 
 	// Set optimizer.
-	pdfWriter.SetOptimizer(optimize.New(optimize.Options{
-		CombineDuplicateDirectObjects:   true,
-		CombineIdenticalIndirectObjects: true,
-		CombineDuplicateStreams:         true,
-		CompressStreams:                 true,
-		UseObjectStreams:                true,
-		ImageQuality:                    80,
-		ImageUpperPPI:                   100,
-		CleanUnusedResources:            true,
-	}))
+	pdfWriter.SetOptimizer(optimize.New(compressops.DefaultOptions()))
 
 	// Create output file.
 	err = pdfWriter.WriteToFile(outputPath)
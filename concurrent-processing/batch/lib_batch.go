@@ -0,0 +1,383 @@
+/*
+ * Bounded worker pool for processing large collections of PDF files.
+ *
+ * Each file is processed by a Task on one of a fixed number of workers. Failures are recorded
+ * per file instead of aborting the batch, transient failures are retried, each file can be
+ * given a timeout and the whole batch can be cancelled through a context. The outcome of the
+ * batch is summarized in a Summary which can be saved as JSON.
+ */
+
+package batch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Task processes the file at `path` and returns the number of pages processed.
+// Long running tasks should return early when `ctx` is done.
+type Task func(ctx context.Context, path string) (pages int, err error)
+
+// Options configures a batch run.
+type Options struct {
+	// Workers is the number of files processed concurrently. Defaults to the number of CPUs.
+	Workers int
+	// Timeout limits the processing time of a single file attempt. Zero means no limit.
+	Timeout time.Duration
+	// MaxAbandoned is the maximum number of timed out attempts still running in the background.
+	// While it is reached, a worker waits up to Timeout for one of them to return before failing
+	// its next file with ErrAbandoned, so that files on which the library hangs cannot exhaust
+	// the memory. Defaults to Workers.
+	MaxAbandoned int
+	// Retries is the number of times a file is retried after a transient failure. Timed out
+	// attempts are not retried, as they may still be running.
+	Retries int
+	// RetryDelay is the pause before retrying a file.
+	RetryDelay time.Duration
+	// IsTransient reports whether a failure is worth retrying. Defaults to IsTransient.
+	IsTransient func(err error) bool
+	// OnResult, if set, is called after each file has been processed. Calls are serialized.
+	OnResult func(res FileResult)
+}
+
+// FileResult is the outcome of processing a single file.
+type FileResult struct {
+	File       string  `json:"file"`
+	Pages      int     `json:"pages"`
+	DurationMs float64 `json:"duration_ms"`
+	Attempts   int     `json:"attempts"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// Summary is the machine-readable outcome of a batch run.
+type Summary struct {
+	Files      int          `json:"files"`
+	Succeeded  int          `json:"succeeded"`
+	Failed     int          `json:"failed"`
+	Pages      int          `json:"pages"`
+	Workers    int          `json:"workers"`
+	DurationMs float64      `json:"duration_ms"`
+	Results    []FileResult `json:"results"`
+	// Abandoned is the number of timed out or cancelled attempts still running when Run returned.
+	Abandoned int `json:"abandoned"`
+}
+
+// ErrTimeout is reported for file attempts exceeding Options.Timeout.
+var ErrTimeout = errors.New("timed out")
+
+// ErrAbandoned is reported for the files not processed because Options.MaxAbandoned timed out
+// attempts were still running.
+var ErrAbandoned = errors.New("too many timed out files still running")
+
+// Run processes `paths` with `task` using a bounded pool of workers and returns a summary in
+// the order of `paths`. Failures of individual files are recorded in the summary. The returned
+// error is only non-nil if `ctx` was cancelled, in which case files that were not processed are
+// reported as failed.
+//
+// Timed out attempts are abandoned rather than interrupted: the library calls do not take a
+// context, so the task keeps running in the background until it returns. They are reported as
+// failed and not retried, so that a retry never runs alongside the abandoned attempt. Tasks
+// writing output should check `ctx` before committing it, e.g. by renaming a temporary file. At
+// most Options.MaxAbandoned abandoned attempts run besides the workers, see ErrAbandoned.
+func Run(ctx context.Context, paths []string, task Task, opts Options) (*Summary, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(paths) && len(paths) > 0 {
+		workers = len(paths)
+	}
+	isTransient := opts.IsTransient
+	if isTransient == nil {
+		isTransient = IsTransient
+	}
+	maxAbandoned := opts.MaxAbandoned
+	if maxAbandoned <= 0 {
+		maxAbandoned = workers
+	}
+	abandoned := newAbandonedAttempts()
+
+	start := time.Now()
+	results := make([]FileResult, len(paths))
+	jobs := make(chan int)
+
+	var mu sync.Mutex
+	report := func(i int, res FileResult) {
+		mu.Lock()
+		defer mu.Unlock()
+		results[i] = res
+		if opts.OnResult != nil {
+			opts.OnResult(res)
+		}
+	}
+
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if !abandoned.wait(ctx, maxAbandoned, opts.Timeout) && ctx.Err() == nil {
+					report(i, FileResult{File: paths[i], Error: ErrAbandoned.Error()})
+					continue
+				}
+				report(i, processFile(ctx, paths[i], task, opts, isTransient, abandoned))
+			}
+		}()
+	}
+
+	// Feed the workers until all files are dispatched or the batch is cancelled.
+	dispatched := 0
+feed:
+	for ; dispatched < len(paths); dispatched++ {
+		select {
+		case jobs <- dispatched:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i := dispatched; i < len(paths); i++ {
+		results[i] = FileResult{File: paths[i], Error: ctx.Err().Error()}
+	}
+
+	summary := &Summary{
+		Files:      len(paths),
+		Workers:    workers,
+		Abandoned:  abandoned.count(),
+		DurationMs: durationMs(time.Since(start)),
+		Results:    results,
+	}
+	for _, res := range results {
+		if res.Error == "" {
+			summary.Succeeded++
+		} else {
+			summary.Failed++
+		}
+		summary.Pages += res.Pages
+	}
+
+	return summary, ctx.Err()
+}
+
+// processFile runs `task` on `path`, retrying transient failures. The abandoned attempts are
+// counted in `abandoned` until they return.
+func processFile(ctx context.Context, path string, task Task, opts Options, isTransient func(error) bool, abandoned *abandonedAttempts) FileResult {
+	res := FileResult{File: path}
+	start := time.Now()
+
+	var err error
+	for attempt := 0; attempt <= opts.Retries; attempt++ {
+		if attempt > 0 && opts.RetryDelay > 0 {
+			select {
+			case <-time.After(opts.RetryDelay):
+			case <-ctx.Done():
+			}
+		}
+		if ctx.Err() != nil {
+			err = ctx.Err()
+			break
+		}
+
+		res.Attempts++
+		res.Pages, err = runAttempt(ctx, path, task, opts.Timeout, abandoned)
+		if err == nil || errors.Is(err, ErrTimeout) || !isTransient(err) {
+			break
+		}
+	}
+
+	res.DurationMs = durationMs(time.Since(start))
+	if err != nil {
+		res.Error = err.Error()
+	}
+	return res
+}
+
+// runAttempt runs a single attempt of `task`, abandoning it after `timeout` or when `ctx` is
+// done. An abandoned attempt is counted in `abandoned` until it returns.
+// Panics in the task are reported as errors so that a single file cannot bring down the batch.
+func runAttempt(ctx context.Context, path string, task Task, timeout time.Duration, abandoned *abandonedAttempts) (int, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	type outcome struct {
+		pages int
+		err   error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				done <- outcome{err: fmt.Errorf("panic: %v", r)}
+			}
+		}()
+		pages, err := task(ctx, path)
+		done <- outcome{pages: pages, err: err}
+	}()
+
+	select {
+	case out := <-done:
+		return out.pages, out.err
+	case <-ctx.Done():
+		abandoned.add()
+		go func() {
+			<-done
+			abandoned.remove()
+		}()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return 0, fmt.Errorf("%w after %v", ErrTimeout, timeout)
+		}
+		return 0, ctx.Err()
+	}
+}
+
+// abandonedAttempts counts the attempts abandoned by runAttempt that have not returned yet.
+type abandonedAttempts struct {
+	mu       sync.Mutex
+	n        int
+	returned chan struct{} // Closed and replaced when an attempt returns.
+}
+
+func newAbandonedAttempts() *abandonedAttempts {
+	return &abandonedAttempts{returned: make(chan struct{})}
+}
+
+func (a *abandonedAttempts) add() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.n++
+}
+
+func (a *abandonedAttempts) remove() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.n--
+	close(a.returned)
+	a.returned = make(chan struct{})
+}
+
+func (a *abandonedAttempts) count() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.n
+}
+
+// wait waits until fewer than `max` attempts are abandoned, for at most `timeout` if not zero.
+// It returns false if they are still `max` or more when the wait ends or `ctx` is done.
+func (a *abandonedAttempts) wait(ctx context.Context, max int, timeout time.Duration) bool {
+	var expired <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		expired = timer.C
+	}
+	for {
+		a.mu.Lock()
+		n, returned := a.n, a.returned
+		a.mu.Unlock()
+		if n < max {
+			return true
+		}
+		select {
+		case <-returned:
+		case <-expired:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
+
+// IsTransient reports whether `err` is a failure that may succeed when retried, such as
+// running out of file descriptors.
+func IsTransient(err error) bool {
+	switch {
+	case errors.Is(err, syscall.EMFILE),
+		errors.Is(err, syscall.ENFILE),
+		errors.Is(err, syscall.EAGAIN),
+		errors.Is(err, syscall.EINTR):
+		return true
+	}
+	return false
+}
+
+// CollectFiles expands `paths` into the list of files to process. Directories are walked
+// recursively for files with extension `ext` (case insensitive), other paths are kept as is.
+func CollectFiles(paths []string, ext string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+
+		var found []string
+		err = filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if !d.IsDir() && strings.EqualFold(filepath.Ext(p), ext) {
+				found = append(found, p)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.Strings(found)
+		files = append(files, found...)
+	}
+
+	return files, nil
+}
+
+// OutputPath returns the path of the output file with extension `ext` of `path`, a file
+// collected by CollectFiles from `paths`, in `outputDir`. Files found in a directory of `paths`
+// keep their path relative to that directory, so that files with the same name in different
+// subdirectories do not overwrite each other's output. Other files are placed in `outputDir`.
+func OutputPath(paths []string, path string, outputDir string, ext string) string {
+	name := filepath.Base(path)
+	for _, root := range paths {
+		rel, err := filepath.Rel(root, path)
+		if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		name = rel
+		break
+	}
+	return filepath.Join(outputDir, strings.TrimSuffix(name, filepath.Ext(name))+ext)
+}
+
+// WriteJSON saves the summary as indented JSON to `path`.
+func (s *Summary) WriteJSON(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// durationMs returns `d` in milliseconds.
+func durationMs(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000.0
+}
@@ -1,23 +1,29 @@
 /*
  * This example demonstrates how to extract content form multiple documents concurrently
- * with each document extraction running in its own go routine.
+ * using a bounded pool of workers, so that large collections of documents can be processed
+ * without exhausting memory. Failures are reported per file instead of being ignored and
+ * a JSON summary (file, pages, duration, error) is written to the output directory.
  *
- * Run as: go run concurrent_extraction.go <input1.pdf> <input2.pdf> <input3.pdf>... <output_dir>
+ * Input directories are walked recursively for PDF files. With -strip-repeated the running headers,
+ * footers and page numbers repeated across the pages of a document are left out of its text.
+ *
+ * Run as: go run concurrent_extraction.go [-workers N] [-timeout 1m] [-retries 1] [-strip-repeated] <input1.pdf> <input2.pdf> <input_dir>... <output_dir>
  */
 
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"io/fs"
 	"os"
+	"os/signal"
 	"path/filepath"
-	"strings"
 	"time"
 
+	"github.com/unidoc/unidoc-examples/concurrent-processing/batch"
+	"github.com/unidoc/unidoc-examples/extract/extractops"
 	"github.com/unidoc/unipdf/v4/common/license"
-	"github.com/unidoc/unipdf/v4/extractor"
-	"github.com/unidoc/unipdf/v4/model"
 )
 
 func init() {
@@ -30,112 +36,80 @@ func init() {
 }
 
 func main() {
-	if len(os.Args) < 3 {
-		fmt.Printf("Usage: go run concurrent_extraction.go input1.pdf input2.pdf input3.pdf... output_dir\n")
+	var opts batch.Options
+	var stripRepeated bool
+	flag.IntVar(&opts.Workers, "workers", 0, "number of documents processed concurrently (default: number of CPUs)")
+	flag.DurationVar(&opts.Timeout, "timeout", 0, "maximum processing time per document (0: no limit)")
+	flag.IntVar(&opts.Retries, "retries", 1, "number of retries after a transient failure")
+	flag.BoolVar(&stripRepeated, "strip-repeated", false, "leave out the headers, footers and page numbers repeated across pages")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) < 2 {
+		fmt.Printf("Usage: go run concurrent_extraction.go [-workers N] [-timeout 1m] [-retries 1] [-strip-repeated] input1.pdf input2.pdf input_dir... output_dir\n")
 		os.Exit(1)
 	}
-	inputDocuments := []string{}
-	args := os.Args
-	var outputDir string
-	for i := 1; i < len(args); i++ {
-		if i == len(args)-1 {
-			outputDir = args[i]
-		} else {
-			inputDocuments = append(inputDocuments, args[i])
-		}
-	}
-
-	start := time.Now()
-	runConcurrent(inputDocuments, outputDir)
-	duration := time.Since(start)
-	fmt.Println("time taken for concurrent extraction", duration)
-}
-
-// runConcurrent takes the list of input documents and destination output directory and runs the extraction concurrently.
-func runConcurrent(documents []string, outputDir string) {
-	res := make(chan map[string]string, len(documents))
+	outputDir := args[len(args)-1]
 
-	err := concurrentExtraction(documents, res)
+	inputs := args[:len(args)-1]
+	inputDocuments, err := batch.CollectFiles(inputs, ".pdf")
 	if err != nil {
-		fmt.Printf("Error. extraction failed %v.\n", err)
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
 	}
-	outputPath := outputDir
-	if _, err := os.Stat(outputPath); err != nil {
-		if os.IsNotExist(err) {
-			err := os.Mkdir(outputPath, fs.ModePerm)
-			if err != nil {
-				fmt.Printf("Error. failed to create directory %s\n", outputPath)
-			}
-		}
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		fmt.Printf("Error: failed to create directory %s: %v\n", outputDir, err)
+		os.Exit(1)
 	}
 
-	for i := 0; i < len(documents); i++ {
-		result := <-res
-		for path, content := range result {
-			basename := filepath.Base(path)
-			fileName := strings.TrimSuffix(basename, filepath.Ext(basename)) + ".txt"
-			filePath := filepath.Join(outputPath, fileName)
-			file, err := os.Create(filePath)
-			if err != nil {
-				fmt.Printf("Error. failed to create file. %v\n", err)
-			}
-			_, err = file.WriteString(content)
-			if err != nil {
-				fmt.Printf("Error. failed to write content. %v\n", err)
-			}
+	// Stop dispatching new documents on Ctrl+C.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	opts.OnResult = func(res batch.FileResult) {
+		if res.Error != "" {
+			fmt.Printf("FAIL %s: %s\n", res.File, res.Error)
 		}
 	}
-}
 
-// concurrentExtraction launches a go routine for each document in `documents` and writes the result of extraction to
-// the channel `res`.
-func concurrentExtraction(documents []string, res chan map[string]string) error {
-	for _, docPath := range documents {
-		filePath := docPath
-		go func(path string, res chan map[string]string) {
-			result, err := extractSingleDoc(path)
-			if err != nil {
-				fmt.Printf("Error. Failed to extract file %v. %v\n", filePath, err)
-			}
-			temp := map[string]string{
-				filePath: result,
-			}
-			res <- temp
-		}(filePath, res)
+	repeated := extractops.RepeatedKeep
+	if stripRepeated {
+		repeated = extractops.RepeatedStrip
 	}
 
-	return nil
-}
-
-// extractSingleDoc takes a single file specified by the `filePath` and returns the extracted text.
-func extractSingleDoc(filePath string) (string, error) {
-	pdfReader, _, err := model.NewPdfReaderFromFile(filePath, nil)
+	start := time.Now()
+	summary, err := batch.Run(ctx, inputDocuments, extractTask(inputs, outputDir, repeated), opts)
 	if err != nil {
-		return "", fmt.Errorf("failed to create pdf reader: %w", err)
+		fmt.Printf("Error: extraction interrupted: %v\n", err)
 	}
-	pages, err := pdfReader.GetNumPages()
-	if err != nil {
-		return "", err
+	duration := time.Since(start)
+
+	summaryPath := filepath.Join(outputDir, "summary.json")
+	if err := summary.WriteJSON(summaryPath); err != nil {
+		fmt.Printf("Error: failed to write summary: %v\n", err)
+		os.Exit(1)
 	}
-	var result string
-	for i := 0; i < pages; i++ {
-		pageNum := i + 1
-		page, err := pdfReader.GetPage(pageNum)
-		if err != nil {
-			return "", fmt.Errorf("failed to get page %d: %w", pageNum, err)
-		}
 
-		ex, err := extractor.New(page)
-		if err != nil {
-			return "", fmt.Errorf("failed to create extractor: %w", err)
-		}
+	fmt.Printf("Extracted %d pages from %d of %d documents using %d workers\n",
+		summary.Pages, summary.Succeeded, summary.Files, summary.Workers)
+	fmt.Println("time taken for concurrent extraction", duration)
+	fmt.Printf("See summary: %s\n", summaryPath)
 
-		text, err := ex.ExtractText()
-		if err != nil {
-			return "", fmt.Errorf("failed to extract text: %w", err)
-		}
-		result += text
+	if summary.Failed > 0 || err != nil {
+		os.Exit(1)
 	}
+}
 
-	return result, nil
+// extractTask returns a batch task extracting the text of a document collected from `inputs` into a
+// text file in `outputDir`, handling the repeated headers, footers and page numbers according to
+// `repeated`. The documents of input directories keep their relative path in `outputDir`.
+func extractTask(inputs []string, outputDir string, repeated extractops.Repeated) batch.Task {
+	return func(ctx context.Context, path string) (int, error) {
+		outputPath := batch.OutputPath(inputs, path, outputDir, ".txt")
+		if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+			return 0, err
+		}
+		return extractops.ExtractTextToFile(ctx, path, outputPath, repeated)
+	}
 }
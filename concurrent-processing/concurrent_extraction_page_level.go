@@ -1,25 +1,25 @@
 /*
- * This example demonstrates how to extract text concurrently
- * with each page extraction running in its own go routine.
- * This can be useful for large documents processing.
+ * This example demonstrates how to extract text concurrently on page level.
+ * Pages are extracted by a fixed number of workers and written to the output file in page
+ * order as soon as they are ready, so the memory used depends on the number of workers rather
+ * than on the document size. This can be useful for large documents processing.
  *
- * Run as: go run concurrent_extraction_page_level.go <input.pdf> <output_dir>
+ * Run as: go run concurrent_extraction_page_level.go [-workers N] <input.pdf> <output_dir>
  */
 
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
-	"io/fs"
 	"os"
 	"path/filepath"
-	"strconv"
-	"sync"
+	"strings"
 	"time"
 
+	"github.com/unidoc/unidoc-examples/extract/extractops"
 	"github.com/unidoc/unipdf/v4/common/license"
-	"github.com/unidoc/unipdf/v4/extractor"
-	"github.com/unidoc/unipdf/v4/model"
 )
 
 func init() {
@@ -32,103 +32,49 @@ func init() {
 }
 
 func main() {
-	if len(os.Args) < 3 {
-		fmt.Printf("Usage: go run concurrent_extraction_page_level.go input.pdf output_dir\n")
+	workers := flag.Int("workers", 0, "number of pages extracted concurrently (default: number of CPUs)")
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		fmt.Printf("Usage: go run concurrent_extraction_page_level.go [-workers N] input.pdf output_dir\n")
 		os.Exit(1)
 	}
-	inputPdf := os.Args[1]
-	outputDir := os.Args[2]
+	inputPdf := flag.Arg(0)
+	outputDir := flag.Arg(1)
 
 	start := time.Now()
 
-	runPageConcurrent(inputPdf, outputDir)
+	numPages, err := runPageConcurrent(inputPdf, outputDir, *workers)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
 
 	duration := time.Since(start)
+	fmt.Printf("Extracted %d pages\n", numPages)
 	fmt.Println("time taken for concurrent extraction", duration)
 }
 
-// runPageConcurrent takes the input PDF and destination output directory and runs the extraction concurrently on page level.
-func runPageConcurrent(filename string, outputDir string) {
-	// Create reader.
-	file, err := os.Open(filename)
-	if err != nil {
-		fmt.Printf("Could not open input file")
-		return
-	}
-	defer file.Close()
-
-	if _, err := os.Stat(outputDir); err != nil {
-		if os.IsNotExist(err) {
-			err := os.Mkdir(outputDir, fs.ModePerm)
-			if err != nil {
-				fmt.Printf("Error: failed to create directory %s\n", outputDir)
-				return
-			}
-		}
+// runPageConcurrent extracts the text of the input PDF concurrently on page level and streams
+// it in page order to a text file in the destination output directory.
+func runPageConcurrent(filename string, outputDir string, workers int) (int, error) {
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create directory %s: %w", outputDir, err)
 	}
 
-	reader, err := model.NewPdfReader(file)
+	basename := filepath.Base(filename)
+	outputPath := filepath.Join(outputDir, strings.TrimSuffix(basename, filepath.Ext(basename))+".txt")
+	file, err := os.Create(outputPath)
 	if err != nil {
-		fmt.Printf("Could not create reader")
-		return
+		return 0, err
 	}
+	defer file.Close()
 
-	// Get total number of pages.
-	numPages, err := reader.GetNumPages()
+	opts := extractops.ParallelOptions{Workers: workers}
+	numPages, err := extractops.StreamText(context.Background(), filename, file, opts)
 	if err != nil {
-		fmt.Printf("Could not retrieve number of pages")
-		return
-	}
-
-	testGoroutineExtract(reader, numPages, outputDir)
-}
-
-func testGoroutineExtract(reader *model.PdfReader, numPages int, outputDir string) {
-	// Extract text from pages simultaneously.
-	var wg sync.WaitGroup
-
-	extractPage := func(pageNum int) error {
-		page, err := reader.GetPage(pageNum)
-		if err != nil {
-			return err
-		}
-
-		ex, err := extractor.New(page)
-		if err != nil {
-			return err
-		}
-
-		text, err := ex.ExtractText()
-		if err != nil {
-			return err
-		}
-		
-		// for test purposes, save each page text to its own file
-		filePath := filepath.Join(outputDir, strconv.Itoa(pageNum)+".txt")
-		file, err := os.Create(filePath)
-		if err != nil {
-			fmt.Printf("Error. failed to create file. %v\n", err)
-		}
-		
-		_, err = file.WriteString(text)
-		if err != nil {
-			fmt.Printf("Error. failed to write content. %v\n", err)
-		}
-		
-		return nil
-	}
-
-	for i := 1; i <= numPages; i++ {
-		wg.Add(1)
-		go func(j int) {
-			defer wg.Done()
-			extractErr := extractPage(j)
-			if extractErr != nil {
-				fmt.Printf("extractPage error")
-				return
-			}
-		}(i)
+		return numPages, err
 	}
 
-	wg.Wait() // Wait for all goroutines to finish
+	return numPages, file.Close()
 }
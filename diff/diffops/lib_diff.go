@@ -0,0 +1,251 @@
+/*
+ * Visual and text comparison of two revisions of a PDF document.
+ *
+ * The pages of both documents are rendered at the same scale and compared pixel by pixel, giving a
+ * similarity score per page and optionally diff images with the changed pixels in red. The words
+ * of both documents are aligned to report the inserted, deleted and changed words with their
+ * bounding boxes, and the changes can be highlighted with annotations in a copy of the new
+ * document.
+ */
+
+package diffops
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/unidoc/unidoc-examples/annotations/annotops"
+	"github.com/unidoc/unipdf/v4/model"
+	"github.com/unidoc/unipdf/v4/render"
+)
+
+// Options configures a comparison.
+type Options struct {
+	// DPI is the rendering resolution. Defaults to 72.
+	DPI float64
+	// Tolerance is the largest difference of the color components (0-255) of unchanged pixels,
+	// to absorb anti-aliasing differences.
+	Tolerance uint8
+	// ImageDir is the directory of the diff images, one PNG file per page. No images if empty.
+	ImageDir string
+	// SkipRaster and SkipText disable the pixel and the text comparison.
+	SkipRaster bool
+	SkipText   bool
+}
+
+// Page statuses.
+const (
+	PageIdentical = "identical"
+	PageChanged   = "changed"
+	PageAdded     = "added"   // Only in the new document.
+	PageRemoved   = "removed" // Only in the old document.
+)
+
+// PageDiff is the pixel comparison of a page.
+type PageDiff struct {
+	Page          int     `json:"page"`
+	Status        string  `json:"status"`
+	Similarity    float64 `json:"similarity"` // Fraction of unchanged pixels.
+	ChangedPixels int     `json:"changed_pixels"`
+	Image         string  `json:"image,omitempty"`
+}
+
+// Report is the comparison of two documents.
+type Report struct {
+	Old      string `json:"old"`
+	New      string `json:"new"`
+	OldPages int    `json:"old_pages"`
+	NewPages int    `json:"new_pages"`
+	// Identical is true if no pixel and no word changed.
+	Identical bool `json:"identical"`
+	// Similarity is the mean similarity of the pages.
+	Similarity float64    `json:"similarity"`
+	Pages      []PageDiff `json:"pages"`
+	Changes    []Change   `json:"changes"`
+}
+
+// Compare compares the documents `oldPath` and `newPath`.
+func Compare(oldPath, newPath string, opts Options) (*Report, error) {
+	if opts.DPI <= 0 {
+		opts.DPI = 72
+	}
+
+	oldReader, oldFile, err := model.NewPdfReaderFromFile(oldPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer oldFile.Close()
+	newReader, newFile, err := model.NewPdfReaderFromFile(newPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer newFile.Close()
+
+	r := &Report{Old: oldPath, New: newPath, Pages: []PageDiff{}, Changes: []Change{}}
+	if r.OldPages, err = oldReader.GetNumPages(); err != nil {
+		return nil, fmt.Errorf("%s: %w", oldPath, err)
+	}
+	if r.NewPages, err = newReader.GetNumPages(); err != nil {
+		return nil, fmt.Errorf("%s: %w", newPath, err)
+	}
+
+	if !opts.SkipRaster {
+		if opts.ImageDir != "" {
+			if err := os.MkdirAll(opts.ImageDir, 0755); err != nil {
+				return nil, err
+			}
+		}
+		if err := r.comparePages(oldReader, newReader, opts); err != nil {
+			return nil, err
+		}
+	}
+
+	if !opts.SkipText {
+		oldWords, err := DocumentWords(oldReader)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", oldPath, err)
+		}
+		newWords, err := DocumentWords(newReader)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", newPath, err)
+		}
+		if changes := DiffWords(oldWords, newWords); changes != nil {
+			r.Changes = changes
+		}
+	}
+
+	r.Identical = r.OldPages == r.NewPages && len(r.Changes) == 0
+	for _, p := range r.Pages {
+		r.Similarity += p.Similarity / float64(len(r.Pages))
+		if p.Status != PageIdentical {
+			r.Identical = false
+		}
+	}
+	if len(r.Pages) == 0 {
+		r.Similarity = 1
+	}
+	return r, nil
+}
+
+// comparePages renders and compares the pages of the documents.
+func (r *Report) comparePages(oldReader, newReader *model.PdfReader, opts Options) error {
+	device := render.NewImageDevice()
+	for pageNum := 1; pageNum <= max(r.OldPages, r.NewPages); pageNum++ {
+		var oldPage, newPage *model.PdfPage
+		var err error
+		if pageNum <= r.OldPages {
+			if oldPage, err = oldReader.GetPage(pageNum); err != nil {
+				return fmt.Errorf("%s: page %d: %w", r.Old, pageNum, err)
+			}
+		}
+		if pageNum <= r.NewPages {
+			if newPage, err = newReader.GetPage(pageNum); err != nil {
+				return fmt.Errorf("%s: page %d: %w", r.New, pageNum, err)
+			}
+		}
+
+		// Both pages are rendered at the scale of the old page, or of the new one if it was added.
+		scalePage := oldPage
+		if scalePage == nil {
+			scalePage = newPage
+		}
+		mbox, err := scalePage.GetMediaBox()
+		if err != nil {
+			return fmt.Errorf("page %d: %w", pageNum, err)
+		}
+		device.OutputWidth = int(mbox.Width()*opts.DPI/72 + 0.5)
+
+		var oldImg, newImg image.Image
+		if oldPage != nil {
+			if oldImg, err = device.Render(oldPage); err != nil {
+				return fmt.Errorf("%s: page %d: %w", r.Old, pageNum, err)
+			}
+		}
+		if newPage != nil {
+			if newImg, err = device.Render(newPage); err != nil {
+				return fmt.Errorf("%s: page %d: %w", r.New, pageNum, err)
+			}
+		}
+
+		pd := PageDiff{Page: pageNum}
+		var diffImg *image.RGBA
+		pd.Similarity, pd.ChangedPixels, diffImg = CompareImages(oldImg, newImg, opts.Tolerance)
+		switch {
+		case oldPage == nil:
+			pd.Status, pd.Similarity = PageAdded, 0
+		case newPage == nil:
+			pd.Status, pd.Similarity = PageRemoved, 0
+		case pd.ChangedPixels > 0:
+			pd.Status = PageChanged
+		default:
+			pd.Status = PageIdentical
+		}
+
+		if opts.ImageDir != "" && pd.Status != PageIdentical {
+			pd.Image = filepath.Join(opts.ImageDir, fmt.Sprintf("diff_page_%03d.png", pageNum))
+			if err := writePNG(pd.Image, diffImg); err != nil {
+				return err
+			}
+		}
+		r.Pages = append(r.Pages, pd)
+	}
+	return nil
+}
+
+// WriteJSON writes `r` as indented JSON to `w`.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteHighlighted writes a copy of the new document of `r` to `outputPath` with the text changes
+// highlighted by annotations: insertions in green, changes in yellow with the old text as comment
+// and deletions as a red mark with the deleted text as comment.
+func (r *Report) WriteHighlighted(outputPath string) error {
+	pdfReader, f, err := model.NewPdfReaderFromFile(r.New, nil)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	byPage := map[int][]Change{}
+	for _, c := range r.Changes {
+		if c.NewPage > 0 && len(c.newBoxes) > 0 {
+			byPage[c.NewPage] = append(byPage[c.NewPage], c)
+		}
+	}
+
+	pdfWriter, err := pdfReader.ToWriter(&model.ReaderToWriterOpts{
+		PageProcessCallback: func(pageNum int, page *model.PdfPage) error {
+			for _, c := range byPage[pageNum] {
+				opts := annotops.MarkupOptions{Type: annotops.MarkupHighlight, Subject: string(c.Kind)}
+				switch c.Kind {
+				case Inserted:
+					opts.Color = "#80ff80"
+					opts.Comment = "Inserted: " + c.NewText
+				case Changed:
+					opts.Color = "#ffff00"
+					opts.Comment = "Was: " + c.OldText
+				case Deleted:
+					opts.Color = "#ff4040"
+					opts.Comment = "Deleted: " + c.OldText
+				}
+				annotation, err := annotops.NewMarkup(c.newBoxes, opts)
+				if err != nil {
+					return err
+				}
+				page.AddAnnotation(annotation)
+			}
+			return nil
+		},
+	})
+	if err != nil {
+		return err
+	}
+	return pdfWriter.WriteToFile(outputPath)
+}
@@ -0,0 +1,98 @@
+/*
+ * Pixel comparison of rendered pages.
+ */
+
+package diffops
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+)
+
+// diffColor marks the changed pixels in the diff images.
+var diffColor = color.RGBA{R: 255, A: 255}
+
+// CompareImages compares `a` and `b` pixel by pixel, aligned on their top left corners. Pixels
+// whose color components differ by more than `tolerance` (0-255) and pixels covered by only one
+// of the images are changed. It returns the fraction of unchanged pixels and an image showing `b`
+// (or `a` if `b` is nil) faded, with the changed pixels in red.
+func CompareImages(a, b image.Image, tolerance uint8) (float64, int, *image.RGBA) {
+	var ra, rb image.Rectangle
+	if a != nil {
+		ra = a.Bounds()
+	}
+	if b != nil {
+		rb = b.Bounds()
+	}
+	width := max(ra.Dx(), rb.Dx())
+	height := max(ra.Dy(), rb.Dy())
+
+	out := image.NewRGBA(image.Rect(0, 0, width, height))
+	changed := 0
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			ca, inA := pixel(a, ra, x, y)
+			cb, inB := pixel(b, rb, x, y)
+
+			base := cb
+			if !inB {
+				base = ca
+			}
+			if inA != inB || differs(ca, cb, tolerance) {
+				changed++
+				out.SetRGBA(x, y, diffColor)
+				continue
+			}
+			// Faded gray version of the page as context.
+			gray := uint8((uint32(base.R)*299+uint32(base.G)*587+uint32(base.B)*114)/1000/4 + 191)
+			out.SetRGBA(x, y, color.RGBA{R: gray, G: gray, B: gray, A: 255})
+		}
+	}
+
+	total := width * height
+	if total == 0 {
+		return 1, 0, out
+	}
+	return 1 - float64(changed)/float64(total), changed, out
+}
+
+// pixel returns the color of pixel (x, y) of `img` relative to the top left corner of its bounds
+// `r`, and false if it is outside of the image. Transparent pixels are composed on white.
+func pixel(img image.Image, r image.Rectangle, x, y int) (color.RGBA, bool) {
+	if img == nil || x >= r.Dx() || y >= r.Dy() {
+		return color.RGBA{R: 255, G: 255, B: 255, A: 255}, false
+	}
+	cr, cg, cb, ca := img.At(r.Min.X+x, r.Min.Y+y).RGBA()
+	white := 0xffff - ca
+	return color.RGBA{
+		R: uint8((cr + white) >> 8),
+		G: uint8((cg + white) >> 8),
+		B: uint8((cb + white) >> 8),
+		A: 255,
+	}, true
+}
+
+func differs(a, b color.RGBA, tolerance uint8) bool {
+	d := func(x, y uint8) uint8 {
+		if x > y {
+			return x - y
+		}
+		return y - x
+	}
+	return d(a.R, b.R) > tolerance || d(a.G, b.G) > tolerance || d(a.B, b.B) > tolerance
+}
+
+// writePNG writes `img` to a PNG file at `path`.
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
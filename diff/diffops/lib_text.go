@@ -0,0 +1,314 @@
+/*
+ * Word level comparison of the text of two documents.
+ *
+ * The words of each document are taken in reading order (see extract/layout) and aligned with the
+ * Myers diff algorithm over the whole document, so that text reflowing to the next page is not
+ * reported as changed. Documents too different for a global alignment are aligned page by page.
+ */
+
+package diffops
+
+import (
+	"math"
+	"strings"
+
+	"github.com/unidoc/unidoc-examples/extract/layout"
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// maxEdits bounds the number of inserted and deleted words of an alignment, and so its time and
+// memory.
+const maxEdits = 2000
+
+// Word is a word of a document.
+type Word struct {
+	Text string
+	Page int
+	BBox [4]float64
+}
+
+// ChangeKind is the kind of a text change.
+type ChangeKind string
+
+// Change kinds.
+const (
+	Inserted ChangeKind = "inserted"
+	Deleted  ChangeKind = "deleted"
+	Changed  ChangeKind = "changed"
+)
+
+// Change is a run of inserted, deleted or replaced words. For insertions, OldPage and OldBBox
+// locate where the words were inserted in the old document, and for deletions NewPage and NewBBox
+// where the words were deleted in the new document.
+type Change struct {
+	Kind    ChangeKind `json:"kind"`
+	OldText string     `json:"old_text,omitempty"`
+	NewText string     `json:"new_text,omitempty"`
+	OldPage int        `json:"old_page"`
+	NewPage int        `json:"new_page"`
+	OldBBox [4]float64 `json:"old_bbox"`
+	NewBBox [4]float64 `json:"new_bbox"`
+
+	// newBoxes are the line boxes of the change in the new document.
+	newBoxes [][4]float64
+}
+
+// DocumentWords returns the words of the pages of `pdfReader` in reading order.
+func DocumentWords(pdfReader *model.PdfReader) ([]Word, error) {
+	numPages, err := pdfReader.GetNumPages()
+	if err != nil {
+		return nil, err
+	}
+
+	var words []Word
+	for i := 0; i < numPages; i++ {
+		pageNum := i + 1
+		page, err := pdfReader.GetPage(pageNum)
+		if err != nil {
+			return nil, err
+		}
+		p, err := layout.AnalyzePage(page)
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range p.Blocks {
+			for _, l := range b.Lines {
+				for _, w := range l.Words {
+					words = append(words, Word{Text: w.Text, Page: pageNum, BBox: w.BBox})
+				}
+			}
+		}
+	}
+	return words, nil
+}
+
+// DiffWords returns the changes turning the words `old` into the words `new`.
+func DiffWords(old, new []Word) []Change {
+	pairs, ok := align(old, new)
+	if !ok {
+		pairs = alignPages(old, new)
+	}
+
+	var changes []Change
+	i, j := 0, 0
+	for _, p := range append(pairs, [2]int{len(old), len(new)}) {
+		if p[0] > i || p[1] > j {
+			changes = append(changes, newChanges(old, new, i, p[0], j, p[1])...)
+		}
+		i, j = p[0]+1, p[1]+1
+	}
+	return changes
+}
+
+// align returns the index pairs of the matching words of `a` and `b`, false if they differ by more
+// than maxEdits words.
+func align(a, b []Word) ([][2]int, bool) {
+	// Common prefix and suffix.
+	n, m := len(a), len(b)
+	prefix := 0
+	for prefix < n && prefix < m && a[prefix].Text == b[prefix].Text {
+		prefix++
+	}
+	suffix := 0
+	for suffix < n-prefix && suffix < m-prefix && a[n-1-suffix].Text == b[m-1-suffix].Text {
+		suffix++
+	}
+
+	middle, ok := myers(a[prefix:n-suffix], b[prefix:m-suffix])
+	if !ok {
+		return nil, false
+	}
+
+	pairs := make([][2]int, 0, prefix+len(middle)+suffix)
+	for k := 0; k < prefix; k++ {
+		pairs = append(pairs, [2]int{k, k})
+	}
+	for _, p := range middle {
+		pairs = append(pairs, [2]int{p[0] + prefix, p[1] + prefix})
+	}
+	for k := suffix; k > 0; k-- {
+		pairs = append(pairs, [2]int{n - k, m - k})
+	}
+	return pairs, true
+}
+
+// alignPages aligns the words of the pages with the same number of `a` and `b`. Pages that differ
+// by more than maxEdits words have no matching words.
+func alignPages(a, b []Word) [][2]int {
+	var pairs [][2]int
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		// The next page of either document.
+		page := math.MaxInt
+		if i < len(a) {
+			page = a[i].Page
+		}
+		if j < len(b) && b[j].Page < page {
+			page = b[j].Page
+		}
+		ei, ej := i, j
+		for ei < len(a) && a[ei].Page == page {
+			ei++
+		}
+		for ej < len(b) && b[ej].Page == page {
+			ej++
+		}
+
+		if pagePairs, ok := align(a[i:ei], b[j:ej]); ok {
+			for _, p := range pagePairs {
+				pairs = append(pairs, [2]int{p[0] + i, p[1] + j})
+			}
+		}
+		i, j = ei, ej
+	}
+	return pairs
+}
+
+// myers returns the index pairs of a longest common subsequence of the texts of `a` and `b` with
+// the Myers O(ND) algorithm, false if more than maxEdits insertions and deletions are needed.
+func myers(a, b []Word) ([][2]int, bool) {
+	n, m := len(a), len(b)
+	if n+m == 0 {
+		return nil, true
+	}
+
+	// v[offset+k] is the furthest x reached on diagonal k = x - y. trace[d] holds v for the
+	// diagonals -d-1..d+1 before step d, as read when backtracking.
+	offset := maxEdits + 1
+	v := make([]int, 2*maxEdits+3)
+	var trace [][]int
+	for d := 0; d <= maxEdits; d++ {
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x].Text == b[y].Text {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(trace, n, m), true
+			}
+		}
+	}
+	return nil, false
+}
+
+// backtrack returns the matching index pairs, in order, of the path found by myers.
+func backtrack(trace [][]int, n, m int) [][2]int {
+	var pairs [][2]int
+	x, y := n, m
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := func(k int) int { return trace[d][k+d+1] }
+		k := x - y
+		prevK := k - 1
+		if k == -d || (k != d && v(k-1) < v(k+1)) {
+			prevK = k + 1
+		}
+		prevX := v(prevK)
+		prevY := prevX - prevK
+		for x > prevX && y > prevY {
+			x--
+			y--
+			pairs = append(pairs, [2]int{x, y})
+		}
+		x, y = prevX, prevY
+	}
+
+	for i, j := 0, len(pairs)-1; i < j; i, j = i+1, j-1 {
+		pairs[i], pairs[j] = pairs[j], pairs[i]
+	}
+	return pairs
+}
+
+// newChanges returns the changes replacing the words old[i0:i1] by new[j0:j1], split at page
+// breaks.
+func newChanges(old, new []Word, i0, i1, j0, j1 int) []Change {
+	deleted := splitPages(old[i0:i1])
+	inserted := splitPages(new[j0:j1])
+
+	var changes []Change
+	for k := 0; k < len(deleted) || k < len(inserted); k++ {
+		var c Change
+		switch {
+		case k < len(deleted) && k < len(inserted):
+			c.Kind = Changed
+		case k < len(deleted):
+			c.Kind = Deleted
+			c.NewPage, c.NewBBox = anchor(new, j0)
+			c.newBoxes = [][4]float64{c.NewBBox}
+		default:
+			c.Kind = Inserted
+			c.OldPage, c.OldBBox = anchor(old, i0)
+		}
+		if k < len(deleted) {
+			c.OldText, c.OldPage, c.OldBBox, _ = wordsText(deleted[k])
+		}
+		if k < len(inserted) {
+			c.NewText, c.NewPage, c.NewBBox, c.newBoxes = wordsText(inserted[k])
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+// splitPages splits `words` into runs of words on the same page.
+func splitPages(words []Word) [][]Word {
+	var runs [][]Word
+	start := 0
+	for i := 1; i <= len(words); i++ {
+		if i == len(words) || words[i].Page != words[start].Page {
+			runs = append(runs, words[start:i])
+			start = i
+		}
+	}
+	return runs
+}
+
+// wordsText returns the text, page, bounding box and line boxes of the words `words` of a page.
+func wordsText(words []Word) (string, int, [4]float64, [][4]float64) {
+	texts := make([]string, len(words))
+	bbox := words[0].BBox
+	var lines [][4]float64
+	for i, w := range words {
+		texts[i] = w.Text
+		bbox = union(bbox, w.BBox)
+		if n := len(lines); n > 0 && sameLine(lines[n-1], w.BBox) {
+			lines[n-1] = union(lines[n-1], w.BBox)
+		} else {
+			lines = append(lines, w.BBox)
+		}
+	}
+	return strings.Join(texts, " "), words[0].Page, bbox, lines
+}
+
+// anchor returns the page and a narrow box at the position of `words` before word `i`: right
+// after the previous word or, at the start of the document, before word `i`.
+func anchor(words []Word, i int) (int, [4]float64) {
+	const width = 2
+	switch {
+	case i > 0:
+		b := words[i-1].BBox
+		return words[i-1].Page, [4]float64{b[2], b[1], b[2] + width, b[3]}
+	case i < len(words):
+		b := words[i].BBox
+		return words[i].Page, [4]float64{b[0] - width, b[1], b[0], b[3]}
+	}
+	return 0, [4]float64{}
+}
+
+// sameLine returns true if `b` follows `a` on the same line.
+func sameLine(a, b [4]float64) bool {
+	overlap := math.Min(a[3], b[3]) - math.Max(a[1], b[1])
+	return b[0] >= a[0] && overlap >= 0.5*math.Min(a[3]-a[1], b[3]-b[1])
+}
+
+func union(a, b [4]float64) [4]float64 {
+	return [4]float64{math.Min(a[0], b[0]), math.Min(a[1], b[1]), math.Max(a[2], b[2]), math.Max(a[3], b[3])}
+}
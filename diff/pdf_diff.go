@@ -0,0 +1,95 @@
+/*
+ * Compares two revisions of a PDF document. The pages are rendered and compared pixel by pixel,
+ * and the words are aligned to find the inserted, deleted and changed text. A summary is printed,
+ * and optionally a JSON report, diff images of the changed pages and a copy of the new document
+ * with the text changes highlighted are written.
+ *
+ * Run as: go run pdf_diff.go [-dpi 100] [-tolerance 16] [-images dir] [-highlight out.pdf] [-json report.json] <old.pdf> <new.pdf>
+ *
+ * Example: go run pdf_diff.go -images diff_images -highlight changes.pdf template_v1.pdf template_v2.pdf
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/unidoc/unidoc-examples/diff/diffops"
+	"github.com/unidoc/unipdf/v4/common/license"
+)
+
+func init() {
+	// Make sure to load your metered License API key prior to using the library.
+	// If you need a key, you can sign up and create a free one at https://cloud.unidoc.io
+	err := license.SetMeteredKey(os.Getenv(`UNIDOC_LICENSE_API_KEY`))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func main() {
+	var opts diffops.Options
+	var tolerance uint
+	flag.Float64Var(&opts.DPI, "dpi", 100, "rendering resolution")
+	flag.UintVar(&tolerance, "tolerance", 16, "largest color component difference (0-255) of unchanged pixels")
+	flag.StringVar(&opts.ImageDir, "images", "", "directory of the diff images of the changed pages")
+	highlightPath := flag.String("highlight", "", "copy of the new document with the text changes highlighted")
+	jsonPath := flag.String("json", "", "JSON report")
+	flag.Parse()
+
+	if flag.NArg() != 2 || tolerance > 255 {
+		fmt.Printf("Usage: go run pdf_diff.go [-dpi 100] [-tolerance 16] [-images dir] [-highlight out.pdf] [-json report.json] old.pdf new.pdf\n")
+		os.Exit(1)
+	}
+	opts.Tolerance = uint8(tolerance)
+
+	report, err := diffops.Compare(flag.Arg(0), flag.Arg(1), opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Pages: %d -> %d, similarity: %.2f%%\n", report.OldPages, report.NewPages, 100*report.Similarity)
+	for _, p := range report.Pages {
+		if p.Status != diffops.PageIdentical {
+			fmt.Printf("  page %d: %s, %.2f%% similar, %d pixels changed\n", p.Page, p.Status, 100*p.Similarity,
+				p.ChangedPixels)
+		}
+	}
+	for _, c := range report.Changes {
+		switch c.Kind {
+		case diffops.Inserted:
+			fmt.Printf("  + page %d: %q\n", c.NewPage, c.NewText)
+		case diffops.Deleted:
+			fmt.Printf("  - page %d: %q\n", c.OldPage, c.OldText)
+		case diffops.Changed:
+			fmt.Printf("  ~ page %d: %q -> %q\n", c.NewPage, c.OldText, c.NewText)
+		}
+	}
+	if report.Identical {
+		fmt.Printf("The documents are identical\n")
+	}
+
+	if *jsonPath != "" {
+		f, err := os.Create(*jsonPath)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		err = report.WriteJSON(f)
+		f.Close()
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if *highlightPath != "" {
+		if err := report.WriteHighlighted(*highlightPath); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		fmt.Printf("Highlighted changes written to %s\n", *highlightPath)
+	}
+}
@@ -0,0 +1,166 @@
+/*
+ * Reusable text extraction helpers shared by the extract examples and the unipdf command line tool.
+ */
+
+package extractops
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/unidoc/unidoc-examples/extract/layout"
+	"github.com/unidoc/unipdf/v4/extractor"
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// PageText holds the text extracted from a single page.
+type PageText struct {
+	PageNum int
+	Text    string
+}
+
+// ExtractText extracts the text of the pages `pageNums` of `inputPath`. All pages are
+// extracted if `pageNums` is empty.
+func ExtractText(inputPath string, pageNums []int) ([]PageText, error) {
+	pdfReader, f, err := model.NewPdfReaderFromFile(inputPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if len(pageNums) == 0 {
+		numPages, err := pdfReader.GetNumPages()
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < numPages; i++ {
+			pageNums = append(pageNums, i+1)
+		}
+	}
+
+	var pages []PageText
+	for _, pageNum := range pageNums {
+		text, err := ExtractPageText(pdfReader, pageNum)
+		if err != nil {
+			return pages, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+		pages = append(pages, PageText{PageNum: pageNum, Text: text})
+	}
+
+	return pages, nil
+}
+
+// ExtractPageText extracts the text of page `pageNum` of `pdfReader`.
+func ExtractPageText(pdfReader *model.PdfReader, pageNum int) (string, error) {
+	page, err := pdfReader.GetPage(pageNum)
+	if err != nil {
+		return "", err
+	}
+
+	ex, err := extractor.New(page)
+	if err != nil {
+		return "", err
+	}
+
+	return ex.ExtractText()
+}
+
+// ExtractPageLayoutText extracts the text of page `pageNum` of `pdfReader` in the reading order
+// found by the layout analyzer: multi-column text is read column by column and blocks are
+// separated by empty lines.
+func ExtractPageLayoutText(pdfReader *model.PdfReader, pageNum int) (string, error) {
+	page, err := pdfReader.GetPage(pageNum)
+	if err != nil {
+		return "", err
+	}
+
+	pl, err := layout.AnalyzePage(page)
+	if err != nil {
+		return "", err
+	}
+
+	return pl.Text(), nil
+}
+
+// ExtractTextToFile extracts the text of all pages of `inputPath` into `outputPath`, writing
+// each page as soon as it is extracted. The repeated headers, footers and page numbers are
+// handled according to `repeated`. Extraction stops between pages when `ctx` is done.
+// The text is written to a temporary file which is only renamed to `outputPath` once all pages
+// are extracted, so a failed or cancelled extraction leaves no partial output.
+// It returns the number of pages extracted.
+func ExtractTextToFile(ctx context.Context, inputPath string, outputPath string, repeated Repeated) (int, error) {
+	pdfReader, f, err := model.NewPdfReaderFromFile(inputPath, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	numPages, err := pdfReader.GetNumPages()
+	if err != nil {
+		return 0, err
+	}
+
+	extract, err := pageExtractor(pdfReader, nil, false, repeated)
+	if err != nil {
+		return 0, err
+	}
+
+	out, err := os.CreateTemp(filepath.Dir(outputPath), "."+filepath.Base(outputPath)+".*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(out.Name())
+	defer out.Close()
+	// Temporary files are private, give the output the permissions of os.Create.
+	if err := out.Chmod(0644); err != nil {
+		return 0, err
+	}
+
+	for i := 0; i < numPages; i++ {
+		if err := ctx.Err(); err != nil {
+			return i, err
+		}
+
+		pageNum := i + 1
+		text, err := extract(pdfReader, pageNum)
+		if err != nil {
+			return i, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+
+		if i > 0 {
+			text = "\f" + text
+		}
+		if _, err := io.WriteString(out, text+"\n"); err != nil {
+			return i, err
+		}
+	}
+
+	if err := out.Close(); err != nil {
+		return numPages, err
+	}
+	if err := ctx.Err(); err != nil {
+		return numPages, err
+	}
+	return numPages, os.Rename(out.Name(), outputPath)
+}
+
+// WriteText writes `pages` to `w`, separating pages with a form feed character.
+func WriteText(w io.Writer, pages []PageText) error {
+	for i, page := range pages {
+		if i > 0 {
+			if _, err := io.WriteString(w, "\f"); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(w, page.Text); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
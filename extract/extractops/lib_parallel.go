@@ -0,0 +1,193 @@
+/*
+ * Page level parallel text extraction emitting page results in page order as they complete.
+ */
+
+package extractops
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// ParallelOptions configures ExtractPagesParallel.
+type ParallelOptions struct {
+	// Workers is the number of pages extracted concurrently. Defaults to the number of CPUs.
+	Workers int
+	// Window is the maximum number of pages being extracted or waiting to be emitted. It bounds
+	// the memory held by results completed out of order. Defaults to twice the number of workers.
+	Window int
+	// PageNums lists the pages to extract. All pages are extracted if empty.
+	PageNums []int
+	// Layout extracts the text in the reading order found by the layout analyzer (see
+	// ExtractPageLayoutText) instead of the extractor order.
+	Layout bool
+	// Repeated selects how the headers, footers and page numbers repeated across pages are
+	// extracted. They are detected on a sample of the pages before the extraction starts and
+	// the text is extracted in layout reading order when not RepeatedKeep.
+	Repeated Repeated
+}
+
+// ExtractPagesParallel extracts the text of the pages of `pdfReader` on several workers and
+// calls `emit` for each page in page order as soon as the page and all pages before it are
+// extracted. At most Window pages are held at any time, so the peak memory depends on the number
+// of workers rather than on the document size. Extraction stops at the first error, including
+// an error returned by `emit`, or when `ctx` is done. No worker is left running on return.
+func ExtractPagesParallel(ctx context.Context, pdfReader *model.PdfReader, opts ParallelOptions, emit func(PageText) error) error {
+	pageNums := opts.PageNums
+	if len(pageNums) == 0 {
+		numPages, err := pdfReader.GetNumPages()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < numPages; i++ {
+			pageNums = append(pageNums, i+1)
+		}
+	}
+	if len(pageNums) == 0 {
+		return nil
+	}
+
+	extract, err := pageExtractor(pdfReader, pageNums, opts.Layout, opts.Repeated)
+	if err != nil {
+		return err
+	}
+
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	window := opts.Window
+	if window <= 0 {
+		window = 2 * workers
+	} else if window < workers {
+		window = workers
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		index int
+		page  PageText
+		err   error
+	}
+
+	// Each dispatched page holds a token until it is emitted.
+	tokens := make(chan struct{}, window)
+	jobs := make(chan int)
+	results := make(chan result)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		defer close(jobs)
+		for i := range pageNums {
+			select {
+			case tokens <- struct{}{}:
+			case <-ctx.Done():
+				return
+			}
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				pageNum := pageNums[i]
+				text, err := extract(pdfReader, pageNum)
+				if err != nil {
+					err = fmt.Errorf("page %d: %w", pageNum, err)
+				}
+				select {
+				case results <- result{index: i, page: PageText{PageNum: pageNum, Text: text}, err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	// stop cancels the extraction and waits for the dispatcher and the workers to return, so
+	// that none of them is still using `pdfReader` when the function returns `err`.
+	stop := func(err error) error {
+		cancel()
+		for range results {
+		}
+		return err
+	}
+
+	// Reorder the results and emit them in page order.
+	pending := map[int]PageText{}
+	next := 0
+	for res := range results {
+		if res.err != nil {
+			return stop(res.err)
+		}
+
+		pending[res.index] = res.page
+		for {
+			page, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			<-tokens
+
+			if err := emit(page); err != nil {
+				return stop(err)
+			}
+		}
+	}
+
+	if next < len(pageNums) {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		return fmt.Errorf("extraction stopped after %d of %d pages", next, len(pageNums))
+	}
+	return nil
+}
+
+// StreamText extracts the text of `inputPath` in parallel and writes it to `w` in page order
+// while the extraction progresses. Pages are separated by a form feed character.
+// It returns the number of pages written.
+func StreamText(ctx context.Context, inputPath string, w io.Writer, opts ParallelOptions) (int, error) {
+	pdfReader, f, err := model.NewPdfReaderFromFile(inputPath, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	written := 0
+	err = ExtractPagesParallel(ctx, pdfReader, opts, func(page PageText) error {
+		text := page.Text + "\n"
+		if written > 0 {
+			text = "\f" + text
+		}
+		if _, err := io.WriteString(w, text); err != nil {
+			return err
+		}
+		written++
+		return nil
+	})
+
+	return written, err
+}
@@ -0,0 +1,81 @@
+/*
+ * Extraction with the running headers, footers and page numbers stripped or tagged so that they
+ * do not pollute search indexes.
+ */
+
+package extractops
+
+import (
+	"fmt"
+
+	"github.com/unidoc/unidoc-examples/extract/layout"
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// Repeated selects how the headers, footers and page numbers repeated across pages (see
+// layout.DetectRepeated) are extracted.
+type Repeated string
+
+// Repeated modes.
+const (
+	RepeatedKeep  Repeated = ""      // Extracted like the body text.
+	RepeatedStrip Repeated = "strip" // Left out.
+	RepeatedTag   Repeated = "tag"   // Extracted in blocks of their own prefixed by their kind, e.g. "[footer] ".
+)
+
+// ParseRepeated parses a Repeated mode: "", "keep", "strip" or "tag".
+func ParseRepeated(s string) (Repeated, error) {
+	switch s {
+	case "", "keep":
+		return RepeatedKeep, nil
+	case string(RepeatedStrip), string(RepeatedTag):
+		return Repeated(s), nil
+	}
+	return RepeatedKeep, fmt.Errorf("invalid repeated text mode %q: expected keep, strip or tag", s)
+}
+
+// ExtractPageRepeatedText extracts the text of page `pageNum` of `pdfReader` in layout reading
+// order with the lines of `repeated` stripped or tagged according to `mode`. Only the repeated
+// lines are stripped, while the headers and footers found by the layout analysis of the page
+// alone are tagged too.
+func ExtractPageRepeatedText(pdfReader *model.PdfReader, pageNum int, repeated *layout.RepeatedSet, mode Repeated) (string, error) {
+	page, err := pdfReader.GetPage(pageNum)
+	if err != nil {
+		return "", err
+	}
+
+	pl, err := layout.AnalyzePage(page)
+	if err != nil {
+		return "", err
+	}
+	if mode == RepeatedTag {
+		repeated.Tag(pl)
+		return pl.TaggedText(), nil
+	}
+	repeated.Strip(pl)
+	return pl.Text(), nil
+}
+
+// pageExtractor returns the function extracting the text of a page of `pdfReader` for the pages
+// `pageNums`. The repeated lines are detected on a sample of the pages when `mode` is not
+// RepeatedKeep.
+func pageExtractor(pdfReader *model.PdfReader, pageNums []int, readingOrder bool, mode Repeated) (func(*model.PdfReader, int) (string, error), error) {
+	switch mode {
+	case RepeatedKeep:
+		if readingOrder {
+			return ExtractPageLayoutText, nil
+		}
+		return ExtractPageText, nil
+	case RepeatedStrip, RepeatedTag:
+	default:
+		return nil, fmt.Errorf("invalid repeated text mode %q", mode)
+	}
+
+	repeated, err := layout.DetectRepeatedInReader(pdfReader, pageNums, layout.RepeatedOptions{})
+	if err != nil {
+		return nil, err
+	}
+	return func(pdfReader *model.PdfReader, pageNum int) (string, error) {
+		return ExtractPageRepeatedText(pdfReader, pageNum, repeated, mode)
+	}, nil
+}
@@ -0,0 +1,297 @@
+/*
+ * Structured text export: text of each page with page geometry, lines, words and text marks
+ * (bounding box, font name, font size, fill color, reading order index), written as JSON or
+ * JSON Lines following the schema described in extract/README.md.
+ */
+
+package extractops
+
+import (
+	"encoding/json"
+	"fmt"
+	"image/color"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/unidoc/unipdf/v4/extractor"
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// SchemaVersion is the version of the structured text schema. It is incremented whenever
+// fields are removed or change meaning, additions do not change the version.
+const SchemaVersion = 1
+
+// BBox is a bounding box [llx, lly, urx, ury] in PDF points, in the unrotated page coordinate
+// system with the origin in the lower left corner of the media box.
+type BBox [4]float64
+
+// StructuredPage is the structured text of a single page.
+type StructuredPage struct {
+	SchemaVersion int     `json:"schema_version"`
+	File          string  `json:"file,omitempty"`
+	Page          int     `json:"page"`
+	Width         float64 `json:"width"`
+	Height        float64 `json:"height"`
+	Rotation      int64   `json:"rotation"`
+	MediaBox      BBox    `json:"media_box"`
+	Text          string  `json:"text"`
+	Lines         []Line  `json:"lines"`
+	Words         []Word  `json:"words"`
+	Marks         []Mark  `json:"marks,omitempty"`
+}
+
+// Line is a line of text. Words[WordStart:WordEnd] are the words of the line.
+type Line struct {
+	Index     int    `json:"index"`
+	Text      string `json:"text"`
+	BBox      BBox   `json:"bbox"`
+	WordStart int    `json:"word_start"`
+	WordEnd   int    `json:"word_end"`
+}
+
+// Word is a whitespace delimited sequence of characters. Marks[MarkStart:MarkEnd] are the
+// marks of the word.
+type Word struct {
+	Index     int    `json:"index"`
+	Text      string `json:"text"`
+	BBox      BBox   `json:"bbox"`
+	Line      int    `json:"line"`
+	MarkStart int    `json:"mark_start"`
+	MarkEnd   int    `json:"mark_end"`
+}
+
+// Mark is a single glyph placed on the page. Index is the reading order index of the mark and
+// Offset is its byte offset in the page text.
+type Mark struct {
+	Index    int     `json:"index"`
+	Text     string  `json:"text"`
+	Offset   int     `json:"offset"`
+	BBox     BBox    `json:"bbox"`
+	Font     string  `json:"font,omitempty"`
+	FontSize float64 `json:"font_size"`
+	Fill     string  `json:"fill,omitempty"`
+}
+
+// StructuredDocument is the structured text of a document, the JSON output format.
+type StructuredDocument struct {
+	SchemaVersion int              `json:"schema_version"`
+	File          string           `json:"file"`
+	Pages         []StructuredPage `json:"pages"`
+}
+
+// Output formats of WriteStructured.
+const (
+	FormatJSON  = "json"
+	FormatJSONL = "jsonl"
+)
+
+var reWord = regexp.MustCompile(`\S+`)
+
+// ExtractStructuredPage extracts the structured text of page `pageNum` of `pdfReader`.
+// Marks are only included if `withMarks` is true, words always refer to mark indexes.
+func ExtractStructuredPage(pdfReader *model.PdfReader, pageNum int, withMarks bool) (*StructuredPage, error) {
+	page, err := pdfReader.GetPage(pageNum)
+	if err != nil {
+		return nil, err
+	}
+
+	mbox, err := page.GetMediaBox()
+	if err != nil {
+		return nil, err
+	}
+	var rotation int64
+	if page.Rotate != nil {
+		rotation = *page.Rotate
+	}
+
+	ex, err := extractor.New(page)
+	if err != nil {
+		return nil, err
+	}
+	pageText, _, _, err := ex.ExtractPageText()
+	if err != nil {
+		return nil, err
+	}
+
+	sp := StructurePageText(pageText)
+	sp.Page = pageNum
+	sp.Width = mbox.Urx - mbox.Llx
+	sp.Height = mbox.Ury - mbox.Lly
+	sp.Rotation = rotation
+	sp.MediaBox = toBBox(*mbox)
+	if !withMarks {
+		sp.Marks = nil
+	}
+	return sp, nil
+}
+
+// StructurePageText splits the text extracted in `pageText` into lines, words and marks. Only
+// the text fields of the returned page are set, the page number and geometry are left to the
+// caller.
+func StructurePageText(pageText *extractor.PageText) *StructuredPage {
+	sp := &StructuredPage{
+		SchemaVersion: SchemaVersion,
+		Text:          pageText.Text(),
+		Lines:         []Line{},
+		Words:         []Word{},
+	}
+
+	// Marks in reading order, skipping the spaces and line breaks inserted by the extractor.
+	var marks []Mark
+	for _, tm := range pageText.Marks().Elements() {
+		if tm.Meta {
+			continue
+		}
+		m := Mark{
+			Text:     tm.Text,
+			Offset:   tm.Offset,
+			BBox:     toBBox(tm.BBox),
+			FontSize: round(tm.FontSize),
+			Fill:     hexColor(tm.FillColor),
+		}
+		if tm.Font != nil {
+			m.Font = tm.Font.BaseFont()
+		}
+		marks = append(marks, m)
+	}
+	sort.SliceStable(marks, func(i, j int) bool { return marks[i].Offset < marks[j].Offset })
+	for i := range marks {
+		marks[i].Index = i
+	}
+
+	// markRange returns the indexes of the marks in the byte range [start, end) of the text.
+	markRange := func(start, end int) (int, int) {
+		lo := sort.Search(len(marks), func(i int) bool { return marks[i].Offset >= start })
+		hi := sort.Search(len(marks), func(i int) bool { return marks[i].Offset >= end })
+		return lo, hi
+	}
+
+	lineStart := 0
+	for lineStart <= len(sp.Text) {
+		lineEnd := len(sp.Text)
+		if i := strings.IndexByte(sp.Text[lineStart:], '\n'); i >= 0 {
+			lineEnd = lineStart + i
+		}
+
+		line := Line{
+			Index:     len(sp.Lines),
+			Text:      sp.Text[lineStart:lineEnd],
+			WordStart: len(sp.Words),
+		}
+		for _, loc := range reWord.FindAllStringIndex(line.Text, -1) {
+			start, end := lineStart+loc[0], lineStart+loc[1]
+			lo, hi := markRange(start, end)
+			word := Word{
+				Index:     len(sp.Words),
+				Text:      sp.Text[start:end],
+				BBox:      unionBBox(marks[lo:hi]),
+				Line:      line.Index,
+				MarkStart: lo,
+				MarkEnd:   hi,
+			}
+			sp.Words = append(sp.Words, word)
+		}
+		line.WordEnd = len(sp.Words)
+
+		if line.WordEnd > line.WordStart {
+			lo, hi := markRange(lineStart, lineEnd)
+			line.BBox = unionBBox(marks[lo:hi])
+			sp.Lines = append(sp.Lines, line)
+		}
+		lineStart = lineEnd + 1
+	}
+
+	sp.Marks = marks
+	return sp
+}
+
+// WriteStructured extracts the structured text of the pages `pageNums` of `inputPath` (all
+// pages if empty) and writes it to `w` in `format`, FormatJSON or FormatJSONL. With FormatJSONL
+// each page is written as a single line as soon as it is extracted.
+func WriteStructured(w io.Writer, inputPath string, pageNums []int, format string, withMarks bool) error {
+	if format != FormatJSON && format != FormatJSONL {
+		return fmt.Errorf("unsupported format %q (use %s or %s)", format, FormatJSON, FormatJSONL)
+	}
+
+	pdfReader, f, err := model.NewPdfReaderFromFile(inputPath, nil)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if len(pageNums) == 0 {
+		numPages, err := pdfReader.GetNumPages()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < numPages; i++ {
+			pageNums = append(pageNums, i+1)
+		}
+	}
+
+	doc := StructuredDocument{
+		SchemaVersion: SchemaVersion,
+		File:          inputPath,
+		Pages:         []StructuredPage{},
+	}
+	enc := json.NewEncoder(w)
+	for _, pageNum := range pageNums {
+		sp, err := ExtractStructuredPage(pdfReader, pageNum, withMarks)
+		if err != nil {
+			return fmt.Errorf("page %d: %w", pageNum, err)
+		}
+
+		if format == FormatJSONL {
+			sp.File = inputPath
+			if err := enc.Encode(sp); err != nil {
+				return err
+			}
+			continue
+		}
+		doc.Pages = append(doc.Pages, *sp)
+	}
+
+	if format == FormatJSON {
+		enc.SetIndent("", "  ")
+		return enc.Encode(doc)
+	}
+	return nil
+}
+
+// toBBox converts `r` to a BBox rounded to 1/100 point.
+func toBBox(r model.PdfRectangle) BBox {
+	return BBox{round(r.Llx), round(r.Lly), round(r.Urx), round(r.Ury)}
+}
+
+// unionBBox returns the bounding box of `marks`.
+func unionBBox(marks []Mark) BBox {
+	if len(marks) == 0 {
+		return BBox{}
+	}
+
+	bbox := marks[0].BBox
+	for _, m := range marks[1:] {
+		bbox[0] = math.Min(bbox[0], m.BBox[0])
+		bbox[1] = math.Min(bbox[1], m.BBox[1])
+		bbox[2] = math.Max(bbox[2], m.BBox[2])
+		bbox[3] = math.Max(bbox[3], m.BBox[3])
+	}
+	return bbox
+}
+
+// hexColor returns `c` as "#rrggbb" or an empty string if `c` is nil.
+func hexColor(c color.Color) string {
+	if c == nil {
+		return ""
+	}
+	r, g, b, _ := c.RGBA()
+	return fmt.Sprintf("#%02x%02x%02x", r>>8, g>>8, b>>8)
+}
+
+// round rounds `v` to 2 decimals.
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,529 @@
+/*
+ * Page layout analysis: segments the text of a page into columns, blocks, headers, footers and
+ * sidebars and returns the blocks in reading order, so that two-column documents are read one
+ * column after the other instead of line by line across the columns.
+ *
+ * Words are grouped into rows by vertical overlap (see Rows) and rows are split into lines at gaps
+ * wider than a word space. Columns are found with a recursive XY cut: the lines of a region are
+ * split at vertical white space strips (columns) or, failing that, at horizontal white space
+ * strips (blocks).
+ */
+
+package layout
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/unidoc/unipdf/v4/extractor"
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// BBox is a bounding box [llx, lly, urx, ury] in PDF points.
+type BBox [4]float64
+
+// Kind is the role of a block on the page.
+type Kind string
+
+// Block kinds.
+const (
+	KindBody    Kind = "body"
+	KindHeader  Kind = "header"
+	KindFooter  Kind = "footer"
+	KindSidebar Kind = "sidebar"
+)
+
+// Page is the layout of a page. Blocks are in reading order: headers, body blocks, sidebars and
+// footers.
+type Page struct {
+	Page   int     `json:"page"`
+	Width  float64 `json:"width"`
+	Height float64 `json:"height"`
+	Blocks []Block `json:"blocks"`
+
+	mediaBox BBox
+}
+
+// Block is a group of consecutive lines, typically a paragraph or a heading. Column is the
+// 0-based index of the column of the block within its part of the page, 0 for single column text.
+type Block struct {
+	Kind   Kind   `json:"kind"`
+	Column int    `json:"column"`
+	BBox   BBox   `json:"bbox"`
+	Text   string `json:"text"`
+	Lines  []Line `json:"lines"`
+}
+
+// Line is a line of words in a single column.
+type Line struct {
+	Text     string  `json:"text"`
+	BBox     BBox    `json:"bbox"`
+	FontSize float64 `json:"font_size"`
+	Words    []Word  `json:"-"`
+}
+
+// Word is a sequence of non space marks.
+type Word struct {
+	Text     string
+	BBox     BBox
+	FontSize float64
+	Font     string
+}
+
+// Text returns the text of the blocks of `p` in reading order, lines separated by line breaks and
+// blocks by empty lines. Blocks of the kinds in `skip` are left out.
+func (p *Page) Text(skip ...Kind) string {
+	var texts []string
+	for _, b := range p.Blocks {
+		if !containsKind(skip, b.Kind) {
+			texts = append(texts, b.Text)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// TaggedText returns the text of `p` like Text, with the text of the blocks other than body text
+// prefixed by their kind in brackets, e.g. "[footer] ".
+func (p *Page) TaggedText() string {
+	texts := make([]string, len(p.Blocks))
+	for i, b := range p.Blocks {
+		texts[i] = b.Text
+		if b.Kind != KindBody {
+			texts[i] = fmt.Sprintf("[%s] %s", b.Kind, b.Text)
+		}
+	}
+	return strings.Join(texts, "\n\n")
+}
+
+// AnalyzeFile returns the layout of the pages `pageNums` of `inputPath` (all pages if empty).
+func AnalyzeFile(inputPath string, pageNums []int) ([]*Page, error) {
+	pdfReader, f, err := model.NewPdfReaderFromFile(inputPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if len(pageNums) == 0 {
+		numPages, err := pdfReader.GetNumPages()
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < numPages; i++ {
+			pageNums = append(pageNums, i+1)
+		}
+	}
+
+	var pages []*Page
+	for _, pageNum := range pageNums {
+		page, err := pdfReader.GetPage(pageNum)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+		p, err := AnalyzePage(page)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+		p.Page = pageNum
+		pages = append(pages, p)
+	}
+	return pages, nil
+}
+
+// WriteJSON writes the layout `pages` of `inputPath` to `w` as indented JSON.
+func WriteJSON(w io.Writer, inputPath string, pages []*Page) error {
+	doc := struct {
+		File  string  `json:"file"`
+		Pages []*Page `json:"pages"`
+	}{inputPath, pages}
+	if doc.Pages == nil {
+		doc.Pages = []*Page{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// AnalyzePage extracts the text of `page` and returns its layout.
+func AnalyzePage(page *model.PdfPage) (*Page, error) {
+	mbox, err := page.GetMediaBox()
+	if err != nil {
+		return nil, err
+	}
+	ex, err := extractor.New(page)
+	if err != nil {
+		return nil, err
+	}
+	pageText, _, _, err := ex.ExtractPageText()
+	if err != nil {
+		return nil, err
+	}
+	return AnalyzePageText(pageText, *mbox), nil
+}
+
+// AnalyzePageText returns the layout of the text extracted in `pageText` on a page with media
+// box `mediaBox`.
+func AnalyzePageText(pageText *extractor.PageText, mediaBox model.PdfRectangle) *Page {
+	return Analyze(WordsFromMarks(pageText.Marks().Elements()), toBBox(mediaBox))
+}
+
+// WordsFromMarks groups `marks` into words. A word ends at a space, at a mark inserted by the
+// extractor or when the next mark is not adjacent on the same line.
+func WordsFromMarks(marks []extractor.TextMark) []Word {
+	var words []Word
+	var cur *Word
+	for _, tm := range marks {
+		if tm.Meta || strings.TrimSpace(tm.Text) == "" {
+			cur = nil
+			continue
+		}
+		bbox := toBBox(tm.BBox)
+		if cur != nil {
+			gap := bbox[0] - cur.BBox[2]
+			size := math.Max(cur.FontSize, tm.FontSize)
+			if gap > 0.25*size || gap < -size || vOverlap(cur.BBox, bbox) < 0.5 {
+				cur = nil
+			}
+		}
+		if cur == nil {
+			w := Word{BBox: bbox, FontSize: tm.FontSize}
+			if tm.Font != nil {
+				w.Font = tm.Font.BaseFont()
+			}
+			words = append(words, w)
+			cur = &words[len(words)-1]
+		} else {
+			cur.BBox = union(cur.BBox, bbox)
+		}
+		cur.Text += tm.Text
+	}
+	return words
+}
+
+// Analyze returns the layout of `words` on a page with media box `mediaBox`.
+func Analyze(words []Word, mediaBox BBox) *Page {
+	p := &Page{
+		Width:  mediaBox[2] - mediaBox[0],
+		Height: mediaBox[3] - mediaBox[1],
+		Blocks: []Block{},
+
+		mediaBox: mediaBox,
+	}
+	if len(words) == 0 {
+		return p
+	}
+
+	a := analyzer{size: median(words, func(w Word) float64 { return w.FontSize })}
+	if a.size <= 0 {
+		a.size = 10
+	}
+	lines := a.identifyLines(words)
+
+	headers, body, footers := a.splitMargins(lines, mediaBox)
+	for _, l := range headers {
+		p.Blocks = append(p.Blocks, newBlock(KindHeader, 0, []*Line{l}))
+	}
+
+	var blocks, sidebars []Block
+	a.cut(body, 0, false, &blocks, &sidebars)
+	p.Blocks = append(p.Blocks, blocks...)
+	p.Blocks = append(p.Blocks, sidebars...)
+
+	for _, l := range footers {
+		p.Blocks = append(p.Blocks, newBlock(KindFooter, 0, []*Line{l}))
+	}
+	return p
+}
+
+// analyzer holds the measures of a page used by the layout heuristics.
+type analyzer struct {
+	size float64 // Median font size of the page.
+}
+
+// identifyLines groups `words` into rows of vertically overlapping words and splits the rows into
+// lines at gaps wider than a column gutter. The lines are returned from top to bottom.
+func (a analyzer) identifyLines(words []Word) []*Line {
+	var lines []*Line
+	for _, row := range Rows(words) {
+		gutter := 1.2 * math.Max(a.size, median(row, func(w Word) float64 { return w.FontSize }))
+		start := 0
+		for i := 1; i <= len(row); i++ {
+			if i == len(row) || row[i].BBox[0]-row[i-1].BBox[2] > gutter {
+				lines = append(lines, newLine(row[start:i]))
+				start = i
+			}
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool {
+		if lines[i].BBox[3] != lines[j].BBox[3] {
+			return lines[i].BBox[3] > lines[j].BBox[3]
+		}
+		return lines[i].BBox[0] < lines[j].BBox[0]
+	})
+	return lines
+}
+
+// marginBand is the fraction of the page height at the top and bottom where headers and footers
+// are looked for.
+const marginBand = 0.08
+
+// maxMarginLines is the maximum number of lines of a header or a footer.
+const maxMarginLines = 3
+
+// splitMargins separates the header and footer lines from the body lines. Headers (footers) are
+// the lines in the top (bottom) band of the page, not larger than the body text, separated from
+// the body by white space.
+func (a analyzer) splitMargins(lines []*Line, mediaBox BBox) (headers, body, footers []*Line) {
+	height := mediaBox[3] - mediaBox[1]
+	minGap := a.minGapY()
+	small := func(l *Line) bool { return l.FontSize <= 1.1*a.size }
+
+	// Lines are sorted from top to bottom.
+	top := 0
+	for top < len(lines) && top < maxMarginLines && small(lines[top]) && lines[top].BBox[1] >= mediaBox[3]-marginBand*height {
+		top++
+	}
+	for ; top > 0; top-- {
+		if top < len(lines) && lowest(lines[:top])-lines[top].BBox[3] >= minGap {
+			break
+		}
+	}
+
+	bottom := len(lines)
+	for bottom > top && len(lines)-bottom < maxMarginLines && small(lines[bottom-1]) && lines[bottom-1].BBox[3] <= mediaBox[1]+marginBand*height {
+		bottom--
+	}
+	for ; bottom < len(lines); bottom++ {
+		if bottom > top && lowest(lines[top:bottom])-highest(lines[bottom:]) >= minGap {
+			break
+		}
+	}
+
+	return lines[:top], lines[top:bottom], lines[bottom:]
+}
+
+// cut splits the region of `lines` recursively and appends its blocks in reading order to
+// `blocks`, or to `sidebars` for the blocks of narrow columns at the edge of the region.
+func (a analyzer) cut(lines []*Line, column int, sidebar bool, blocks, sidebars *[]Block) {
+	if len(lines) == 0 {
+		return
+	}
+
+	// Columns first: a horizontal gap common to several columns must not split them.
+	if groups := splitX(lines, a.minGapX()); len(groups) > 1 && a.textColumns(groups) {
+		width := extentX(lines)
+		for i, group := range groups {
+			w := extentX(group)
+			narrow := w < 0.25*width && (i == 0 || i == len(groups)-1)
+			a.cut(group, i, sidebar || (narrow && len(groups) > 1 && wider(groups, w)), blocks, sidebars)
+		}
+		return
+	}
+
+	if groups := splitY(lines, a.minGapY()); len(groups) > 1 {
+		for _, group := range groups {
+			a.cut(group, column, sidebar, blocks, sidebars)
+		}
+		return
+	}
+
+	if sidebar {
+		*sidebars = append(*sidebars, newBlock(KindSidebar, column, lines))
+		return
+	}
+	*blocks = append(*blocks, newBlock(KindBody, column, lines))
+}
+
+// textColumns returns true if `groups` look like columns of running text rather than the columns
+// of a table or a form: every group has lines of several words and one has long lines.
+func (a analyzer) textColumns(groups [][]*Line) bool {
+	long := false
+	for _, group := range groups {
+		words := 0
+		for _, l := range group {
+			words += len(l.Words)
+		}
+		avg := float64(words) / float64(len(group))
+		if avg < 2 {
+			return false
+		}
+		if avg >= 4 {
+			long = true
+		}
+	}
+	return long
+}
+
+// minGapX is the minimum width of a column gutter.
+func (a analyzer) minGapX() float64 {
+	return a.size
+}
+
+// minGapY is the minimum height of the white space between two blocks.
+func (a analyzer) minGapY() float64 {
+	return 0.6 * a.size
+}
+
+// splitX splits `lines` at the vertical white space strips at least `minGap` wide.
+func splitX(lines []*Line, minGap float64) [][]*Line {
+	sorted := make([]*Line, len(lines))
+	copy(sorted, lines)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].BBox[0] < sorted[j].BBox[0] })
+
+	var groups [][]*Line
+	right := math.Inf(-1)
+	for _, l := range sorted {
+		if len(groups) == 0 || l.BBox[0]-right >= minGap {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], l)
+		right = math.Max(right, l.BBox[2])
+	}
+	for _, group := range groups {
+		sortTopDown(group)
+	}
+	return groups
+}
+
+// splitY splits `lines` at the horizontal white space strips at least `minGap` high.
+func splitY(lines []*Line, minGap float64) [][]*Line {
+	sorted := make([]*Line, len(lines))
+	copy(sorted, lines)
+	sortTopDown(sorted)
+
+	var groups [][]*Line
+	bottom := math.Inf(1)
+	for _, l := range sorted {
+		if len(groups) == 0 || bottom-l.BBox[3] >= minGap {
+			groups = append(groups, nil)
+		}
+		groups[len(groups)-1] = append(groups[len(groups)-1], l)
+		bottom = math.Min(bottom, l.BBox[1])
+	}
+	return groups
+}
+
+// newLine returns the line made of `words`.
+func newLine(words []Word) *Line {
+	l := &Line{Words: words, BBox: words[0].BBox}
+	texts := make([]string, len(words))
+	for i, w := range words {
+		texts[i] = w.Text
+		l.BBox = union(l.BBox, w.BBox)
+	}
+	l.Text = strings.Join(texts, " ")
+	l.FontSize = median(words, func(w Word) float64 { return w.FontSize })
+	return l
+}
+
+// newBlock returns a block of `kind` made of `lines`, sorted from top to bottom.
+func newBlock(kind Kind, column int, lines []*Line) Block {
+	sortTopDown(lines)
+	b := Block{Kind: kind, Column: column, BBox: lines[0].BBox}
+	texts := make([]string, len(lines))
+	for i, l := range lines {
+		b.Lines = append(b.Lines, *l)
+		b.BBox = union(b.BBox, l.BBox)
+		texts[i] = l.Text
+	}
+	b.Text = strings.Join(texts, "\n")
+	return b
+}
+
+// sortTopDown sorts `lines` from top to bottom and left to right.
+func sortTopDown(lines []*Line) {
+	sort.SliceStable(lines, func(i, j int) bool {
+		if math.Abs(centerY(lines[i].BBox)-centerY(lines[j].BBox)) > 0.5 {
+			return centerY(lines[i].BBox) > centerY(lines[j].BBox)
+		}
+		return lines[i].BBox[0] < lines[j].BBox[0]
+	})
+}
+
+// wider returns true if one of `groups` is at least twice as wide as `width`.
+func wider(groups [][]*Line, width float64) bool {
+	for _, group := range groups {
+		if extentX(group) >= 2*width {
+			return true
+		}
+	}
+	return false
+}
+
+// extentX returns the width covered by `lines`.
+func extentX(lines []*Line) float64 {
+	left, right := math.Inf(1), math.Inf(-1)
+	for _, l := range lines {
+		left = math.Min(left, l.BBox[0])
+		right = math.Max(right, l.BBox[2])
+	}
+	return right - left
+}
+
+// lowest returns the lowest bottom edge of `lines`.
+func lowest(lines []*Line) float64 {
+	y := math.Inf(1)
+	for _, l := range lines {
+		y = math.Min(y, l.BBox[1])
+	}
+	return y
+}
+
+// highest returns the highest top edge of `lines`.
+func highest(lines []*Line) float64 {
+	y := math.Inf(-1)
+	for _, l := range lines {
+		y = math.Max(y, l.BBox[3])
+	}
+	return y
+}
+
+// vOverlap returns the height of the vertical overlap of `a` and `b` as a fraction of the smaller
+// of their heights.
+func vOverlap(a, b BBox) float64 {
+	h := math.Min(a[3]-a[1], b[3]-b[1])
+	if h <= 0 {
+		return 0
+	}
+	return (math.Min(a[3], b[3]) - math.Max(a[1], b[1])) / h
+}
+
+// median returns the median of `f` over `items`.
+func median[T any](items []T, f func(T) float64) float64 {
+	if len(items) == 0 {
+		return 0
+	}
+	values := make([]float64, len(items))
+	for i, item := range items {
+		values[i] = f(item)
+	}
+	sort.Float64s(values)
+	return values[len(values)/2]
+}
+
+func centerY(b BBox) float64 {
+	return (b[1] + b[3]) / 2
+}
+
+func union(a, b BBox) BBox {
+	return BBox{math.Min(a[0], b[0]), math.Min(a[1], b[1]), math.Max(a[2], b[2]), math.Max(a[3], b[3])}
+}
+
+func containsKind(kinds []Kind, kind Kind) bool {
+	for _, k := range kinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// toBBox converts `r` to a BBox rounded to 1/100 point.
+func toBBox(r model.PdfRectangle) BBox {
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	return BBox{round(r.Llx), round(r.Lly), round(r.Urx), round(r.Ury)}
+}
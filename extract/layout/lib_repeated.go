@@ -0,0 +1,366 @@
+/*
+ * Detection of the running headers, footers and page numbers of a document: lines repeated at
+ * the same position on many pages, ignoring the digits so that "Page 3 of 10" and "Page 4 of 10"
+ * are the same line.
+ */
+
+package layout
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// KindPageNumber is the kind of the blocks holding a page number.
+const KindPageNumber Kind = "page_number"
+
+// RepeatedOptions configures the detection of repeated lines.
+type RepeatedOptions struct {
+	// MinFraction is the minimum fraction of the analyzed pages a line must be repeated on.
+	// Defaults to 0.4, low enough for headers alternating between even and odd pages.
+	MinFraction float64
+	// MinPages is the minimum number of pages a line must be repeated on. Defaults to 2.
+	MinPages int
+	// Margin is the fraction of the page height at the top and at the bottom of the page where
+	// repeated lines are looked for. Defaults to 0.2, use 0.5 for the whole page.
+	Margin float64
+	// Tolerance is the maximum distance in points between the positions of a repeated line.
+	// Defaults to 4.
+	Tolerance float64
+	// SamplePages is the maximum number of pages analyzed by DetectRepeatedInReader, evenly
+	// spread over the document. Defaults to 40.
+	SamplePages int
+}
+
+// Repeated is a line repeated at the same position on several pages.
+type Repeated struct {
+	Kind  Kind   `json:"kind"`
+	Key   string `json:"key"`  // Text of the line, lower case with numbers replaced by "#".
+	Text  string `json:"text"` // Text of the first occurrence.
+	BBox  BBox   `json:"bbox"` // Bounding box of the first occurrence.
+	Pages []int  `json:"pages"`
+}
+
+// RepeatedSet holds the repeated lines of a document.
+type RepeatedSet struct {
+	Items     []Repeated
+	tolerance float64
+}
+
+// withDefaults returns `opts` with the zero fields set to their default values.
+func (opts RepeatedOptions) withDefaults() RepeatedOptions {
+	if opts.MinFraction <= 0 {
+		opts.MinFraction = 0.4
+	}
+	if opts.MinPages <= 0 {
+		opts.MinPages = 2
+	}
+	if opts.Margin <= 0 {
+		opts.Margin = 0.2
+	}
+	if opts.Tolerance <= 0 {
+		opts.Tolerance = 4
+	}
+	if opts.SamplePages <= 0 {
+		opts.SamplePages = 40
+	}
+	return opts
+}
+
+// DetectRepeated finds the lines repeated at the same position on `pages`. Pages without a page
+// number are numbered by their position in `pages`.
+func DetectRepeated(pages []*Page, opts RepeatedOptions) *RepeatedSet {
+	opts = opts.withDefaults()
+	set := &RepeatedSet{tolerance: opts.Tolerance}
+
+	// Lines with the same key are only repeated if their text is the same on all pages or if one
+	// of their numbers follows the page number, e.g. "Chapter 2, page 14".
+	type candidate struct {
+		Repeated
+		varies  bool
+		offsets []int // Differences between the numbers of the line and the page number.
+	}
+	var candidates []candidate
+	for pageIdx, p := range pages {
+		pageNum := p.Page
+		if pageNum == 0 {
+			pageNum = pageIdx + 1
+		}
+		for _, b := range p.Blocks {
+			for _, l := range b.Lines {
+				if !inMargin(l.BBox, p, opts.Margin) {
+					continue
+				}
+				key := repeatedKey(l.Text)
+				if key == "" {
+					continue
+				}
+				offsets := numberOffsets(l.Text, pageNum)
+
+				i := -1
+				for j := range candidates {
+					if set.matches(candidates[j].Repeated, key, l.BBox) {
+						i = j
+						break
+					}
+				}
+				if i < 0 {
+					candidates = append(candidates, candidate{
+						Repeated: Repeated{Key: key, Text: l.Text, BBox: l.BBox},
+						offsets:  offsets,
+					})
+					i = len(candidates) - 1
+				}
+				c := &candidates[i]
+				if n := len(c.Pages); n > 0 && c.Pages[n-1] == pageNum {
+					continue
+				}
+				c.Pages = append(c.Pages, pageNum)
+				c.varies = c.varies || l.Text != c.Text
+				c.offsets = intersect(c.offsets, offsets)
+			}
+		}
+	}
+
+	minPages := max(opts.MinPages, int(math.Ceil(opts.MinFraction*float64(len(pages)))))
+	for _, cand := range candidates {
+		c := cand.Repeated
+		if len(c.Pages) < minPages {
+			continue
+		}
+		if cand.varies && len(cand.offsets) == 0 && !rePageNumber.MatchString(c.Key) {
+			continue
+		}
+		switch {
+		case rePageNumber.MatchString(c.Key):
+			c.Kind = KindPageNumber
+		case centerY(c.BBox) > pageMiddle(pages):
+			c.Kind = KindHeader
+		default:
+			c.Kind = KindFooter
+		}
+		set.Items = append(set.Items, c)
+	}
+	return set
+}
+
+// DetectRepeatedInReader finds the lines repeated at the same position on the pages `pageNums`
+// of `pdfReader` (all pages if empty). At most opts.SamplePages pages, evenly spread over the
+// pages, are analyzed. Numbers are ignored when comparing lines so that the result applies to the
+// pages that were not analyzed.
+func DetectRepeatedInReader(pdfReader *model.PdfReader, pageNums []int, opts RepeatedOptions) (*RepeatedSet, error) {
+	opts = opts.withDefaults()
+	if len(pageNums) == 0 {
+		numPages, err := pdfReader.GetNumPages()
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < numPages; i++ {
+			pageNums = append(pageNums, i+1)
+		}
+	}
+
+	sample := pageNums
+	if len(pageNums) > opts.SamplePages {
+		sample = make([]int, opts.SamplePages)
+		for i := range sample {
+			sample[i] = pageNums[i*len(pageNums)/opts.SamplePages]
+		}
+	}
+
+	var pages []*Page
+	for _, pageNum := range sample {
+		page, err := pdfReader.GetPage(pageNum)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+		p, err := AnalyzePage(page)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+		p.Page = pageNum
+		pages = append(pages, p)
+	}
+	return DetectRepeated(pages, opts), nil
+}
+
+// Match returns the repeated line matching `line` on a page.
+func (s *RepeatedSet) Match(line Line) (Repeated, bool) {
+	if s == nil {
+		return Repeated{}, false
+	}
+	key := repeatedKey(line.Text)
+	for _, item := range s.Items {
+		if s.matches(item, key, line.BBox) {
+			return item, true
+		}
+	}
+	return Repeated{}, false
+}
+
+// Tag moves the lines of `p` matching a repeated line into blocks of their own with the kind of
+// the repeated line. Headers and page numbers at the top of the page are placed first, footers
+// and page numbers at the bottom last.
+func (s *RepeatedSet) Tag(p *Page) {
+	if s == nil || len(s.Items) == 0 {
+		return
+	}
+
+	var head, body, tail []Block
+	for _, b := range p.Blocks {
+		var rest []*Line
+		for i := range b.Lines {
+			l := &b.Lines[i]
+			r, ok := s.Match(*l)
+			if !ok {
+				rest = append(rest, l)
+				continue
+			}
+			tagged := newBlock(r.Kind, 0, []*Line{l})
+			if centerY(l.BBox) > p.middle() {
+				head = append(head, tagged)
+			} else {
+				tail = append(tail, tagged)
+			}
+		}
+		if len(rest) == 0 {
+			continue
+		}
+		if len(rest) < len(b.Lines) {
+			b = newBlock(b.Kind, b.Column, rest)
+		}
+		switch b.Kind {
+		case KindHeader:
+			head = append(head, b)
+		case KindFooter:
+			tail = append(tail, b)
+		default:
+			body = append(body, b)
+		}
+	}
+
+	blocks := make([]Block, 0, len(head)+len(body)+len(tail))
+	blocks = append(blocks, head...)
+	blocks = append(blocks, body...)
+	blocks = append(blocks, tail...)
+	p.Blocks = blocks
+}
+
+// Strip removes the lines of `p` matching a repeated line, and the blocks left empty. The other
+// lines are kept, including the headers and footers found by the layout analysis of `p` alone.
+func (s *RepeatedSet) Strip(p *Page) {
+	if s == nil || len(s.Items) == 0 {
+		return
+	}
+
+	blocks := p.Blocks[:0]
+	for _, b := range p.Blocks {
+		var rest []*Line
+		for i := range b.Lines {
+			if _, ok := s.Match(b.Lines[i]); !ok {
+				rest = append(rest, &b.Lines[i])
+			}
+		}
+		if len(rest) == 0 {
+			continue
+		}
+		if len(rest) < len(b.Lines) {
+			b = newBlock(b.Kind, b.Column, rest)
+		}
+		blocks = append(blocks, b)
+	}
+	p.Blocks = blocks
+}
+
+// matches returns true if `item` has key `key` and is at the position of `bbox`. Positions match
+// if the vertical centers are within tolerance and the lines are left, right or center aligned
+// within tolerance.
+func (s *RepeatedSet) matches(item Repeated, key string, bbox BBox) bool {
+	tol := s.tolerance
+	if item.Key != key || math.Abs(centerY(item.BBox)-centerY(bbox)) > tol {
+		return false
+	}
+	b := item.BBox
+	return math.Abs(b[0]-bbox[0]) <= tol || math.Abs(b[2]-bbox[2]) <= tol ||
+		math.Abs((b[0]+b[2])/2-(bbox[0]+bbox[2])/2) <= tol
+}
+
+// numberOffsets returns the differences between the numbers in `text` and `pageNum`.
+func numberOffsets(text string, pageNum int) []int {
+	var offsets []int
+	for _, m := range reNumbers.FindAllString(text, -1) {
+		if n, err := strconv.Atoi(m); err == nil {
+			offsets = append(offsets, n-pageNum)
+		}
+	}
+	return offsets
+}
+
+// intersect returns the values of `a` that are in `b`.
+func intersect(a, b []int) []int {
+	var common []int
+	for _, v := range a {
+		if slices.Contains(b, v) {
+			common = append(common, v)
+		}
+	}
+	return common
+}
+
+var (
+	reNumbers = regexp.MustCompile(`\d+`)
+	reRoman   = regexp.MustCompile(`^(?i)m{0,3}(cm|cd|d?c{0,3})(xc|xl|l?x{0,3})(ix|iv|v?i{0,3})$`)
+
+	// rePageNumber matches the keys of page numbers, such as "#", "- # -", "page # of #" or "#/#".
+	rePageNumber = regexp.MustCompile(`^(page|pg\.?|p\.|seite|página|pagina)?\s*[-–—(\[]?\s*#\s*[-–—)\]]?\s*((of|/|von|de|sur)\s*#)?$`)
+)
+
+// repeatedKey returns the text of a line used to compare lines: lower case, with numbers and
+// roman numerals replaced by "#".
+func repeatedKey(text string) string {
+	fields := strings.Fields(strings.ToLower(text))
+	if len(fields) <= 4 {
+		// Roman numerals are only looked for in short lines to leave words like "mix" alone.
+		for i, f := range fields {
+			if f := strings.Trim(f, ".-–—()[]"); f != "" && reRoman.MatchString(f) {
+				fields[i] = "#"
+			}
+		}
+	}
+	return reNumbers.ReplaceAllString(strings.Join(fields, " "), "#")
+}
+
+// inMargin returns true if `bbox` lies in the top or bottom `margin` fraction of page `p`.
+func inMargin(bbox BBox, p *Page, margin float64) bool {
+	top, bottom := p.top(), p.top()-p.Height
+	return bbox[1] >= top-margin*p.Height || bbox[3] <= bottom+margin*p.Height
+}
+
+// top returns the top edge of the page. Pages analyzed without their media box origin are assumed
+// to start at 0.
+func (p *Page) top() float64 {
+	if p.mediaBox != (BBox{}) {
+		return p.mediaBox[3]
+	}
+	return p.Height
+}
+
+// middle returns the vertical center of the page.
+func (p *Page) middle() float64 {
+	return p.top() - p.Height/2
+}
+
+// pageMiddle returns the average vertical center of `pages`.
+func pageMiddle(pages []*Page) float64 {
+	sum := 0.0
+	for _, p := range pages {
+		sum += p.middle()
+	}
+	return sum / float64(len(pages))
+}
@@ -0,0 +1,122 @@
+/*
+ * Grouping of the words of a page into the rows and columns of a table, as used by
+ * text/pdf_to_csv.go to convert tables to CSV.
+ */
+
+package layout
+
+import (
+	"math"
+	"sort"
+	"strings"
+)
+
+// Rows groups `words` into rows of vertically overlapping words. The rows are returned from top
+// to bottom with their words from left to right.
+func Rows(words []Word) [][]Word {
+	sorted := make([]Word, len(words))
+	copy(sorted, words)
+	sort.SliceStable(sorted, func(i, j int) bool { return centerY(sorted[i].BBox) > centerY(sorted[j].BBox) })
+
+	// Rows of words, with the bounding box of each row.
+	var rows [][]Word
+	var rowBoxes []BBox
+	for _, w := range sorted {
+		match := -1
+		for i := len(rows) - 1; i >= 0; i-- {
+			if rowBoxes[i][1] > w.BBox[3] {
+				break // The remaining rows are above the word.
+			}
+			if vOverlap(rowBoxes[i], w.BBox) >= 0.5 {
+				match = i
+				break
+			}
+		}
+		if match < 0 {
+			rows = append(rows, []Word{w})
+			rowBoxes = append(rowBoxes, w.BBox)
+			continue
+		}
+		rows[match] = append(rows[match], w)
+		rowBoxes[match] = union(rowBoxes[match], w.BBox)
+	}
+
+	for _, row := range rows {
+		sort.SliceStable(row, func(i, j int) bool { return row[i].BBox[0] < row[j].BBox[0] })
+	}
+	return rows
+}
+
+// Columns returns the bounding boxes of the columns of the table made of `words`, from left to
+// right. A word joins the column whose first word it overlaps most horizontally, and adjacent
+// columns overlapping each other are merged.
+func Columns(words []Word) []BBox {
+	// First word and bounding box of each column.
+	var firsts, boxes []BBox
+	for _, w := range words {
+		best, bestOverlap := -1, 0.0
+		for i, first := range firsts {
+			if overlap := columnOverlap(w.BBox, first); overlap < bestOverlap {
+				best, bestOverlap = i, overlap
+			}
+		}
+		if best < 0 {
+			firsts = append(firsts, w.BBox)
+			boxes = append(boxes, w.BBox)
+			continue
+		}
+		boxes[best] = union(boxes[best], w.BBox)
+	}
+
+	order := make([]int, len(boxes))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return firsts[order[i]][0] < firsts[order[j]][0] })
+
+	var columns []BBox
+	for i := 0; i < len(order); {
+		column := boxes[order[i]]
+		j := i + 1
+		for ; j < len(order) && columnOverlap(column, boxes[order[j]]) <= 0; j++ {
+			column = union(column, boxes[order[j]])
+		}
+		columns = append(columns, column)
+		i = j
+	}
+	return columns
+}
+
+// TableCells returns the text of the cells of `rows` in the columns `columns`. Each word goes to
+// the column it overlaps most horizontally and the words of a cell are separated by spaces.
+func TableCells(rows [][]Word, columns []BBox) [][]string {
+	cells := make([][]string, len(rows))
+	for i, row := range rows {
+		texts := make([][]string, len(columns))
+		for _, w := range row {
+			best, bestOverlap := -1, math.Inf(1)
+			for j, column := range columns {
+				if overlap := columnOverlap(w.BBox, column); overlap < bestOverlap {
+					best, bestOverlap = j, overlap
+				}
+			}
+			if best >= 0 {
+				texts[best] = append(texts[best], w.Text)
+			}
+		}
+		cells[i] = make([]string, len(columns))
+		for j, words := range texts {
+			cells[i][j] = strings.Join(words, " ")
+		}
+	}
+	return cells
+}
+
+// columnOverlap measures the horizontal overlap of `a` and `b`: 0 when they are exactly next to
+// each other, negative when they overlap and positive when they are apart.
+func columnOverlap(a, b BBox) float64 {
+	u := union(a, b)
+	width := u[2] - u[0]
+	widths := (a[2] - a[0]) + (b[2] - b[0])
+	return (width - widths) / (width + widths)
+}
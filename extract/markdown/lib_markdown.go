@@ -0,0 +1,502 @@
+/*
+ * PDF to Markdown conversion: headings are inferred from the font size and weight of the text
+ * lines, bullet and numbered lists come from the extractor list detection, tables are written as
+ * GitHub Flavored Markdown tables and the remaining text as paragraphs in reading order.
+ */
+
+package markdown
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/unidoc/unidoc-examples/extract/extractops"
+	"github.com/unidoc/unidoc-examples/extract/layout"
+	"github.com/unidoc/unidoc-examples/extract/tables"
+	"github.com/unidoc/unipdf/v4/extractor"
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// Options configures the conversion.
+type Options struct {
+	// MaxHeadingLevel is the deepest heading level given to text larger than the body text.
+	// Larger fonts get lower levels. Bold lines in the body font size get the next level.
+	// Defaults to 3.
+	MaxHeadingLevel int
+	// PageMarkers adds a `<!-- page N -->` comment before the content of each page.
+	PageMarkers bool
+	// DisableDocumentTags makes the list detection ignore the document structure tags and rely on
+	// the list markers found in the text only.
+	DisableDocumentTags bool
+	// Layout orders the text with the layout analyzer, reading multi-column pages column by
+	// column, and leaves out the running headers and footers.
+	Layout bool
+}
+
+// Convert converts the pages `pageNums` of `inputPath` (all pages if empty) to Markdown and
+// writes it to `w`.
+func Convert(w io.Writer, inputPath string, pageNums []int, opts Options) error {
+	pdfReader, f, err := model.NewPdfReaderFromFile(inputPath, nil)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if len(pageNums) == 0 {
+		numPages, err := pdfReader.GetNumPages()
+		if err != nil {
+			return err
+		}
+		for i := 0; i < numPages; i++ {
+			pageNums = append(pageNums, i+1)
+		}
+	}
+
+	var pages []*pageLayout
+	for _, pageNum := range pageNums {
+		pl, err := analyzePage(pdfReader, pageNum, opts)
+		if err != nil {
+			return fmt.Errorf("page %d: %w", pageNum, err)
+		}
+		pages = append(pages, pl)
+	}
+
+	// The heading levels depend on the font sizes used in the whole document.
+	styles := newDocStyles(pages, opts)
+
+	for i, pl := range pages {
+		if i > 0 {
+			if _, err := io.WriteString(w, "\n"); err != nil {
+				return err
+			}
+		}
+		if _, err := io.WriteString(w, pl.render(styles, opts)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pageLayout is the text of a page split into lines annotated with the information needed to
+// build Markdown blocks.
+type pageLayout struct {
+	pageNum   int
+	lines     []textLine
+	tables    []tables.Table
+	listItems []listItem
+}
+
+// textLine is a line of text in reading order.
+type textLine struct {
+	text     string
+	bbox     extractops.BBox
+	size     float64 // Median font size of the line marks.
+	bold     bool    // Most marks of the line use a bold font.
+	table    int     // Index of the table containing the line or -1.
+	listItem int     // Index of the list item containing the line or -1.
+	newBlock bool    // The line starts a block found by the layout analyzer.
+}
+
+// listItem is an item of a list detected by the extractor.
+type listItem struct {
+	depth  int
+	marker string
+	text   string
+}
+
+// analyzePage extracts the lines, tables and lists of page `pageNum` of `pdfReader`.
+func analyzePage(pdfReader *model.PdfReader, pageNum int, opts Options) (*pageLayout, error) {
+	page, err := pdfReader.GetPage(pageNum)
+	if err != nil {
+		return nil, err
+	}
+
+	ex, err := extractor.NewWithOptions(page, &extractor.Options{DisableDocumentTags: opts.DisableDocumentTags})
+	if err != nil {
+		return nil, err
+	}
+	pageText, _, _, err := ex.ExtractPageText()
+	if err != nil {
+		return nil, err
+	}
+
+	pl := &pageLayout{pageNum: pageNum}
+	for _, textTable := range pageText.Tables() {
+		pl.tables = append(pl.tables, tables.FromTextTable(pageNum, textTable))
+	}
+	var listLines map[string]int
+	pl.listItems, listLines = parseLists(pageText.List().Text())
+
+	lines, err := pageLines(page, pageText, opts)
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range lines {
+		for i, t := range pl.tables {
+			if containsCenter(t.BBox, line.bbox) {
+				line.table = i
+				break
+			}
+		}
+		if item, ok := listLines[collapseSpaces(line.text)]; ok && line.table < 0 {
+			line.listItem = item
+		}
+		pl.lines = append(pl.lines, line)
+	}
+	return pl, nil
+}
+
+// pageLines returns the lines of `pageText` in the extractor order or, with opts.Layout, in the
+// reading order of the layout analyzer.
+func pageLines(page *model.PdfPage, pageText *extractor.PageText, opts Options) ([]textLine, error) {
+	var lines []textLine
+	if opts.Layout {
+		mbox, err := page.GetMediaBox()
+		if err != nil {
+			return nil, err
+		}
+		for _, b := range layout.AnalyzePageText(pageText, *mbox).Blocks {
+			if b.Kind == layout.KindHeader || b.Kind == layout.KindFooter {
+				continue
+			}
+			for i, l := range b.Lines {
+				fonts := make([]string, len(l.Words))
+				for j, w := range l.Words {
+					fonts[j] = w.Font
+				}
+				lines = append(lines, textLine{
+					text:     l.Text,
+					bbox:     extractops.BBox(l.BBox),
+					size:     l.FontSize,
+					bold:     isBold(fonts),
+					table:    -1,
+					listItem: -1,
+					newBlock: i == 0,
+				})
+			}
+		}
+		return lines, nil
+	}
+
+	sp := extractops.StructurePageText(pageText)
+	for _, l := range sp.Lines {
+		marks := sp.Marks[sp.Words[l.WordStart].MarkStart:sp.Words[l.WordEnd-1].MarkEnd]
+		sizes := make([]float64, len(marks))
+		fonts := make([]string, len(marks))
+		for i, m := range marks {
+			sizes[i] = m.FontSize
+			fonts[i] = m.Font
+		}
+		lines = append(lines, textLine{
+			text:     strings.TrimSpace(l.Text),
+			bbox:     l.BBox,
+			size:     median(sizes),
+			bold:     isBold(fonts),
+			table:    -1,
+			listItem: -1,
+		})
+	}
+	return lines, nil
+}
+
+// reListMarker matches a list item: a bullet, a (multilevel) number, a letter or a roman numeral
+// followed by the item text.
+var reListMarker = regexp.MustCompile(`^([•◦▪▫●○■□‣⁃∙·*\-–]|\(?(?:\d+\.)*\d+[.)]|\(?[a-zA-Z][.)]|\(?[ivxlcdmIVXLCDM]+[.)])\s+(.*)$`)
+
+// reNumberMarker matches numbered list markers and captures their last number.
+var reNumberMarker = regexp.MustCompile(`^(?:\d+\.)*(\d+)[.)]$`)
+
+// parseLists parses the text representation of the extractor lists, where each item starts with
+// its marker, is indented by 3 spaces per nesting level and may continue on the next lines.
+// It returns the items and maps the lines of the text, with collapsed spaces, to their item.
+func parseLists(text string) ([]listItem, map[string]int) {
+	var items []listItem
+	lines := map[string]int{}
+	for _, line := range strings.Split(text, "\n") {
+		content := strings.TrimSpace(line)
+		if content == "" {
+			continue
+		}
+		indent := len(line) - len(strings.TrimLeft(line, " "))
+
+		if m := reListMarker.FindStringSubmatch(content); m != nil {
+			items = append(items, listItem{depth: indent / 3, marker: m[1], text: m[2]})
+		} else if len(items) > 0 {
+			items[len(items)-1].text += " " + content
+		} else {
+			items = append(items, listItem{depth: indent / 3, text: content})
+		}
+		lines[collapseSpaces(content)] = len(items) - 1
+	}
+	return items, lines
+}
+
+// docStyles maps the font sizes of a document to heading levels.
+type docStyles struct {
+	bodySize      float64
+	levels        map[float64]int // Heading level of the font sizes larger than the body text.
+	boldLevel     int             // Heading level of the bold lines in the body font size.
+	maxHeadingLen int             // Maximum length of a heading in characters.
+}
+
+// newDocStyles finds the body font size of `pages`, the size used by most characters outside
+// tables, and gives a heading level to each larger font size.
+func newDocStyles(pages []*pageLayout, opts Options) docStyles {
+	maxLevel := opts.MaxHeadingLevel
+	if maxLevel <= 0 {
+		maxLevel = 3
+	}
+	styles := docStyles{levels: map[float64]int{}, maxHeadingLen: 200}
+
+	chars := map[float64]int{}
+	for _, pl := range pages {
+		for _, line := range pl.lines {
+			if line.table < 0 {
+				chars[roundSize(line.size)] += utf8.RuneCountInString(line.text)
+			}
+		}
+	}
+	for size, n := range chars {
+		if n > chars[styles.bodySize] || (n == chars[styles.bodySize] && size < styles.bodySize) {
+			styles.bodySize = size
+		}
+	}
+
+	var sizes []float64
+	for size := range chars {
+		if size >= styles.bodySize*1.15 {
+			sizes = append(sizes, size)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(sizes)))
+	for i, size := range sizes {
+		styles.levels[size] = min(i+1, maxLevel)
+	}
+	styles.boldLevel = min(len(sizes)+1, maxLevel+1, 6)
+	return styles
+}
+
+// headingLevel returns the heading level of `line` or 0 if it is not a heading.
+func (s docStyles) headingLevel(line textLine) int {
+	if line.table >= 0 || line.listItem >= 0 || utf8.RuneCountInString(line.text) > s.maxHeadingLen {
+		return 0
+	}
+	if level, ok := s.levels[roundSize(line.size)]; ok {
+		return level
+	}
+	if line.bold && math.Abs(line.size-s.bodySize) < 1 && !strings.ContainsAny(lastRune(line.text), ".,;:") {
+		return s.boldLevel
+	}
+	return 0
+}
+
+// render returns the Markdown of the page.
+func (p *pageLayout) render(styles docStyles, opts Options) string {
+	var blocks []string
+	if opts.PageMarkers {
+		blocks = append(blocks, fmt.Sprintf("<!-- page %d -->", p.pageNum))
+	}
+
+	emittedTables := map[int]bool{}
+	for i := 0; i < len(p.lines); {
+		line := p.lines[i]
+		switch {
+		case line.table >= 0:
+			if !emittedTables[line.table] {
+				emittedTables[line.table] = true
+				blocks = append(blocks, renderTable(p.tables[line.table]))
+			}
+			i++
+
+		case line.listItem >= 0:
+			var items []int
+			for ; i < len(p.lines) && p.lines[i].listItem >= 0; i++ {
+				if item := p.lines[i].listItem; len(items) == 0 || items[len(items)-1] != item {
+					items = append(items, item)
+				}
+			}
+			blocks = append(blocks, p.renderList(items))
+
+		case styles.headingLevel(line) > 0:
+			level := styles.headingLevel(line)
+			text := line.text
+			for i++; i < len(p.lines) && styles.headingLevel(p.lines[i]) == level && !newBlock(p.lines[i-1], p.lines[i]); i++ {
+				text += " " + p.lines[i].text
+			}
+			blocks = append(blocks, strings.Repeat("#", level)+" "+escapeInline(text))
+
+		default:
+			text := line.text
+			for i++; i < len(p.lines); i++ {
+				next := p.lines[i]
+				if next.table >= 0 || next.listItem >= 0 || styles.headingLevel(next) > 0 || newBlock(p.lines[i-1], next) {
+					break
+				}
+				text = joinLines(text, next.text)
+			}
+			blocks = append(blocks, escapeBlock(text))
+		}
+	}
+
+	if len(blocks) == 0 {
+		return ""
+	}
+	return strings.Join(blocks, "\n\n") + "\n"
+}
+
+// renderList returns the list items `items` of the page as a Markdown list. Nested items are
+// indented by 4 spaces per level, which is deep enough for both bullet and numbered parents.
+func (p *pageLayout) renderList(items []int) string {
+	minDepth := math.MaxInt
+	for _, i := range items {
+		minDepth = min(minDepth, p.listItems[i].depth)
+	}
+
+	var lines []string
+	for _, i := range items {
+		item := p.listItems[i]
+		indent := strings.Repeat("    ", item.depth-minDepth)
+		marker := "- "
+		if m := reNumberMarker.FindStringSubmatch(item.marker); m != nil {
+			marker = m[1] + ". "
+		} else if item.marker != "" && utf8.RuneCountInString(item.marker) > 1 {
+			// Letters and roman numerals have no Markdown equivalent, keep them in the text.
+			marker = "- " + item.marker + " "
+		}
+		lines = append(lines, indent+marker+escapeInline(item.text))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderTable returns `t` as a GitHub Flavored Markdown table. The first row is used as the
+// header row, as GFM tables require one. Merged cells keep their text in the first position.
+func renderTable(t tables.Table) string {
+	grid := t.Strings()
+	if len(grid) == 0 || t.Cols == 0 {
+		return ""
+	}
+
+	row := func(cells []string) string {
+		escaped := make([]string, len(cells))
+		for i, cell := range cells {
+			escaped[i] = strings.ReplaceAll(escapeInline(cell), "|", `\|`)
+		}
+		return "| " + strings.Join(escaped, " | ") + " |"
+	}
+
+	lines := []string{row(grid[0])}
+	sep := make([]string, t.Cols)
+	for i := range sep {
+		sep[i] = "---"
+	}
+	lines = append(lines, "| "+strings.Join(sep, " | ")+" |")
+	for _, cells := range grid[1:] {
+		lines = append(lines, row(cells))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// newBlock returns true if `line` starts a new paragraph after `prev`: it starts a layout block,
+// there is a vertical gap larger than the usual line spacing, the text moved up (a new column) or
+// the font size changed.
+func newBlock(prev, line textLine) bool {
+	if line.newBlock {
+		return true
+	}
+	size := math.Max(prev.size, line.size)
+	gap := prev.bbox[1] - line.bbox[3]
+	return gap > 0.8*size || gap < -size || math.Abs(prev.size-line.size) > 1
+}
+
+// joinLines joins the lines of a paragraph, removing the hyphen of words broken over two lines.
+func joinLines(text, next string) string {
+	if strings.HasSuffix(text, "-") && len(text) > 1 {
+		before, _ := utf8.DecodeLastRuneInString(text[:len(text)-1])
+		after, _ := utf8.DecodeRuneInString(next)
+		if unicode.IsLetter(before) && unicode.IsLower(after) {
+			return text[:len(text)-1] + next
+		}
+	}
+	return text + " " + next
+}
+
+// reBlockStart matches text that Markdown would read as a heading, quote, bullet or rule and
+// reOrderedStart text that it would read as a numbered list item.
+var (
+	reBlockStart   = regexp.MustCompile(`^(#|>|[-+]\s|[-=]{3,}$)`)
+	reOrderedStart = regexp.MustCompile(`^(\d+)([.)]\s)`)
+)
+
+// escapeBlock escapes `text` so that it is read as a plain paragraph.
+func escapeBlock(text string) string {
+	text = escapeInline(text)
+	if reBlockStart.MatchString(text) {
+		return `\` + text
+	}
+	return reOrderedStart.ReplaceAllString(text, `$1\$2`)
+}
+
+// escapeInline escapes the characters that Markdown interprets for emphasis, code and links.
+func escapeInline(text string) string {
+	return inlineEscaper.Replace(text)
+}
+
+var inlineEscaper = strings.NewReplacer(`\`, `\\`, "*", `\*`, "_", `\_`, "`", "\\`", "[", `\[`, "]", `\]`, "<", `\<`)
+
+// median returns the median of `values`.
+func median(values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+	sort.Float64s(values)
+	return values[len(values)/2]
+}
+
+// boldNames are parts of font names of bold fonts.
+var boldNames = []string{"bold", "black", "heavy", "semibold", "demi"}
+
+// isBold returns true if most of the font names `fonts` are names of bold fonts.
+func isBold(fonts []string) bool {
+	bold := 0
+	for _, font := range fonts {
+		name := strings.ToLower(font)
+		for _, b := range boldNames {
+			if strings.Contains(name, b) {
+				bold++
+				break
+			}
+		}
+	}
+	return len(fonts) > 0 && bold*5 >= len(fonts)*4
+}
+
+// roundSize rounds a font size to half a point so that slightly different sizes are grouped.
+func roundSize(size float64) float64 {
+	return math.Round(size*2) / 2
+}
+
+// containsCenter returns true if the center of `b` lies inside `a`.
+func containsCenter(a tables.BBox, b extractops.BBox) bool {
+	cx := (b[0] + b[2]) / 2
+	cy := (b[1] + b[3]) / 2
+	return cx >= a[0] && cx <= a[2] && cy >= a[1] && cy <= a[3]
+}
+
+var reSpaces = regexp.MustCompile(`\s+`)
+
+// collapseSpaces returns `text` with runs of white space replaced by a single space.
+func collapseSpaces(text string) string {
+	return reSpaces.ReplaceAllString(strings.TrimSpace(text), " ")
+}
+
+// lastRune returns the last character of `text`.
+func lastRune(text string) string {
+	r, _ := utf8.DecodeLastRuneInString(text)
+	return string(r)
+}
@@ -0,0 +1,58 @@
+/*
+ * pdf2md: Convert a PDF file to Markdown. Headings are inferred from the font size and weight of
+ * the text, bullet and numbered lists come from the extractor list detection, tables are written
+ * as GitHub Flavored Markdown tables and the rest of the text as paragraphs in reading order.
+ *
+ * Run as: go run pdf2md.go [-page-markers] [-max-heading-level N] [-layout] input.pdf output.md
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/unidoc/unidoc-examples/extract/markdown"
+	"github.com/unidoc/unipdf/v4/common/license"
+)
+
+func init() {
+	// Make sure to load your metered License API key prior to using the library.
+	// If you need a key, you can sign up and create a free one at https://cloud.unidoc.io
+	err := license.SetMeteredKey(os.Getenv(`UNIDOC_LICENSE_API_KEY`))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func main() {
+	var opts markdown.Options
+	flag.BoolVar(&opts.PageMarkers, "page-markers", false, "add a <!-- page N --> comment before each page")
+	flag.IntVar(&opts.MaxHeadingLevel, "max-heading-level", 3, "deepest heading level given to text larger than the body text")
+	flag.BoolVar(&opts.DisableDocumentTags, "no-tags", false, "detect lists from the text only, ignoring the structure tags")
+	flag.BoolVar(&opts.Layout, "layout", false, "read multi-column pages column by column and leave out running headers and footers")
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		fmt.Printf("Usage: go run pdf2md.go [-page-markers] [-max-heading-level N] [-layout] input.pdf output.md\n")
+		os.Exit(1)
+	}
+	inputPath := flag.Arg(0)
+	outputPath := flag.Arg(1)
+
+	f, err := os.Create(outputPath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	err = markdown.Convert(f, inputPath, nil, opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Complete, see output file: %s\n", outputPath)
+}
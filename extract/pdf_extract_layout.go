@@ -0,0 +1,61 @@
+/*
+ * Analyze the layout of the pages of a PDF file: the text of each page is segmented into blocks
+ * which are classified as header, footer, sidebar or body text and returned in reading order, so
+ * that multi-column pages are read one column after the other.
+ *
+ * Run as: go run pdf_extract_layout.go [-json] input.pdf
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/unidoc/unidoc-examples/extract/layout"
+	"github.com/unidoc/unipdf/v4/common/license"
+)
+
+func init() {
+	// Make sure to load your metered License API key prior to using the library.
+	// If you need a key, you can sign up and create a free one at https://cloud.unidoc.io
+	err := license.SetMeteredKey(os.Getenv(`UNIDOC_LICENSE_API_KEY`))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func main() {
+	asJSON := flag.Bool("json", false, "write the layout as JSON")
+	flag.Parse()
+
+	if flag.NArg() < 1 {
+		fmt.Printf("Usage: go run pdf_extract_layout.go [-json] input.pdf\n")
+		os.Exit(1)
+	}
+	inputPath := flag.Arg(0)
+
+	pages, err := layout.AnalyzeFile(inputPath, nil)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *asJSON {
+		if err := layout.WriteJSON(os.Stdout, inputPath, pages); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	for _, page := range pages {
+		fmt.Printf("------------------------------\n")
+		fmt.Printf("Page %d: %d blocks\n", page.Page, len(page.Blocks))
+		for i, block := range page.Blocks {
+			fmt.Printf("\n[%d] %s, column %d, bbox %v\n", i+1, block.Kind, block.Column, block.BBox)
+			fmt.Println(block.Text)
+		}
+	}
+}
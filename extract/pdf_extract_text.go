@@ -1,15 +1,22 @@
 /*
  * PDF to text: Extract all text for each page of a pdf file.
  *
- * Run as: go run pdf_extract_text.go input.pdf
+ * With -repeated strip the running headers, footers and page numbers repeated at the same position
+ * across pages are left out, with -repeated tag they are printed in blocks prefixed by their kind,
+ * e.g. "[page_number] 3".
+ *
+ * Run as: go run pdf_extract_text.go [-repeated keep|strip|tag] input.pdf
  */
 
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 
+	"github.com/unidoc/unidoc-examples/extract/extractops"
+	"github.com/unidoc/unidoc-examples/extract/layout"
 	"github.com/unidoc/unipdf/v4/common/license"
 	"github.com/unidoc/unipdf/v4/extractor"
 	"github.com/unidoc/unipdf/v4/model"
@@ -25,22 +32,30 @@ func init() {
 }
 
 func main() {
-	if len(os.Args) < 2 {
-		fmt.Printf("Usage: go run pdf_extract_text.go input.pdf\n")
+	repeatedMode := flag.String("repeated", "keep", "headers, footers and page numbers repeated across pages: keep, strip or tag")
+	flag.Parse()
+	if flag.NArg() < 1 {
+		fmt.Printf("Usage: go run pdf_extract_text.go [-repeated keep|strip|tag] input.pdf\n")
 		os.Exit(1)
 	}
 
-	inputPath := os.Args[1]
+	inputPath := flag.Arg(0)
+	repeated, err := extractops.ParseRepeated(*repeatedMode)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
 
-	err := outputPdfText(inputPath)
+	err = outputPdfText(inputPath, repeated)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 }
 
-// outputPdfText prints out contents of PDF file to stdout.
-func outputPdfText(inputPath string) error {
+// outputPdfText prints out contents of PDF file to stdout. The repeated headers, footers and page
+// numbers are handled according to `repeated`.
+func outputPdfText(inputPath string, repeated extractops.Repeated) error {
 	f, err := os.Open(inputPath)
 	if err != nil {
 		return err
@@ -58,23 +73,25 @@ func outputPdfText(inputPath string) error {
 		return err
 	}
 
+	// Find the lines repeated at the same position across pages, ignoring the page numbers.
+	var repeatedSet *layout.RepeatedSet
+	if repeated != extractops.RepeatedKeep {
+		repeatedSet, err = layout.DetectRepeatedInReader(pdfReader, nil, layout.RepeatedOptions{})
+		if err != nil {
+			return err
+		}
+		for _, r := range repeatedSet.Items {
+			fmt.Printf("Repeated %s on %d pages: %q\n", r.Kind, len(r.Pages), r.Text)
+		}
+	}
+
 	fmt.Printf("--------------------\n")
 	fmt.Printf("PDF to text extraction:\n")
 	fmt.Printf("--------------------\n")
 	for i := 0; i < numPages; i++ {
 		pageNum := i + 1
 
-		page, err := pdfReader.GetPage(pageNum)
-		if err != nil {
-			return err
-		}
-
-		ex, err := extractor.New(page)
-		if err != nil {
-			return err
-		}
-
-		text, err := ex.ExtractText()
+		text, err := extractPageText(pdfReader, pageNum, repeatedSet, repeated)
 		if err != nil {
 			return err
 		}
@@ -87,3 +104,23 @@ func outputPdfText(inputPath string) error {
 
 	return nil
 }
+
+// extractPageText returns the text of page `pageNum` of `pdfReader`. The lines of `repeatedSet`
+// are stripped or tagged according to `repeated`.
+func extractPageText(pdfReader *model.PdfReader, pageNum int, repeatedSet *layout.RepeatedSet, repeated extractops.Repeated) (string, error) {
+	if repeated != extractops.RepeatedKeep {
+		return extractops.ExtractPageRepeatedText(pdfReader, pageNum, repeatedSet, repeated)
+	}
+
+	page, err := pdfReader.GetPage(pageNum)
+	if err != nil {
+		return "", err
+	}
+
+	ex, err := extractor.New(page)
+	if err != nil {
+		return "", err
+	}
+
+	return ex.ExtractText()
+}
@@ -0,0 +1,56 @@
+/*
+ * PDF to structured JSON: Extract the text of each page of a PDF file together with the page
+ * geometry, lines, words and text marks (bounding box, font, size, fill color and reading order).
+ * See README.md for the description of the output schema.
+ *
+ * Run as: go run pdf_extract_text_json.go [-format json|jsonl] [-no-marks] input.pdf output.json
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/unidoc/unidoc-examples/extract/extractops"
+	"github.com/unidoc/unipdf/v4/common/license"
+)
+
+func init() {
+	// Make sure to load your metered License API key prior to using the library.
+	// If you need a key, you can sign up and create a free one at https://cloud.unidoc.io
+	err := license.SetMeteredKey(os.Getenv(`UNIDOC_LICENSE_API_KEY`))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func main() {
+	format := flag.String("format", extractops.FormatJSON, "output format: json (one document) or jsonl (one page per line)")
+	noMarks := flag.Bool("no-marks", false, "omit the per glyph marks")
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		fmt.Printf("Usage: go run pdf_extract_text_json.go [-format json|jsonl] [-no-marks] input.pdf output.json\n")
+		os.Exit(1)
+	}
+	inputPath := flag.Arg(0)
+	outputPath := flag.Arg(1)
+
+	f, err := os.Create(outputPath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	// Extract all pages and write them in the requested format.
+	err = extractops.WriteStructured(f, inputPath, nil, *format, !*noMarks)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Complete, see output file: %s\n", outputPath)
+}
@@ -1,7 +1,10 @@
 /*
  * Extract all tables from the specified pages of one or more PDF files.
+ * Tables are saved as CSV files and optionally as XLSX workbooks (one sheet per table) and as JSON
+ * with the bounding box, row and column span of each cell and the detected header rows.
+ * With -merge-across-pages, tables continuing over page breaks are stitched together.
  *
- * Run as: go run pdf_tables.go input.pdf
+ * Run as: go run pdf_tables.go [-xlsx outxlsx] [-json outjson] [-merge-across-pages] input.pdf
  */
 
 package main
@@ -15,20 +18,16 @@ import (
 	"os"
 	"os/user"
 	"path/filepath"
-	"regexp"
 	"runtime/pprof"
 	"sort"
 	"strings"
 	"time"
 
-	"golang.org/x/text/unicode/norm"
-
 	"github.com/bmatcuk/doublestar"
+	"github.com/unidoc/unidoc-examples/extract/tables"
 	"github.com/unidoc/unipdf/v4/common"
 	"github.com/unidoc/unipdf/v4/common/license"
-	"github.com/unidoc/unipdf/v4/extractor"
 	"github.com/unidoc/unipdf/v4/model"
-	"github.com/unidoc/unipdf/v4/pdfutil"
 )
 
 func init() {
@@ -48,11 +47,16 @@ func main() {
 	var (
 		firstPage, lastPage     int
 		width, height           int
-		csvDir                  string
+		csvDir, xlsxDir         string
+		jsonDir                 string
+		mergeAcrossPages        bool
 		debug, trace, doProfile bool
 		verbose                 int
 	)
 	flag.StringVar(&csvDir, "o", "./outcsv", `Output CSVs (default outtext). Set to "" to not save.`)
+	flag.StringVar(&xlsxDir, "xlsx", "", `Output XLSX workbooks, one sheet per table. Set to "" to not save.`)
+	flag.StringVar(&jsonDir, "json", "", `Output JSON files with cell bounding boxes, spans and headers. Set to "" to not save.`)
+	flag.BoolVar(&mergeAcrossPages, "merge-across-pages", false, "Stitch tables continuing over page breaks.")
 	flag.IntVar(&firstPage, "f", -1, "First page.")
 	flag.IntVar(&lastPage, "l", 100000, "Last page.")
 	flag.IntVar(&width, "w", 0, "Minimum table width.")
@@ -84,8 +88,10 @@ func main() {
 	}
 
 	makeDir("CSV directory", csvDir)
+	makeDir("XLSX directory", xlsxDir)
+	makeDir("JSON directory", jsonDir)
 
-	pathList, err := patternsToPaths(os.Args[1:])
+	pathList, err := patternsToPaths(args)
 	if err != nil {
 		panic(err)
 	}
@@ -111,15 +117,29 @@ func main() {
 			continue
 		}
 		duration := time.Since(t0).Seconds()
-		numPages := len(result.pageTables)
+		if mergeAcrossPages {
+			result.tables = tables.MergeAcrossPages(result.tables)
+		}
 		result = result.filter(width, height)
 		fmt.Printf("%3d of %d: %4.1f MB %3d pages %4.1f sec %q %s",
-			i+1, len(pathList), fileSizeMB(inPath), numPages, duration, inPath, result.describe(verbose))
+			i+1, len(pathList), fileSizeMB(inPath), result.numPages, duration, inPath, result.describe(verbose))
 		csvRoot := changeDirExt(csvDir, filepath.Base(inPath), "", "")
 		if err := result.saveCSVFiles(csvRoot); err != nil {
 			fmt.Printf("Failed to write %q: %v\n", csvRoot, err)
 			continue
 		}
+		xlsxPath := changeDirExt(xlsxDir, filepath.Base(inPath), "", ".xlsx")
+		if xlsxPath != "" && len(result.tables) > 0 {
+			if err := tables.WriteXLSXFile(xlsxPath, result.tables); err != nil {
+				fmt.Printf("Failed to write %q: %v\n", xlsxPath, err)
+				continue
+			}
+		}
+		jsonPath := changeDirExt(jsonDir, filepath.Base(inPath), "", ".json")
+		if err := result.saveJSONFile(jsonPath, inPath); err != nil {
+			fmt.Printf("Failed to write %q: %v\n", jsonPath, err)
+			continue
+		}
 	}
 }
 
@@ -147,58 +167,36 @@ func extractTables(inPath string, firstPage, lastPage int) (docTables, error) {
 		lastPage = numPages
 	}
 
-	result := docTables{pageTables: make(map[int][]stringTable)}
+	result := docTables{}
 	for pageNum := firstPage; pageNum <= lastPage; pageNum++ {
-		tables, err := extractPageTables(pdfReader, pageNum)
+		pageTables, err := tables.ExtractPageTables(pdfReader, pageNum)
 		if err != nil {
 			return docTables{}, fmt.Errorf("extractPageTables failed. inPath=%q pageNum=%d err=%w",
 				inPath, pageNum, err)
 		}
-		result.pageTables[pageNum] = tables
+		result.tables = append(result.tables, pageTables...)
+		result.numPages++
 	}
 	return result, nil
 }
 
-// extractPageTables extracts the tables from (1-offset) page number `pageNum` in opened
-// PdfReader `pdfReader.
-func extractPageTables(pdfReader *model.PdfReader, pageNum int) ([]stringTable, error) {
-	page, err := pdfReader.GetPage(pageNum)
-	if err != nil {
-		return nil, err
-	}
-	if err := pdfutil.NormalizePage(page); err != nil {
-		return nil, err
-	}
-
-	ex, err := extractor.New(page)
-	if err != nil {
-		return nil, err
-	}
-	pageText, _, _, err := ex.ExtractPageText()
-	if err != nil {
-		return nil, err
-	}
-	tables := pageText.Tables()
-	stringTables := make([]stringTable, len(tables))
-	for i, table := range tables {
-		stringTables[i] = asStringTable(table)
-	}
-	return stringTables, nil
-}
-
 // docTables describes the tables in a document.
 type docTables struct {
-	pageTables map[int][]stringTable
+	numPages int            // Number of pages processed.
+	tables   []tables.Table // Tables in page order.
 }
 
 // stringTable is the strings in TextTable.
 type stringTable [][]string
 
 func (r docTables) saveCSVFiles(csvRoot string) error {
+	if csvRoot == "" {
+		return nil
+	}
 	for _, pageNum := range r.pageNumbers() {
-		for i, table := range r.pageTables[pageNum] {
-			csvPath := fmt.Sprintf("%s.page%d.table%d.csv", csvRoot, pageNum, i+1)
-			contents := table.csv()
+		for i, table := range r.pageTables(pageNum) {
+			csvPath := fmt.Sprintf("%s.page%s.table%d.csv", csvRoot, pageLabel(table), i+1)
+			contents := stringTable(table.Strings()).csv()
 			if err := ioutil.WriteFile(csvPath, []byte(contents), 0666); err != nil {
 				return fmt.Errorf("failed to write csvPath=%q err=%w", csvPath, err)
 			}
@@ -207,6 +205,23 @@ func (r docTables) saveCSVFiles(csvRoot string) error {
 	return nil
 }
 
+// saveJSONFile saves the tables in `r` with their cell geometry to `jsonPath`.
+func (r docTables) saveJSONFile(jsonPath, inPath string) error {
+	if jsonPath == "" {
+		return nil
+	}
+	f, err := os.Create(jsonPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := tables.WriteJSON(f, inPath, r.tables); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
 // wh returns the width and height of table `t`.
 func (t stringTable) wh() (int, int) {
 	if len(t) == 0 {
@@ -243,31 +258,34 @@ func (r *docTables) String() string {
 //	    table %d: %d x %d       (level 3)
 //	        contents            (level 4)
 func (r *docTables) describe(level int) string {
-	if level == 0 || r.numTables() == 0 {
+	if level == 0 || len(r.tables) == 0 {
 		return "\n"
 	}
 	var sb strings.Builder
 	pageNumbers := r.pageNumbers()
-	fmt.Fprintf(&sb, "%d pages %d tables\n", len(pageNumbers), r.numTables())
+	fmt.Fprintf(&sb, "%d pages %d tables\n", len(pageNumbers), len(r.tables))
 	if level <= 1 {
 		return sb.String()
 	}
-	for _, pageNum := range r.pageNumbers() {
-		tables := r.pageTables[pageNum]
-		if len(tables) == 0 {
-			continue
-		}
-		fmt.Fprintf(&sb, "   page %d: %d tables\n", pageNum, len(tables))
+	for _, pageNum := range pageNumbers {
+		pageTables := r.pageTables(pageNum)
+		fmt.Fprintf(&sb, "   page %d: %d tables\n", pageNum, len(pageTables))
 		if level <= 2 {
 			continue
 		}
-		for i, table := range tables {
-			w, h := table.wh()
-			fmt.Fprintf(&sb, "      table %d: %d x %d\n", i+1, w, h)
-			if level <= 3 || len(table) == 0 {
+		for i, table := range pageTables {
+			fmt.Fprintf(&sb, "      table %d: %d x %d", i+1, table.Cols, table.Rows)
+			if len(table.Pages) > 1 {
+				fmt.Fprintf(&sb, " (pages %s)", pageLabel(table))
+			}
+			if table.HeaderRows > 0 {
+				fmt.Fprintf(&sb, " header")
+			}
+			fmt.Fprintln(&sb)
+			if level <= 3 || table.Rows == 0 {
 				continue
 			}
-			for _, row := range table {
+			for _, row := range table.Strings() {
 				cells := make([]string, len(row))
 				for i, cell := range row {
 					if len(cell) > 0 {
@@ -281,78 +299,48 @@ func (r *docTables) describe(level int) string {
 	return sb.String()
 }
 
+// pageNumbers returns the numbers of the pages where the tables in `r` start.
 func (r *docTables) pageNumbers() []int {
-	pageNums := make([]int, len(r.pageTables))
-	i := 0
-	for pageNum := range r.pageTables {
-		pageNums[i] = pageNum
-		i++
+	var pageNums []int
+	for _, table := range r.tables {
+		if n := len(pageNums); n == 0 || pageNums[n-1] != table.Pages[0] {
+			pageNums = append(pageNums, table.Pages[0])
+		}
 	}
-	sort.Ints(pageNums)
 	return pageNums
 }
 
-func (r *docTables) numTables() int {
-	n := 0
-	for _, tables := range r.pageTables {
-		n += len(tables)
+// pageTables returns the tables in `r` starting on page `pageNum`.
+func (r *docTables) pageTables(pageNum int) []tables.Table {
+	var pageTables []tables.Table
+	for _, table := range r.tables {
+		if table.Pages[0] == pageNum {
+			pageTables = append(pageTables, table)
+		}
 	}
-	return n
+	return pageTables
 }
 
 // filter returns the tables in `r` that are at least `width` cells wide and `height` cells high.
 func (r docTables) filter(width, height int) docTables {
-	filtered := docTables{pageTables: make(map[int][]stringTable)}
-	for pageNum, tables := range r.pageTables {
-		var filteredTables []stringTable
-		for _, table := range tables {
-			if len(table[0]) >= width && len(table) >= height {
-				filteredTables = append(filteredTables, table)
-			}
-		}
-		if len(filteredTables) > 0 {
-			filtered.pageTables[pageNum] = filteredTables
+	filtered := docTables{numPages: r.numPages}
+	for _, table := range r.tables {
+		if table.Cols >= width && table.Rows >= height {
+			filtered.tables = append(filtered.tables, table)
 		}
 	}
 	return filtered
 }
 
-// asStringTable returns TextTable `table` as a stringTable.
-func asStringTable(table extractor.TextTable) stringTable {
-	cells := make(stringTable, table.H)
-	for y, row := range table.Cells {
-		cells[y] = make([]string, table.W)
-		for x, cell := range row {
-			cells[y][x] = cell.Text
-		}
-	}
-	return normalizeTable(cells)
-}
-
-// normalizeTable returns `cells` with each cell normalized.
-func normalizeTable(cells stringTable) stringTable {
-	for y, row := range cells {
-		for x, cell := range row {
-			cells[y][x] = normalize(cell)
-		}
+// pageLabel returns the page number of `table`, or its page range if it continues over page breaks.
+func pageLabel(table tables.Table) string {
+	first, last := table.Pages[0], table.Pages[len(table.Pages)-1]
+	if first == last {
+		return fmt.Sprint(first)
 	}
-	return cells
+	return fmt.Sprintf("%d-%d", first, last)
 }
 
-// normalize returns a version of `text` that is NFKC normalized and has reduceSpaces() applied.
-func normalize(text string) string {
-	return reduceSpaces(norm.NFKC.String(text))
-}
-
-// reduceSpaces returns `text` with runs of spaces of any kind (spaces, tabs, line breaks, etc)
-// reduced to a single space.
-func reduceSpaces(text string) string {
-	text = reSpace.ReplaceAllString(text, " ")
-	return strings.Trim(text, " \t\n\r\v")
-}
-
-var reSpace = regexp.MustCompile(`(?m)\s+`)
-
 // patternsToPaths returns the file paths matched by the patterns in `patternList`.
 func patternsToPaths(patternList []string) ([]string, error) {
 	var pathList []string
@@ -0,0 +1,424 @@
+/*
+ * Table extraction keeping the cell geometry: each cell has its bounding box, row and column span,
+ * the header rows of a table are detected and tables continuing over page breaks can be stitched
+ * together. Tables can be saved as JSON or as XLSX workbooks (see lib_xlsx.go).
+ */
+
+package tables
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/unicode/norm"
+
+	"github.com/unidoc/unipdf/v4/extractor"
+	"github.com/unidoc/unipdf/v4/model"
+	"github.com/unidoc/unipdf/v4/pdfutil"
+)
+
+// BBox is a bounding box [llx, lly, urx, ury] in PDF points.
+type BBox [4]float64
+
+// Cell is a table cell. A merged cell is reported once at its top left position with RowSpan
+// and ColSpan greater than 1, the grid positions it covers are marked as Covered.
+type Cell struct {
+	Row     int    `json:"row"`
+	Col     int    `json:"col"`
+	RowSpan int    `json:"row_span"`
+	ColSpan int    `json:"col_span"`
+	Text    string `json:"text"`
+	Page    int    `json:"page"`
+	BBox    BBox   `json:"bbox"`
+	Header  bool   `json:"header,omitempty"`
+	Covered bool   `json:"-"`
+}
+
+// Table is a table extracted from one or, when merged across page breaks, several pages. BBox is
+// the bounding box of the table on its first page, the cells have the bounding box on their page.
+type Table struct {
+	Pages      []int    `json:"pages"`
+	BBox       BBox     `json:"bbox"`
+	Rows       int      `json:"rows"`
+	Cols       int      `json:"cols"`
+	HeaderRows int      `json:"header_rows"`
+	Cells      [][]Cell `json:"-"`
+}
+
+// tolerance is the distance in points under which cell edges are considered equal.
+const tolerance = 0.5
+
+// ExtractPageTables extracts the tables of page `pageNum` of `pdfReader`.
+func ExtractPageTables(pdfReader *model.PdfReader, pageNum int) ([]Table, error) {
+	page, err := pdfReader.GetPage(pageNum)
+	if err != nil {
+		return nil, err
+	}
+	if err := pdfutil.NormalizePage(page); err != nil {
+		return nil, err
+	}
+
+	ex, err := extractor.New(page)
+	if err != nil {
+		return nil, err
+	}
+	pageText, _, _, err := ex.ExtractPageText()
+	if err != nil {
+		return nil, err
+	}
+
+	textTables := pageText.Tables()
+	tables := make([]Table, len(textTables))
+	for i, textTable := range textTables {
+		tables[i] = FromTextTable(pageNum, textTable)
+	}
+	return tables, nil
+}
+
+// ExtractTables extracts the tables of the pages `pageNums` of `inputPath` (all pages if empty).
+// Tables continuing over page breaks are stitched together if `mergeAcrossPages` is true.
+func ExtractTables(inputPath string, pageNums []int, mergeAcrossPages bool) ([]Table, error) {
+	pdfReader, f, err := model.NewPdfReaderFromFile(inputPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if len(pageNums) == 0 {
+		numPages, err := pdfReader.GetNumPages()
+		if err != nil {
+			return nil, err
+		}
+		for i := 0; i < numPages; i++ {
+			pageNums = append(pageNums, i+1)
+		}
+	}
+
+	var tables []Table
+	for _, pageNum := range pageNums {
+		pageTables, err := ExtractPageTables(pdfReader, pageNum)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+		tables = append(tables, pageTables...)
+	}
+
+	if mergeAcrossPages {
+		tables = MergeAcrossPages(tables)
+	}
+	return tables, nil
+}
+
+// FromTextTable converts the extractor table `textTable` found on page `pageNum`, detecting
+// merged cells and header rows. Cell texts are normalized with Normalize.
+func FromTextTable(pageNum int, textTable extractor.TextTable) Table {
+	t := Table{
+		Pages: []int{pageNum},
+		BBox:  toBBox(textTable.PdfRectangle),
+		Rows:  textTable.H,
+		Cols:  textTable.W,
+		Cells: make([][]Cell, textTable.H),
+	}
+	for y := 0; y < t.Rows; y++ {
+		t.Cells[y] = make([]Cell, t.Cols)
+		for x := 0; x < t.Cols; x++ {
+			cell := Cell{Row: y, Col: x, RowSpan: 1, ColSpan: 1, Page: pageNum}
+			if y < len(textTable.Cells) && x < len(textTable.Cells[y]) {
+				tc := textTable.Cells[y][x]
+				cell.Text = Normalize(tc.Text)
+				cell.BBox = toBBox(tc.PdfRectangle)
+			}
+			t.Cells[y][x] = cell
+		}
+	}
+
+	t.detectSpans()
+	t.detectHeader()
+	return t
+}
+
+// detectSpans merges cells covering several grid positions. The extractor reports a merged
+// cell at each grid position it covers, either with the same rectangle or with the text only in
+// the first position, so a neighbor is absorbed if it has the same rectangle or if it is empty
+// and its center lies inside the rectangle of the anchor cell.
+func (t *Table) detectSpans() {
+	absorbs := func(anchor, other Cell) bool {
+		if other.Covered {
+			return false
+		}
+		if sameBBox(anchor.BBox, other.BBox) {
+			return true
+		}
+		return other.Text == "" && anchor.Text != "" && containsCenter(anchor.BBox, other.BBox)
+	}
+
+	for y := 0; y < t.Rows; y++ {
+		for x := 0; x < t.Cols; x++ {
+			anchor := &t.Cells[y][x]
+			if anchor.Covered {
+				continue
+			}
+
+			for x+anchor.ColSpan < t.Cols && absorbs(*anchor, t.Cells[y][x+anchor.ColSpan]) {
+				anchor.ColSpan++
+			}
+		rows:
+			for y+anchor.RowSpan < t.Rows {
+				for dx := 0; dx < anchor.ColSpan; dx++ {
+					if !absorbs(*anchor, t.Cells[y+anchor.RowSpan][x+dx]) {
+						break rows
+					}
+				}
+				anchor.RowSpan++
+			}
+
+			for dy := 0; dy < anchor.RowSpan; dy++ {
+				for dx := 0; dx < anchor.ColSpan; dx++ {
+					if dy == 0 && dx == 0 {
+						continue
+					}
+					covered := &t.Cells[y+dy][x+dx]
+					covered.Covered = true
+					anchor.BBox = unionBBox(anchor.BBox, covered.BBox)
+				}
+			}
+		}
+	}
+}
+
+// detectHeader marks the first row as a header if all its cells have text, none of them is a
+// number and the rows below either contain numbers or the first row has no repeated values.
+func (t *Table) detectHeader() {
+	t.HeaderRows = 0
+	if t.Rows < 2 {
+		return
+	}
+
+	seen := map[string]bool{}
+	unique := true
+	for _, cell := range t.Cells[0] {
+		if cell.Covered {
+			continue
+		}
+		if cell.Text == "" || IsNumber(cell.Text) {
+			return
+		}
+		if seen[cell.Text] {
+			unique = false
+		}
+		seen[cell.Text] = true
+	}
+
+	bodyNumbers := false
+	for _, row := range t.Cells[1:] {
+		for _, cell := range row {
+			if !cell.Covered && IsNumber(cell.Text) {
+				bodyNumbers = true
+			}
+		}
+	}
+	if !bodyNumbers && !unique {
+		return
+	}
+
+	t.HeaderRows = 1
+	for x := range t.Cells[0] {
+		t.Cells[0][x].Header = true
+	}
+}
+
+// MergeAcrossPages stitches tables continuing over page breaks. A table is continued by the first
+// table of the next page if it is the last table of its page and both tables have the same
+// columns. A header row repeated at the top of the continuation is dropped.
+func MergeAcrossPages(tables []Table) []Table {
+	var merged []Table
+	for i, t := range tables {
+		// Tables are in page order, so the previous table is the last one of its page and `t`
+		// the first one of its page when they are on different pages.
+		if i > 0 && tables[i-1].Pages[0] != t.Pages[0] {
+			prev := &merged[len(merged)-1]
+			if prev.continuedBy(t) {
+				prev.appendRows(t)
+				continue
+			}
+		}
+		merged = append(merged, t)
+	}
+	return merged
+}
+
+// continuedBy returns true if `next` looks like the continuation of `t` on the next page.
+func (t *Table) continuedBy(next Table) bool {
+	if next.Pages[0] != t.Pages[len(t.Pages)-1]+1 || next.Cols != t.Cols || t.Rows == 0 || next.Rows == 0 {
+		return false
+	}
+
+	// Compare the left edges of the columns on the first row of both tables.
+	const colTolerance = 5.0
+	for x := 0; x < t.Cols; x++ {
+		a := t.Cells[0][x].BBox[0]
+		b := next.Cells[0][x].BBox[0]
+		if math.Abs(a-b) > colTolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// appendRows appends the rows of `next` to `t`, skipping a repeated header.
+func (t *Table) appendRows(next Table) {
+	rows := next.Cells
+	if next.HeaderRows > 0 && t.HeaderRows > 0 && sameRowText(t.Cells[0], next.Cells[0]) {
+		rows = rows[next.HeaderRows:]
+	}
+
+	for _, row := range rows {
+		newRow := make([]Cell, len(row))
+		for x, cell := range row {
+			cell.Row = t.Rows
+			cell.Header = false
+			newRow[x] = cell
+		}
+		t.Cells = append(t.Cells, newRow)
+		t.Rows++
+	}
+	t.Pages = append(t.Pages, next.Pages...)
+}
+
+// Strings returns the cell texts of `t` as a grid. Positions covered by merged cells are empty.
+func (t Table) Strings() [][]string {
+	grid := make([][]string, t.Rows)
+	for y, row := range t.Cells {
+		grid[y] = make([]string, t.Cols)
+		for x, cell := range row {
+			if !cell.Covered {
+				grid[y][x] = cell.Text
+			}
+		}
+	}
+	return grid
+}
+
+// MarshalJSON implements json.Marshaler. The cells are listed row by row, leaving out the grid
+// positions covered by merged cells.
+func (t Table) MarshalJSON() ([]byte, error) {
+	cells := []Cell{}
+	for _, row := range t.Cells {
+		for _, cell := range row {
+			if !cell.Covered {
+				cells = append(cells, cell)
+			}
+		}
+	}
+
+	return json.Marshal(struct {
+		Pages      []int  `json:"pages"`
+		BBox       BBox   `json:"bbox"`
+		Rows       int    `json:"rows"`
+		Cols       int    `json:"cols"`
+		HeaderRows int    `json:"header_rows"`
+		Cells      []Cell `json:"cells"`
+	}{t.Pages, t.BBox, t.Rows, t.Cols, t.HeaderRows, cells})
+}
+
+// WriteJSON writes `tables` of `inputPath` to `w` as indented JSON.
+func WriteJSON(w io.Writer, inputPath string, tables []Table) error {
+	doc := struct {
+		File   string  `json:"file"`
+		Tables []Table `json:"tables"`
+	}{inputPath, tables}
+	if doc.Tables == nil {
+		doc.Tables = []Table{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// Normalize returns a version of `text` that is NFKC normalized and has runs of spaces of any
+// kind (spaces, tabs, line breaks, etc) reduced to a single space.
+func Normalize(text string) string {
+	text = reSpace.ReplaceAllString(norm.NFKC.String(text), " ")
+	return strings.Trim(text, " \t\n\r\v")
+}
+
+var reSpace = regexp.MustCompile(`(?m)\s+`)
+
+var reNumber = regexp.MustCompile(`^[-+]?(\d{1,3}(,\d{3})+|\d+)(\.\d+)?$`)
+
+// ParseNumber parses `text` as a number, accepting thousands separators, a leading currency
+// sign and negative numbers in parentheses as in "(1,234.50)".
+func ParseNumber(text string) (float64, bool) {
+	s := strings.TrimSpace(text)
+	negative := false
+	if strings.HasPrefix(s, "(") && strings.HasSuffix(s, ")") {
+		negative = true
+		s = strings.TrimSpace(s[1 : len(s)-1])
+	}
+	s = strings.TrimLeft(s, "$€£¥")
+	if !reNumber.MatchString(s) {
+		return 0, false
+	}
+
+	v, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", ""), 64)
+	if err != nil {
+		return 0, false
+	}
+	if negative {
+		v = -v
+	}
+	return v, true
+}
+
+// IsNumber returns true if `text` is a number as accepted by ParseNumber.
+func IsNumber(text string) bool {
+	_, ok := ParseNumber(text)
+	return ok
+}
+
+// sameRowText returns true if the rows `a` and `b` contain the same texts.
+func sameRowText(a, b []Cell) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].Text != b[i].Text {
+			return false
+		}
+	}
+	return true
+}
+
+// sameBBox returns true if `a` and `b` are equal within tolerance.
+func sameBBox(a, b BBox) bool {
+	for i := range a {
+		if math.Abs(a[i]-b[i]) > tolerance {
+			return false
+		}
+	}
+	return true
+}
+
+// containsCenter returns true if the center of `b` lies inside `a`.
+func containsCenter(a, b BBox) bool {
+	cx := (b[0] + b[2]) / 2
+	cy := (b[1] + b[3]) / 2
+	return cx >= a[0]-tolerance && cx <= a[2]+tolerance && cy >= a[1]-tolerance && cy <= a[3]+tolerance
+}
+
+// unionBBox returns the bounding box of `a` and `b`.
+func unionBBox(a, b BBox) BBox {
+	return BBox{math.Min(a[0], b[0]), math.Min(a[1], b[1]), math.Max(a[2], b[2]), math.Max(a[3], b[3])}
+}
+
+// toBBox converts `r` to a BBox rounded to 1/100 point.
+func toBBox(r model.PdfRectangle) BBox {
+	round := func(v float64) float64 { return math.Round(v*100) / 100 }
+	return BBox{round(r.Llx), round(r.Lly), round(r.Urx), round(r.Ury)}
+}
@@ -0,0 +1,210 @@
+/*
+ * Minimal XLSX (Office Open XML spreadsheet) writer for extracted tables: one worksheet per table,
+ * numbers stored as numeric cells, header rows in bold and merged cells kept as merged ranges.
+ */
+
+package tables
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// WriteXLSXFile saves `tables` to the XLSX workbook `outputPath`.
+func WriteXLSXFile(outputPath string, tables []Table) error {
+	f, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := WriteXLSX(f, tables); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// WriteXLSX writes `tables` to `w` as an XLSX workbook with one worksheet per table. The sheets
+// are named after the pages of the tables, e.g. "p3 t1" for the first table of page 3 or
+// "p3-4 t1" for a table merged across pages 3 and 4.
+func WriteXLSX(w io.Writer, tables []Table) error {
+	if len(tables) == 0 {
+		return errors.New("no tables to write, a workbook needs at least one sheet")
+	}
+	zw := zip.NewWriter(w)
+
+	names := sheetNames(tables)
+	files := []struct {
+		name    string
+		content string
+	}{
+		{"[Content_Types].xml", contentTypesXML(len(tables))},
+		{"_rels/.rels", relsXML},
+		{"xl/workbook.xml", workbookXML(names)},
+		{"xl/_rels/workbook.xml.rels", workbookRelsXML(len(tables))},
+		{"xl/styles.xml", stylesXML},
+	}
+	for i, t := range tables {
+		files = append(files, struct {
+			name    string
+			content string
+		}{fmt.Sprintf("xl/worksheets/sheet%d.xml", i+1), sheetXML(t)})
+	}
+
+	for _, file := range files {
+		fw, err := zw.Create(file.name)
+		if err != nil {
+			return err
+		}
+		if _, err := io.WriteString(fw, file.content); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+const xmlHeader = `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>` + "\n"
+
+const relsXML = xmlHeader +
+	`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+	`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+	`</Relationships>`
+
+// stylesXML defines the cell formats: 0 is the default format and 1 the bold header format.
+const stylesXML = xmlHeader +
+	`<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+	`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+	`<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>` +
+	`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+	`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+	`<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+	`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+	`</styleSheet>`
+
+func contentTypesXML(numSheets int) string {
+	var sb strings.Builder
+	sb.WriteString(xmlHeader)
+	sb.WriteString(`<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">`)
+	sb.WriteString(`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>`)
+	sb.WriteString(`<Default Extension="xml" ContentType="application/xml"/>`)
+	sb.WriteString(`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>`)
+	sb.WriteString(`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>`)
+	for i := 1; i <= numSheets; i++ {
+		fmt.Fprintf(&sb, `<Override PartName="/xl/worksheets/sheet%d.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>`, i)
+	}
+	sb.WriteString(`</Types>`)
+	return sb.String()
+}
+
+func workbookXML(names []string) string {
+	var sb strings.Builder
+	sb.WriteString(xmlHeader)
+	sb.WriteString(`<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships"><sheets>`)
+	for i, name := range names {
+		fmt.Fprintf(&sb, `<sheet name="%s" sheetId="%d" r:id="rId%d"/>`, escape(name), i+1, i+1)
+	}
+	sb.WriteString(`</sheets></workbook>`)
+	return sb.String()
+}
+
+// workbookRelsXML returns the workbook relationships: rId1..rIdN are the sheets and rIdN+1 the
+// styles.
+func workbookRelsXML(numSheets int) string {
+	var sb strings.Builder
+	sb.WriteString(xmlHeader)
+	sb.WriteString(`<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">`)
+	for i := 1; i <= numSheets; i++ {
+		fmt.Fprintf(&sb, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet%d.xml"/>`, i, i)
+	}
+	fmt.Fprintf(&sb, `<Relationship Id="rId%d" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>`, numSheets+1)
+	sb.WriteString(`</Relationships>`)
+	return sb.String()
+}
+
+func sheetXML(t Table) string {
+	var sb strings.Builder
+	sb.WriteString(xmlHeader)
+	sb.WriteString(`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">`)
+	if t.HeaderRows > 0 {
+		// Freeze the header rows.
+		fmt.Fprintf(&sb, `<sheetViews><sheetView workbookViewId="0"><pane ySplit="%d" topLeftCell="%s" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>`,
+			t.HeaderRows, cellRef(t.HeaderRows, 0))
+	}
+	sb.WriteString(`<sheetData>`)
+
+	var merges []string
+	for y, row := range t.Cells {
+		fmt.Fprintf(&sb, `<row r="%d">`, y+1)
+		for x, cell := range row {
+			if cell.Covered {
+				continue
+			}
+			if cell.RowSpan > 1 || cell.ColSpan > 1 {
+				merges = append(merges, cellRef(y, x)+":"+cellRef(y+cell.RowSpan-1, x+cell.ColSpan-1))
+			}
+			if cell.Text == "" {
+				continue
+			}
+
+			style := ""
+			if cell.Header || y < t.HeaderRows {
+				style = ` s="1"`
+			}
+			if v, ok := ParseNumber(cell.Text); ok && !cell.Header && y >= t.HeaderRows {
+				fmt.Fprintf(&sb, `<c r="%s"%s><v>%s</v></c>`, cellRef(y, x), style, strconv.FormatFloat(v, 'f', -1, 64))
+			} else {
+				fmt.Fprintf(&sb, `<c r="%s"%s t="inlineStr"><is><t xml:space="preserve">%s</t></is></c>`, cellRef(y, x), style, escape(cell.Text))
+			}
+		}
+		sb.WriteString(`</row>`)
+	}
+	sb.WriteString(`</sheetData>`)
+
+	if len(merges) > 0 {
+		fmt.Fprintf(&sb, `<mergeCells count="%d">`, len(merges))
+		for _, ref := range merges {
+			fmt.Fprintf(&sb, `<mergeCell ref="%s"/>`, ref)
+		}
+		sb.WriteString(`</mergeCells>`)
+	}
+	sb.WriteString(`</worksheet>`)
+	return sb.String()
+}
+
+// sheetNames returns unique worksheet names for `tables`.
+func sheetNames(tables []Table) []string {
+	names := make([]string, len(tables))
+	count := map[string]int{}
+	for i, t := range tables {
+		pages := fmt.Sprintf("p%d", t.Pages[0])
+		if last := t.Pages[len(t.Pages)-1]; last != t.Pages[0] {
+			pages = fmt.Sprintf("p%d-%d", t.Pages[0], last)
+		}
+		count[pages]++
+		names[i] = fmt.Sprintf("%s t%d", pages, count[pages])
+	}
+	return names
+}
+
+// cellRef returns the A1 style reference of the 0-based cell (`row`, `col`).
+func cellRef(row, col int) string {
+	name := ""
+	for col++; col > 0; col = (col - 1) / 26 {
+		name = string(rune('A'+(col-1)%26)) + name
+	}
+	return name + strconv.Itoa(row+1)
+}
+
+// escape escapes `s` for use in XML text and attributes. Characters not allowed in XML are
+// replaced by U+FFFD.
+func escape(s string) string {
+	var sb strings.Builder
+	xml.EscapeText(&sb, []byte(s))
+	return sb.String()
+}
@@ -0,0 +1,216 @@
+/*
+ * Reusable page manipulation helpers shared by the page examples and the unipdf command line tool.
+ */
+
+package pageops
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/unidoc/unipdf/v4/common"
+	"github.com/unidoc/unipdf/v4/contentstream"
+	"github.com/unidoc/unipdf/v4/core"
+	"github.com/unidoc/unipdf/v4/model"
+	"github.com/unidoc/unipdf/v4/pdfutil"
+)
+
+// MergePdf loads all pages of the files in `inputPaths` and writes them to `outputPath`.
+// If `mergeForms` is true, the AcroForm fields of the input documents are merged as well.
+func MergePdf(inputPaths []string, outputPath string, mergeForms bool) error {
+	if len(inputPaths) == 0 {
+		return fmt.Errorf("no input files")
+	}
+
+	return pdfutil.MergePdf(inputPaths, outputPath, mergeForms)
+}
+
+// SplitPdf extracts pages `pageFrom` to `pageTo` (inclusive, 1-based) of `inputPath` into `outputPath`.
+func SplitPdf(inputPath string, outputPath string, pageFrom, pageTo int) error {
+	if pageFrom < 1 || pageTo < pageFrom {
+		return fmt.Errorf("invalid page range %d-%d", pageFrom, pageTo)
+	}
+
+	return pdfutil.ExtractPageRange(inputPath, outputPath, pageFrom, pageTo, false)
+}
+
+// SplitPdfFile splits `inputPath` into single page documents saved in `outputDir`.
+// Unused XObjects are removed from each page so that the parts do not carry copies of
+// the resources of the whole document. It returns the paths of the written files.
+func SplitPdfFile(inputPath string, outputDir string) ([]string, error) {
+	pdfReader, f, err := model.NewPdfReaderFromFile(inputPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return nil, err
+	}
+
+	numPages, err := pdfReader.GetNumPages()
+	if err != nil {
+		return nil, err
+	}
+
+	base := strings.TrimSuffix(filepath.Base(inputPath), filepath.Ext(inputPath))
+
+	var outputPaths []string
+	for i := 0; i < numPages; i++ {
+		pageNum := i + 1
+
+		page, err := pdfReader.GetPage(pageNum)
+		if err != nil {
+			return outputPaths, err
+		}
+		CleanUnusedXObjects(page)
+
+		w := model.NewPdfWriter()
+		if err := w.AddPage(page); err != nil {
+			return outputPaths, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+
+		outputPath := filepath.Join(outputDir, fmt.Sprintf("%s_page_%d.pdf", base, pageNum))
+		if err := w.WriteToFile(outputPath); err != nil {
+			return outputPaths, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+		outputPaths = append(outputPaths, outputPath)
+	}
+
+	return outputPaths, nil
+}
+
+// CleanUnusedXObjects removes entries of unused XObjects from the page's XObject resource dictionary.
+func CleanUnusedXObjects(page *model.PdfPage) {
+	if page.Resources == nil {
+		return
+	}
+
+	contents, err := page.GetAllContentStreams()
+	if err != nil {
+		common.Log.Debug("failed to get page content stream: %v", err)
+		return
+	}
+	operations, err := contentstream.NewContentStreamParser(contents).Parse()
+	if err != nil {
+		common.Log.Debug("failed to parse content stream: %v", err)
+		return
+	}
+
+	used := map[string]bool{}
+	for _, op := range *operations {
+		// Check for `Do` (Draw XObject) operator.
+		if op.Operand == "Do" && len(op.Params) > 0 {
+			if name, ok := core.GetName(op.Params[0]); ok {
+				used[name.String()] = true
+			}
+		}
+	}
+
+	dict, ok := core.GetDict(page.Resources.XObject)
+	if !ok {
+		return
+	}
+	for _, key := range dict.Keys() {
+		if !used[key.String()] {
+			dict.Remove(key)
+		}
+	}
+}
+
+// CropPage trims `percentage` percent off the width and height of the page's media box,
+// keeping the page middle in view.
+func CropPage(page *model.PdfPage, percentage float64) error {
+	if percentage < 0 || percentage > 100 {
+		return fmt.Errorf("percentage should be in the range 0 - 100, got %v", percentage)
+	}
+
+	bbox, err := page.GetMediaBox()
+	if err != nil {
+		return err
+	}
+
+	// Zoom in on the page middle, with a scaled width and height.
+	width := bbox.Urx - bbox.Llx
+	height := bbox.Ury - bbox.Lly
+	newWidth := width * percentage / 100.0
+	newHeight := height * percentage / 100.0
+	bbox.Llx += newWidth / 2
+	bbox.Lly += newHeight / 2
+	bbox.Urx -= newWidth / 2
+	bbox.Ury -= newHeight / 2
+
+	page.MediaBox = bbox
+
+	return nil
+}
+
+// CropPdf crops all pages of `inputPath` by `percentage` and writes the result to `outputPath`.
+func CropPdf(inputPath string, outputPath string, percentage float64) error {
+	pdfReader, f, err := model.NewPdfReaderFromFile(inputPath, nil)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	// Process each page using the following callback
+	// when generating PdfWriter from PdfReader.
+	opts := &model.ReaderToWriterOpts{
+		PageProcessCallback: func(pageNum int, page *model.PdfPage) error {
+			return CropPage(page, percentage)
+		},
+	}
+
+	// Generate a PdfWriter instance from existing PdfReader.
+	pdfWriter, err := pdfReader.ToWriter(opts)
+	if err != nil {
+		return err
+	}
+
+	return pdfWriter.WriteToFile(outputPath)
+}
+
+// RotatePage rotates `page` clockwise by `degrees` relative to its current rotation.
+// Degrees needs to be a multiple of 90.
+func RotatePage(page *model.PdfPage, degrees int64) error {
+	if degrees%90 != 0 {
+		return fmt.Errorf("degrees needs to be a multiple of 90, got %d", degrees)
+	}
+
+	var rotate int64
+	if page.Rotate != nil {
+		rotate = *page.Rotate
+	}
+	rotate = ((rotate+degrees)%360 + 360) % 360
+	page.Rotate = &rotate
+
+	return nil
+}
+
+// RotatePdf rotates all pages of `inputPath` by `degrees` using the global rotation flag
+// and writes the result to `outputPath`. Degrees needs to be a multiple of 90.
+func RotatePdf(inputPath string, degrees int64, outputPath string) error {
+	if degrees%90 != 0 {
+		return fmt.Errorf("degrees needs to be a multiple of 90, got %d", degrees)
+	}
+
+	pdfReader, f, err := model.NewPdfReaderFromFile(inputPath, nil)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	pdfWriter, err := pdfReader.ToWriter(&model.ReaderToWriterOpts{})
+	if err != nil {
+		return err
+	}
+
+	// Rotate all page degrees.
+	if err := pdfWriter.SetRotation(degrees); err != nil {
+		return err
+	}
+
+	return pdfWriter.WriteToFile(outputPath)
+}
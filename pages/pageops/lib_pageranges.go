@@ -0,0 +1,98 @@
+/*
+ * Page selections such as "1-5,9,12-" shared by the examples and the unipdf command line tool.
+ */
+
+package pageops
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// PageRange is an inclusive range of pages. To is 0 for ranges open to the end of the document.
+type PageRange struct {
+	From, To int
+}
+
+// PageRanges is a selection of pages. An empty selection selects all pages.
+type PageRanges []PageRange
+
+// ParsePageRanges parses a comma separated list of page numbers and ranges, e.g. "1-5,9,12-".
+// An empty string or "all" selects all pages.
+func ParsePageRanges(s string) (PageRanges, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.EqualFold(s, "all") {
+		return nil, nil
+	}
+
+	var ranges PageRanges
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		from, to, isRange := strings.Cut(part, "-")
+
+		var pr PageRange
+		var err error
+		pr.From, err = strconv.Atoi(strings.TrimSpace(from))
+		switch {
+		case err != nil:
+		case !isRange:
+			pr.To = pr.From
+		case strings.TrimSpace(to) != "":
+			pr.To, err = strconv.Atoi(strings.TrimSpace(to))
+		}
+		if err != nil || pr.From < 1 || (pr.To != 0 && pr.To < pr.From) {
+			return nil, fmt.Errorf("invalid page range %q", part)
+		}
+		ranges = append(ranges, pr)
+	}
+	return ranges, nil
+}
+
+// Open returns true if a range of `pr` is open to the end of the document, so that the selected
+// pages depend on the number of pages.
+func (pr PageRanges) Open() bool {
+	for _, r := range pr {
+		if r.To == 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Contains returns true if page `pageNum` is selected.
+func (pr PageRanges) Contains(pageNum int) bool {
+	if len(pr) == 0 {
+		return true
+	}
+	for _, r := range pr {
+		if pageNum >= r.From && (r.To == 0 || pageNum <= r.To) {
+			return true
+		}
+	}
+	return false
+}
+
+// Pages returns the selected pages of a document of `numPages` pages in selection order without
+// duplicates. All pages are returned for an empty selection.
+func (pr PageRanges) Pages(numPages int) []int {
+	if len(pr) == 0 {
+		pr = PageRanges{{From: 1}}
+	}
+
+	var pageNums []int
+	seen := map[int]bool{}
+	for _, r := range pr {
+		to := r.To
+		if to == 0 || to > numPages {
+			to = numPages
+		}
+		for pageNum := r.From; pageNum <= to; pageNum++ {
+			if !seen[pageNum] {
+				seen[pageNum] = true
+				pageNums = append(pageNums, pageNum)
+			}
+		}
+	}
+	return pageNums
+}
@@ -12,8 +12,8 @@ import (
 	"os"
 	"strconv"
 
+	"github.com/unidoc/unidoc-examples/pages/pageops"
 	"github.com/unidoc/unipdf/v4/common/license"
-	"github.com/unidoc/unipdf/v4/model"
 )
 
 func init() {
@@ -35,17 +35,18 @@ func main() {
 	percentageStr := os.Args[2]
 	outputPath := os.Args[3]
 
-	percentage, err := strconv.ParseInt(percentageStr, 10, 32)
+	percentage, err := strconv.ParseFloat(percentageStr, 64)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
 	}
 	if percentage < 0 || percentage > 100 {
-		fmt.Printf("Percentage should be in the range 0 - 100 (%)\n")
+		fmt.Printf("Percentage should be in the range 0 - 100 (%%)\n")
 		os.Exit(1)
 	}
 
-	err = cropPdf(inputPath, outputPath, percentage)
+	// Crop all pages by the given percentage.
+	err = pageops.CropPdf(inputPath, outputPath, percentage)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
@@ -53,51 +54,3 @@ func main() {
 
 	fmt.Printf("Complete, see output file: %s\n", outputPath)
 }
-
-// Crop all pages by a given percentage.
-func cropPdf(inputPath string, outputPath string, percentage int64) error {
-	pdfReader, f, err := model.NewPdfReaderFromFile(inputPath, nil)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	// Process each page using the following callback
-	// when generating PdfWriter from PdfReader.
-	opts := &model.ReaderToWriterOpts{
-		PageProcessCallback: func(pageNum int, page *model.PdfPage) error {
-			bbox, err := page.GetMediaBox()
-			if err != nil {
-				return err
-			}
-
-			// Zoom in on the page middle, with a scaled width and height.
-			width := (*bbox).Urx - (*bbox).Llx
-			height := (*bbox).Ury - (*bbox).Lly
-			newWidth := width * float64(percentage) / 100.0
-			newHeight := height * float64(percentage) / 100.0
-			(*bbox).Llx += newWidth / 2
-			(*bbox).Lly += newHeight / 2
-			(*bbox).Urx -= newWidth / 2
-			(*bbox).Ury -= newHeight / 2
-
-			page.MediaBox = bbox
-
-			return nil
-		},
-	}
-
-	// Generate a PdfWriter instance from existing PdfReader.
-	pdfWriter, err := pdfReader.ToWriter(opts)
-	if err != nil {
-		return err
-	}
-
-	// Write to file.
-	err = pdfWriter.WriteToFile(outputPath)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
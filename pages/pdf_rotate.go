@@ -14,8 +14,8 @@ import (
 	"os"
 	"strconv"
 
+	"github.com/unidoc/unidoc-examples/pages/pageops"
 	"github.com/unidoc/unipdf/v4/common/license"
-	"github.com/unidoc/unipdf/v4/model"
 )
 
 func init() {
@@ -46,7 +46,8 @@ func main() {
 		os.Exit(1)
 	}
 
-	err = rotatePdf(inputPath, degrees, outputPath)
+	// Rotate all pages by degrees.
+	err = pageops.RotatePdf(inputPath, degrees, outputPath)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
@@ -54,27 +55,3 @@ func main() {
 
 	fmt.Printf("Complete, see output file: %s\n", outputPath)
 }
-
-// Rotate all pages by degrees.
-func rotatePdf(inputPath string, degrees int64, outputPath string) error {
-	pdfReader, f, err := model.NewPdfReaderFromFile(inputPath, nil)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	pdfWriter, err := pdfReader.ToWriter(&model.ReaderToWriterOpts{})
-	if err != nil {
-		return nil
-	}
-
-	// Rotate all page degrees.
-	err = pdfWriter.SetRotation(degrees)
-	if err != nil {
-		return nil
-	}
-
-	pdfWriter.WriteToFile(outputPath)
-
-	return err
-}
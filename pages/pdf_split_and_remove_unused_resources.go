@@ -10,16 +10,12 @@
 package main
 
 import (
-	"bytes"
 	"fmt"
 	"log"
 	"os"
 
-	"github.com/unidoc/unipdf/v4/common"
+	"github.com/unidoc/unidoc-examples/pages/pageops"
 	"github.com/unidoc/unipdf/v4/common/license"
-	"github.com/unidoc/unipdf/v4/contentstream"
-	"github.com/unidoc/unipdf/v4/core"
-	"github.com/unidoc/unipdf/v4/model"
 )
 
 func init() {
@@ -40,91 +36,15 @@ func main() {
 	inputPath := os.Args[1]
 
 	outputDir := os.Args[2]
-	splitPdfFile(inputPath, outputDir)
-}
-
-// splitPdfFile splits inputFile and saves the output files in `outputDir`.
-func splitPdfFile(inputFile string, outputDir string) {
-	pdfBytes, err := os.ReadFile(inputFile)
-	if err != nil {
-		log.Fatalf("Failed to read file: %v", err)
-	}
-
-	r := bytes.NewReader(pdfBytes)
-	pdfReader, err := model.NewPdfReader(r)
-	if err != nil {
-		log.Fatalf("Failed to create reader: %v", err)
-	}
-
-	numPages, err := pdfReader.GetNumPages()
-	if err != nil {
-		log.Fatalf("Failed to get number of pages: %v", err)
-	}
-	for pageIdx := 0; pageIdx < numPages; pageIdx++ {
-		pdfPage, _ := pdfReader.GetPage(pageIdx + 1)
-		cleanUnusedXobjects(pdfPage)
-		w := model.NewPdfWriter()
-		if err := w.AddPage(pdfPage); err != nil {
-			log.Fatalf("Failed to convert pageIdx = %d, %v", pageIdx, err)
-		}
-		if err := w.WriteToFile(fmt.Sprintf("./%s/output/doc_page_%d_new.pdf", outputDir, pageIdx)); err != nil {
-			log.Fatalf("Failed to write to file %d: %v", pageIdx, err)
-		}
-	}
-}
 
-// cleanUnusedXobjects removes entries of unused XObjects from teh Resource's XObjects dictionary.
-func cleanUnusedXobjects(page *model.PdfPage) {
-	contents, err := page.GetAllContentStreams()
-	if err != nil {
-		common.Log.Debug("failed to get page content stream")
-	}
-	parser := contentstream.NewContentStreamParser(contents)
-	operations, err := parser.Parse()
+	// Each page is written to its own file after removing the unused entries
+	// of its XObject dictionary, see pageops.CleanUnusedXObjects.
+	outputPaths, err := pageops.SplitPdfFile(inputPath, outputDir)
 	if err != nil {
-		common.Log.Debug("failed to parse content stream")
-	}
-	usedObjectsNames := []string{}
-	for _, op := range *operations {
-		operand := op.Operand
-		// Check for `Do` (Draw XObject) operator.
-		if operand == "Do" {
-			params := op.Params
-			imageName := params[0].String()
-			usedObjectsNames = append(usedObjectsNames, imageName)
-		}
-	}
-
-	xObject := page.Resources.XObject
-	dict, ok := xObject.(*core.PdfObjectDictionary)
-	if ok {
-		keys := getKeys(dict)
-		for _, k := range keys {
-			if exists(k, usedObjectsNames) {
-				continue
-			}
-			name := *core.MakeName(k)
-			dict.Remove(name)
-		}
+		log.Fatalf("Failed to split file: %v", err)
 	}
 
-}
-
-// getKeys gets the keys of the dictionary `dict`.
-func getKeys(dict *core.PdfObjectDictionary) []string {
-	keys := []string{}
-	for _, k := range dict.Keys() {
-		keys = append(keys, k.String())
-	}
-	return keys
-}
-
-// exists checks if `element` exists in `elements`.
-func exists(element string, elements []string) bool {
-	for _, el := range elements {
-		if element == el {
-			return true
-		}
+	for _, outputPath := range outputPaths {
+		fmt.Printf("Created %s\n", outputPath)
 	}
-	return false
 }
@@ -0,0 +1,382 @@
+/*
+ * Pipeline runner applying a list of page operations (rotate, crop, watermark, optimize...) to a
+ * document in a single load/write pass, instead of re-parsing and re-serializing the document
+ * for each operation.
+ *
+ * A recipe is read from a JSON or YAML file, e.g.
+ *
+ *	steps:
+ *	  - op: rotate
+ *	    degrees: 90
+ *	  - op: crop
+ *	    percentage: 10
+ *	    pages: [1, 2]
+ *	  - op: watermark
+ *	    text: DRAFT
+ *	    font_path: Roboto-Regular.ttf
+ *	  - op: optimize
+ *	    image_quality: 80
+ *
+ * or built from a command line chain such as `rotate=90 crop=10 watermark=DRAFT optimize`.
+ */
+
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/unidoc/unidoc-examples/compress/compressops"
+	"github.com/unidoc/unidoc-examples/pages/pageops"
+	"github.com/unidoc/unipdf/v4/model"
+	"github.com/unidoc/unipdf/v4/model/optimize"
+	"gopkg.in/yaml.v3"
+)
+
+// Supported step operations.
+const (
+	OpRotate         = "rotate"
+	OpCrop           = "crop"
+	OpWatermark      = "watermark"
+	OpWatermarkImage = "watermark-image"
+	OpOptimize       = "optimize"
+)
+
+// Step is a single operation of a recipe. Only the fields relevant to `Op` are used.
+type Step struct {
+	Op string `json:"op" yaml:"op"`
+	// Pages restricts page operations to the listed pages. All pages are processed if empty.
+	Pages []int `json:"pages,omitempty" yaml:"pages,omitempty"`
+
+	// Degrees is the clockwise rotation of OpRotate, a multiple of 90.
+	Degrees int64 `json:"degrees,omitempty" yaml:"degrees,omitempty"`
+	// Percentage is the trim-off percentage of OpCrop.
+	Percentage float64 `json:"percentage,omitempty" yaml:"percentage,omitempty"`
+
+	// Text and the font options are used by OpWatermark.
+	Text     string  `json:"text,omitempty" yaml:"text,omitempty"`
+	FontPath string  `json:"font_path,omitempty" yaml:"font_path,omitempty"`
+	FontSize float64 `json:"font_size,omitempty" yaml:"font_size,omitempty"`
+	Color    string  `json:"color,omitempty" yaml:"color,omitempty"`
+	Angle    float64 `json:"angle,omitempty" yaml:"angle,omitempty"`
+	// ImagePath is the watermark image of OpWatermarkImage.
+	ImagePath string `json:"image_path,omitempty" yaml:"image_path,omitempty"`
+	// Alpha is the watermark opacity of OpWatermark and OpWatermarkImage.
+	Alpha float64 `json:"alpha,omitempty" yaml:"alpha,omitempty"`
+
+	// ImageQuality and ImagePPI override the defaults of OpOptimize.
+	ImageQuality int     `json:"image_quality,omitempty" yaml:"image_quality,omitempty"`
+	ImagePPI     float64 `json:"image_ppi,omitempty" yaml:"image_ppi,omitempty"`
+}
+
+// Recipe is an ordered list of steps.
+type Recipe struct {
+	Steps []Step `json:"steps" yaml:"steps"`
+}
+
+// LoadRecipe reads a recipe from a JSON (.json) or YAML (.yaml, .yml) file. Unknown keys are
+// rejected, so that a misspelled setting is not silently ignored.
+func LoadRecipe(path string) (*Recipe, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var recipe Recipe
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&recipe)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err = dec.Decode(&recipe); errors.Is(err, io.EOF) {
+			err = nil // Empty file.
+		}
+	default:
+		return nil, fmt.Errorf("unsupported recipe format %q (use .json, .yaml or .yml)", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := recipe.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &recipe, nil
+}
+
+// ParseChain builds a recipe from command line steps of the form `op` or `op=value`, e.g.
+// `rotate=90`, `crop=10`, `watermark=DRAFT`, `watermark-image=logo.png` and `optimize`.
+func ParseChain(args []string) (*Recipe, error) {
+	var recipe Recipe
+	for _, arg := range args {
+		op, value, _ := strings.Cut(arg, "=")
+		step := Step{Op: op}
+
+		var err error
+		switch op {
+		case OpRotate:
+			step.Degrees, err = strconv.ParseInt(value, 10, 64)
+		case OpCrop:
+			step.Percentage, err = strconv.ParseFloat(value, 64)
+		case OpWatermark:
+			step.Text = value
+		case OpWatermarkImage:
+			step.ImagePath = value
+		case OpOptimize:
+			if value != "" {
+				step.ImageQuality, err = strconv.Atoi(value)
+			}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid step %q: %w", arg, err)
+		}
+
+		recipe.Steps = append(recipe.Steps, step)
+	}
+
+	if err := recipe.Validate(); err != nil {
+		return nil, err
+	}
+	return &recipe, nil
+}
+
+// Validate checks that every step of the recipe is well formed.
+func (r *Recipe) Validate() error {
+	if len(r.Steps) == 0 {
+		return fmt.Errorf("recipe has no steps")
+	}
+
+	optimizeSteps := 0
+	for i, step := range r.Steps {
+		var err error
+		switch step.Op {
+		case OpRotate:
+			if step.Degrees%90 != 0 {
+				err = fmt.Errorf("degrees needs to be a multiple of 90, got %d", step.Degrees)
+			}
+		case OpCrop:
+			if step.Percentage < 0 || step.Percentage > 100 {
+				err = fmt.Errorf("percentage should be in the range 0 - 100, got %v", step.Percentage)
+			}
+		case OpWatermark:
+			if step.Text == "" {
+				err = fmt.Errorf("missing watermark text")
+			} else if step.Color != "" {
+				_, err = parseHexColor(step.Color)
+			}
+		case OpWatermarkImage:
+			if step.ImagePath == "" {
+				err = fmt.Errorf("missing watermark image path")
+			}
+		case OpOptimize:
+			optimizeSteps++
+			if len(step.Pages) > 0 {
+				err = fmt.Errorf("optimize applies to the whole document and does not accept pages")
+			} else if optimizeSteps > 1 {
+				err = fmt.Errorf("only one optimize step is allowed")
+			}
+		default:
+			err = fmt.Errorf("unknown operation %q", step.Op)
+		}
+		if err != nil {
+			return fmt.Errorf("step %d (%s): %w", i+1, step.Op, err)
+		}
+	}
+
+	return nil
+}
+
+// pageFunc applies a step to a single page.
+type pageFunc func(page *model.PdfPage) error
+
+// Run loads `inputPath` once, applies the steps of `recipe` in order and writes the result
+// to `outputPath`. Page operations are applied while the writer is generated from the reader and
+// an optimize step attaches the optimizer to the writer, so the document is serialized once.
+func Run(inputPath string, outputPath string, recipe *Recipe) error {
+	if err := recipe.Validate(); err != nil {
+		return err
+	}
+
+	// Prepare the page operations up front so that shared resources such as
+	// watermark images are loaded only once.
+	var pageFuncs []pageFunc
+	var pageSets []map[int]bool
+	var pageOps []string
+	var optimizer *optimize.Optimizer
+	for _, step := range recipe.Steps {
+		if step.Op == OpOptimize {
+			optimizer = optimize.New(optimizeOptions(step))
+			continue
+		}
+
+		fn, err := makePageFunc(step)
+		if err != nil {
+			return fmt.Errorf("%s: %w", step.Op, err)
+		}
+		pageFuncs = append(pageFuncs, fn)
+		pageSets = append(pageSets, pageSet(step.Pages))
+		pageOps = append(pageOps, step.Op)
+	}
+
+	pdfReader, f, err := model.NewPdfReaderFromFile(inputPath, nil)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	opts := &model.ReaderToWriterOpts{
+		PageProcessCallback: func(pageNum int, page *model.PdfPage) error {
+			for i, fn := range pageFuncs {
+				if pageSets[i] != nil && !pageSets[i][pageNum] {
+					continue
+				}
+				if err := fn(page); err != nil {
+					return fmt.Errorf("page %d: %s: %w", pageNum, pageOps[i], err)
+				}
+			}
+			return nil
+		},
+	}
+
+	// Generate a PdfWriter instance from existing PdfReader.
+	pdfWriter, err := pdfReader.ToWriter(opts)
+	if err != nil {
+		return err
+	}
+
+	if optimizer != nil {
+		pdfWriter.SetOptimizer(optimizer)
+	}
+
+	return pdfWriter.WriteToFile(outputPath)
+}
+
+// makePageFunc returns the page operation of `step`.
+func makePageFunc(step Step) (pageFunc, error) {
+	switch step.Op {
+	case OpRotate:
+		return func(page *model.PdfPage) error {
+			return pageops.RotatePage(page, step.Degrees)
+		}, nil
+	case OpCrop:
+		return func(page *model.PdfPage) error {
+			return pageops.CropPage(page, step.Percentage)
+		}, nil
+	case OpWatermark:
+		options := model.WatermarkTextOptions{
+			Alpha:     0.3,
+			FontSize:  40,
+			FontPath:  step.FontPath,
+			FontColor: color.RGBA{R: 255, G: 0, B: 0, A: 255},
+			Angle:     30,
+		}
+		if step.Alpha > 0 {
+			options.Alpha = step.Alpha
+		}
+		if step.FontSize > 0 {
+			options.FontSize = step.FontSize
+		}
+		if step.Angle != 0 {
+			options.Angle = step.Angle
+		}
+		if step.Color != "" {
+			c, err := parseHexColor(step.Color)
+			if err != nil {
+				return nil, err
+			}
+			options.FontColor = c
+		}
+		return func(page *model.PdfPage) error {
+			return page.AddWatermarkText(step.Text, options)
+		}, nil
+	case OpWatermarkImage:
+		xImage, err := loadXObjectImage(step.ImagePath)
+		if err != nil {
+			return nil, err
+		}
+		options := model.WatermarkImageOptions{Alpha: 0.5, FitToWidth: true}
+		if step.Alpha > 0 {
+			options.Alpha = step.Alpha
+		}
+		return func(page *model.PdfPage) error {
+			return page.AddWatermarkImage(xImage, options)
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown operation %q", step.Op)
+}
+
+// optimizeOptions returns the optimizer options of `step`.
+func optimizeOptions(step Step) optimize.Options {
+	opts := compressops.DefaultOptions()
+	if step.ImageQuality > 0 {
+		opts.ImageQuality = step.ImageQuality
+	}
+	if step.ImagePPI > 0 {
+		opts.ImageUpperPPI = step.ImagePPI
+	}
+	return opts
+}
+
+// loadXObjectImage loads the image at `path` as an image XObject.
+func loadXObjectImage(path string) (*model.XObjectImage, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	goImg, _, err := image.Decode(f)
+	if err != nil {
+		return nil, err
+	}
+
+	img, err := model.DefaultImageHandler{}.NewImageFromGoImage(goImg)
+	if err != nil {
+		return nil, err
+	}
+
+	return model.NewXObjectImageFromImage(img, nil, nil)
+}
+
+// pageSet returns the set of `pageNums` or nil if `pageNums` is empty.
+func pageSet(pageNums []int) map[int]bool {
+	if len(pageNums) == 0 {
+		return nil
+	}
+
+	set := make(map[int]bool, len(pageNums))
+	for _, pageNum := range pageNums {
+		set[pageNum] = true
+	}
+	return set
+}
+
+// parseHexColor parses colors of the form "#rrggbb".
+func parseHexColor(s string) (color.RGBA, error) {
+	hex := strings.TrimPrefix(s, "#")
+	if len(hex) != 6 {
+		return color.RGBA{}, fmt.Errorf("invalid color %q (expected #rrggbb)", s)
+	}
+
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return color.RGBA{}, fmt.Errorf("invalid color %q (expected #rrggbb)", s)
+	}
+
+	return color.RGBA{R: uint8(v >> 16), G: uint8(v >> 8), B: uint8(v), A: 255}, nil
+}
@@ -0,0 +1,72 @@
+/*
+ * Redact text by rules: Redacts the PII found by the named detectors (SSN, IBAN, phone, email,
+ * payment card, custom regex and term lists) of a rules file and writes a JSON audit report
+ * listing every redaction with its page, box, rule and the SHA-256 hash of the removed text.
+ *
+ * See redaction_rules.yaml for the rules file format.
+ *
+ * Run as: go run redact_rules.go rules.yaml input.pdf output.pdf [audit.json]
+ */
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/unidoc/unidoc-examples/redact/redactops"
+	"github.com/unidoc/unipdf/v4/common/license"
+)
+
+func init() {
+	// Make sure to load your metered License API key prior to using the library.
+	// If you need a key, you can sign up and create a free one at https://cloud.unidoc.io
+	err := license.SetMeteredKey(os.Getenv(`UNIDOC_LICENSE_API_KEY`))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func main() {
+	if len(os.Args) < 4 {
+		fmt.Printf("Usage: go run redact_rules.go rules.yaml input.pdf output.pdf [audit.json]\n")
+		os.Exit(1)
+	}
+
+	rulesPath, inputPath, outputPath := os.Args[1], os.Args[2], os.Args[3]
+	auditPath := strings.TrimSuffix(outputPath, ".pdf") + ".audit.json"
+	if len(os.Args) > 4 {
+		auditPath = os.Args[4]
+	}
+
+	rules, err := redactops.LoadRules(rulesPath)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	report, err := redactops.RedactFile(inputPath, outputPath, rules)
+	if errors.Is(err, redactops.ErrNotRedacted) {
+		// Keep the report listing the texts left in the output.
+		if werr := report.WriteJSON(auditPath); werr == nil {
+			fmt.Printf("Audit report: %s\n", auditPath)
+		}
+	}
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if err := report.WriteJSON(auditPath); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	for _, rc := range report.Rules {
+		fmt.Printf("%-20s %d redactions\n", rc.Rule, rc.Count)
+	}
+	fmt.Printf("Redacted document: %s\n", outputPath)
+	fmt.Printf("Audit report: %s\n", auditPath)
+}
@@ -0,0 +1,625 @@
+/*
+ * Rule driven redaction with a JSON audit report listing every redaction (page, box, rule and a
+ * hash of the removed text), so that what was removed can be proven without keeping the text.
+ *
+ * The matches of the rules are found on the extracted text of each page and validated (Luhn,
+ * IBAN checksum...). The matched texts are then removed with the unipdf redactor, one pass per fill
+ * color, and the labels are written over the redaction boxes. The redactor removes a text from
+ * all the pages of a document, so a text matched on a page is only removed from that page: the
+ * pages redacted with different texts are taken from different runs of the redactor. Finally the
+ * text of the output is extracted again to check that none of the matched texts is left.
+ */
+
+package redactops
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/unidoc/unipdf/v4/creator"
+	"github.com/unidoc/unipdf/v4/extractor"
+	"github.com/unidoc/unipdf/v4/model"
+	"github.com/unidoc/unipdf/v4/redactor"
+)
+
+// Match is a match of a rule on a page.
+type Match struct {
+	Page int
+	Rule *Rule
+	Text string
+	BBox model.PdfRectangle
+	// Extra is set for the occurrences of a matched text outside of the matches on the page of
+	// the match, e.g. where a match of another rule overlaps it. The redactor removes all the
+	// occurrences of a matched text on the page.
+	Extra bool
+
+	span      [2]int // Byte offsets of Text in the page text.
+	remaining bool   // Text is still found on the page of the output.
+}
+
+// Redaction is an entry of the audit report.
+type Redaction struct {
+	Page       int        `json:"page"`
+	BBox       [4]float64 `json:"bbox"`
+	Rule       string     `json:"rule"`
+	TextSHA256 string     `json:"text_sha256"`
+	Length     int        `json:"length"` // Number of characters of the redacted text.
+	Extra      bool       `json:"extra,omitempty"`
+	// Remaining is set if the text is still found on the page of the output, i.e. the redactor
+	// failed to remove it.
+	Remaining bool `json:"remaining,omitempty"`
+}
+
+// RuleCount is the number of redactions of a rule.
+type RuleCount struct {
+	Rule     string `json:"rule"`
+	Detector string `json:"detector"`
+	Count    int    `json:"count"`
+}
+
+// Report is the audit report of a redaction.
+type Report struct {
+	Input        string      `json:"input"`
+	InputSHA256  string      `json:"input_sha256"`
+	Output       string      `json:"output"`
+	OutputSHA256 string      `json:"output_sha256"`
+	Time         time.Time   `json:"time"`
+	Salted       bool        `json:"salted"`
+	Rules        []RuleCount `json:"rules"`
+	Redactions   []Redaction `json:"redactions"`
+	// Remaining is the number of redactions whose text is still found in the output.
+	Remaining int `json:"remaining"`
+}
+
+// ErrNotRedacted is returned by RedactFile, along with the report, when matched texts are still
+// found in the output.
+var ErrNotRedacted = errors.New("matched text still found in the redacted document")
+
+// pageText is the extracted text of a page.
+type pageText struct {
+	pageNum int
+	text    string
+	marks   *extractor.TextMarkArray
+}
+
+// FindMatches returns the matches of `rules` on the pages of `pdfReader` in page order. Where
+// matches of several rules overlap, the match of the first rule is kept.
+func FindMatches(pdfReader *model.PdfReader, rules *Rules) ([]Match, error) {
+	pages, err := extractPages(pdfReader)
+	if err != nil {
+		return nil, err
+	}
+	return findMatches(pages, rules), nil
+}
+
+// RedactFile redacts the matches of `rules` in `inputPath`, writes the result to `outputPath` and
+// returns the audit report. The text of `outputPath` is extracted again: if matched texts are still
+// found, their redactions are flagged Remaining and the report is returned with ErrNotRedacted.
+func RedactFile(inputPath, outputPath string, rules *Rules) (*Report, error) {
+	pdfReader, f, err := model.NewPdfReaderFromFile(inputPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	pages, err := extractPages(pdfReader)
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	matches := findMatches(pages, rules)
+	passes := redactionPasses(rules, matches)
+	matches = append(matches, extraMatches(pages, matches)...)
+
+	if err := applyRedactions(inputPath, outputPath, passes, matches); err != nil {
+		return nil, err
+	}
+	if err := checkRedacted(outputPath, matches); err != nil {
+		return nil, err
+	}
+	report, err := newReport(inputPath, outputPath, rules, matches)
+	if err != nil {
+		return nil, err
+	}
+	if report.Remaining > 0 {
+		return report, fmt.Errorf("%w: %d of %d redactions in %s", ErrNotRedacted, report.Remaining,
+			len(report.Redactions), outputPath)
+	}
+	return report, nil
+}
+
+// WriteJSON writes `r` to `path`.
+func (r *Report) WriteJSON(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if err := r.Write(f); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+// Write writes `r` as indented JSON to `w`.
+func (r *Report) Write(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// extractPages returns the text and marks of the pages of `pdfReader`.
+func extractPages(pdfReader *model.PdfReader) ([]pageText, error) {
+	numPages, err := pdfReader.GetNumPages()
+	if err != nil {
+		return nil, err
+	}
+
+	pages := make([]pageText, 0, numPages)
+	for i := 0; i < numPages; i++ {
+		pageNum := i + 1
+		page, err := pdfReader.GetPage(pageNum)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+		ex, err := extractor.New(page)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+		pt, _, _, err := ex.ExtractPageText()
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+		pages = append(pages, pageText{pageNum: pageNum, text: pt.Text(), marks: pt.Marks()})
+	}
+	return pages, nil
+}
+
+// findMatches returns the matches of `rules` on `pages`.
+func findMatches(pages []pageText, rules *Rules) []Match {
+	var matches []Match
+	for _, p := range pages {
+		var taken [][2]int
+		for i := range rules.Rules {
+			rule := &rules.Rules[i]
+			if !rule.AppliesTo(p.pageNum) {
+				continue
+			}
+			for _, span := range rule.Find(p.text) {
+				if overlaps(taken, span) {
+					continue
+				}
+				bbox, ok := spanBBox(p.marks, span)
+				if !ok {
+					continue
+				}
+				taken = append(taken, span)
+				matches = append(matches, Match{Page: p.pageNum, Rule: rule, Text: p.text[span[0]:span[1]], BBox: bbox, span: span})
+			}
+		}
+	}
+	return matches
+}
+
+// redactionPass is a run of the redactor removing the matched texts of each page from that page
+// with boxes of color `fillColor`.
+type redactionPass struct {
+	fillColor string
+	terms     map[int][]string // Matched texts by page number.
+}
+
+// add adds the text of `m` to the terms of its page.
+func (pass *redactionPass) add(m Match) {
+	if !slices.Contains(pass.terms[m.Page], m.Text) {
+		pass.terms[m.Page] = append(pass.terms[m.Page], m.Text)
+	}
+}
+
+// redactionPasses groups the matched texts by fill color, in the order of the rules.
+func redactionPasses(rules *Rules, matches []Match) []*redactionPass {
+	var passes []*redactionPass
+	byColor := map[string]*redactionPass{}
+	for i := range rules.Rules {
+		rule := &rules.Rules[i]
+		color := strings.ToLower(rule.FillColor)
+		for _, m := range matches {
+			if m.Rule != rule {
+				continue
+			}
+			pass, ok := byColor[color]
+			if !ok {
+				pass = &redactionPass{fillColor: color, terms: map[int][]string{}}
+				byColor[color] = pass
+				passes = append(passes, pass)
+			}
+			pass.add(m)
+		}
+	}
+	return passes
+}
+
+// extraMatches returns the occurrences on `pages` of the texts of `matches` on the same page that
+// are not matches. The redactor removes every occurrence of a term on the page, so these are
+// redacted too and must be reported.
+func extraMatches(pages []pageText, matches []Match) []Match {
+	type pageTerm struct {
+		pageNum int
+		text    string
+	}
+	rules := map[pageTerm]*Rule{}
+	terms := map[int][]string{}
+	taken := map[int][][2]int{}
+	for _, m := range matches {
+		taken[m.Page] = append(taken[m.Page], m.span)
+		key := pageTerm{m.Page, m.Text}
+		if _, ok := rules[key]; !ok {
+			rules[key] = m.Rule
+			terms[m.Page] = append(terms[m.Page], m.Text)
+		}
+	}
+
+	var extra []Match
+	for _, p := range pages {
+		for _, term := range terms[p.pageNum] {
+			pattern := regexp.MustCompile(regexp.QuoteMeta(term))
+			for _, loc := range pattern.FindAllStringIndex(p.text, -1) {
+				span := [2]int{loc[0], loc[1]}
+				if overlaps(taken[p.pageNum], span) {
+					continue
+				}
+				bbox, ok := spanBBox(p.marks, span)
+				if !ok {
+					continue
+				}
+				taken[p.pageNum] = append(taken[p.pageNum], span)
+				extra = append(extra, Match{Page: p.pageNum, Rule: rules[pageTerm{p.pageNum, term}], Text: term, BBox: bbox, Extra: true, span: span})
+			}
+		}
+	}
+	return extra
+}
+
+// applyRedactions runs the redaction `passes` on `inputPath` and writes the labels of `matches`,
+// saving the result to `outputPath`. Intermediate documents are written next to `outputPath`.
+func applyRedactions(inputPath, outputPath string, passes []*redactionPass, matches []Match) error {
+	hasLabels := false
+	for _, m := range matches {
+		hasLabels = hasLabels || m.Rule.Label != ""
+	}
+	if len(passes) == 0 {
+		return copyFile(inputPath, outputPath)
+	}
+
+	src := inputPath
+	for i, pass := range passes {
+		dst := outputPath
+		if i < len(passes)-1 || hasLabels {
+			tmp, err := tempPath(outputPath)
+			if err != nil {
+				return err
+			}
+			defer os.Remove(tmp)
+			dst = tmp
+		}
+
+		if err := redactPass(src, dst, pass); err != nil {
+			return err
+		}
+		src = dst
+	}
+
+	if hasLabels {
+		return drawLabels(src, outputPath, matches)
+	}
+	return nil
+}
+
+// redactPass removes the terms of each page of `pass` from that page of `inputPath` and saves the
+// result to `outputPath`. The pages with the same terms are redacted by the same run of the
+// redactor, which removes the terms from all the pages of the document, and each page is then
+// taken from the run of its terms.
+func redactPass(inputPath, outputPath string, pass *redactionPass) error {
+	pdfReader, f, err := model.NewPdfReaderFromFile(inputPath, nil)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	numPages, err := pdfReader.GetNumPages()
+	if err != nil {
+		return err
+	}
+
+	// Index of the terms of each page in `termSets`, -1 for the pages without terms.
+	var termSets [][]string
+	pageSets := make([]int, numPages)
+	index := map[string]int{}
+	for i := range pageSets {
+		terms := pass.terms[i+1]
+		if len(terms) == 0 {
+			pageSets[i] = -1
+			continue
+		}
+		key := strings.Join(terms, "\x00")
+		set, ok := index[key]
+		if !ok {
+			set = len(termSets)
+			index[key] = set
+			termSets = append(termSets, terms)
+		}
+		pageSets[i] = set
+	}
+
+	if len(termSets) == 1 && !slices.Contains(pageSets, -1) {
+		return redactTerms(pdfReader, outputPath, termSets[0], pass.fillColor)
+	}
+
+	redacted := make([]*model.PdfReader, len(termSets))
+	for i, terms := range termSets {
+		// The redactor modifies the pages of its reader, so each run gets a reader of its own.
+		r, rf, err := model.NewPdfReaderFromFile(inputPath, nil)
+		if err != nil {
+			return err
+		}
+		defer rf.Close()
+		tmp, err := tempPath(outputPath)
+		if err != nil {
+			return err
+		}
+		defer os.Remove(tmp)
+		if err := redactTerms(r, tmp, terms, pass.fillColor); err != nil {
+			return err
+		}
+
+		redacted[i], rf, err = model.NewPdfReaderFromFile(tmp, nil)
+		if err != nil {
+			return err
+		}
+		defer rf.Close()
+	}
+
+	c := creator.New()
+	for i, set := range pageSets {
+		src := pdfReader
+		if set >= 0 {
+			src = redacted[set]
+		}
+		page, err := src.GetPage(i + 1)
+		if err != nil {
+			return err
+		}
+		if _, err := addPage(c, page); err != nil {
+			return fmt.Errorf("page %d: %w", i+1, err)
+		}
+	}
+	c.SetOutlineTree(pdfReader.GetOutlineTree())
+	return c.WriteToFile(outputPath)
+}
+
+// redactTerms removes `terms` from all the pages of `pdfReader` with boxes of color `fillColor`
+// and saves the result to `outputPath`.
+func redactTerms(pdfReader *model.PdfReader, outputPath string, terms []string, fillColor string) error {
+	redactionTerms := make([]redactor.RedactionTerm, len(terms))
+	for i, term := range terms {
+		redactionTerms[i] = redactor.RedactionTerm{Pattern: regexp.MustCompile(regexp.QuoteMeta(term))}
+	}
+	rectProps := &redactor.RectangleProps{
+		FillColor:   creator.ColorRGBFromHex(fillColor),
+		BorderWidth: 0.0,
+		FillOpacity: 1.0,
+	}
+
+	red := redactor.New(pdfReader, &redactor.RedactionOptions{Terms: redactionTerms}, rectProps)
+	if err := red.Redact(); err != nil {
+		return err
+	}
+	return red.WriteToFile(outputPath)
+}
+
+// drawLabels writes the labels of the rules of `matches` over their redaction boxes in
+// `inputPath` and saves the result to `outputPath`.
+func drawLabels(inputPath, outputPath string, matches []Match) error {
+	pdfReader, f, err := model.NewPdfReaderFromFile(inputPath, nil)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	numPages, err := pdfReader.GetNumPages()
+	if err != nil {
+		return err
+	}
+
+	c := creator.New()
+	for i := 0; i < numPages; i++ {
+		pageNum := i + 1
+		page, err := pdfReader.GetPage(pageNum)
+		if err != nil {
+			return err
+		}
+		mediaBox, err := addPage(c, page)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range matches {
+			if m.Page != pageNum || m.Rule.Label == "" {
+				continue
+			}
+			r := m.BBox
+			height := r.Ury - r.Lly
+			fontSize := math.Max(math.Min(0.8*height, 10), 4)
+
+			p := c.NewStyledParagraph()
+			p.SetText(m.Rule.Label)
+			p.SetFontSize(fontSize)
+			p.SetFontColor(creator.ColorRGBFromHex(m.Rule.LabelColor))
+			p.SetEnableWrap(false)
+			p.SetTextOverflow(creator.TextOverflowHidden)
+			p.SetWidth(r.Urx - r.Llx)
+			p.SetTextAlignment(creator.TextAlignmentCenter)
+			p.SetPos(r.Llx, mediaBox.Ury-r.Ury+(height-fontSize)/2)
+			if err := c.Draw(p); err != nil {
+				return fmt.Errorf("page %d: %w", pageNum, err)
+			}
+		}
+	}
+
+	c.SetOutlineTree(pdfReader.GetOutlineTree())
+	return c.WriteToFile(outputPath)
+}
+
+// addPage adds `page` to `c` and returns its media box.
+func addPage(c *creator.Creator, page *model.PdfPage) (*model.PdfRectangle, error) {
+	mediaBox, err := page.GetMediaBox()
+	if err != nil {
+		return nil, err
+	}
+	if page.MediaBox == nil {
+		// Deal with MediaBox inherited from Parent.
+		page.MediaBox = mediaBox
+	}
+	return mediaBox, c.AddPage(page)
+}
+
+// newReport returns the audit report of the redaction of `matches` from `inputPath` to
+// `outputPath`.
+func newReport(inputPath, outputPath string, rules *Rules, matches []Match) (*Report, error) {
+	inputHash, err := fileSHA256(inputPath)
+	if err != nil {
+		return nil, err
+	}
+	outputHash, err := fileSHA256(outputPath)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		Input:        inputPath,
+		InputSHA256:  inputHash,
+		Output:       outputPath,
+		OutputSHA256: outputHash,
+		Time:         time.Now().UTC(),
+		Salted:       rules.HashSalt != "",
+		Redactions:   []Redaction{},
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool { return matches[i].Page < matches[j].Page })
+	counts := map[*Rule]int{}
+	for _, m := range matches {
+		counts[m.Rule]++
+		sum := sha256.Sum256([]byte(rules.HashSalt + m.Text))
+		report.Redactions = append(report.Redactions, Redaction{
+			Page:       m.Page,
+			BBox:       [4]float64{m.BBox.Llx, m.BBox.Lly, m.BBox.Urx, m.BBox.Ury},
+			Rule:       m.Rule.Name,
+			TextSHA256: hex.EncodeToString(sum[:]),
+			Length:     utf8.RuneCountInString(m.Text),
+			Extra:      m.Extra,
+			Remaining:  m.remaining,
+		})
+		if m.remaining {
+			report.Remaining++
+		}
+	}
+	for i := range rules.Rules {
+		rule := &rules.Rules[i]
+		report.Rules = append(report.Rules, RuleCount{Rule: rule.Name, Detector: rule.Detector, Count: counts[rule]})
+	}
+	return report, nil
+}
+
+// checkRedacted sets `remaining` on the `matches` whose text is still found on their page of the
+// redacted document `outputPath`. The labels drawn on a page are ignored, as they may contain
+// the text.
+func checkRedacted(outputPath string, matches []Match) error {
+	pdfReader, f, err := model.NewPdfReaderFromFile(outputPath, nil)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	pages, err := extractPages(pdfReader)
+	if err != nil {
+		return err
+	}
+
+	texts := map[int]string{}
+	for _, page := range pages {
+		texts[page.pageNum] = page.text
+	}
+	for _, m := range matches {
+		if m.Rule.Label != "" {
+			texts[m.Page] = strings.ReplaceAll(texts[m.Page], m.Rule.Label, " ")
+		}
+	}
+	for i := range matches {
+		m := &matches[i]
+		m.remaining = m.Text != "" && strings.Contains(texts[m.Page], m.Text)
+	}
+	return nil
+}
+
+// spanBBox returns the bounding box of the marks of the text at byte offsets `span`.
+func spanBBox(marks *extractor.TextMarkArray, span [2]int) (model.PdfRectangle, bool) {
+	spanMarks, err := marks.RangeOffset(span[0], span[1])
+	if err != nil {
+		return model.PdfRectangle{}, false
+	}
+	return spanMarks.BBox()
+}
+
+// overlaps returns true if `span` overlaps any of `spans`.
+func overlaps(spans [][2]int, span [2]int) bool {
+	for _, s := range spans {
+		if span[0] < s[1] && s[0] < span[1] {
+			return true
+		}
+	}
+	return false
+}
+
+// fileSHA256 returns the hex encoded SHA-256 hash of the file at `path`.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// tempPath returns the path of a new empty temporary PDF file next to `path`.
+func tempPath(path string) (string, error) {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "redact-*.pdf")
+	if err != nil {
+		return "", err
+	}
+	return tmp.Name(), tmp.Close()
+}
+
+// copyFile copies `inputPath` to `outputPath`.
+func copyFile(inputPath, outputPath string) error {
+	data, err := os.ReadFile(inputPath)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(outputPath, data, 0644)
+}
@@ -0,0 +1,432 @@
+/*
+ * Redaction rules: named PII detectors, custom patterns and term lists together with the style of
+ * their redaction boxes and the pages they apply to, read from a JSON or YAML file, e.g.
+ *
+ *	hash_salt: change-me
+ *	rules:
+ *	  - name: ssn
+ *	    detector: ssn
+ *	    label: "[SSN]"
+ *	  - name: cards
+ *	    detector: card
+ *	    fill_color: "#202020"
+ *	    pages: 1-3,7,10-
+ *	  - name: project names
+ *	    detector: terms
+ *	    terms: [Project Falcon, Bluebird]
+ *	    ignore_case: true
+ *	  - name: employee ids
+ *	    detector: regex
+ *	    pattern: 'Employee ID: (?P<redact>E\d{6})'
+ */
+
+package redactops
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/unidoc/unidoc-examples/pages/pageops"
+	"gopkg.in/yaml.v3"
+)
+
+// Built-in detectors.
+const (
+	DetectorSSN   = "ssn"   // US social security numbers.
+	DetectorIBAN  = "iban"  // International bank account numbers with a valid checksum.
+	DetectorPhone = "phone" // Phone numbers of 7 to 15 digits.
+	DetectorEmail = "email" // Email addresses.
+	DetectorCard  = "card"  // Payment card numbers passing the Luhn check.
+	DetectorRegex = "regex" // The rule pattern.
+	DetectorTerms = "terms" // The rule terms as whole words.
+)
+
+// Detector finds the candidate matches of Pattern and keeps those accepted by Valid, all of them
+// if Valid is nil.
+type Detector struct {
+	Pattern *regexp.Regexp
+	Valid   func(match string) bool
+	// Shorten retries the candidates rejected by Valid on their prefixes ending before a space or
+	// hyphen, longest first, then on the text after their first space or hyphen. The greedy
+	// patterns of grouped numbers take in the words and numbers around a value, e.g. "BIC" in
+	// "DE89 3704 0044 0532 0130 00 BIC", which would otherwise hide it. Only for patterns without
+	// a "redact" group.
+	Shorten bool
+}
+
+// Detectors are the built-in named detectors.
+var Detectors = map[string]Detector{
+	DetectorSSN: {
+		Pattern: regexp.MustCompile(`\b\d{3}[- ]\d{2}[- ]\d{4}\b`),
+		Valid:   validSSN,
+	},
+	DetectorIBAN: {
+		Pattern: regexp.MustCompile(`\b[A-Z]{2}\d{2}(?: ?[A-Z0-9]){11,30}\b`),
+		Valid:   validIBAN,
+		Shorten: true,
+	},
+	DetectorPhone: {
+		Pattern: regexp.MustCompile(`(?:\+\d{1,3}[ .-]?)?(?:\(\d{1,4}\)[ .-]?)?\b\d{2,4}(?:[ .-]\d{2,4}){1,4}\b`),
+		Valid:   validPhone,
+	},
+	DetectorEmail: {
+		Pattern: regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`),
+	},
+	DetectorCard: {
+		Pattern: regexp.MustCompile(`\b(?:\d[ -]?){12,18}\d\b`),
+		Valid:   validCard,
+		Shorten: true,
+	},
+}
+
+// Rule is a named detector with the style of its redaction boxes.
+type Rule struct {
+	Name string `json:"name" yaml:"name"`
+	// Detector is one of the Detector* constants.
+	Detector string `json:"detector" yaml:"detector"`
+	// Pattern is the regular expression of DetectorRegex. If it has a group named "redact", only
+	// the text of the group is redacted.
+	Pattern string `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	// Terms are the words or phrases of DetectorTerms.
+	Terms []string `json:"terms,omitempty" yaml:"terms,omitempty"`
+	// IgnoreCase makes Pattern and Terms case insensitive.
+	IgnoreCase bool `json:"ignore_case,omitempty" yaml:"ignore_case,omitempty"`
+
+	// FillColor is the "#rrggbb" color of the redaction boxes. Defaults to black.
+	FillColor string `json:"fill_color,omitempty" yaml:"fill_color,omitempty"`
+	// Label is the text written over the redaction boxes, e.g. "[REDACTED]". No text if empty.
+	Label string `json:"label,omitempty" yaml:"label,omitempty"`
+	// LabelColor is the "#rrggbb" color of Label. Defaults to white.
+	LabelColor string `json:"label_color,omitempty" yaml:"label_color,omitempty"`
+	// Pages restricts the rule to page ranges such as "1-3,7,10-". All pages if empty.
+	Pages string `json:"pages,omitempty" yaml:"pages,omitempty"`
+
+	detector Detector
+	pages    pageops.PageRanges
+}
+
+// Rules is an ordered list of rules. When matches of several rules overlap, the first rule wins.
+type Rules struct {
+	// HashSalt is prepended to the matched text before hashing it in the audit report, so that
+	// short values such as SSNs cannot be recovered by hashing all possible values.
+	HashSalt string `json:"hash_salt,omitempty" yaml:"hash_salt,omitempty"`
+	Rules    []Rule `json:"rules" yaml:"rules"`
+}
+
+// LoadRules reads rules from a JSON (.json) or YAML (.yaml, .yml) file. Unknown keys are rejected,
+// so that a misspelled setting, e.g. of a detector, is not silently ignored.
+func LoadRules(path string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rules Rules
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&rules)
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(bytes.NewReader(data))
+		dec.KnownFields(true)
+		if err = dec.Decode(&rules); errors.Is(err, io.EOF) {
+			err = nil // Empty file.
+		}
+	default:
+		return nil, fmt.Errorf("unsupported rules format %q (use .json, .yaml or .yml)", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := rules.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &rules, nil
+}
+
+// Validate checks the rules and prepares their detectors. It is called by LoadRules and must be
+// called on rules built in code before use.
+func (r *Rules) Validate() error {
+	if len(r.Rules) == 0 {
+		return fmt.Errorf("no rules")
+	}
+
+	names := map[string]bool{}
+	for i := range r.Rules {
+		rule := &r.Rules[i]
+		if rule.Name == "" {
+			rule.Name = rule.Detector
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("rule %d: duplicate name %q", i+1, rule.Name)
+		}
+		names[rule.Name] = true
+
+		if err := rule.prepare(); err != nil {
+			return fmt.Errorf("rule %q: %w", rule.Name, err)
+		}
+	}
+	return nil
+}
+
+// prepare checks `r` and sets up its detector and page ranges.
+func (r *Rule) prepare() error {
+	prefix := ""
+	if r.IgnoreCase {
+		prefix = "(?i)"
+	}
+
+	switch r.Detector {
+	case DetectorRegex:
+		if r.Pattern == "" {
+			return fmt.Errorf("missing pattern")
+		}
+		re, err := regexp.Compile(prefix + r.Pattern)
+		if err != nil {
+			return err
+		}
+		// A pattern matching the empty string would match everywhere.
+		if re.MatchString("") {
+			return fmt.Errorf("pattern %q matches the empty string", r.Pattern)
+		}
+		r.detector = Detector{Pattern: re}
+	case DetectorTerms:
+		if len(r.Terms) == 0 {
+			return fmt.Errorf("missing terms")
+		}
+		for i, t := range r.Terms {
+			if strings.TrimSpace(t) == "" {
+				return fmt.Errorf("term %d is blank", i+1)
+			}
+		}
+		r.detector = Detector{Pattern: termsPattern(prefix, r.Terms)}
+	default:
+		d, ok := Detectors[r.Detector]
+		if !ok {
+			return fmt.Errorf("unknown detector %q", r.Detector)
+		}
+		r.detector = d
+	}
+
+	for _, c := range []string{r.FillColor, r.LabelColor} {
+		if c != "" && !reHexColor.MatchString(c) {
+			return fmt.Errorf("invalid color %q (expected #rrggbb)", c)
+		}
+	}
+	if r.FillColor == "" {
+		r.FillColor = "#000000"
+	}
+	if r.LabelColor == "" {
+		r.LabelColor = "#ffffff"
+	}
+
+	var err error
+	r.pages, err = pageops.ParsePageRanges(r.Pages)
+	return err
+}
+
+// AppliesTo returns true if `r` applies to page `pageNum`.
+func (r *Rule) AppliesTo(pageNum int) bool {
+	return r.pages.Contains(pageNum)
+}
+
+// Find returns the [start, end) byte offsets of the valid matches of `r` in `text`.
+func (r *Rule) Find(text string) [][2]int {
+	re := r.detector.Pattern
+	group := re.SubexpIndex("redact")
+	if r.detector.Shorten && group < 0 {
+		return r.findShortened(text)
+	}
+
+	var spans [][2]int
+	for _, loc := range re.FindAllStringSubmatchIndex(text, -1) {
+		start, end := loc[0], loc[1]
+		if group > 0 {
+			start, end = loc[2*group], loc[2*group+1]
+			if start < 0 {
+				continue
+			}
+		}
+		if start == end {
+			continue
+		}
+		if r.detector.Valid != nil && !r.detector.Valid(text[start:end]) {
+			continue
+		}
+		spans = append(spans, [2]int{start, end})
+	}
+	return spans
+}
+
+// findShortened is Find for the detectors with Shorten.
+func (r *Rule) findShortened(text string) [][2]int {
+	re, valid := r.detector.Pattern, r.detector.Valid
+	var spans [][2]int
+	for pos := 0; pos < len(text); {
+		loc := re.FindStringIndex(text[pos:])
+		if loc == nil {
+			break
+		}
+		start, end := pos+loc[0], pos+loc[1]
+		if start == end {
+			pos = end + 1
+			continue
+		}
+		match := text[start:end]
+		if valid == nil || valid(match) {
+			spans = append(spans, [2]int{start, end})
+			pos = end
+			continue
+		}
+
+		pos = end
+		for i := len(match) - 1; i > 0; i-- {
+			if isSeparator(match[i]) && !isSeparator(match[i-1]) && valid(match[:i]) {
+				spans = append(spans, [2]int{start, start + i})
+				pos = start + i
+				break
+			}
+		}
+		if pos == end {
+			if i := strings.IndexAny(match, " -"); i >= 0 {
+				pos = start + i + 1
+			}
+		}
+	}
+	return spans
+}
+
+// isSeparator returns true for the separators of the groups of digits of the detectors.
+func isSeparator(c byte) bool {
+	return c == ' ' || c == '-'
+}
+
+var reHexColor = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// termsPattern returns a pattern matching any of the non blank `terms`, longest first. Terms
+// starting or ending with an ASCII letter or digit only match whole words.
+func termsPattern(prefix string, terms []string) *regexp.Regexp {
+	sorted := append([]string(nil), terms...)
+	sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	alternatives := make([]string, 0, len(sorted))
+	for _, t := range sorted {
+		t = strings.TrimSpace(t)
+		quoted := strings.Join(strings.Fields(regexp.QuoteMeta(t)), `\s+`)
+		if isWordRune(firstRune(t)) {
+			quoted = `\b` + quoted
+		}
+		if isWordRune(lastRune(t)) {
+			quoted += `\b`
+		}
+		alternatives = append(alternatives, quoted)
+	}
+	return regexp.MustCompile(prefix + "(?:" + strings.Join(alternatives, "|") + ")")
+}
+
+// validSSN returns true if `s` is a possible US social security number: area not 000, 666 or
+// 9xx, group not 00 and serial not 0000.
+func validSSN(s string) bool {
+	d := digits(s)
+	if len(d) != 9 {
+		return false
+	}
+	area, group, serial := d[:3], d[3:5], d[5:]
+	return area != "000" && area != "666" && area[0] != '9' && group != "00" && serial != "0000"
+}
+
+// validIBAN returns true if `s` is an IBAN with a valid ISO 13616 mod 97 checksum.
+func validIBAN(s string) bool {
+	iban := strings.ReplaceAll(s, " ", "")
+	if len(iban) < 15 || len(iban) > 34 {
+		return false
+	}
+
+	// Move the country code and check digits to the end and replace letters with 10..35.
+	var sb strings.Builder
+	for _, r := range iban[4:] + iban[:4] {
+		switch {
+		case r >= '0' && r <= '9':
+			sb.WriteRune(r)
+		case r >= 'A' && r <= 'Z':
+			sb.WriteString(strconv.Itoa(int(r-'A') + 10))
+		default:
+			return false
+		}
+	}
+	n, ok := new(big.Int).SetString(sb.String(), 10)
+	return ok && new(big.Int).Mod(n, big.NewInt(97)).Int64() == 1
+}
+
+// validPhone returns true if `s` has 7 to 15 digits and does not look like a date or an SSN.
+func validPhone(s string) bool {
+	n := len(digits(s))
+	return n >= 7 && n <= 15 && !reNotPhone.MatchString(s)
+}
+
+var reNotPhone = regexp.MustCompile(`^\d{4}[-.]\d{2}[-.]\d{2}$|^\d{2}[-.]\d{2}[-.]\d{4}$|^\d{3}-\d{2}-\d{4}$`)
+
+// validCard returns true if `s` has 13 to 19 digits passing the Luhn check.
+func validCard(s string) bool {
+	d := digits(s)
+	if len(d) < 13 || len(d) > 19 {
+		return false
+	}
+
+	sum := 0
+	for i := range d {
+		v := int(d[len(d)-1-i] - '0')
+		if i%2 == 1 {
+			v *= 2
+			if v > 9 {
+				v -= 9
+			}
+		}
+		sum += v
+	}
+	return sum%10 == 0
+}
+
+// digits returns the ASCII digits of `s`.
+func digits(s string) string {
+	var sb strings.Builder
+	for _, r := range s {
+		if r >= '0' && r <= '9' {
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// isWordRune returns true for the ASCII word characters of the \b assertion of regexp.
+func isWordRune(r rune) bool {
+	return r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_')
+}
+
+func firstRune(s string) rune {
+	for _, r := range s {
+		return r
+	}
+	return 0
+}
+
+func lastRune(s string) rune {
+	r := []rune(s)
+	if len(r) == 0 {
+		return 0
+	}
+	return r[len(r)-1]
+}
@@ -0,0 +1,118 @@
+/*
+ * This example shows how to apply many search to replacement mappings, read from a CSV or JSON
+ * file, to all pages of many documents, e.g. to rename products across a collection of manuals.
+ * Mappings can be regular expressions with capture groups, see replace_mapping.csv.
+ *
+ * Replacements needing characters that are missing from the (often subset) embedded font are not
+ * applied and are reported instead. With -dry-run, nothing is written and every change that would
+ * be made is listed with its page and bounding box.
+ *
+ * Run as: go run replace_batch.go [-dry-run] [-workers N] [-report report.json] <mapping.csv|mapping.json> <output_dir> <input.pdf|input_dir>...
+ *
+ * Example: go run replace_batch.go -dry-run replace_mapping.csv ./output ./test-data
+ */
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+
+	"github.com/unidoc/unidoc-examples/concurrent-processing/batch"
+	"github.com/unidoc/unidoc-examples/search-and-replace/replaceops"
+	"github.com/unidoc/unipdf/v4/common/license"
+)
+
+func init() {
+	// Make sure to load your metered License API key prior to using the library.
+	// If you need a key, you can sign up and create a free one at https://cloud.unidoc.io
+	err := license.SetMeteredKey(os.Getenv(`UNIDOC_LICENSE_API_KEY`))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func main() {
+	var opts batch.Options
+	dryRun := flag.Bool("dry-run", false, "list the changes without writing any file")
+	reportPath := flag.String("report", "", "JSON report of the changes")
+	flag.IntVar(&opts.Workers, "workers", 0, "number of files processed concurrently (default: number of CPUs)")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) < 3 {
+		fmt.Printf("Usage: go run replace_batch.go [-dry-run] [-workers N] [-report report.json] mapping.csv|mapping.json output_dir input.pdf|input_dir...\n")
+		os.Exit(1)
+	}
+
+	mappings, err := replaceops.LoadMappings(args[0])
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	opts.OnResult = func(res batch.FileResult) {
+		if res.Error != "" {
+			fmt.Printf("FAIL %s: %s\n", res.File, res.Error)
+		}
+	}
+	report, runErr := replaceops.ReplaceFiles(ctx, args[2:], args[1], mappings, *dryRun, opts)
+	if report == nil {
+		fmt.Printf("Error: %v\n", runErr)
+		os.Exit(1)
+	}
+
+	for _, fr := range report.Files {
+		for _, c := range fr.Changes {
+			status := "replace"
+			if *dryRun {
+				status = "would replace"
+			}
+			if !c.Applied {
+				status = fmt.Sprintf("SKIP missing glyphs %q in %s:", strings.Join(c.MissingGlyphs, ""), c.Font)
+			}
+			fmt.Printf("%s page %d {%.2f %.2f %.2f %.2f} %s %q -> %q\n",
+				fr.Input, c.Page, c.BBox[0], c.BBox[1], c.BBox[2], c.BBox[3], status, c.Text, c.Replacement)
+		}
+	}
+
+	missing := report.MissingGlyphs()
+	fonts := make([]string, 0, len(missing))
+	for font := range missing {
+		fonts = append(fonts, font)
+	}
+	sort.Strings(fonts)
+	for _, font := range fonts {
+		fmt.Printf("Font %q is missing glyphs %q\n", font, strings.Join(missing[font], ""))
+	}
+	fmt.Printf("%d files: %d changes applied, %d skipped, %d files failed\n",
+		len(report.Files), report.Applied, report.Skipped, report.Summary.Failed)
+
+	if *reportPath != "" {
+		f, err := os.Create(*reportPath)
+		if err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		defer f.Close()
+		if err := report.WriteJSON(f); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+	}
+	if runErr != nil {
+		fmt.Printf("Error: %v\n", runErr)
+		os.Exit(1)
+	}
+	if report.Summary.Failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,188 @@
+/*
+ * Search to replacement mappings read from a CSV or JSON file, e.g.
+ *
+ *	search,replace,regex,ignore_case,whole_word
+ *	Acme Widget,Globex Gadget,,,true
+ *	Acme (\w+) Edition,Globex $1 Edition,true,,
+ *
+ * or
+ *
+ *	[
+ *	  {"search": "Acme Widget", "replace": "Globex Gadget", "whole_word": true},
+ *	  {"search": "Acme (\\w+) Edition", "replace": "Globex $1 Edition", "regex": true}
+ *	]
+ *
+ * The CSV header names the columns, search and replace are required and the flag columns are
+ * optional.
+ */
+
+package replaceops
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/unidoc/unidoc-examples/search-and-replace/searchops"
+)
+
+// Mapping replaces the matches of Search by Replace.
+type Mapping struct {
+	Search string `json:"search"`
+	// Replace is the replacement text. For regular expressions, $1 or ${name} are replaced by the
+	// text of the capture groups.
+	Replace string `json:"replace"`
+	// Regex interprets Search as a regular expression (RE2 syntax) instead of a literal.
+	Regex bool `json:"regex,omitempty"`
+	// IgnoreCase matches Search regardless of letter case.
+	IgnoreCase bool `json:"ignore_case,omitempty"`
+	// WholeWord only replaces matches that neither start nor end inside a word.
+	WholeWord bool `json:"whole_word,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// Mappings is an ordered list of mappings. When matches of several mappings overlap, the first
+// mapping wins.
+type Mappings []Mapping
+
+// LoadMappings reads mappings from a CSV (.csv) or JSON (.json) file. Unknown JSON keys are
+// rejected, so that a misspelled setting is not silently ignored.
+func LoadMappings(path string) (Mappings, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var mappings Mappings
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv":
+		mappings, err = parseCSV(string(data))
+	case ".json":
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(&mappings)
+	default:
+		return nil, fmt.Errorf("unsupported mapping format %q (use .csv or .json)", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	if err := mappings.Validate(); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return mappings, nil
+}
+
+// Validate checks the mappings and compiles their patterns. It is called by LoadMappings and must
+// be called on mappings built in code before use.
+func (ms Mappings) Validate() error {
+	if len(ms) == 0 {
+		return fmt.Errorf("no mappings")
+	}
+	for i := range ms {
+		if err := ms[i].compile(); err != nil {
+			return fmt.Errorf("mapping %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// compile compiles the pattern of `m`.
+func (m *Mapping) compile() error {
+	if m.Search == "" {
+		return fmt.Errorf("empty search")
+	}
+	pattern := m.Search
+	if !m.Regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if m.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+
+	var err error
+	m.re, err = regexp.Compile(pattern)
+	return err
+}
+
+// find returns the [start, end) byte offsets of the matches of `m` in `text` and their
+// replacements.
+func (m *Mapping) find(text string) ([][2]int, []string) {
+	var spans [][2]int
+	var replacements []string
+	for _, loc := range m.re.FindAllStringSubmatchIndex(text, -1) {
+		start, end := loc[0], loc[1]
+		if start == end || (m.WholeWord && !searchops.IsWholeWord(text, start, end)) {
+			continue
+		}
+		replacement := m.Replace
+		if m.Regex {
+			replacement = string(m.re.ExpandString(nil, m.Replace, text, loc))
+		}
+		spans = append(spans, [2]int{start, end})
+		replacements = append(replacements, replacement)
+	}
+	return spans, replacements
+}
+
+// parseCSV parses mappings from CSV `data` with a header row.
+func parseCSV(data string) (Mappings, error) {
+	r := csv.NewReader(strings.NewReader(data))
+	r.TrimLeadingSpace = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("missing header row")
+	}
+
+	columns := map[string]int{}
+	for i, name := range records[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, name := range []string{"search", "replace"} {
+		if _, ok := columns[name]; !ok {
+			return nil, fmt.Errorf("missing %q column", name)
+		}
+	}
+
+	var mappings Mappings
+	for i, record := range records[1:] {
+		line := i + 2
+		field := func(name string) string {
+			if j, ok := columns[name]; ok && j < len(record) {
+				return record[j]
+			}
+			return ""
+		}
+		flag := func(name string) (bool, error) {
+			v := strings.TrimSpace(field(name))
+			if v == "" {
+				return false, nil
+			}
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return false, fmt.Errorf("line %d: invalid %s %q", line, name, v)
+			}
+			return b, nil
+		}
+
+		m := Mapping{Search: field("search"), Replace: field("replace")}
+		for name, dst := range map[string]*bool{"regex": &m.Regex, "ignore_case": &m.IgnoreCase, "whole_word": &m.WholeWord} {
+			if *dst, err = flag(name); err != nil {
+				return nil, err
+			}
+		}
+		mappings = append(mappings, m)
+	}
+	return mappings, nil
+}
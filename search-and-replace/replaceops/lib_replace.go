@@ -0,0 +1,536 @@
+/*
+ * Batch find and replace in the content streams of PDF pages.
+ *
+ * The text shown by the text operators (Tj, ', ", TJ) of a page is decoded with the fonts of the
+ * operators and concatenated. The matches of all the mappings are found on that text and the
+ * replacements are encoded with the font of the operator where the match starts. Embedded fonts
+ * are often subsets that only contain the glyphs used in the document: a replacement with a
+ * character the font cannot encode or has no glyph for is not applied and is reported as missing
+ * glyphs instead of producing garbled text.
+ *
+ * Text split over operators with explicit positioning in between, e.g. words placed without a
+ * space character, is matched as if the parts were adjacent. Text in form XObjects and
+ * annotations is not replaced.
+ */
+
+package replaceops
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/unidoc/unidoc-examples/concurrent-processing/batch"
+	"github.com/unidoc/unipdf/v4/common"
+	"github.com/unidoc/unipdf/v4/contentstream"
+	"github.com/unidoc/unipdf/v4/core"
+	"github.com/unidoc/unipdf/v4/extractor"
+	"github.com/unidoc/unipdf/v4/model"
+	"github.com/unidoc/unipdf/v4/model/optimize"
+)
+
+// Change is a replacement of a match on a page.
+type Change struct {
+	Page int `json:"page"`
+	// Mapping is the number of the mapping, starting from 1.
+	Mapping     int    `json:"mapping"`
+	Text        string `json:"text"`
+	Replacement string `json:"replacement"`
+	// BBox is the bounding box of the matched text, zero if it was not found in the extracted
+	// text of the page.
+	BBox [4]float64 `json:"bbox"`
+	Font string     `json:"font"`
+	// MissingGlyphs are the characters of Replacement that Font cannot show. Changes with missing
+	// glyphs are not applied.
+	MissingGlyphs []string `json:"missing_glyphs,omitempty"`
+	Applied       bool     `json:"applied"`
+}
+
+// FileReport lists the changes made (or that would be made in a dry run) to a file.
+type FileReport struct {
+	Input   string   `json:"input"`
+	Output  string   `json:"output,omitempty"`
+	Pages   int      `json:"pages"`
+	Applied int      `json:"applied"`
+	Skipped int      `json:"skipped"`
+	Changes []Change `json:"changes"`
+}
+
+// Report is the outcome of a batch replacement.
+type Report struct {
+	DryRun  bool           `json:"dry_run"`
+	Applied int            `json:"applied"`
+	Skipped int            `json:"skipped"`
+	Files   []*FileReport  `json:"files"`
+	Summary *batch.Summary `json:"summary"`
+}
+
+// ReplaceFile applies `mappings` to all pages of `inputPath` and writes the result to
+// `outputPath`. If `dryRun` is true, nothing is written and the report lists the changes that
+// would be made. It returns early with the context error when `ctx` is done, and the result is
+// written to a temporary file only renamed to `outputPath` if `ctx` is still not done, so that a
+// run abandoned after a timeout leaves no output.
+func ReplaceFile(ctx context.Context, inputPath, outputPath string, mappings Mappings, dryRun bool) (*FileReport, error) {
+	pdfReader, f, err := model.NewPdfReaderFromFile(inputPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	numPages, err := pdfReader.GetNumPages()
+	if err != nil {
+		return nil, err
+	}
+
+	report := &FileReport{Input: inputPath, Pages: numPages, Changes: []Change{}}
+	pdfWriter := model.NewPdfWriter()
+	for i := 0; i < numPages; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		pageNum := i + 1
+		page, err := pdfReader.GetPage(pageNum)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+
+		changes, err := replacePage(page, mappings, dryRun)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+		for _, c := range changes {
+			c.Page = pageNum
+			if c.Applied {
+				report.Applied++
+			} else {
+				report.Skipped++
+			}
+			report.Changes = append(report.Changes, c)
+		}
+
+		if !dryRun {
+			if err := pdfWriter.AddPage(page); err != nil {
+				return nil, fmt.Errorf("page %d: %w", pageNum, err)
+			}
+		}
+	}
+	if dryRun {
+		return report, nil
+	}
+
+	pdfWriter.SetOptimizer(optimize.New(optimize.Options{
+		CombineDuplicateStreams:         true,
+		CombineIdenticalIndirectObjects: true,
+		UseObjectStreams:                true,
+		CompressStreams:                 true,
+	}))
+	if err := writeFile(ctx, &pdfWriter, outputPath); err != nil {
+		return nil, err
+	}
+	report.Output = outputPath
+	return report, nil
+}
+
+// writeFile writes `pdfWriter` to a temporary file renamed to `outputPath` unless `ctx` is done.
+func writeFile(ctx context.Context, pdfWriter *model.PdfWriter, outputPath string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(outputPath), "."+filepath.Base(outputPath)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	// Temporary files are private, give the output the permissions of os.Create.
+	if err := tmp.Chmod(0644); err != nil {
+		return err
+	}
+	if err := pdfWriter.Write(tmp); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), outputPath)
+}
+
+// ReplaceFiles applies `mappings` to the PDF files of `inputs`, files or directories walked
+// recursively, with a pool of workers configured by `opts` and writes the results with the same
+// names in `outputDir`. The files found in a directory keep their path relative to it. Failures
+// of individual files are recorded in the summary of the report.
+func ReplaceFiles(ctx context.Context, inputs []string, outputDir string, mappings Mappings, dryRun bool,
+	opts batch.Options) (*Report, error) {
+	inputPaths, err := batch.CollectFiles(inputs, ".pdf")
+	if err != nil {
+		return nil, err
+	}
+
+	var mu sync.Mutex
+	reports := map[string]*FileReport{}
+	task := func(ctx context.Context, path string) (int, error) {
+		outputPath := batch.OutputPath(inputs, path, outputDir, ".pdf")
+		if !dryRun {
+			if sameFile(path, outputPath) {
+				return 0, fmt.Errorf("output %s would overwrite the input", outputPath)
+			}
+			if err := os.MkdirAll(filepath.Dir(outputPath), 0755); err != nil {
+				return 0, err
+			}
+		}
+		fr, err := ReplaceFile(ctx, path, outputPath, mappings, dryRun)
+		if err != nil {
+			return 0, err
+		}
+		mu.Lock()
+		reports[path] = fr
+		mu.Unlock()
+		return fr.Pages, nil
+	}
+
+	summary, runErr := batch.Run(ctx, inputPaths, task, opts)
+
+	report := &Report{DryRun: dryRun, Files: []*FileReport{}, Summary: summary}
+	for _, res := range summary.Results {
+		mu.Lock()
+		fr := reports[res.File]
+		mu.Unlock()
+		if res.Error != "" || fr == nil {
+			continue
+		}
+		report.Applied += fr.Applied
+		report.Skipped += fr.Skipped
+		report.Files = append(report.Files, fr)
+	}
+	return report, runErr
+}
+
+// WriteJSON writes `r` as indented JSON to `w`.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// MissingGlyphs returns the characters missing from each font over all the changes of `r`.
+func (r *Report) MissingGlyphs() map[string][]string {
+	seen := map[string]map[string]bool{}
+	for _, fr := range r.Files {
+		for _, c := range fr.Changes {
+			for _, g := range c.MissingGlyphs {
+				if seen[c.Font] == nil {
+					seen[c.Font] = map[string]bool{}
+				}
+				seen[c.Font][g] = true
+			}
+		}
+	}
+
+	missing := map[string][]string{}
+	for font, glyphs := range seen {
+		for g := range glyphs {
+			missing[font] = append(missing[font], g)
+		}
+		sort.Strings(missing[font])
+	}
+	return missing
+}
+
+// textChunk is the text of a string operand of a text operator.
+type textChunk struct {
+	font   *model.PdfFont
+	strObj *core.PdfObjectString
+	val    string
+	idx    int // Byte offset of val in the page text.
+}
+
+// match is a match of a mapping in the page text.
+type match struct {
+	span        [2]int
+	mapping     int
+	replacement string
+}
+
+// replacePage replaces the matches of `mappings` in the content stream of `page`, unless `dryRun`
+// is true, and returns the changes.
+func replacePage(page *model.PdfPage, mappings Mappings, dryRun bool) ([]Change, error) {
+	contents, err := page.GetAllContentStreams()
+	if err != nil {
+		return nil, err
+	}
+	ops, err := contentstream.NewContentStreamParser(contents).Parse()
+	if err != nil {
+		return nil, err
+	}
+	text, chunks, err := textChunks(*ops, page.Resources)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := findMatches(text, mappings)
+	if len(matches) == 0 {
+		return nil, nil
+	}
+
+	locate, err := newLocator(page)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]Change, len(matches))
+	var applied []match
+	for i, m := range matches {
+		c := Change{
+			Mapping:     m.mapping + 1,
+			Text:        text[m.span[0]:m.span[1]],
+			Replacement: m.replacement,
+		}
+		c.BBox = locate(c.Text)
+
+		font := chunkAt(chunks, m.span[0]).font
+		if font != nil {
+			c.Font = font.BaseFont()
+		}
+		c.MissingGlyphs = missingGlyphs(font, m.replacement)
+		if len(c.MissingGlyphs) == 0 {
+			c.Applied = true
+			applied = append(applied, m)
+		}
+		changes[i] = c
+	}
+
+	if dryRun || len(applied) == 0 {
+		return changes, nil
+	}
+	applyMatches(chunks, applied)
+	return changes, page.SetContentStreams([]string{ops.String()}, core.NewFlateEncoder())
+}
+
+// textChunks returns the decoded text of the text operators of `ops` and its chunks.
+func textChunks(ops contentstream.ContentStreamOperations, resources *model.PdfPageResources) (string, []*textChunk, error) {
+	var sb strings.Builder
+	var chunks []*textChunk
+	var font *model.PdfFont
+
+	addChunk := func(obj core.PdfObject) {
+		strObj, ok := core.GetString(obj)
+		if !ok {
+			return
+		}
+		val := strObj.String()
+		if font != nil {
+			decoded, _, numMisses := font.CharcodeBytesToUnicode(strObj.Bytes())
+			if numMisses != 0 {
+				common.Log.Debug("WARN: some charcodes could not be decoded. %v -> %s", strObj.Bytes(), decoded)
+			}
+			val = decoded
+		}
+		chunks = append(chunks, &textChunk{font: font, strObj: strObj, val: val, idx: sb.Len()})
+		sb.WriteString(val)
+	}
+
+	processor := contentstream.NewContentStreamProcessor(ops)
+	processor.AddHandler(contentstream.HandlerConditionEnumAllOperands, "",
+		func(op *contentstream.ContentStreamOperation, gs contentstream.GraphicsState, resources *model.PdfPageResources) error {
+			switch op.Operand {
+			case "Tj", "'":
+				if len(op.Params) == 1 {
+					addChunk(op.Params[0])
+				}
+			case `"`:
+				if len(op.Params) == 3 {
+					addChunk(op.Params[2])
+				}
+			case "TJ":
+				if len(op.Params) != 1 {
+					return nil
+				}
+				if arr, ok := core.GetArray(op.Params[0]); ok {
+					for _, obj := range arr.Elements() {
+						addChunk(obj)
+					}
+				}
+			case "Tf":
+				if len(op.Params) != 2 {
+					return nil
+				}
+				name, ok := core.GetName(op.Params[0])
+				if !ok || resources == nil {
+					return nil
+				}
+				fontObj, ok := resources.GetFontByName(*name)
+				if !ok {
+					common.Log.Debug("ERROR: font %s not found", name)
+					font = nil
+					return nil
+				}
+				pdfFont, err := model.NewPdfFontFromPdfObject(fontObj)
+				if err != nil {
+					common.Log.Debug("ERROR: loading font %s: %v", name, err)
+					font = nil
+					return nil
+				}
+				font = pdfFont
+			}
+			return nil
+		})
+	if err := processor.Process(resources); err != nil {
+		return "", nil, err
+	}
+	return sb.String(), chunks, nil
+}
+
+// findMatches returns the matches of `mappings` in `text` in text order. Matches overlapping the
+// match of a previous mapping are dropped.
+func findMatches(text string, mappings Mappings) []match {
+	var matches []match
+	for i := range mappings {
+		spans, replacements := mappings[i].find(text)
+		for j, span := range spans {
+			overlaps := false
+			for _, m := range matches {
+				if span[0] < m.span[1] && m.span[0] < span[1] {
+					overlaps = true
+					break
+				}
+			}
+			if !overlaps {
+				matches = append(matches, match{span: span, mapping: i, replacement: replacements[j]})
+			}
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].span[0] < matches[j].span[0] })
+	return matches
+}
+
+// applyMatches rewrites the chunks covered by `matches`, in text order. The replacement of a match
+// goes to the chunk where the match starts and the rest of the match is removed from the
+// following chunks.
+func applyMatches(chunks []*textChunk, matches []match) {
+	for _, c := range chunks {
+		start, end := c.idx, c.idx+len(c.val)
+		var sb strings.Builder
+		pos := start
+		changed := false
+		for _, m := range matches {
+			if m.span[1] <= start || m.span[0] >= end {
+				continue
+			}
+			changed = true
+			if m.span[0] > pos {
+				sb.WriteString(c.val[pos-start : m.span[0]-start])
+			}
+			if m.span[0] >= start {
+				sb.WriteString(m.replacement)
+			}
+			pos = m.span[1]
+			if pos > end {
+				pos = end
+			}
+		}
+		if !changed {
+			continue
+		}
+		sb.WriteString(c.val[pos-start:])
+		c.val = sb.String()
+
+		// Chunks without a known font were not decoded and keep their raw bytes.
+		encoded := []byte(c.val)
+		if c.font != nil {
+			var numMisses int
+			encoded, numMisses = c.font.StringToCharcodeBytes(c.val)
+			if numMisses != 0 {
+				common.Log.Debug("WARN: some runes could not be encoded. %q -> %v", c.val, encoded)
+			}
+		}
+		*c.strObj = *core.MakeStringFromBytes(encoded)
+	}
+}
+
+// chunkAt returns the chunk containing byte offset `offset` of the page text.
+func chunkAt(chunks []*textChunk, offset int) *textChunk {
+	i := sort.Search(len(chunks), func(i int) bool { return chunks[i].idx+len(chunks[i].val) > offset })
+	return chunks[i]
+}
+
+// missingGlyphs returns the characters of `s` that `font` cannot encode or, except for spaces,
+// has no glyph width for. Widths of subset fonts are zero for the glyphs left out of the subset.
+// All the characters are missing if the font is unknown.
+func missingGlyphs(font *model.PdfFont, s string) []string {
+	var missing []string
+	seen := map[rune]bool{}
+	for _, r := range s {
+		if seen[r] {
+			continue
+		}
+		seen[r] = true
+
+		ok := false
+		if font != nil {
+			_, numMisses := font.StringToCharcodeBytes(string(r))
+			ok = numMisses == 0
+		}
+		if ok && !unicode.IsSpace(r) {
+			metrics, found := font.GetRuneMetrics(r)
+			ok = found && metrics.Wx > 0
+		}
+		if !ok {
+			missing = append(missing, string(r))
+		}
+	}
+	return missing
+}
+
+// newLocator returns a function returning the bounding box of the next occurrence of a text in the
+// extracted text of `page`, or zero if there is none.
+func newLocator(page *model.PdfPage) (func(text string) [4]float64, error) {
+	ex, err := extractor.New(page)
+	if err != nil {
+		return nil, err
+	}
+	pageText, _, _, err := ex.ExtractPageText()
+	if err != nil {
+		return nil, err
+	}
+	text, marks := pageText.Text(), pageText.Marks()
+
+	next := map[string]int{}
+	return func(s string) [4]float64 {
+		from := next[s]
+		i := strings.Index(text[from:], s)
+		if i < 0 {
+			return [4]float64{}
+		}
+		start := from + i
+		next[s] = start + len(s)
+
+		spanMarks, err := marks.RangeOffset(start, start+len(s))
+		if err != nil {
+			return [4]float64{}
+		}
+		bbox, ok := spanMarks.BBox()
+		if !ok {
+			return [4]float64{}
+		}
+		return [4]float64{bbox.Llx, bbox.Lly, bbox.Urx, bbox.Ury}
+	}, nil
+}
+
+// sameFile returns true if `a` and `b` are the same existing file.
+func sameFile(a, b string) bool {
+	fa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	fb, err := os.Stat(b)
+	return err == nil && os.SameFile(fa, fb)
+}
@@ -0,0 +1,62 @@
+/*
+ * This example shows how to query a full-text index built by search_index.go. The pages
+ * containing all the terms and "quoted phrases" of the query are listed best first with the
+ * bounding boxes of the occurrences.
+ *
+ * Run as: go run search_corpus.go [-limit 20] [-json] <index_dir> <query>
+ *
+ * Example: go run search_corpus.go ./index '"copyright law" license'
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/unidoc/unidoc-examples/search-and-replace/searchindex"
+)
+
+func main() {
+	limit := flag.Int("limit", 20, "maximum number of pages listed (0: all)")
+	asJSON := flag.Bool("json", false, "print the results as JSON")
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		fmt.Printf("Usage: go run search_corpus.go [-limit 20] [-json] index_dir query\n")
+		os.Exit(1)
+	}
+	indexDir, query := flag.Arg(0), flag.Arg(1)
+
+	ix, err := searchindex.Open(indexDir)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	results, err := ix.Search(query, *limit)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *asJSON {
+		if err := searchindex.WriteJSON(os.Stdout, query, results); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(results) == 0 {
+		fmt.Printf("No pages match %s\n", query)
+		return
+	}
+	for _, res := range results {
+		fmt.Printf("%.3f %s page %d\n", res.Score, res.File, res.Page)
+		for _, hit := range res.Hits {
+			fmt.Printf("    %q at {%.2f %.2f %.2f %.2f}\n", hit.Text, hit.BBox[0], hit.BBox[1], hit.BBox[2], hit.BBox[3])
+		}
+	}
+}
@@ -0,0 +1,82 @@
+/*
+ * This example shows how to build a full-text index over a collection of PDF files, so that they
+ * can be searched without parsing every file again per query (see search_corpus.go).
+ *
+ * Input directories are walked recursively for PDF files. Running it again on an existing index
+ * only extracts the new and changed files (by SHA-256 hash) and drops the files that are gone.
+ *
+ * Run as: go run search_index.go [-workers N] [-timeout 2m] <index_dir> <input.pdf|input_dir>...
+ *
+ * Example: go run search_index.go ./index ./test-data
+ */
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/unidoc/unidoc-examples/concurrent-processing/batch"
+	"github.com/unidoc/unidoc-examples/search-and-replace/searchindex"
+	"github.com/unidoc/unipdf/v4/common/license"
+)
+
+func init() {
+	// Make sure to load your metered License API key prior to using the library.
+	// If you need a key, you can sign up and create a free one at https://cloud.unidoc.io
+	err := license.SetMeteredKey(os.Getenv(`UNIDOC_LICENSE_API_KEY`))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func main() {
+	var opts batch.Options
+	flag.IntVar(&opts.Workers, "workers", 0, "number of files extracted concurrently (default: number of CPUs)")
+	flag.DurationVar(&opts.Timeout, "timeout", 0, "maximum extraction time per file (0: no limit)")
+	flag.Parse()
+
+	args := flag.Args()
+	if len(args) < 2 {
+		fmt.Printf("Usage: go run search_index.go [-workers N] [-timeout 2m] index_dir input.pdf|input_dir...\n")
+		os.Exit(1)
+	}
+	indexDir := args[0]
+
+	files, err := batch.CollectFiles(args[1:], ".pdf")
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	ix, err := searchindex.Open(indexDir)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	// Stop dispatching new files on Ctrl+C, the files indexed so far are saved.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	opts.OnResult = func(res batch.FileResult) {
+		if res.Error != "" {
+			fmt.Printf("FAIL %s: %s\n", res.File, res.Error)
+		}
+	}
+	stats, runErr := ix.Update(ctx, files, opts)
+	if err := ix.Save(indexDir); err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Indexed %d files: %d added, %d updated, %d unchanged, %d removed, %d failed\n",
+		len(ix.Docs), stats.Added, stats.Updated, stats.Unchanged, stats.Removed, stats.Failed)
+	if runErr != nil {
+		fmt.Printf("Error: indexing interrupted: %v\n", runErr)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,85 @@
+/*
+ * This example shows how to search text with regular expressions, whole-word, case-insensitive and
+ * diacritic-insensitive matching. All pages are searched unless page ranges are given. Each match
+ * is printed with a snippet of the text around it and its bounding box.
+ *
+ * Run as: go run search_text_modes.go [-regex] [-word] [-i] [-a] [-pages 1-5,9,12-] [-context 40] [-json] <pattern> <input.pdf>
+ *
+ * Example: go run search_text_modes.go -i -a -word resume ./test-data/file1.pdf
+ * Example: go run search_text_modes.go -regex -pages 2- '\d{4}-\d{2}-\d{2}' ./test-data/file1.pdf
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/unidoc/unidoc-examples/pages/pageops"
+	"github.com/unidoc/unidoc-examples/search-and-replace/searchops"
+	"github.com/unidoc/unipdf/v4/common/license"
+)
+
+func init() {
+	// Make sure to load your metered License API key prior to using the library.
+	// If you need a key, you can sign up and create a free one at https://cloud.unidoc.io
+	err := license.SetMeteredKey(os.Getenv(`UNIDOC_LICENSE_API_KEY`))
+	if err != nil {
+		panic(err)
+	}
+}
+
+func main() {
+	var opts searchops.Options
+	flag.BoolVar(&opts.Regex, "regex", false, "the pattern is a regular expression")
+	flag.BoolVar(&opts.WholeWord, "word", false, "match whole words only")
+	flag.BoolVar(&opts.IgnoreCase, "i", false, "ignore case")
+	flag.BoolVar(&opts.IgnoreDiacritics, "a", false, "ignore accents and other diacritics")
+	flag.IntVar(&opts.Context, "context", 40, "number of characters of the snippets around the matches")
+	pagesArg := flag.String("pages", "", "pages to search, e.g. 1-5,9,12- (default: all)")
+	asJSON := flag.Bool("json", false, "print the matches as JSON")
+	flag.Parse()
+
+	if flag.NArg() < 2 {
+		fmt.Printf("Usage: go run search_text_modes.go [-regex] [-word] [-i] [-a] [-pages 1-5,9,12-] [-context 40] [-json] pattern input.pdf\n")
+		os.Exit(1)
+	}
+	pattern, inputPath := flag.Arg(0), flag.Arg(1)
+
+	pages, err := pageops.ParsePageRanges(*pagesArg)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+	m, err := searchops.Compile(pattern, opts)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	matches, err := searchops.SearchFile(inputPath, m, pages)
+	if err != nil {
+		fmt.Printf("Error: %v\n", err)
+		os.Exit(1)
+	}
+
+	if *asJSON {
+		if err := searchops.WriteJSON(os.Stdout, inputPath, pattern, opts, matches); err != nil {
+			fmt.Printf("Error: %v\n", err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(matches) == 0 {
+		fmt.Printf("No matches of %q in %s\n", pattern, inputPath)
+		return
+	}
+	for _, match := range matches {
+		b := match.BBox
+		fmt.Printf("page %d [%d:%d] {%.2f %.2f %.2f %.2f} %s\n",
+			match.Page, match.Start, match.End, b[0], b[1], b[2], b[3], match.Snippet)
+	}
+	fmt.Printf("%d matches\n", len(matches))
+}
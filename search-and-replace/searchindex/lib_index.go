@@ -0,0 +1,399 @@
+/*
+ * Full-text inverted index over collections of PDF files, mapping terms to the file, page, word
+ * position and bounding box of their occurrences, so that a corpus can be searched without
+ * parsing the documents again for each query.
+ *
+ * The index is stored in a single gob file in an index directory. Updating an index only extracts
+ * the files that are new or whose content hash changed, and drops the files that are gone.
+ */
+
+package searchindex
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/unidoc/unidoc-examples/concurrent-processing/batch"
+	"github.com/unidoc/unidoc-examples/extract/layout"
+	"github.com/unidoc/unipdf/v4/extractor"
+	"github.com/unidoc/unipdf/v4/model"
+	"golang.org/x/text/unicode/norm"
+)
+
+// formatVersion is the version of the index file format.
+const formatVersion = 1
+
+// indexFile is the name of the index file in the index directory.
+const indexFile = "index.gob"
+
+// Index is an inverted index of the words of a collection of PDF files.
+type Index struct {
+	Version int
+	Docs    []Doc
+	// Terms maps each term to its occurrences in document, page and position order.
+	Terms map[string][]Posting
+}
+
+// Doc is an indexed file. Documents are identified by their position in Index.Docs.
+type Doc struct {
+	Path    string
+	SHA256  string
+	Size    int64
+	ModTime time.Time
+	// PageWords is the number of indexed words of each page.
+	PageWords []int
+}
+
+// Posting is an occurrence of a term.
+type Posting struct {
+	Doc  int32
+	Page int32
+	Pos  int32 // Position of the word on the page, consecutive words have consecutive positions.
+	BBox [4]float32
+}
+
+// UpdateStats summarizes an index update.
+type UpdateStats struct {
+	Added     int `json:"added"`
+	Updated   int `json:"updated"`
+	Unchanged int `json:"unchanged"`
+	Removed   int `json:"removed"`
+	Failed    int `json:"failed"`
+	// Summary is the outcome of the extraction of the new and changed files.
+	Summary *batch.Summary `json:"summary"`
+}
+
+// New returns an empty index.
+func New() *Index {
+	return &Index{Version: formatVersion, Terms: map[string][]Posting{}}
+}
+
+// Open reads the index stored in `dir`. It returns an empty index if `dir` has no index.
+func Open(dir string) (*Index, error) {
+	f, err := os.Open(filepath.Join(dir, indexFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return New(), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ix := New()
+	if err := gob.NewDecoder(f).Decode(ix); err != nil {
+		return nil, fmt.Errorf("%s: %w", f.Name(), err)
+	}
+	if ix.Version != formatVersion {
+		return nil, fmt.Errorf("%s: unsupported index version %d, rebuild the index", f.Name(), ix.Version)
+	}
+	return ix, nil
+}
+
+// Save writes `ix` to `dir`, replacing the previous index atomically.
+func (ix *Index) Save(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, indexFile+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(ix); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), filepath.Join(dir, indexFile))
+}
+
+// Update makes `ix` index exactly the files `paths`: new files and files whose content changed are
+// extracted with a pool of workers configured by `opts`, files that are no longer in `paths` are
+// removed. Files whose size and modification time did not change are not read, the others are
+// hashed and only extracted if their SHA-256 hash changed. Files that fail are left out of the
+// index and reported in the summary.
+func (ix *Index) Update(ctx context.Context, paths []string, opts batch.Options) (*UpdateStats, error) {
+	stats := &UpdateStats{}
+
+	byPath := map[string]int{}
+	for i, d := range ix.Docs {
+		byPath[d.Path] = i
+	}
+
+	keep := make([]bool, len(ix.Docs))
+	requested := map[string]bool{}
+	var pending []string
+	for _, path := range paths {
+		abs, err := filepath.Abs(path)
+		if err != nil {
+			return nil, err
+		}
+		if requested[abs] {
+			continue
+		}
+		requested[abs] = true
+		if i, ok := byPath[abs]; ok && ix.unchanged(i, abs) {
+			keep[i] = true
+			stats.Unchanged++
+			continue
+		}
+		pending = append(pending, abs)
+	}
+
+	var mu sync.Mutex
+	extracted := map[string]*docIndex{}
+	task := func(ctx context.Context, path string) (int, error) {
+		di, err := extractDoc(ctx, path)
+		if err != nil {
+			return 0, err
+		}
+		mu.Lock()
+		extracted[path] = di
+		mu.Unlock()
+		return len(di.doc.PageWords), nil
+	}
+
+	summary, runErr := batch.Run(ctx, pending, task, opts)
+	stats.Summary = summary
+
+	// Timed out files may still complete in the background: only the successful results count.
+	var added []*docIndex
+	for _, res := range summary.Results {
+		if res.Error != "" {
+			stats.Failed++
+			continue
+		}
+		mu.Lock()
+		di := extracted[res.File]
+		mu.Unlock()
+		if _, ok := byPath[res.File]; ok {
+			stats.Updated++
+		} else {
+			stats.Added++
+		}
+		added = append(added, di)
+	}
+
+	for _, d := range ix.Docs {
+		if !requested[d.Path] {
+			stats.Removed++
+		}
+	}
+
+	// Changed files are removed and added again, files that failed are left out.
+	ix.remove(keep)
+	for _, di := range added {
+		ix.add(di)
+	}
+	return stats, runErr
+}
+
+// unchanged returns true if the file at `path` has the content of document `i`. The stored
+// size and modification time of the document are updated when only the hash is unchanged.
+func (ix *Index) unchanged(i int, path string) bool {
+	d := &ix.Docs[i]
+	fi, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	if fi.Size() == d.Size && fi.ModTime().Equal(d.ModTime) {
+		return true
+	}
+
+	hash, err := fileSHA256(path)
+	if err != nil || hash != d.SHA256 {
+		return false
+	}
+	d.Size, d.ModTime = fi.Size(), fi.ModTime()
+	return true
+}
+
+// remove removes the documents of `ix` that are not kept and renumbers the others.
+func (ix *Index) remove(keep []bool) {
+	ids := make([]int32, len(ix.Docs))
+	var docs []Doc
+	for i, d := range ix.Docs {
+		ids[i] = -1
+		if keep[i] {
+			ids[i] = int32(len(docs))
+			docs = append(docs, d)
+		}
+	}
+	if len(docs) == len(ix.Docs) {
+		return
+	}
+	ix.Docs = docs
+
+	for term, postings := range ix.Terms {
+		kept := postings[:0]
+		for _, p := range postings {
+			if id := ids[p.Doc]; id >= 0 {
+				p.Doc = id
+				kept = append(kept, p)
+			}
+		}
+		if len(kept) == 0 {
+			delete(ix.Terms, term)
+		} else {
+			ix.Terms[term] = kept
+		}
+	}
+}
+
+// add adds the extracted document `di` to `ix`.
+func (ix *Index) add(di *docIndex) {
+	id := int32(len(ix.Docs))
+	ix.Docs = append(ix.Docs, di.doc)
+	for term, postings := range di.terms {
+		for i := range postings {
+			postings[i].Doc = id
+		}
+		ix.Terms[term] = append(ix.Terms[term], postings...)
+	}
+}
+
+// docIndex is the index of a single document.
+type docIndex struct {
+	doc   Doc
+	terms map[string][]Posting
+}
+
+// extractDoc extracts the words of the file at `path`.
+func extractDoc(ctx context.Context, path string) (*docIndex, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := fileSHA256(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pdfReader, f, err := model.NewPdfReaderFromFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	numPages, err := pdfReader.GetNumPages()
+	if err != nil {
+		return nil, err
+	}
+
+	di := &docIndex{
+		doc:   Doc{Path: path, SHA256: hash, Size: fi.Size(), ModTime: fi.ModTime(), PageWords: make([]int, numPages)},
+		terms: map[string][]Posting{},
+	}
+	for i := 0; i < numPages; i++ {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		pageNum := i + 1
+		page, err := pdfReader.GetPage(pageNum)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+		ex, err := extractor.New(page)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+		pageText, _, _, err := ex.ExtractPageText()
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+
+		pos := int32(0)
+		for _, w := range layout.WordsFromMarks(pageText.Marks().Elements()) {
+			for _, t := range Tokenize(w.Text) {
+				di.terms[t.Term] = append(di.terms[t.Term], Posting{
+					Page: int32(pageNum),
+					Pos:  pos,
+					BBox: tokenBBox(w, t),
+				})
+				pos++
+			}
+		}
+		di.doc.PageWords[i] = int(pos)
+	}
+	return di, nil
+}
+
+// Token is a term of a text with its rune offsets in the text.
+type Token struct {
+	Term       string
+	Start, End int
+}
+
+// Tokenize splits `text` into lower case terms made of letters and digits. Compatibility
+// characters such as ligatures are decomposed so that "ﬁle" and "file" are the same term.
+func Tokenize(text string) []Token {
+	var tokens []Token
+	var sb strings.Builder
+	start := -1
+	offset := 0
+	flush := func() {
+		if start >= 0 {
+			tokens = append(tokens, Token{Term: norm.NFKC.String(strings.ToLower(sb.String())), Start: start, End: offset})
+			sb.Reset()
+			start = -1
+		}
+	}
+	for _, r := range text {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) {
+			if start < 0 {
+				start = offset
+			}
+			sb.WriteRune(r)
+		} else {
+			flush()
+		}
+		offset++
+	}
+	flush()
+	return tokens
+}
+
+// tokenBBox returns the part of the bounding box of word `w` covered by token `t`, assuming
+// characters of equal widths.
+func tokenBBox(w layout.Word, t Token) [4]float32 {
+	n := utf8.RuneCountInString(w.Text)
+	b := w.BBox
+	if n > 0 && (t.Start > 0 || t.End < n) {
+		width := (b[2] - b[0]) / float64(n)
+		b[0], b[2] = b[0]+width*float64(t.Start), b[0]+width*float64(t.End)
+	}
+	return [4]float32{float32(b[0]), float32(b[1]), float32(b[2]), float32(b[3])}
+}
+
+// fileSHA256 returns the hex encoded SHA-256 hash of the file at `path`.
+func fileSHA256(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,187 @@
+/*
+ * Queries of the full-text index: pages containing all the terms and quoted phrases of a query,
+ * ranked by BM25 score, with the bounding boxes of the occurrences.
+ */
+
+package searchindex
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+)
+
+// BM25 parameters.
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// Hit is an occurrence of a query term or phrase on a page.
+type Hit struct {
+	Text string     `json:"text"`
+	BBox [4]float64 `json:"bbox"`
+}
+
+// Result is a page matching a query.
+type Result struct {
+	File  string  `json:"file"`
+	Page  int     `json:"page"`
+	Score float64 `json:"score"`
+	Hits  []Hit   `json:"hits"`
+}
+
+// pageKey identifies a page of the index.
+type pageKey struct {
+	doc, page int32
+}
+
+// ParseQuery splits `query` into clauses: single terms and the terms of "quoted phrases".
+func ParseQuery(query string) ([][]string, error) {
+	var clauses [][]string
+	parts := strings.Split(query, `"`)
+	if len(parts)%2 == 0 {
+		return nil, fmt.Errorf("unbalanced quotes in query %q", query)
+	}
+	for i, part := range parts {
+		var terms []string
+		for _, t := range Tokenize(part) {
+			terms = append(terms, t.Term)
+		}
+		if i%2 == 1 {
+			if len(terms) > 0 {
+				clauses = append(clauses, terms)
+			}
+			continue
+		}
+		for _, t := range terms {
+			clauses = append(clauses, []string{t})
+		}
+	}
+	if len(clauses) == 0 {
+		return nil, fmt.Errorf("empty query %q", query)
+	}
+	return clauses, nil
+}
+
+// Search returns the pages containing all the terms and phrases of `query`, best first. Phrases
+// are quoted, e.g. `"force majeure" termination`. At most `limit` results are returned if `limit`
+// is positive.
+func (ix *Index) Search(query string, limit int) ([]Result, error) {
+	clauses, err := ParseQuery(query)
+	if err != nil {
+		return nil, err
+	}
+
+	occurrences := make([]map[pageKey][]Hit, len(clauses))
+	for i, clause := range clauses {
+		occurrences[i] = ix.phraseHits(clause)
+		if len(occurrences[i]) == 0 {
+			return []Result{}, nil
+		}
+	}
+
+	numPages, totalWords := 0, 0
+	for _, d := range ix.Docs {
+		numPages += len(d.PageWords)
+		for _, n := range d.PageWords {
+			totalWords += n
+		}
+	}
+	avgWords := float64(totalWords) / math.Max(float64(numPages), 1)
+
+	results := []Result{}
+	for key := range occurrences[0] {
+		doc := ix.Docs[key.doc]
+		length := float64(doc.PageWords[key.page-1])
+		res := Result{File: doc.Path, Page: int(key.page)}
+		for _, occ := range occurrences {
+			hits, ok := occ[key]
+			if !ok {
+				res.Hits = nil
+				break
+			}
+			df := float64(len(occ))
+			idf := math.Log(1 + (float64(numPages)-df+0.5)/(df+0.5))
+			tf := float64(len(hits))
+			res.Score += idf * tf * (bm25K1 + 1) / (tf + bm25K1*(1-bm25B+bm25B*length/avgWords))
+			res.Hits = append(res.Hits, hits...)
+		}
+		if res.Hits != nil {
+			results = append(results, res)
+		}
+	}
+
+	sort.Slice(results, func(i, j int) bool {
+		a, b := results[i], results[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Page < b.Page
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results, nil
+}
+
+// phraseHits returns the occurrences of the consecutive terms `phrase` by page.
+func (ix *Index) phraseHits(phrase []string) map[pageKey][]Hit {
+	text := strings.Join(phrase, " ")
+
+	// Positions of the following terms of the phrase.
+	type wordKey struct {
+		pageKey
+		pos int32
+	}
+	rest := make([]map[wordKey][4]float32, len(phrase)-1)
+	for i, term := range phrase[1:] {
+		rest[i] = map[wordKey][4]float32{}
+		for _, p := range ix.Terms[term] {
+			rest[i][wordKey{pageKey{p.Doc, p.Page}, p.Pos}] = p.BBox
+		}
+	}
+
+	hits := map[pageKey][]Hit{}
+	for _, p := range ix.Terms[phrase[0]] {
+		key := pageKey{p.Doc, p.Page}
+		bbox := toFloat64(p.BBox)
+		found := true
+		for i, positions := range rest {
+			b, ok := positions[wordKey{key, p.Pos + int32(i) + 1}]
+			if !ok {
+				found = false
+				break
+			}
+			bbox = union(bbox, toFloat64(b))
+		}
+		if found {
+			hits[key] = append(hits[key], Hit{Text: text, BBox: bbox})
+		}
+	}
+	return hits
+}
+
+// WriteJSON writes `results` of `query` as indented JSON to `w`.
+func WriteJSON(w io.Writer, query string, results []Result) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		Query   string   `json:"query"`
+		Results []Result `json:"results"`
+	}{query, results})
+}
+
+func toFloat64(b [4]float32) [4]float64 {
+	return [4]float64{float64(b[0]), float64(b[1]), float64(b[2]), float64(b[3])}
+}
+
+func union(a, b [4]float64) [4]float64 {
+	return [4]float64{math.Min(a[0], b[0]), math.Min(a[1], b[1]), math.Max(a[2], b[2]), math.Max(a[3], b[3])}
+}
@@ -0,0 +1,257 @@
+/*
+ * Text search with literal or regular expression patterns, whole-word, case-insensitive and
+ * diacritic-insensitive matching, context snippets and the bounding boxes of the matches.
+ *
+ * Diacritic-insensitive matching folds the page text and the pattern by decomposing the
+ * characters (NFD) and dropping the combining marks, so that "resume" matches "résumé". The
+ * offsets of the matches refer to the original page text.
+ */
+
+package searchops
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/unidoc/unidoc-examples/pages/pageops"
+	"github.com/unidoc/unipdf/v4/extractor"
+	"github.com/unidoc/unipdf/v4/model"
+	"golang.org/x/text/unicode/norm"
+)
+
+// Options configures the matching of a pattern.
+type Options struct {
+	// Regex interprets the pattern as a regular expression (RE2 syntax) instead of a literal.
+	Regex bool `json:"regex"`
+	// WholeWord only keeps matches that neither start nor end inside a word.
+	WholeWord bool `json:"whole_word"`
+	// IgnoreCase matches regardless of letter case.
+	IgnoreCase bool `json:"ignore_case"`
+	// IgnoreDiacritics matches regardless of accents and other combining marks.
+	IgnoreDiacritics bool `json:"ignore_diacritics"`
+	// Context is the number of characters of the snippets on each side of a match.
+	Context int `json:"context"`
+}
+
+// Match is a match of a pattern on a page.
+type Match struct {
+	Page int    `json:"page"`
+	Text string `json:"text"`
+	// Start and End are the byte offsets of the match in the page text.
+	Start int `json:"start"`
+	End   int `json:"end"`
+	// BBox is the bounding box of the match and Boxes the bounding boxes of its parts on each
+	// line, for matches spanning several lines.
+	BBox    [4]float64   `json:"bbox"`
+	Boxes   [][4]float64 `json:"boxes"`
+	Snippet string       `json:"snippet"`
+}
+
+// Matcher finds the matches of a pattern.
+type Matcher struct {
+	re   *regexp.Regexp
+	opts Options
+}
+
+// Compile returns a Matcher for `pattern`.
+func Compile(pattern string, opts Options) (*Matcher, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("empty pattern")
+	}
+	if opts.Context < 0 {
+		return nil, fmt.Errorf("negative context %d", opts.Context)
+	}
+	if !opts.Regex {
+		pattern = regexp.QuoteMeta(pattern)
+	}
+	if opts.IgnoreDiacritics {
+		// Regular expression syntax is ASCII, folding only changes the literal characters.
+		pattern, _ = fold(pattern)
+	}
+	if opts.IgnoreCase {
+		pattern = "(?i)" + pattern
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	return &Matcher{re: re, opts: opts}, nil
+}
+
+// FindAll returns the [start, end) byte offsets of the matches in `text`.
+func (m *Matcher) FindAll(text string) [][2]int {
+	searched, offsets := text, []int(nil)
+	if m.opts.IgnoreDiacritics {
+		searched, offsets = fold(text)
+	}
+
+	var spans [][2]int
+	for _, loc := range m.re.FindAllStringIndex(searched, -1) {
+		start, end := loc[0], loc[1]
+		if start == end {
+			continue
+		}
+		if offsets != nil {
+			start, end = offsets[start], offsets[end]
+		}
+		if m.opts.WholeWord && !IsWholeWord(text, start, end) {
+			continue
+		}
+		spans = append(spans, [2]int{start, end})
+	}
+	return spans
+}
+
+// FindPage returns the matches in the text of page `pageNum` with marks `marks`.
+func (m *Matcher) FindPage(pageNum int, text string, marks *extractor.TextMarkArray) []Match {
+	var matches []Match
+	for _, span := range m.FindAll(text) {
+		match := Match{
+			Page:    pageNum,
+			Text:    text[span[0]:span[1]],
+			Start:   span[0],
+			End:     span[1],
+			Snippet: Snippet(text, span[0], span[1], m.opts.Context),
+			Boxes:   [][4]float64{},
+		}
+		if spanMarks, err := marks.RangeOffset(span[0], span[1]); err == nil {
+			if bbox, ok := spanMarks.BBox(); ok {
+				match.BBox = [4]float64{bbox.Llx, bbox.Lly, bbox.Urx, bbox.Ury}
+			}
+			match.Boxes = LineBoxes(spanMarks.Elements())
+		}
+		matches = append(matches, match)
+	}
+	return matches
+}
+
+// SearchFile returns the matches of `m` on the pages `pages` of `inputPath`, all pages if `pages` is
+// empty.
+func SearchFile(inputPath string, m *Matcher, pages pageops.PageRanges) ([]Match, error) {
+	pdfReader, f, err := model.NewPdfReaderFromFile(inputPath, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	numPages, err := pdfReader.GetNumPages()
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []Match{}
+	for _, pageNum := range pages.Pages(numPages) {
+		page, err := pdfReader.GetPage(pageNum)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+		ex, err := extractor.New(page)
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+		pageText, _, _, err := ex.ExtractPageText()
+		if err != nil {
+			return nil, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+		matches = append(matches, m.FindPage(pageNum, pageText.Text(), pageText.Marks())...)
+	}
+	return matches, nil
+}
+
+// WriteJSON writes the `matches` of `pattern` in `inputPath` as indented JSON to `w`.
+func WriteJSON(w io.Writer, inputPath, pattern string, opts Options, matches []Match) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(struct {
+		File    string  `json:"file"`
+		Pattern string  `json:"pattern"`
+		Options Options `json:"options"`
+		Matches []Match `json:"matches"`
+	}{inputPath, pattern, opts, matches})
+}
+
+// Snippet returns the text around `text[start:end]` with up to `context` characters on each side,
+// the match in square brackets and line breaks replaced by spaces, e.g. "…the [force majeure]
+// clause…". A negative `context` is taken as 0.
+func Snippet(text string, start, end, context int) string {
+	context = max(context, 0)
+	before, after := text[:start], text[end:]
+	prefix, suffix := "", ""
+	if n := utf8.RuneCountInString(before); n > context {
+		before = string([]rune(before)[n-context:])
+		prefix = "…"
+	}
+	if n := utf8.RuneCountInString(after); n > context {
+		after = string([]rune(after)[:context])
+		suffix = "…"
+	}
+
+	s := prefix + before + "[" + text[start:end] + "]" + after + suffix
+	return strings.Join(strings.Fields(s), " ")
+}
+
+// LineBoxes returns the bounding boxes of the parts of `marks` on each line.
+func LineBoxes(marks []extractor.TextMark) [][4]float64 {
+	boxes := [][4]float64{}
+	for _, tm := range marks {
+		if tm.Meta || strings.TrimSpace(tm.Text) == "" {
+			continue
+		}
+		b := [4]float64{tm.BBox.Llx, tm.BBox.Lly, tm.BBox.Urx, tm.BBox.Ury}
+		if n := len(boxes); n > 0 && sameLine(boxes[n-1], b) {
+			last := &boxes[n-1]
+			last[0], last[1] = math.Min(last[0], b[0]), math.Min(last[1], b[1])
+			last[2], last[3] = math.Max(last[2], b[2]), math.Max(last[3], b[3])
+			continue
+		}
+		boxes = append(boxes, b)
+	}
+	return boxes
+}
+
+// sameLine returns true if `b` follows `a` on the same line: their vertical centers are within
+// half a line height and `b` does not start left of `a`.
+func sameLine(a, b [4]float64) bool {
+	height := math.Max(a[3]-a[1], b[3]-b[1])
+	return math.Abs((a[1]+a[3])/2-(b[1]+b[3])/2) <= height/2 && b[0] >= a[0]
+}
+
+// fold returns `s` with its characters decomposed and combining marks removed, and the byte offset
+// in `s` of each byte offset of the result, including the end offset.
+func fold(s string) (string, []int) {
+	var sb strings.Builder
+	offsets := make([]int, 0, len(s)+1)
+	for i, r := range s {
+		for _, d := range norm.NFD.String(string(r)) {
+			if unicode.Is(unicode.Mn, d) {
+				continue
+			}
+			for j := 0; j < utf8.RuneLen(d); j++ {
+				offsets = append(offsets, i)
+			}
+			sb.WriteRune(d)
+		}
+	}
+	offsets = append(offsets, len(s))
+	return sb.String(), offsets
+}
+
+// IsWholeWord returns true if `text[start:end]` neither starts nor ends inside a word.
+func IsWholeWord(text string, start, end int) bool {
+	before, _ := utf8.DecodeLastRuneInString(text[:start])
+	after, _ := utf8.DecodeRuneInString(text[end:])
+	first, _ := utf8.DecodeRuneInString(text[start:end])
+	last, _ := utf8.DecodeLastRuneInString(text[start:end])
+	return !(isWordRune(before) && isWordRune(first)) && !(isWordRune(last) && isWordRune(after))
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.Is(unicode.Mn, r) || r == '_'
+}
@@ -4,7 +4,7 @@
  * in the code here although not on the command line.
  *
  * The user-pass is a password required to view the file with the access specified by certain permission flags (specified
- * in the code example below), whereas the owner pass is needed to have full access to the file.
+ * in securityops.DefaultPermissions), whereas the owner pass is needed to have full access to the file.
  * See pdf_check_permissions.go for an example about checking the permissions for a given PDF file.
  *
  * If anyone is supposed to be able to read the PDF under the given access restrictions, then the user password should
@@ -20,10 +20,8 @@ import (
 	"fmt"
 	"os"
 
-	"github.com/unidoc/unipdf/v4/model"
-
+	"github.com/unidoc/unidoc-examples/security/securityops"
 	"github.com/unidoc/unipdf/v4/common/license"
-	"github.com/unidoc/unipdf/v4/core/security"
 )
 
 func init() {
@@ -47,7 +45,8 @@ func main() {
 	ownerPassword := os.Args[3]
 	outputPath := os.Args[4]
 
-	err := protectPdf(inputPath, outputPath, userPassword, ownerPassword)
+	// Protect with the permissions listed in securityops.DefaultPermissions.
+	err := securityops.ProtectPdf(inputPath, outputPath, userPassword, ownerPassword, securityops.DefaultPermissions)
 	if err != nil {
 		fmt.Printf("Error: %v\n", err)
 		os.Exit(1)
@@ -55,53 +54,3 @@ func main() {
 
 	fmt.Printf("Complete, see output file: %s\n", outputPath)
 }
-
-func protectPdf(inputPath string, outputPath string, userPassword, ownerPassword string) error {
-	permissions := security.PermPrinting | // Allow printing with low quality
-		security.PermFullPrintQuality |
-		security.PermModify | // Allow modifications.
-		security.PermAnnotate | // Allow annotations.
-		security.PermFillForms |
-		security.PermRotateInsert | // Allow modifying page order, rotating pages etc.
-		security.PermExtractGraphics | // Allow extracting graphics.
-		security.PermDisabilityExtract // Allow extracting graphics (accessibility)
-
-	encryptOptions := &model.EncryptOptions{
-		Permissions: permissions,
-	}
-
-	f, err := os.Open(inputPath)
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-
-	pdfReader, err := model.NewPdfReader(f)
-	if err != nil {
-		return err
-	}
-
-	isEncrypted, err := pdfReader.IsEncrypted()
-	if err != nil {
-		return err
-	}
-	if isEncrypted {
-		return fmt.Errorf("The PDF is already locked (need to unlock first)")
-	}
-
-	// Generate a PdfWriter instance from existing PdfReader.
-	pdfWriter, err := pdfReader.ToWriter(nil)
-	if err != nil {
-		return err
-	}
-
-	// Encrypt document before writing to file.
-	err = pdfWriter.Encrypt([]byte(userPassword), []byte(ownerPassword), encryptOptions)
-	if err != nil {
-		return err
-	}
-
-	// Write to file.
-	err = pdfWriter.WriteToFile(outputPath)
-	return err
-}
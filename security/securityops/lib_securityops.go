@@ -0,0 +1,100 @@
+/*
+ * Reusable document security helpers shared by the security examples and the unipdf command line tool.
+ */
+
+package securityops
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/unidoc/unipdf/v4/core/security"
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// DefaultPermissions are the access permissions granted to users opening a protected
+// document with the user password.
+const DefaultPermissions = security.PermPrinting | // Allow printing with low quality
+	security.PermFullPrintQuality |
+	security.PermModify | // Allow modifications.
+	security.PermAnnotate | // Allow annotations.
+	security.PermFillForms |
+	security.PermRotateInsert | // Allow modifying page order, rotating pages etc.
+	security.PermExtractGraphics | // Allow extracting graphics.
+	security.PermDisabilityExtract // Allow extracting graphics (accessibility)
+
+// ProtectPdf encrypts `inputPath` with `userPassword` and `ownerPassword` and writes the
+// result to `outputPath`. Users opening the document with the user password are granted `permissions`.
+func ProtectPdf(inputPath string, outputPath string, userPassword, ownerPassword string, permissions security.Permissions) error {
+	f, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	pdfReader, err := model.NewPdfReader(f)
+	if err != nil {
+		return err
+	}
+
+	isEncrypted, err := pdfReader.IsEncrypted()
+	if err != nil {
+		return err
+	}
+	if isEncrypted {
+		return fmt.Errorf("the PDF is already locked (need to unlock first)")
+	}
+
+	// Generate a PdfWriter instance from existing PdfReader.
+	pdfWriter, err := pdfReader.ToWriter(nil)
+	if err != nil {
+		return err
+	}
+
+	// Encrypt document before writing to file.
+	encryptOptions := &model.EncryptOptions{
+		Permissions: permissions,
+	}
+	err = pdfWriter.Encrypt([]byte(userPassword), []byte(ownerPassword), encryptOptions)
+	if err != nil {
+		return err
+	}
+
+	return pdfWriter.WriteToFile(outputPath)
+}
+
+// UnlockPdf decrypts `inputPath` with `password` and writes an unencrypted copy to `outputPath`.
+func UnlockPdf(inputPath string, outputPath string, password string) error {
+	f, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	pdfReader, err := model.NewPdfReader(f)
+	if err != nil {
+		return err
+	}
+
+	isEncrypted, err := pdfReader.IsEncrypted()
+	if err != nil {
+		return err
+	}
+	if isEncrypted {
+		auth, err := pdfReader.Decrypt([]byte(password))
+		if err != nil {
+			return err
+		}
+		if !auth {
+			return fmt.Errorf("wrong password")
+		}
+	}
+
+	// Generate a PdfWriter instance from existing PdfReader.
+	pdfWriter, err := pdfReader.ToWriter(nil)
+	if err != nil {
+		return err
+	}
+
+	return pdfWriter.WriteToFile(outputPath)
+}
@@ -201,8 +201,14 @@ func ltvEnableAndTimestamp(r io.ReadSeeker, certChain []*x509.Certificate) ([]by
 		return nil, err
 	}
 
+	// Set timestamp server, overridden by the TSA_URL environment variable.
+	timestampServerURL := "https://freetsa.org/tsr"
+	if tsaURL := os.Getenv("TSA_URL"); tsaURL != "" {
+		timestampServerURL = tsaURL
+	}
+
 	// Create timestamp handler.
-	handler, err := sighandler.NewDocTimeStamp("https://freetsa.org/tsr", crypto.SHA512)
+	handler, err := sighandler.NewDocTimeStamp(timestampServerURL, crypto.SHA512)
 	if err != nil {
 		return nil, err
 	}
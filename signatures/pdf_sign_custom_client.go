@@ -90,8 +90,11 @@ func main() {
 		log.Fatal("Fail: %v\n", err)
 	}
 
-	// Set timestamp server.
+	// Set timestamp server, overridden by the TSA_URL environment variable.
 	timestampServerURL := "https://freetsa.org/tsr"
+	if tsaURL := os.Getenv("TSA_URL"); tsaURL != "" {
+		timestampServerURL = tsaURL
+	}
 
 	// Create PAdES signature handler.
 	padEs := sighandler.NewEtsiPAdES(sighandler.LevelLT)
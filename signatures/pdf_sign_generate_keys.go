@@ -3,6 +3,11 @@
  * private/public key pair.
  *
  * $ ./pdf_sign_generate_keys <INPUT_PDF_PATH> <OUTPUT_PDF_PATH>
+ *
+ * With -ca, it writes instead a generated self-signed CA certificate and its private key to PEM
+ * files, e.g. to be loaded by pdf_sign_test_authority -ca-cert -ca-key:
+ *
+ * $ ./pdf_sign_generate_keys -ca <CA_CERT_PATH> <CA_KEY_PATH>
  */
 package main
 
@@ -11,6 +16,7 @@ import (
 	"crypto/rsa"
 	"crypto/x509"
 	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
 	"log"
 	"math/big"
@@ -35,12 +41,19 @@ func init() {
 
 var now = time.Now()
 
-const usagef = "Usage: %s INPUT_PDF_PATH OUTPUT_PDF_PATH\n"
+const usagef = "Usage: %s INPUT_PDF_PATH OUTPUT_PDF_PATH\n       %s -ca CA_CERT_PATH CA_KEY_PATH\n"
 
 func main() {
 	args := os.Args
-	if len(args) < 3 {
-		fmt.Printf(usagef, os.Args[0])
+	if len(args) < 3 || args[1] == "-ca" && len(args) < 4 {
+		fmt.Printf(usagef, os.Args[0], os.Args[0])
+		return
+	}
+	if args[1] == "-ca" {
+		if err := writeCA(args[2], args[3]); err != nil {
+			log.Fatalf("Fail: %v\n", err)
+		}
+		log.Printf("CA certificate: %s, private key: %s\n", args[2], args[3])
 		return
 	}
 	inputPath := args[1]
@@ -150,3 +163,43 @@ func generateKeys() (*rsa.PrivateKey, *x509.Certificate, error) {
 
 	return priv, cert, nil
 }
+
+// writeCA writes a generated self-signed CA certificate, which can issue certificates
+// (KeyUsageCertSign), to the PEM file `certPath` and its private key to the PEM file `keyPath`.
+func writeCA(certPath, keyPath string) error {
+	priv, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return err
+	}
+
+	template := x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   "Test Root CA",
+			Organization: []string{"Test Company"},
+		},
+		NotBefore:             now.Add(-time.Hour).UTC(),
+		NotAfter:              now.Add(time.Hour * 24 * 365 * 10).UTC(),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	certData, err := x509.CreateCertificate(rand.Reader, &template, &template, priv.Public(), priv)
+	if err != nil {
+		return err
+	}
+	keyData, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return err
+	}
+
+	err = os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certData}), 0644)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyData}), 0600)
+}
@@ -92,8 +92,11 @@ func main() {
 		log.Fatal("Fail: %v\n", err)
 	}
 
-	// Set timestamp server.
+	// Set timestamp server, overridden by the TSA_URL environment variable.
 	timestampServerURL := "https://freetsa.org/tsr"
+	if tsaURL := os.Getenv("TSA_URL"); tsaURL != "" {
+		timestampServerURL = tsaURL
+	}
 
 	// Create signature handler.
 	handler, err := sighandler.NewEtsiPAdESLevelLT(priv.(*rsa.PrivateKey), cert, cacert, timestampServerURL, appender)
@@ -0,0 +1,105 @@
+/*
+ * This example showcases how to determine the PAdES baseline level (B-B, B-T, B-LT or B-LTA) that
+ * the signatures of PDF files reach, and which requirement of the next level fails.
+ *
+ * $ ./pdf_sign_pades_level [options] <INPUT_PDF_PATH>...
+ *
+ *     -trust <dir>: Directory of the PEM files of the trusted root certificates, which need not be
+ *                   embedded in the documents
+ *     -level <level>: Required level, e.g. B-LTA
+ *     -json <path>: Write the report as JSON
+ *
+ * The levels check
+ *   - B-B: an ETSI.CAdES.detached signature of the whole revision, with the signing certificate
+ *     attribute and without the CMS signing time attribute,
+ *   - B-T: a signature timestamp, or a document timestamp of a later revision,
+ *   - B-LT: the certificates and OCSP responses or CRLs of the chains of the signer and of the
+ *     timestamp authority in the document security store (DSS),
+ *   - B-LTA: a document timestamp of a revision whose DSS has this validation data.
+ * Use pdf_sign_verify_report.go to check that the signatures are valid and trusted.
+ * Exits with status 1 if a signature is below the -level level.
+ */
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/unidoc/unidoc-examples/signatures/sigverify"
+	"github.com/unidoc/unipdf/v4/common/license"
+)
+
+func init() {
+	// Make sure to load your metered License API key prior to using the library.
+	// If you need a key, you can sign up and create a free one at https://cloud.unidoc.io
+	err := license.SetMeteredKey(os.Getenv(`UNIDOC_LICENSE_API_KEY`))
+	if err != nil {
+		panic(err)
+	}
+}
+
+const usage = `Usage:
+pdf_sign_pades_level [options] INPUT_PDF_PATH...
+`
+
+func main() {
+	var opts sigverify.Options
+	var trustDir, level, jsonPath string
+	flag.StringVar(&trustDir, "trust", "", "Directory of the PEM files of the trusted root certificates (optional)")
+	flag.StringVar(&level, "level", "", "Required level: B-B, B-T, B-LT or B-LTA (optional)")
+	flag.StringVar(&jsonPath, "json", "", "Write the report as JSON to this file (optional)")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 || (level != "" && !sigverify.ValidLevel(level)) {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	if trustDir != "" {
+		trust, err := sigverify.LoadTrustStore(trustDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		opts.Trust = trust
+	}
+
+	belowLevel := false
+	var reports []*sigverify.PAdESReport
+	for _, inputPath := range flag.Args() {
+		report, err := sigverify.CheckPAdESFile(inputPath, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", inputPath, err)
+			os.Exit(2)
+		}
+		report.WriteText(os.Stdout)
+		reports = append(reports, report)
+		if level != "" && (len(report.Signatures) == 0 || !sigverify.LevelAtLeast(report.Level, level)) {
+			belowLevel = true
+		}
+	}
+
+	if jsonPath != "" {
+		f, err := os.Create(jsonPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		err = sigverify.WritePAdESJSON(f, reports)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	if belowLevel {
+		os.Exit(1)
+	}
+}
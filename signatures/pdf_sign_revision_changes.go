@@ -0,0 +1,90 @@
+/*
+ * This example showcases how to analyze the incremental updates of signed PDF files: the objects
+ * changed by each revision are classified (form field value, new annotation, new page, content
+ * stream change, signature addition, ...) and checked against the DocMDP and FieldMDP permissions
+ * of the signatures of the previous revisions.
+ *
+ * $ ./pdf_sign_revision_changes [options] <INPUT_PDF_PATH>...
+ *
+ *     -json <path>: Write the report as JSON
+ *
+ * A certification signature allows, after it,
+ *   - P=1: no change,
+ *   - P=2: filling in form fields and signing,
+ *   - P=3: also creating, modifying and deleting annotations.
+ * The changes allowed after approval signatures only are those of P=3. Validation data (DSS) and
+ * document timestamps are always allowed, and FieldMDP locked fields must keep their values.
+ * Exits with status 1 if a revision makes a disallowed change.
+ */
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/unidoc/unidoc-examples/signatures/revdiff"
+	"github.com/unidoc/unipdf/v4/common/license"
+)
+
+func init() {
+	// Make sure to load your metered License API key prior to using the library.
+	// If you need a key, you can sign up and create a free one at https://cloud.unidoc.io
+	err := license.SetMeteredKey(os.Getenv(`UNIDOC_LICENSE_API_KEY`))
+	if err != nil {
+		panic(err)
+	}
+}
+
+const usage = `Usage:
+pdf_sign_revision_changes [options] INPUT_PDF_PATH...
+`
+
+func main() {
+	var jsonPath string
+	flag.StringVar(&jsonPath, "json", "", "Write the report as JSON to this file (optional)")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	disallowed := false
+	var reports []*revdiff.Report
+	for _, inputPath := range flag.Args() {
+		report, err := revdiff.AnalyzeFile(inputPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", inputPath, err)
+			os.Exit(2)
+		}
+		report.WriteText(os.Stdout)
+		reports = append(reports, report)
+		if report.HasDisallowed() {
+			disallowed = true
+		}
+	}
+
+	if jsonPath != "" {
+		f, err := os.Create(jsonPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		err = revdiff.WriteJSON(f, reports)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	if disallowed {
+		os.Exit(1)
+	}
+}
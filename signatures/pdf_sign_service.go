@@ -0,0 +1,90 @@
+/*
+ * This example showcases a batch signing service: PDF files submitted to a local HTTP API are
+ * queued and signed according to signing profiles (PAdES level, DocMDP permission, visible
+ * appearance) with the signers of the configuration: PKCS#12 or PEM files, AWS KMS, Google Cloud
+ * KMS, GlobalSign DSS or, in cgo builds, PKCS#11 tokens.
+ *
+ * $ ./pdf_sign_service -config <CONFIG_JSON_PATH> [-addr localhost:8080]
+ *
+ * The $VAR references of the configuration file are replaced by the environment variables, e.g.
+ * for the passwords. See the README for the configuration and the API.
+ */
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/unidoc/unidoc-examples/signatures/signsvc"
+	"github.com/unidoc/unipdf/v4/common/license"
+)
+
+func init() {
+	// Make sure to load your metered License API key prior to using the library.
+	// If you need a key, you can sign up and create a free one at https://cloud.unidoc.io
+	err := license.SetMeteredKey(os.Getenv(`UNIDOC_LICENSE_API_KEY`))
+	if err != nil {
+		panic(err)
+	}
+}
+
+const usage = `Usage:
+pdf_sign_service -config CONFIG_JSON_PATH [-addr ADDRESS]
+`
+
+func main() {
+	var configPath, addr string
+	flag.StringVar(&configPath, "config", "", "Service configuration file")
+	flag.StringVar(&addr, "addr", "localhost:8080", "Address of the HTTP API")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if configPath == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := signsvc.LoadServiceConfig(configPath)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	signers, err := signsvc.OpenSigners(cfg.Signers)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	service, err := signsvc.NewService(cfg, signers, signsvc.Options{})
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           service.Handler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		<-stop
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	log.Printf("Signing service listening on http://%s (backends: %v)", addr, signsvc.Backends())
+	if err := server.ListenAndServe(); !errors.Is(err, http.ErrServerClosed) {
+		log.Fatalf("Error: %v", err)
+	}
+	// Sign the queued documents before exiting.
+	service.Close()
+}
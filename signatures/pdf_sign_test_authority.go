@@ -0,0 +1,102 @@
+/*
+ * This example runs a local test PKI for signing without internet access: a generated root CA with
+ * an RFC 3161 timestamp authority (TSA), an OCSP responder and a CRL, served over HTTP, and a
+ * signing certificate issued by the CA whose validation data is fetched from it.
+ *
+ * $ ./pdf_sign_test_authority [-addr localhost:8079] [-out test_pki] [-password test] [-ca-cert ca.pem -ca-key ca_key.pem]
+ *
+ * The files written to the output directory are
+ *   - trusted/ca.pem: the root CA certificate, in the directory of the trusted certificates of the
+ *     validation,
+ *   - signer.p12: the signing key and certificate, protected by the password,
+ *   - signer_key.pem and signer_chain.pem: the same as PEM files, with the root CA certificate.
+ * The keys are generated at each start: sign and validate with the same run of the server, or load
+ * the root CA with -ca-cert and -ca-key, e.g. written by pdf_sign_generate_keys -ca, to keep the
+ * trusted certificate across runs.
+ */
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/unidoc/unidoc-examples/signatures/testpki"
+)
+
+const usage = `Usage:
+pdf_sign_test_authority [-addr ADDRESS] [-out DIR] [-password PASSWORD] [-ca-cert CA_CERT_PEM -ca-key CA_KEY_PEM]
+`
+
+func main() {
+	var addr, outDir, password, caCertPath, caKeyPath string
+	flag.StringVar(&addr, "addr", "localhost:8079", "Address of the TSA, OCSP responder and CRL")
+	flag.StringVar(&outDir, "out", "test_pki", "Directory to write the certificates and keys to")
+	flag.StringVar(&password, "password", "test", "Password of signer.p12")
+	flag.StringVar(&caCertPath, "ca-cert", "", "PEM file of the root CA certificate, generated if not set")
+	flag.StringVar(&caKeyPath, "ca-key", "", "PEM file of the private key of -ca-cert")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if (caCertPath == "") != (caKeyPath == "") {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	server, err := listen(addr, caCertPath, caKeyPath)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	defer server.Close()
+
+	key, chain, err := server.IssueSigner("Test Signer")
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	trustDir := filepath.Join(outDir, "trusted")
+	if err := os.MkdirAll(trustDir, 0755); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	caPath := filepath.Join(trustDir, "ca.pem")
+	p12Path := filepath.Join(outDir, "signer.p12")
+	for _, err := range []error{
+		testpki.WriteCertificates(caPath, server.Root),
+		testpki.WritePKCS12(p12Path, key, chain[0], password),
+		testpki.WriteKey(filepath.Join(outDir, "signer_key.pem"), key),
+		testpki.WriteCertificates(filepath.Join(outDir, "signer_chain.pem"), chain...),
+	} {
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+
+	fmt.Printf("TSA:  %s\nOCSP: %s\nCRL:  %s\n\n", server.TSAURL(), server.OCSPURL(), server.CRLURL())
+	fmt.Printf("Sign with a PAdES B-LTA signature:\n")
+	fmt.Printf("  TSA_URL=%s go run pdf_sign_pades_b_lta.go %s %s %s input.pdf output.pdf\n",
+		server.TSAURL(), p12Path, password, caPath)
+	fmt.Printf("Validate it:\n")
+	fmt.Printf("  go run pdf_sign_verify_report.go -trust %s output.pdf\n", trustDir)
+
+	stop := make(chan os.Signal, 1)
+	signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+	<-stop
+}
+
+// listen serves the test PKI on `addr`, with the root CA of the files `caCertPath` and `caKeyPath`
+// if set.
+func listen(addr, caCertPath, caKeyPath string) (*testpki.Server, error) {
+	if caCertPath == "" {
+		return testpki.Listen(addr)
+	}
+	root, rootKey, err := testpki.LoadCA(caCertPath, caKeyPath)
+	if err != nil {
+		return nil, err
+	}
+	return testpki.ListenWithCA(addr, root, rootKey)
+}
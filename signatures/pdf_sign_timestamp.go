@@ -126,7 +126,13 @@ func main() {
 		log.Fatal("Fail: %v\n", err)
 	}
 
-	handler, err = sighandler.NewDocTimeStamp("https://freetsa.org/tsr", crypto.SHA512)
+	// Set timestamp server, overridden by the TSA_URL environment variable.
+	timestampServerURL := "https://freetsa.org/tsr"
+	if tsaURL := os.Getenv("TSA_URL"); tsaURL != "" {
+		timestampServerURL = tsaURL
+	}
+
+	handler, err = sighandler.NewDocTimeStamp(timestampServerURL, crypto.SHA512)
 	if err != nil {
 		log.Fatal("Fail: %v\n", err)
 	}
@@ -0,0 +1,128 @@
+/*
+ * This example showcases how to verify all the digital signatures of PDF files for an audit
+ * report: the integrity of each signature, its certificate chain up to a trust store, the
+ * revocation status of the chain and its timestamp, with a verdict per signature.
+ *
+ * $ ./pdf_sign_verify_report [options] <INPUT_PDF_PATH>...
+ *
+ *     -trust <dir>: Directory of the PEM files of the trusted root certificates
+ *     -fetch: Fetch the revocation data missing from the documents from the OCSP responders and
+ *             CRL distribution points of the certificates
+ *     -ocsp <url>: OCSP responder to use instead of those of the certificates (implies -fetch)
+ *     -crl <url>: CRL to use instead of the distribution points of the certificates (implies -fetch)
+ *     -json <path>: Write the report as JSON
+ *     -html <path>: Write the report as HTML
+ *
+ * The revocation data embedded in the document security store (DSS) and in the signatures is
+ * used first. A verdict is
+ *   - valid: the signed bytes are unmodified, the chain leads to a trusted root and is not revoked
+ *     at the time of a trusted timestamp (or now), and the timestamp matches the signing time,
+ *   - invalid: the signed bytes are modified, a certificate is revoked, or the timestamp is invalid
+ *     or earlier than the claimed signing time,
+ *   - indeterminate: the chain is not trusted or there is no revocation data.
+ * Exits with status 1 unless all the signatures of all the files are valid.
+ */
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/unidoc/unidoc-examples/signatures/sigverify"
+	"github.com/unidoc/unipdf/v4/common/license"
+)
+
+func init() {
+	// Make sure to load your metered License API key prior to using the library.
+	// If you need a key, you can sign up and create a free one at https://cloud.unidoc.io
+	err := license.SetMeteredKey(os.Getenv(`UNIDOC_LICENSE_API_KEY`))
+	if err != nil {
+		panic(err)
+	}
+}
+
+const usage = `Usage:
+pdf_sign_verify_report [options] INPUT_PDF_PATH...
+`
+
+func main() {
+	var opts sigverify.Options
+	var trustDir, jsonPath, htmlPath string
+	flag.StringVar(&trustDir, "trust", "", "Directory of the PEM files of the trusted root certificates")
+	flag.BoolVar(&opts.Fetch, "fetch", false, "Fetch the missing revocation data from the OCSP responders and CRL distribution points")
+	flag.StringVar(&opts.OCSPServer, "ocsp", "", "OCSP responder replacing those of the certificates (implies -fetch)")
+	flag.StringVar(&opts.CRLURL, "crl", "", "CRL URL replacing the distribution points of the certificates (implies -fetch)")
+	flag.StringVar(&jsonPath, "json", "", "Write the report as JSON to this file (optional)")
+	flag.StringVar(&htmlPath, "html", "", "Write the report as HTML to this file (optional)")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if opts.OCSPServer != "" || opts.CRLURL != "" {
+		opts.Fetch = true
+	}
+
+	if trustDir != "" {
+		trust, err := sigverify.LoadTrustStore(trustDir)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		opts.Trust = trust
+	} else {
+		fmt.Fprintln(os.Stderr, "Warning: no -trust directory, no certificate chain is trusted")
+	}
+
+	allValid := true
+	var reports []*sigverify.Report
+	for _, inputPath := range flag.Args() {
+		report, err := sigverify.VerifyFile(inputPath, opts)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %s: %v\n", inputPath, err)
+			os.Exit(2)
+		}
+		report.WriteText(os.Stdout)
+		reports = append(reports, report)
+		if report.Verdict != sigverify.VerdictValid {
+			allValid = false
+		}
+	}
+
+	if jsonPath != "" {
+		if err := writeReport(jsonPath, reports, sigverify.WriteJSON); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+	}
+	if htmlPath != "" {
+		if err := writeReport(htmlPath, reports, sigverify.WriteHTML); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	if !allValid {
+		os.Exit(1)
+	}
+}
+
+// writeReport writes `reports` to `path` with `write`.
+func writeReport(path string, reports []*sigverify.Report,
+	write func(w io.Writer, reports []*sigverify.Report) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	err = write(f, reports)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
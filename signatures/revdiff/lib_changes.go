@@ -0,0 +1,708 @@
+/*
+ * Diffs of the objects of consecutive revisions and classification of the changes.
+ */
+
+package revdiff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/unidoc/unipdf/v4/core"
+)
+
+// Kinds of changes.
+const (
+	KindFormFieldValue       = "form field value"
+	KindFormFieldAdded       = "form field added"
+	KindFormFieldModified    = "form field modified"
+	KindFormAppearance       = "form field appearance"
+	KindFormDictionary       = "form dictionary"
+	KindAnnotationAdded      = "annotation added"
+	KindAnnotationModified   = "annotation modified"
+	KindAnnotationDeleted    = "annotation deleted"
+	KindAnnotationAppearance = "annotation appearance"
+	KindPageAdded            = "page added"
+	KindPageDeleted          = "page deleted"
+	KindContentChange        = "content stream change"
+	KindSignatureAdded       = "signature added"
+	KindSignatureModified    = "signature modified"
+	KindDocTimestamp         = "document timestamp added"
+	KindValidationData       = "validation data"
+	KindMetadata             = "metadata"
+	KindUnused               = "unreferenced object"
+	KindOther                = "other"
+)
+
+// maxDepth bounds the walks of the parent chains of fields and of the page tree.
+const maxDepth = 32
+
+// Change is a change of an object in a revision.
+type Change struct {
+	Object int64  `json:"object"`
+	Kind   string `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+	Field  string `json:"field,omitempty"`
+	Page   int    `json:"page,omitempty"`
+	// Allowed is true if the permissions of the previous signatures allow the change.
+	Allowed bool   `json:"allowed"`
+	Rule    string `json:"rule"`
+}
+
+// role is what an object is used for in a revision, found by walking the references from the
+// pages, the annotations, the form and the catalog.
+type role int
+
+const (
+	roleNone role = iota
+	roleContent
+	roleFormResources
+	roleFormAppearance
+	roleAnnotAppearance
+	roleTimestampAppearance
+	roleValidation
+	roleMetadata
+)
+
+// structure is the document structure of a revision.
+type structure struct {
+	rev                           *revision
+	rootNum, infoNum, acroFormNum int64
+	pages                         map[int64]int // Page number of the page objects.
+	annots                        map[int64]int // Page number of the annotation objects.
+	annotArrays                   map[int64]int // Page number of the indirect /Annots arrays.
+	fieldsNum                     int64         // Indirect /Fields array of the form.
+	sigFields                     map[int64]string
+	roles                         map[int64]role
+	reachable                     map[int64]bool
+}
+
+// newStructure returns the structure of `rev`.
+func newStructure(rev *revision) *structure {
+	s := &structure{
+		rev:         rev,
+		pages:       map[int64]int{},
+		annots:      map[int64]int{},
+		annotArrays: map[int64]int{},
+		sigFields:   map[int64]string{},
+		roles:       map[int64]role{},
+		reachable:   map[int64]bool{},
+	}
+	root, rootNum := rev.root()
+	if root == nil {
+		return s
+	}
+	s.rootNum = rootNum
+	s.acroFormNum, _ = objectNum(root.Get("AcroForm"))
+	var info core.PdfObject
+	if trailer, err := rev.reader.GetTrailer(); err == nil && trailer != nil {
+		info = trailer.Get("Info")
+		s.infoNum, _ = objectNum(info)
+	}
+
+	// The roles of the stricter kinds are marked first.
+	var pages []*core.PdfObjectDictionary
+	s.walkPages(root.Get("Pages"), &pages, 0)
+	for _, page := range pages {
+		s.mark(page.Get("Contents"), roleContent)
+		s.mark(page.Get("Resources"), roleContent)
+	}
+	if acroForm, ok := rev.resolveDict(root.Get("AcroForm")); ok {
+		s.mark(acroForm.Get("DR"), roleFormResources)
+		s.fieldsNum, _ = objectNum(acroForm.Get("Fields"))
+	}
+	for i, page := range pages {
+		if num, ok := objectNum(page.Get("Annots")); ok {
+			s.annotArrays[num] = i + 1
+		}
+		annots, _ := rev.resolveArray(page.Get("Annots"))
+		if annots == nil {
+			continue
+		}
+		for _, obj := range annots.Elements() {
+			if num, ok := objectNum(obj); ok {
+				s.annots[num] = i + 1
+			}
+			annot, ok := rev.resolveDict(obj)
+			if !ok {
+				continue
+			}
+			r := roleAnnotAppearance
+			if nameVal(annot.Get("Subtype")) == "Widget" {
+				r = roleFormAppearance
+				if sig, ok := rev.resolveDict(annot.Get("V")); ok && nameVal(sig.Get("SubFilter")) == "ETSI.RFC3161" {
+					r = roleTimestampAppearance
+				}
+			}
+			s.mark(annot.Get("AP"), r)
+		}
+	}
+	s.mark(root.Get("DSS"), roleValidation)
+	s.mark(root.Get("Metadata"), roleMetadata)
+	s.mark(info, roleMetadata)
+
+	s.walkReachable(rootNum)
+	s.walkReachable(s.infoNum)
+	for num := range rev.objects {
+		dict, ok := rev.dict(num)
+		if !ok {
+			continue
+		}
+		if v, ok := objectNum(dict.Get("V")); ok {
+			if sig, ok := rev.resolveDict(dict.Get("V")); ok && isSignatureDict(sig) {
+				s.sigFields[v] = fieldName(rev, dict)
+			}
+		}
+	}
+	return s
+}
+
+// walkPages appends the pages of the page tree node `obj` to `pages`. The resources of the
+// intermediate nodes, inherited by their pages, are marked as content.
+func (s *structure) walkPages(obj core.PdfObject, pages *[]*core.PdfObjectDictionary, depth int) {
+	dict, ok := s.rev.resolveDict(obj)
+	if !ok || depth > maxDepth {
+		return
+	}
+	num, isRef := objectNum(obj)
+	if isRef {
+		if _, seen := s.pages[num]; seen {
+			return
+		}
+	}
+	kids, ok := s.rev.resolveArray(dict.Get("Kids"))
+	if !ok && nameVal(dict.Get("Type")) != "Pages" {
+		*pages = append(*pages, dict)
+		if isRef {
+			s.pages[num] = len(*pages)
+		}
+		return
+	}
+	if isRef {
+		s.pages[num] = 0
+	}
+	s.mark(dict.Get("Resources"), roleContent)
+	if kids != nil {
+		for _, kid := range kids.Elements() {
+			s.walkPages(kid, pages, depth+1)
+		}
+	}
+}
+
+// mark sets the role of the objects referenced by `obj`, directly or through other objects, that
+// have no role yet. The /Parent and /P back references are not followed.
+func (s *structure) mark(obj core.PdfObject, r role) {
+	if num, ok := objectNum(obj); ok {
+		if _, seen := s.roles[num]; seen {
+			return
+		}
+		s.roles[num] = r
+		obj = s.rev.resolve(obj)
+	}
+	switch o := obj.(type) {
+	case *core.PdfObjectDictionary:
+		for _, key := range o.Keys() {
+			if key != "Parent" && key != "P" {
+				s.mark(o.Get(key), r)
+			}
+		}
+	case *core.PdfObjectStream:
+		if o.PdfObjectDictionary != nil {
+			s.mark(o.PdfObjectDictionary, r)
+		}
+	case *core.PdfObjectArray:
+		for _, elem := range o.Elements() {
+			s.mark(elem, r)
+		}
+	}
+}
+
+// walkReachable marks the objects reachable from the object `num`.
+func (s *structure) walkReachable(num int64) {
+	if num == 0 || s.reachable[num] {
+		return
+	}
+	s.reachable[num] = true
+	var walk func(obj core.PdfObject)
+	walk = func(obj core.PdfObject) {
+		if n, ok := objectNum(obj); ok {
+			if s.reachable[n] {
+				return
+			}
+			s.reachable[n] = true
+			obj = s.rev.resolve(obj)
+		}
+		switch o := obj.(type) {
+		case *core.PdfObjectDictionary:
+			for _, key := range o.Keys() {
+				walk(o.Get(key))
+			}
+		case *core.PdfObjectStream:
+			if o.PdfObjectDictionary != nil {
+				walk(o.PdfObjectDictionary)
+			}
+		case *core.PdfObjectArray:
+			for _, elem := range o.Elements() {
+				walk(elem)
+			}
+		}
+	}
+	walk(s.rev.resolve(&core.PdfObjectReference{ObjectNumber: num}))
+}
+
+// differ classifies the changes between the revisions `prev` and `cur`.
+type differ struct {
+	prev, cur *structure
+}
+
+// diffRevisions returns the changes of the objects of `cur` from `prev`.
+func diffRevisions(prev, cur *revision) []Change {
+	d := &differ{prev: newStructure(prev), cur: newStructure(cur)}
+	nums := map[int64]bool{}
+	for num := range prev.objects {
+		nums[num] = true
+	}
+	for num := range cur.objects {
+		nums[num] = true
+	}
+	sorted := make([]int64, 0, len(nums))
+	for num := range nums {
+		sorted = append(sorted, num)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var changes []Change
+	for _, num := range sorted {
+		old, obj := prev.objects[num], cur.objects[num]
+		if old != nil && obj != nil && serialize(old) == serialize(obj) {
+			continue
+		}
+		if obj == nil {
+			changes = append(changes, d.deleted(num)...)
+		} else {
+			changes = append(changes, d.changed(num, old != nil)...)
+		}
+	}
+	return changes
+}
+
+// changed classifies the change of the object `num` of the current revision, added if not `existed`.
+func (d *differ) changed(num int64, existed bool) []Change {
+	c := Change{Object: num}
+	dict, _ := d.cur.rev.dict(num)
+	var oldDict *core.PdfObjectDictionary
+	if existed {
+		oldDict, _ = d.prev.rev.dict(num)
+	}
+	if dict == nil {
+		dict = core.MakeDict()
+	}
+	switch nameVal(dict.Get("Type")) {
+	case "XRef", "ObjStm":
+		return nil
+	}
+
+	switch {
+	case num == d.cur.rootNum:
+		return d.catalogChanges(num, oldDict, dict)
+	case num == d.cur.infoNum || d.cur.roles[num] == roleMetadata:
+		c.Kind = KindMetadata
+	case d.cur.roles[num] == roleValidation:
+		c.Kind = KindValidationData
+	case isSignatureDict(dict):
+		c.Field = d.cur.sigFields[num]
+		switch {
+		case existed:
+			c.Kind = KindSignatureModified
+		case nameVal(dict.Get("SubFilter")) == "ETSI.RFC3161":
+			c.Kind = KindDocTimestamp
+		default:
+			c.Kind = KindSignatureAdded
+		}
+	case num == d.cur.acroFormNum:
+		c = d.formDictChange(num, oldDict, dict)
+	case num == d.cur.fieldsNum || d.cur.annotArrays[num] > 0:
+		if !existed {
+			// The form or page referring to the new array reports its entries.
+			return nil
+		}
+		ref := &core.PdfObjectReference{ObjectNumber: num}
+		if num == d.cur.fieldsNum {
+			c = Change{Object: num, Kind: KindFormDictionary, Detail: "/Fields"}
+			if kind := d.signaturesAdded(ref, ref); kind != "" {
+				c.Kind, c.Detail = kind, "signature field added to the form"
+			}
+			break
+		}
+		return d.annotsChanges(num, d.cur.annotArrays[num], ref, ref, "annotations in the /Annots array")
+	case nameVal(dict.Get("Type")) == "Pages":
+		c = d.pageTreeChange(num, oldDict, dict)
+	case d.cur.pages[num] > 0:
+		if !existed {
+			c.Kind, c.Page = KindPageAdded, d.cur.pages[num]
+			break
+		}
+		return d.pageChanges(num, oldDict, dict)
+	case isField(dict) || nameVal(dict.Get("Subtype")) == "Widget":
+		c = d.fieldChange(num, oldDict, dict)
+	case d.cur.annots[num] > 0 || (dict.Get("Subtype") != nil && dict.Get("Rect") != nil):
+		c.Page = d.cur.annots[num]
+		c.Detail = "/" + nameVal(dict.Get("Subtype"))
+		if existed {
+			c.Kind = KindAnnotationModified
+			c.Detail += " " + keyList(changedKeys(oldDict, dict))
+		} else {
+			c.Kind = KindAnnotationAdded
+		}
+	default:
+		switch d.cur.roles[num] {
+		case roleContent:
+			c.Kind = KindContentChange
+		case roleFormResources:
+			c.Kind, c.Detail = KindFormDictionary, "default resources"
+		case roleFormAppearance:
+			c.Kind = KindFormAppearance
+		case roleAnnotAppearance:
+			c.Kind = KindAnnotationAppearance
+		case roleTimestampAppearance:
+			c.Kind, c.Detail = KindDocTimestamp, "appearance"
+		default:
+			c.Kind = KindUnused
+			if d.cur.reachable[num] {
+				c.Kind = KindOther
+				if existed {
+					c.Detail = keyList(changedKeys(oldDict, dict))
+				}
+			}
+		}
+	}
+	return []Change{c}
+}
+
+// deleted classifies the deletion of the object `num` of the previous revision.
+func (d *differ) deleted(num int64) []Change {
+	c := Change{Object: num, Detail: "deleted"}
+	switch {
+	case d.prev.annots[num] > 0:
+		c.Kind, c.Page = KindAnnotationDeleted, d.prev.annots[num]
+	case d.prev.pages[num] > 0:
+		c.Kind, c.Page = KindPageDeleted, d.prev.pages[num]
+	case d.prev.roles[num] == roleValidation:
+		c.Kind = KindValidationData
+	case d.prev.reachable[num] && !d.cur.reachable[num]:
+		// No longer referenced: the change is that of the object referring to it.
+		return nil
+	case d.prev.reachable[num]:
+		c.Kind = KindOther
+	default:
+		c.Kind = KindUnused
+	}
+	return []Change{c}
+}
+
+// catalogChanges classifies the changed entries of the catalog.
+func (d *differ) catalogChanges(num int64, old, dict *core.PdfObjectDictionary) []Change {
+	if old == nil {
+		old = core.MakeDict()
+	}
+	var changes []Change
+	for _, key := range changedKeys(old, dict) {
+		c := Change{Object: num, Detail: "catalog /" + key}
+		switch key {
+		case "AcroForm":
+			oldForm, _ := d.prev.rev.resolveDict(old.Get("AcroForm"))
+			form, _ := d.cur.rev.resolveDict(dict.Get("AcroForm"))
+			if form == nil {
+				c.Kind = KindFormDictionary
+				break
+			}
+			c = d.formDictChange(num, oldForm, form)
+		case "DSS", "Extensions", "Version":
+			// The extensions and version declare the PAdES features of the signatures and DSS.
+			c.Kind = KindValidationData
+		case "Metadata":
+			c.Kind = KindMetadata
+		default:
+			c.Kind = KindOther
+		}
+		changes = append(changes, c)
+	}
+	return changes
+}
+
+// formDictChange classifies the change of the interactive form dictionary: adding signature
+// fields is a signature addition, any other change is a form dictionary change.
+func (d *differ) formDictChange(num int64, old, dict *core.PdfObjectDictionary) Change {
+	c := Change{Object: num, Kind: KindFormDictionary}
+	keys := changedKeys(old, dict)
+	c.Detail = keyList(keys)
+	for _, key := range keys {
+		if key != "Fields" && key != "SigFlags" {
+			return c
+		}
+	}
+	var oldFields core.PdfObject
+	if old != nil {
+		oldFields = old.Get("Fields")
+	}
+	if kind := d.signaturesAdded(oldFields, dict.Get("Fields")); kind != "" {
+		c.Kind, c.Detail = kind, "signature field added to the form"
+	}
+	return c
+}
+
+// signaturesAdded returns the kind of the fields added to the form fields `old` of the previous
+// revision in the form fields `fields`: KindDocTimestamp if they are all document timestamps,
+// KindSignatureAdded if they are all signature fields, and "" if there are other fields or none.
+func (d *differ) signaturesAdded(old, fields core.PdfObject) string {
+	oldFields := fieldNums(d.prev.rev, old)
+	kind := ""
+	for num := range fieldNums(d.cur.rev, fields) {
+		if oldFields[num] {
+			continue
+		}
+		field, ok := d.cur.rev.dict(num)
+		if !ok || fieldType(d.cur.rev, field) != "Sig" {
+			return ""
+		}
+		if sig, ok := d.cur.rev.resolveDict(field.Get("V")); ok &&
+			nameVal(sig.Get("SubFilter")) == "ETSI.RFC3161" && kind != KindSignatureAdded {
+			kind = KindDocTimestamp
+		} else {
+			kind = KindSignatureAdded
+		}
+	}
+	return kind
+}
+
+// pageTreeChange classifies the change of an intermediate node of the page tree.
+func (d *differ) pageTreeChange(num int64, old, dict *core.PdfObjectDictionary) Change {
+	c := Change{Object: num, Kind: KindOther, Detail: "page tree " + keyList(changedKeys(old, dict))}
+	count, _ := core.GetIntVal(d.cur.rev.resolve(dict.Get("Count")))
+	oldCount := 0
+	if old != nil {
+		oldCount, _ = core.GetIntVal(d.prev.rev.resolve(old.Get("Count")))
+	}
+	switch {
+	case count > oldCount:
+		c.Kind = KindPageAdded
+	case count < oldCount:
+		c.Kind = KindPageDeleted
+	}
+	if count != oldCount {
+		c.Detail = fmt.Sprintf("page tree: %d to %d pages", oldCount, count)
+	}
+	return c
+}
+
+// pageChanges classifies the changes of a page: the annotations added to or removed from its
+// /Annots and the changes of its other entries, which change its content.
+func (d *differ) pageChanges(num int64, old, dict *core.PdfObjectDictionary) []Change {
+	if old == nil {
+		old = core.MakeDict()
+	}
+	page := d.cur.pages[num]
+	var changes []Change
+	var others []string
+	for _, key := range changedKeys(old, dict) {
+		if key != "Annots" {
+			others = append(others, key)
+			continue
+		}
+		changes = append(changes, d.annotsChanges(num, page, old.Get("Annots"), dict.Get("Annots"),
+			"annotations in the page dictionary")...)
+	}
+	if len(others) > 0 {
+		changes = append(changes, Change{Object: num, Kind: KindContentChange, Page: page,
+			Detail: "page " + keyList(others)})
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Object < changes[j].Object })
+	return changes
+}
+
+// annotsChanges classifies the changes from the /Annots `old` of the previous revision to the
+// /Annots `annotsObj` of the page `page`: the annotations added to or removed from it and the
+// changes of its direct annotations, a modification of the object `num` detailed by `directDetail`.
+func (d *differ) annotsChanges(num int64, page int, old, annotsObj core.PdfObject, directDetail string) []Change {
+	var changes []Change
+	oldAnnots, oldDirect := annotNums(d.prev.rev, old)
+	annots, direct := annotNums(d.cur.rev, annotsObj)
+	for n := range oldAnnots {
+		// The deleted annotation objects are reported with the object deletions.
+		if !annots[n] && d.cur.rev.objects[n] != nil {
+			changes = append(changes, Change{Object: n, Kind: KindAnnotationDeleted, Page: page,
+				Detail: "removed from the page"})
+		}
+	}
+	for n := range annots {
+		// The new annotation objects are reported with the object additions.
+		if !oldAnnots[n] && d.prev.rev.objects[n] != nil {
+			changes = append(changes, Change{Object: n, Kind: KindAnnotationAdded, Page: page,
+				Detail: "added to the page"})
+		}
+	}
+	if oldDirect != direct {
+		changes = append(changes, Change{Object: num, Kind: KindAnnotationModified, Page: page,
+			Detail: directDetail})
+	}
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Object < changes[j].Object })
+	return changes
+}
+
+// fieldChange classifies the change of a form field or widget.
+func (d *differ) fieldChange(num int64, old, dict *core.PdfObjectDictionary) Change {
+	rev := d.cur.rev
+	c := Change{Object: num, Field: fieldName(rev, dict), Page: d.cur.annots[num]}
+	ft := fieldType(rev, dict)
+	sig, signed := rev.resolveDict(dict.Get("V"))
+	signed = signed && isSignatureDict(sig)
+	timestamp := signed && nameVal(sig.Get("SubFilter")) == "ETSI.RFC3161"
+	if old == nil {
+		switch {
+		case timestamp:
+			c.Kind = KindDocTimestamp
+		case ft == "Sig":
+			c.Kind, c.Detail = KindSignatureAdded, "signature field"
+		default:
+			c.Kind, c.Detail = KindFormFieldAdded, "/"+ft
+		}
+		return c
+	}
+
+	keys := changedKeys(old, dict)
+	c.Detail = keyList(keys)
+	valueOnly, hasValue := true, false
+	for _, key := range keys {
+		switch key {
+		case "V":
+			hasValue = true
+		case "AS", "AP":
+		default:
+			valueOnly = false
+		}
+	}
+	switch {
+	case !valueOnly:
+		c.Kind = KindFormFieldModified
+	case ft == "Sig" && hasValue:
+		c.Kind = KindSignatureModified
+		if old.Get("V") == nil {
+			c.Kind, c.Detail = KindSignatureAdded, "signed"
+			if timestamp {
+				c.Kind = KindDocTimestamp
+			}
+		}
+	case hasValue:
+		c.Kind, c.Detail = KindFormFieldValue, valueText(rev.resolve(dict.Get("V")))
+	default:
+		c.Kind = KindFormAppearance
+	}
+	return c
+}
+
+// isField returns true if `dict` is a form field dictionary.
+func isField(dict *core.PdfObjectDictionary) bool {
+	return dict.Get("FT") != nil || dict.Get("T") != nil
+}
+
+// fieldNums returns the numbers of the field objects of the form fields `fields` of `rev`.
+func fieldNums(rev *revision, fields core.PdfObject) map[int64]bool {
+	nums := map[int64]bool{}
+	var walk func(obj core.PdfObject, depth int)
+	walk = func(obj core.PdfObject, depth int) {
+		arr, ok := rev.resolveArray(obj)
+		if !ok || depth > maxDepth {
+			return
+		}
+		for _, elem := range arr.Elements() {
+			num, ok := objectNum(elem)
+			if !ok || nums[num] {
+				continue
+			}
+			nums[num] = true
+			if field, ok := rev.dict(num); ok {
+				walk(field.Get("Kids"), depth+1)
+			}
+		}
+	}
+	walk(fields, 0)
+	return nums
+}
+
+// annotNums returns the numbers of the annotation objects of the /Annots `obj` of `rev` and the
+// serialization of its direct annotations.
+func annotNums(rev *revision, obj core.PdfObject) (map[int64]bool, string) {
+	nums := map[int64]bool{}
+	var direct strings.Builder
+	if arr, ok := rev.resolveArray(obj); ok {
+		for _, elem := range arr.Elements() {
+			if num, ok := objectNum(elem); ok {
+				nums[num] = true
+			} else {
+				direct.WriteString(serialize(elem))
+			}
+		}
+	}
+	return nums, direct.String()
+}
+
+// changedKeys returns the keys whose values differ between the dictionaries `old` and `dict`,
+// either of which may be nil.
+func changedKeys(old, dict *core.PdfObjectDictionary) []string {
+	keys := map[string]bool{}
+	for _, d := range []*core.PdfObjectDictionary{old, dict} {
+		if d == nil {
+			continue
+		}
+		for _, key := range d.Keys() {
+			keys[string(key)] = true
+		}
+	}
+	var changed []string
+	for key := range keys {
+		if valueString(old, key) != valueString(dict, key) {
+			changed = append(changed, key)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// valueString returns the serialization of the value of `key` in `dict`, empty if absent.
+func valueString(dict *core.PdfObjectDictionary, key string) string {
+	if dict == nil {
+		return ""
+	}
+	obj := dict.Get(core.PdfObjectName(key))
+	if obj == nil {
+		return ""
+	}
+	return obj.WriteString()
+}
+
+// keyList formats `keys` as a list of PDF names.
+func keyList(keys []string) string {
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = "/" + key
+	}
+	return strings.Join(names, " ")
+}
+
+// valueText returns a short description of the field value `obj`.
+func valueText(obj core.PdfObject) string {
+	var text string
+	switch o := obj.(type) {
+	case nil:
+		return "cleared"
+	case *core.PdfObjectString:
+		text = fmt.Sprintf("%q", o.Decoded())
+	default:
+		text = o.WriteString()
+	}
+	if len(text) > 60 {
+		text = text[:57] + "..."
+	}
+	return "value " + text
+}
@@ -0,0 +1,297 @@
+/*
+ * Signatures of a document and the DocMDP and FieldMDP permissions they set on the later
+ * revisions.
+ */
+
+package revdiff
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/unidoc/unipdf/v4/core"
+)
+
+// DocMDP permission levels (/P of the transform parameters of a certification signature).
+const (
+	// NoChanges allows no change but validation data and document timestamps.
+	NoChanges = 1
+	// FormFilling allows filling in forms, instantiating page templates and signing.
+	FormFilling = 2
+	// FormFillingAndAnnotations also allows creating, deleting and modifying annotations.
+	FormFillingAndAnnotations = 3
+)
+
+// Signature describes a signature of the document and the permissions it sets.
+type Signature struct {
+	Field     string `json:"field"`
+	SubFilter string `json:"sub_filter"`
+	// Revision is the number of the revision the signature was applied in, 0 if its /ByteRange
+	// does not match a revision.
+	Revision int `json:"revision"`
+	// DocMDP is the /P of a certification signature, 0 for an approval signature.
+	DocMDP int `json:"docmdp,omitempty"`
+	// Lock describes the fields locked by the FieldMDP transform of the signature or the /Lock of
+	// its field, empty if none.
+	Lock string `json:"lock,omitempty"`
+
+	end  int64
+	lock *fieldLock
+}
+
+// fieldLock is a FieldMDP lock: the fields whose values the later revisions must not change.
+type fieldLock struct {
+	action string // All, Include or Exclude.
+	fields map[string]bool
+}
+
+// locks returns true if `lock` locks the field named `name` or one of its ancestors.
+func (lock *fieldLock) locks(name string) bool {
+	listed := false
+	for n := name; n != ""; {
+		if lock.fields[n] {
+			listed = true
+			break
+		}
+		i := strings.LastIndexByte(n, '.')
+		if i < 0 {
+			break
+		}
+		n = n[:i]
+	}
+	switch lock.action {
+	case "All":
+		return true
+	case "Include":
+		return listed
+	case "Exclude":
+		return !listed
+	}
+	return false
+}
+
+func (lock *fieldLock) String() string {
+	if lock.action == "All" {
+		return "all fields"
+	}
+	var names []string
+	for n := range lock.fields {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	if lock.action == "Exclude" {
+		return "all fields except " + strings.Join(names, ", ")
+	}
+	return strings.Join(names, ", ")
+}
+
+// findSignatures returns the signatures of the revision `rev`, the last of the document, in the
+// order of their revisions.
+func findSignatures(rev *revision) []*Signature {
+	var sigs []*Signature
+	seen := map[int64]bool{}
+	for _, num := range rev.objectNums() {
+		dict, ok := rev.dict(num)
+		if !ok {
+			continue
+		}
+		sigDict, ok := rev.resolveDict(dict.Get("V"))
+		if !ok || !isSignatureDict(sigDict) {
+			continue
+		}
+		if n, ok := objectNum(dict.Get("V")); ok {
+			if seen[n] {
+				continue
+			}
+			seen[n] = true
+		}
+		sig := &Signature{
+			Field:     fieldName(rev, dict),
+			SubFilter: nameVal(sigDict.Get("SubFilter")),
+			end:       byteRangeEnd(sigDict),
+		}
+		if lockDict, ok := rev.resolveDict(dict.Get("Lock")); ok {
+			sig.lock = newFieldLock(rev, lockDict)
+		}
+		refs, _ := rev.resolveArray(sigDict.Get("Reference"))
+		if refs != nil {
+			for _, obj := range refs.Elements() {
+				ref, ok := rev.resolveDict(obj)
+				if !ok {
+					continue
+				}
+				params, _ := rev.resolveDict(ref.Get("TransformParams"))
+				switch nameVal(ref.Get("TransformMethod")) {
+				case "DocMDP":
+					sig.DocMDP = FormFilling
+					if params != nil {
+						if p, ok := core.GetIntVal(rev.resolve(params.Get("P"))); ok && p >= NoChanges && p <= FormFillingAndAnnotations {
+							sig.DocMDP = p
+						}
+					}
+				case "FieldMDP":
+					if params != nil && sig.lock == nil {
+						sig.lock = newFieldLock(rev, params)
+					}
+				}
+			}
+		}
+		if sig.lock != nil {
+			sig.Lock = sig.lock.String()
+		}
+		sigs = append(sigs, sig)
+	}
+	sort.SliceStable(sigs, func(i, j int) bool { return sigs[i].end < sigs[j].end })
+	return sigs
+}
+
+// newFieldLock returns the lock of the FieldMDP transform parameters or /Lock dictionary `dict`.
+func newFieldLock(rev *revision, dict *core.PdfObjectDictionary) *fieldLock {
+	lock := &fieldLock{action: nameVal(dict.Get("Action")), fields: map[string]bool{}}
+	if arr, ok := rev.resolveArray(dict.Get("Fields")); ok {
+		for _, obj := range arr.Elements() {
+			if s, ok := core.GetString(rev.resolve(obj)); ok {
+				lock.fields[s.Decoded()] = true
+			}
+		}
+	}
+	return lock
+}
+
+// permissions are the permissions in force for a revision: those of the signatures of the previous
+// revisions.
+type permissions struct {
+	signatures []*Signature
+	docMDP     int        // 0 without a certification signature.
+	certifier  *Signature // The certification signature.
+}
+
+// permissionsAfter returns the permissions set by the signatures `sigs` applied in revisions
+// before `revNum`.
+func permissionsAfter(sigs []*Signature, revNum int) permissions {
+	var p permissions
+	for _, sig := range sigs {
+		if sig.Revision == 0 || sig.Revision >= revNum {
+			continue
+		}
+		p.signatures = append(p.signatures, sig)
+		if sig.DocMDP > 0 && (p.docMDP == 0 || sig.DocMDP < p.docMDP) {
+			p.docMDP = sig.DocMDP
+			p.certifier = sig
+		}
+	}
+	return p
+}
+
+// String describes the permissions.
+func (p permissions) String() string {
+	if len(p.signatures) == 0 {
+		return "not signed"
+	}
+	var parts []string
+	if p.certifier != nil {
+		parts = append(parts, fmt.Sprintf("DocMDP P=%d (%s)", p.docMDP, p.certifier.Field))
+	} else {
+		parts = append(parts, "approval signatures only")
+	}
+	for _, sig := range p.signatures {
+		if sig.lock != nil {
+			parts = append(parts, fmt.Sprintf("%s locks %s", sig.Field, sig.Lock))
+		}
+	}
+	return strings.Join(parts, "; ")
+}
+
+// check sets whether the change `c` is allowed by the permissions and the rule deciding it.
+// Without a certification signature the changes Acrobat accepts after approval signatures, those
+// of DocMDP P=3, are allowed.
+func (p permissions) check(c *Change) {
+	if len(p.signatures) == 0 {
+		c.Allowed, c.Rule = true, "before the first signature"
+		return
+	}
+	switch c.Kind {
+	case KindValidationData, KindDocTimestamp, KindUnused:
+		c.Allowed, c.Rule = true, "always allowed"
+		return
+	}
+	if c.Field != "" {
+		switch c.Kind {
+		case KindFormFieldValue, KindFormAppearance, KindFormFieldModified, KindSignatureAdded:
+			for _, sig := range p.signatures {
+				if sig.lock != nil && sig.lock.locks(c.Field) {
+					c.Allowed, c.Rule = false, fmt.Sprintf("field locked by the FieldMDP of %s", sig.Field)
+					return
+				}
+			}
+		}
+	}
+
+	level, by := FormFillingAndAnnotations, "approval signatures (as P=3)"
+	if p.certifier != nil {
+		level, by = p.docMDP, fmt.Sprintf("DocMDP P=%d of %s", p.docMDP, p.certifier.Field)
+	}
+	required := 0 // Not allowed at any level.
+	switch c.Kind {
+	case KindSignatureAdded, KindFormFieldValue, KindFormAppearance, KindFormDictionary, KindMetadata:
+		required = FormFilling
+	case KindAnnotationAdded, KindAnnotationModified, KindAnnotationDeleted, KindAnnotationAppearance:
+		required = FormFillingAndAnnotations
+	}
+	c.Allowed = required > 0 && level >= required
+	if c.Allowed {
+		c.Rule = "allowed by " + by
+	} else {
+		c.Rule = "not allowed by " + by
+	}
+}
+
+// isSignatureDict returns true if `dict` is a signature dictionary.
+func isSignatureDict(dict *core.PdfObjectDictionary) bool {
+	return dict.Get("ByteRange") != nil && dict.Get("Contents") != nil
+}
+
+// byteRangeEnd returns the end of the last byte range of the signature dictionary `dict`, -1 if
+// it is malformed.
+func byteRangeEnd(dict *core.PdfObjectDictionary) int64 {
+	arr, ok := core.GetArray(dict.Get("ByteRange"))
+	if !ok {
+		return -1
+	}
+	br, err := arr.ToInt64Array()
+	if err != nil || len(br) < 2 || len(br)%2 != 0 {
+		return -1
+	}
+	return br[len(br)-2] + br[len(br)-1]
+}
+
+// fieldName returns the full name of the field or widget `dict` of `rev`.
+func fieldName(rev *revision, dict *core.PdfObjectDictionary) string {
+	var parts []string
+	for depth := 0; dict != nil && depth < maxDepth; depth++ {
+		if s, ok := core.GetString(rev.resolve(dict.Get("T"))); ok {
+			parts = append([]string{s.Decoded()}, parts...)
+		}
+		dict, _ = rev.resolveDict(dict.Get("Parent"))
+	}
+	return strings.Join(parts, ".")
+}
+
+// fieldType returns the field type of the field or widget `dict` of `rev`, inherited from its
+// ancestors.
+func fieldType(rev *revision, dict *core.PdfObjectDictionary) string {
+	for depth := 0; dict != nil && depth < maxDepth; depth++ {
+		if ft := nameVal(dict.Get("FT")); ft != "" {
+			return ft
+		}
+		dict, _ = rev.resolveDict(dict.Get("Parent"))
+	}
+	return ""
+}
+
+// nameVal returns the value of the name `obj`, empty if it is not a name.
+func nameVal(obj core.PdfObject) string {
+	name, _ := core.GetNameVal(obj)
+	return name
+}
@@ -0,0 +1,151 @@
+/*
+ * Change analysis of the revisions of a document and its text and JSON reports.
+ */
+
+package revdiff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// Revision is a revision of a document and its changes from the previous revision.
+type Revision struct {
+	// Number is the number of the revision, 1 for the original document.
+	Number int   `json:"number"`
+	End    int64 `json:"end"`
+	// Signatures are the fields of the signatures applied in the revision.
+	Signatures []string `json:"signatures,omitempty"`
+	// Permissions describes the permissions of the signatures of the previous revisions.
+	Permissions string   `json:"permissions"`
+	Changes     []Change `json:"changes,omitempty"`
+}
+
+// Report is the change analysis of the revisions of a document.
+type Report struct {
+	File       string       `json:"file,omitempty"`
+	Signatures []*Signature `json:"signatures"`
+	Revisions  []*Revision  `json:"revisions"`
+	// Disallowed is the number of changes that the permissions in force do not allow.
+	Disallowed int `json:"disallowed"`
+}
+
+// AnalyzeFile analyzes the revisions of the PDF file `path`.
+func AnalyzeFile(path string) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	report, err := Analyze(data)
+	if err != nil {
+		return nil, err
+	}
+	report.File = path
+	return report, nil
+}
+
+// Analyze analyzes the revisions of the PDF document `data`: it diffs the objects of each revision
+// with those of the previous one, classifies the changes and checks them against the DocMDP and
+// FieldMDP permissions of the signatures of the previous revisions.
+func Analyze(data []byte) (*Report, error) {
+	revisions, err := splitRevisions(data)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{Signatures: findSignatures(revisions[len(revisions)-1])}
+	for i, rev := range revisions {
+		report.Revisions = append(report.Revisions, &Revision{Number: i + 1, End: rev.end})
+	}
+	for _, sig := range report.Signatures {
+		// The signature covers its revision, up to the end of line of the %%EOF marker that may
+		// not be included.
+		for i, rev := range revisions {
+			if sig.end > 0 && sig.end <= rev.end && (i == 0 || sig.end > revisions[i-1].end) {
+				sig.Revision = i + 1
+				report.Revisions[i].Signatures = append(report.Revisions[i].Signatures, sig.Field)
+				break
+			}
+		}
+	}
+
+	for i := 1; i < len(revisions); i++ {
+		r := report.Revisions[i]
+		perms := permissionsAfter(report.Signatures, r.Number)
+		r.Permissions = perms.String()
+		r.Changes = diffRevisions(revisions[i-1], revisions[i])
+		for j := range r.Changes {
+			perms.check(&r.Changes[j])
+			if !r.Changes[j].Allowed {
+				report.Disallowed++
+			}
+		}
+	}
+	report.Revisions[0].Permissions = permissionsAfter(report.Signatures, 1).String()
+	return report, nil
+}
+
+// HasDisallowed returns true if a revision of the document makes a change that the signatures
+// before it do not allow.
+func (r *Report) HasDisallowed() bool {
+	return r.Disallowed > 0
+}
+
+// WriteJSON writes `reports` to `w` as JSON.
+func WriteJSON(w io.Writer, reports []*Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(reports)
+}
+
+// WriteText writes `r` to `w` as text.
+func (r *Report) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "%s: %d revisions, %d signatures, %d disallowed changes\n",
+		r.File, len(r.Revisions), len(r.Signatures), r.Disallowed)
+	for _, sig := range r.Signatures {
+		var notes []string
+		if sig.DocMDP > 0 {
+			notes = append(notes, fmt.Sprintf("certification P=%d", sig.DocMDP))
+		}
+		if sig.Lock != "" {
+			notes = append(notes, "locks "+sig.Lock)
+		}
+		line := fmt.Sprintf("  signature %q (%s) in revision %d", sig.Field, sig.SubFilter, sig.Revision)
+		if len(notes) > 0 {
+			line += ": " + strings.Join(notes, ", ")
+		}
+		fmt.Fprintln(w, line)
+	}
+	for _, rev := range r.Revisions {
+		line := fmt.Sprintf("  revision %d (%d bytes)", rev.Number, rev.End)
+		if rev.Number > 1 {
+			line += fmt.Sprintf(", %d changes under %s", len(rev.Changes), rev.Permissions)
+		}
+		if len(rev.Signatures) > 0 {
+			line += fmt.Sprintf(", signs %s", strings.Join(rev.Signatures, ", "))
+		}
+		fmt.Fprintln(w, line)
+		for _, c := range rev.Changes {
+			status := "ok  "
+			if !c.Allowed {
+				status = "FAIL"
+			}
+			line := fmt.Sprintf("     %s %-24s obj %d", status, c.Kind, c.Object)
+			if c.Page > 0 {
+				line += fmt.Sprintf(" page %d", c.Page)
+			}
+			if c.Field != "" {
+				line += fmt.Sprintf(" field %q", c.Field)
+			}
+			if c.Detail != "" {
+				line += " " + c.Detail
+			}
+			if !c.Allowed {
+				line += ": " + c.Rule
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+}
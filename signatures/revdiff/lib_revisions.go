@@ -0,0 +1,184 @@
+/*
+ * Package revdiff analyzes the incremental updates of signed PDF documents: it splits a document
+ * into its revisions, diffs the objects of each revision with the previous one, classifies the
+ * changes (form field values, annotations, pages, content streams, signatures, ...) and checks them
+ * against the DocMDP and FieldMDP permissions of the signatures they follow.
+ */
+
+package revdiff
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+
+	"github.com/unidoc/unipdf/v4/core"
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// eofMarker ends each revision of a PDF file.
+var eofMarker = []byte("%%EOF")
+
+// revision is a revision of a document: the file up to the end of one of its incremental updates.
+type revision struct {
+	end    int64 // Offset of the end of the revision.
+	reader *model.PdfReader
+	// objects are the objects of the revision by number.
+	objects map[int64]core.PdfObject
+}
+
+// splitRevisions returns the revisions of the PDF document `data`, from the original document to
+// the last update. The revisions end at the %%EOF markers and their end of line. Markers after
+// which the document cannot be opened, e.g. in a stream or the first part of a linearized file,
+// are skipped.
+func splitRevisions(data []byte) ([]*revision, error) {
+	var ends []int64
+	for i := 0; ; {
+		j := bytes.Index(data[i:], eofMarker)
+		if j < 0 {
+			break
+		}
+		end := i + j + len(eofMarker)
+		for end < len(data) && (data[end] == '\r' || data[end] == '\n') {
+			end++
+		}
+		ends = append(ends, int64(end))
+		i = end
+	}
+	if len(ends) == 0 || ends[len(ends)-1] != int64(len(data)) {
+		// Content after the last marker belongs to the last revision.
+		if len(ends) > 0 {
+			ends = ends[:len(ends)-1]
+		}
+		ends = append(ends, int64(len(data)))
+	}
+
+	var revisions []*revision
+	for i, end := range ends {
+		rev, err := loadRevision(data[:end])
+		if err != nil {
+			if i == len(ends)-1 {
+				return nil, err
+			}
+			continue
+		}
+		rev.end = end
+		revisions = append(revisions, rev)
+	}
+	return revisions, nil
+}
+
+// loadRevision opens the revision `data` and loads its objects.
+func loadRevision(data []byte) (*revision, error) {
+	reader, err := model.NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	rev := &revision{reader: reader, objects: map[int64]core.PdfObject{}}
+	for _, num := range reader.GetObjectNums() {
+		obj, err := reader.GetIndirectObjectByNumber(num)
+		if err != nil || obj == nil {
+			continue
+		}
+		rev.objects[int64(num)] = obj
+	}
+	if len(rev.objects) == 0 {
+		return nil, fmt.Errorf("no objects")
+	}
+	return rev, nil
+}
+
+// objectNums returns the numbers of the objects of `rev` in increasing order.
+func (rev *revision) objectNums() []int64 {
+	nums := make([]int64, 0, len(rev.objects))
+	for num := range rev.objects {
+		nums = append(nums, num)
+	}
+	sort.Slice(nums, func(i, j int) bool { return nums[i] < nums[j] })
+	return nums
+}
+
+// dict returns the dictionary of the object `num` of `rev`, of a stream for a stream object.
+func (rev *revision) dict(num int64) (*core.PdfObjectDictionary, bool) {
+	switch obj := rev.objects[num].(type) {
+	case *core.PdfObjectStream:
+		return obj.PdfObjectDictionary, obj.PdfObjectDictionary != nil
+	case *core.PdfIndirectObject:
+		return core.GetDict(obj.PdfObject)
+	}
+	return nil, false
+}
+
+// resolve returns the object `obj` refers to in `rev`, or `obj` if it is a direct object.
+func (rev *revision) resolve(obj core.PdfObject) core.PdfObject {
+	if num, ok := objectNum(obj); ok {
+		if o, ok := rev.objects[num]; ok {
+			if ind, ok := o.(*core.PdfIndirectObject); ok {
+				return ind.PdfObject
+			}
+			return o
+		}
+		return nil
+	}
+	return obj
+}
+
+// resolveDict returns the dictionary `obj` is or refers to in `rev`.
+func (rev *revision) resolveDict(obj core.PdfObject) (*core.PdfObjectDictionary, bool) {
+	switch o := rev.resolve(obj).(type) {
+	case *core.PdfObjectDictionary:
+		return o, true
+	case *core.PdfObjectStream:
+		return o.PdfObjectDictionary, o.PdfObjectDictionary != nil
+	}
+	return nil, false
+}
+
+// resolveArray returns the array `obj` is or refers to in `rev`.
+func (rev *revision) resolveArray(obj core.PdfObject) (*core.PdfObjectArray, bool) {
+	arr, ok := rev.resolve(obj).(*core.PdfObjectArray)
+	return arr, ok
+}
+
+// root returns the catalog of `rev`.
+func (rev *revision) root() (*core.PdfObjectDictionary, int64) {
+	trailer, err := rev.reader.GetTrailer()
+	if err != nil || trailer == nil {
+		return nil, 0
+	}
+	num, _ := objectNum(trailer.Get("Root"))
+	root, _ := rev.resolveDict(trailer.Get("Root"))
+	return root, num
+}
+
+// objectNum returns the number of the object referenced by `obj`, if it is a reference.
+func objectNum(obj core.PdfObject) (int64, bool) {
+	switch o := obj.(type) {
+	case *core.PdfObjectReference:
+		return o.ObjectNumber, true
+	case *core.PdfIndirectObject:
+		return o.ObjectNumber, true
+	case *core.PdfObjectStream:
+		return o.ObjectNumber, true
+	}
+	return 0, false
+}
+
+// serialize returns the serialization of the object `obj` of a revision, with the raw data of the
+// streams, to compare objects across revisions. The references are not followed.
+func serialize(obj core.PdfObject) string {
+	switch o := obj.(type) {
+	case *core.PdfIndirectObject:
+		if o.PdfObject == nil {
+			return "null"
+		}
+		return o.PdfObject.WriteString()
+	case *core.PdfObjectStream:
+		var dict string
+		if o.PdfObjectDictionary != nil {
+			dict = o.PdfObjectDictionary.WriteString()
+		}
+		return dict + "stream" + string(o.Stream)
+	}
+	return obj.WriteString()
+}
@@ -0,0 +1,146 @@
+/*
+ * Tests of the classification of the changes of incremental updates, on documents written by the
+ * tests.
+ *
+ * Run as: go test ./signatures/revdiff/
+ *
+ * The tests need a license and are skipped without it: a metered license key in
+ * UNIDOC_LICENSE_API_KEY or, to run them offline, an offline license key file in UNIDOC_LICENSE_FILE
+ * with its customer name in UNIDOC_LICENSE_CUSTOMER.
+ */
+
+package revdiff
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"testing"
+
+	"github.com/unidoc/unidoc-examples/testing/testlicense"
+)
+
+// appendRevision appends to the document `data` a revision, the original document if `data` is
+// empty, with the objects `objects` by number and a trailer of `size` objects.
+func appendRevision(data []byte, objects map[int]string, size int) []byte {
+	var buf bytes.Buffer
+	buf.Write(data)
+	prev := -1
+	if len(data) == 0 {
+		buf.WriteString("%PDF-1.7\n")
+	} else {
+		prev = bytes.LastIndex(data, []byte("startxref"))
+		fmt.Sscanf(string(data[prev+len("startxref"):]), "%d", &prev)
+	}
+
+	nums := make([]int, 0, len(objects))
+	for num := range objects {
+		nums = append(nums, num)
+	}
+	sort.Ints(nums)
+	offsets := map[int]int{}
+	for _, num := range nums {
+		offsets[num] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", num, objects[num])
+	}
+
+	xref := buf.Len()
+	buf.WriteString("xref\n")
+	if prev < 0 {
+		buf.WriteString("0 1\n0000000000 65535 f \n")
+	}
+	for _, num := range nums {
+		fmt.Fprintf(&buf, "%d 1\n%010d 00000 n \n", num, offsets[num])
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R", size)
+	if prev >= 0 {
+		fmt.Fprintf(&buf, " /Prev %d", prev)
+	}
+	fmt.Fprintf(&buf, " >>\nstartxref\n%d\n%%%%EOF\n", xref)
+	return buf.Bytes()
+}
+
+// original is a one page document whose /Annots and form /Fields are indirect arrays.
+var original = map[int]string{
+	1: "<< /Type /Catalog /Pages 2 0 R /AcroForm 6 0 R >>",
+	2: "<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+	3: "<< /Type /Page /Parent 2 0 R /MediaBox [0 0 200 200] /Annots 4 0 R >>",
+	4: "[5 0 R]",
+	5: "<< /Type /Annot /Subtype /Text /Rect [10 10 30 30] /Contents (first) >>",
+	6: "<< /Fields 7 0 R >>",
+	7: "[]",
+}
+
+// diffUpdate returns the changes of the update `update` of the original document, checked against
+// the permissions of an approval signature of the original document.
+func diffUpdate(t *testing.T, update map[int]string) []Change {
+	t.Helper()
+	data := appendRevision(nil, original, 8)
+	data = appendRevision(data, update, 10)
+	revisions, err := splitRevisions(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 {
+		t.Fatalf("got %d revisions, want 2", len(revisions))
+	}
+	changes := diffRevisions(revisions[0], revisions[1])
+	p := permissions{signatures: []*Signature{{Field: "Approval", Revision: 1}}}
+	for i := range changes {
+		p.check(&changes[i])
+	}
+	return changes
+}
+
+// checkChanges checks that `changes` are allowed and that the objects `want` changed with their
+// kinds.
+func checkChanges(t *testing.T, changes []Change, want map[int64]string) {
+	t.Helper()
+	got := map[int64]string{}
+	for _, c := range changes {
+		if !c.Allowed {
+			t.Errorf("object %d: %s %s: %s", c.Object, c.Kind, c.Detail, c.Rule)
+		}
+		got[c.Object] = c.Kind
+	}
+	for num, kind := range want {
+		if got[num] != kind {
+			t.Errorf("object %d: got kind %q, want %q", num, got[num], kind)
+		}
+	}
+}
+
+func TestIndirectAnnots(t *testing.T) {
+	testlicense.Setup(t)
+
+	t.Run("added", func(t *testing.T) {
+		changes := diffUpdate(t, map[int]string{
+			4: "[5 0 R 8 0 R]",
+			8: "<< /Type /Annot /Subtype /Text /Rect [40 10 60 30] /Contents (second) >>",
+		})
+		checkChanges(t, changes, map[int64]string{8: KindAnnotationAdded})
+	})
+
+	t.Run("removed", func(t *testing.T) {
+		changes := diffUpdate(t, map[int]string{4: "[]"})
+		checkChanges(t, changes, map[int64]string{5: KindAnnotationDeleted})
+	})
+
+	t.Run("direct", func(t *testing.T) {
+		changes := diffUpdate(t, map[int]string{
+			4: "[5 0 R << /Type /Annot /Subtype /Text /Rect [40 10 60 30] >>]",
+		})
+		checkChanges(t, changes, map[int64]string{4: KindAnnotationModified})
+	})
+}
+
+func TestIndirectFields(t *testing.T) {
+	testlicense.Setup(t)
+
+	changes := diffUpdate(t, map[int]string{
+		4: "[5 0 R 8 0 R]",
+		7: "[8 0 R]",
+		8: "<< /Type /Annot /Subtype /Widget /FT /Sig /T (Approval2) /Rect [40 10 60 30] /P 3 0 R >>",
+	})
+	checkChanges(t, changes, map[int64]string{7: KindSignatureAdded, 8: KindSignatureAdded})
+}
@@ -0,0 +1,88 @@
+/*
+ * Software signer backends: keys of PKCS#12 and PEM files.
+ */
+
+package signsvc
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+func init() {
+	RegisterBackend("pkcs12", newPKCS12Signer)
+	RegisterBackend("pem", newPEMSigner)
+}
+
+// newPKCS12Signer opens the key and certificate chain of the PKCS#12 file `cfg.Path`.
+func newPKCS12Signer(cfg Config) (Signer, error) {
+	data, err := os.ReadFile(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	key, cert, caCerts, err := pkcs12.DecodeChain(data, cfg.Password)
+	if err != nil {
+		return nil, err
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	return NewKeySigner(signer, append([]*x509.Certificate{cert}, caCerts...)...)
+}
+
+// newPEMSigner opens the key of the PEM file `cfg.KeyPath` and the certificate chain of the PEM
+// file `cfg.CertPath`.
+func newPEMSigner(cfg Config) (Signer, error) {
+	data, err := os.ReadFile(cfg.KeyPath)
+	if err != nil {
+		return nil, err
+	}
+	key, err := parsePrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", cfg.KeyPath, err)
+	}
+	certs, err := readCertificates(cfg.CertPath)
+	if err != nil {
+		return nil, err
+	}
+	return NewKeySigner(key, certs...)
+}
+
+// parsePrivateKey returns the first private key of the PEM data `data`, in PKCS#8, PKCS#1 or SEC 1
+// form.
+func parsePrivateKey(data []byte) (crypto.Signer, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("no private key")
+		}
+		var key any
+		var err error
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "EC PRIVATE KEY":
+			key, err = x509.ParseECPrivateKey(block.Bytes)
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		signer, ok := key.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported key type %T", key)
+		}
+		return signer, nil
+	}
+}
@@ -0,0 +1,93 @@
+/*
+ * GlobalSign Digital Signing Service signer backend.
+ */
+
+package signsvc
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/unidoc/globalsign-dss"
+)
+
+func init() {
+	RegisterBackend("globalsign", newGlobalSignSigner)
+}
+
+// globalSignSigner signs with the short-lived identities of the GlobalSign DSS API. The identity,
+// and so the signing certificate, is renewed by the client when it expires.
+type globalSignSigner struct {
+	client   *globalsign.Client
+	identity string
+	ctx      context.Context
+}
+
+// newGlobalSignSigner opens the GlobalSign DSS API with the API credentials of `cfg` and the mTLS
+// client certificate `cfg.CertPath` and key `cfg.KeyPath`.
+func newGlobalSignSigner(cfg Config) (Signer, error) {
+	client, err := globalsign.NewClient(cfg.APIKey, cfg.APISecret, cfg.CertPath, cfg.KeyPath)
+	if err != nil {
+		return nil, err
+	}
+	identity := cfg.Identity
+	if identity == "" {
+		identity = "UniDoc"
+	}
+	s := &globalSignSigner{client: client, identity: identity, ctx: context.Background()}
+	if _, err := s.Certificates(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Public returns the public key of the signing certificate of the current identity.
+func (s *globalSignSigner) Public() crypto.PublicKey {
+	certs, err := s.Certificates()
+	if err != nil {
+		return nil
+	}
+	return certs[0].PublicKey
+}
+
+// Sign signs the SHA-256 `digest` with the current identity.
+func (s *globalSignSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts.HashFunc() != crypto.SHA256 {
+		return nil, fmt.Errorf("unsupported digest %v", opts.HashFunc())
+	}
+	return s.client.DSSService.DSSIdentitySign(s.ctx, s.identity, &globalsign.IdentityRequest{}, digest)
+}
+
+// Certificates returns the signing certificate of the current identity followed by the
+// certificates of the GlobalSign CA.
+func (s *globalSignSigner) Certificates() ([]*x509.Certificate, error) {
+	identity, err := s.client.DSSService.DSSGetIdentity(s.ctx, s.identity, &globalsign.IdentityRequest{})
+	if err != nil {
+		return nil, err
+	}
+	var certs []*x509.Certificate
+	for _, data := range []string{identity.SigningCert, identity.CA} {
+		rest := []byte(data)
+		for {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, err
+			}
+			certs = append(certs, cert)
+		}
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no signing certificate")
+	}
+	return certs, nil
+}
@@ -0,0 +1,186 @@
+/*
+ * Cloud KMS signer backends: AWS KMS and Google Cloud KMS asymmetric signing keys.
+ */
+
+package signsvc
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+
+	kms "cloud.google.com/go/kms/apiv1"
+	kmspb "cloud.google.com/go/kms/apiv1/kmspb"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	awskms "github.com/aws/aws-sdk-go/service/kms"
+	gcOption "google.golang.org/api/option"
+	"google.golang.org/protobuf/types/known/wrapperspb"
+)
+
+func init() {
+	RegisterBackend("awskms", newAWSKMSSigner)
+	RegisterBackend("gcpkms", newGCPKMSSigner)
+}
+
+// awsKMSSigner signs with an AWS KMS asymmetric key with the SIGN_VERIFY key usage.
+type awsKMSSigner struct {
+	client *awskms.KMS
+	keyID  string
+	public crypto.PublicKey
+	certs  []*x509.Certificate
+}
+
+// newAWSKMSSigner opens the AWS KMS key `cfg.KeyID` of the region `cfg.Region`, with the
+// credentials of the default AWS credential chain.
+func newAWSKMSSigner(cfg Config) (Signer, error) {
+	if cfg.KeyID == "" {
+		return nil, errors.New("no key_id")
+	}
+	region := cfg.Region
+	if region == "" {
+		region = "us-west-1"
+	}
+	sess, err := session.NewSession(&aws.Config{
+		CredentialsChainVerboseErrors: aws.Bool(true),
+		Region:                        aws.String(region),
+	})
+	if err != nil {
+		return nil, err
+	}
+	s := &awsKMSSigner{client: awskms.New(sess), keyID: cfg.KeyID}
+	resp, err := s.client.GetPublicKey(&awskms.GetPublicKeyInput{KeyId: aws.String(cfg.KeyID)})
+	if err != nil {
+		return nil, err
+	}
+	if s.public, err = x509.ParsePKIXPublicKey(resp.PublicKey); err != nil {
+		return nil, err
+	}
+	if s.certs, err = certificatesOf(cfg.CertPath, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Public returns the public key of the KMS key.
+func (s *awsKMSSigner) Public() crypto.PublicKey {
+	return s.public
+}
+
+// Sign signs the SHA-256 `digest` with the KMS key.
+func (s *awsKMSSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts.HashFunc() != crypto.SHA256 {
+		return nil, fmt.Errorf("unsupported digest %v", opts.HashFunc())
+	}
+	algorithm := awskms.SigningAlgorithmSpecRsassaPkcs1V15Sha256
+	if _, ok := s.public.(*ecdsa.PublicKey); ok {
+		algorithm = awskms.SigningAlgorithmSpecEcdsaSha256
+	}
+	result, err := s.client.Sign(&awskms.SignInput{
+		KeyId:   aws.String(s.keyID),
+		Message: digest,
+		// The digest is sent instead of the message.
+		MessageType:      aws.String(awskms.MessageTypeDigest),
+		SigningAlgorithm: aws.String(algorithm),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.Signature, nil
+}
+
+// Certificates returns the signing certificate followed by its issuers.
+func (s *awsKMSSigner) Certificates() ([]*x509.Certificate, error) {
+	return s.certs, nil
+}
+
+// gcpKMSSigner signs with a Google Cloud KMS asymmetric signing key version.
+type gcpKMSSigner struct {
+	client  *kms.KeyManagementClient
+	keyName string
+	public  crypto.PublicKey
+	certs   []*x509.Certificate
+}
+
+// newGCPKMSSigner opens the Google Cloud KMS key version `cfg.KeyID` with the credentials of the
+// file `cfg.CredentialsPath`, or the default credentials without it.
+func newGCPKMSSigner(cfg Config) (Signer, error) {
+	if cfg.KeyID == "" {
+		return nil, errors.New("no key_id")
+	}
+	ctx := context.Background()
+	var opts []gcOption.ClientOption
+	if cfg.CredentialsPath != "" {
+		opts = append(opts, gcOption.WithCredentialsFile(cfg.CredentialsPath))
+	}
+	client, err := kms.NewKeyManagementClient(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	s := &gcpKMSSigner{client: client, keyName: cfg.KeyID}
+	resp, err := client.GetPublicKey(ctx, &kmspb.GetPublicKeyRequest{Name: cfg.KeyID})
+	if err != nil {
+		return nil, err
+	}
+	// Check the integrity of the response, see
+	// https://cloud.google.com/kms/docs/data-integrity-guidelines
+	if int64(crc32c([]byte(resp.Pem))) != resp.PemCrc32C.GetValue() {
+		return nil, errors.New("public key corrupted in transit")
+	}
+	block, _ := pem.Decode([]byte(resp.Pem))
+	if block == nil {
+		return nil, errors.New("invalid public key")
+	}
+	if s.public, err = x509.ParsePKIXPublicKey(block.Bytes); err != nil {
+		return nil, err
+	}
+	if s.certs, err = certificatesOf(cfg.CertPath, s); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// Public returns the public key of the KMS key version.
+func (s *gcpKMSSigner) Public() crypto.PublicKey {
+	return s.public
+}
+
+// Sign signs the SHA-256 `digest` with the KMS key version.
+func (s *gcpKMSSigner) Sign(rand io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts.HashFunc() != crypto.SHA256 {
+		return nil, fmt.Errorf("unsupported digest %v", opts.HashFunc())
+	}
+	result, err := s.client.AsymmetricSign(context.Background(), &kmspb.AsymmetricSignRequest{
+		Name: s.keyName,
+		Digest: &kmspb.Digest{
+			Digest: &kmspb.Digest_Sha256{Sha256: digest},
+		},
+		DigestCrc32C: wrapperspb.Int64(int64(crc32c(digest))),
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !result.VerifiedDigestCrc32C {
+		return nil, errors.New("sign request corrupted in transit")
+	}
+	if int64(crc32c(result.Signature)) != result.SignatureCrc32C.GetValue() {
+		return nil, errors.New("sign response corrupted in transit")
+	}
+	return result.Signature, nil
+}
+
+// Certificates returns the signing certificate followed by its issuers.
+func (s *gcpKMSSigner) Certificates() ([]*x509.Certificate, error) {
+	return s.certs, nil
+}
+
+// crc32c returns the CRC32C checksum of `data`.
+func crc32c(data []byte) uint32 {
+	return crc32.Checksum(data, crc32.MakeTable(crc32.Castagnoli))
+}
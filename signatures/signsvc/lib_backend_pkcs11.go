@@ -0,0 +1,62 @@
+//go:build cgo
+
+/*
+ * PKCS#11 signer backend for HSMs and tokens, e.g. SoftHSM. The crypto11 package needs cgo, the
+ * backend is only registered in cgo builds.
+ */
+
+package signsvc
+
+import (
+	"crypto/x509"
+	"errors"
+	"fmt"
+
+	"github.com/ThalesIgnite/crypto11"
+)
+
+func init() {
+	RegisterBackend("pkcs11", newPKCS11Signer)
+}
+
+// pkcs11Signer signs with a key pair of a PKCS#11 token. The session of the token stays open for
+// the lifetime of the process, as the key can only sign while it is open.
+type pkcs11Signer struct {
+	crypto11.Signer
+	certs []*x509.Certificate
+}
+
+// newPKCS11Signer opens the key pair labeled `cfg.KeyID` of the token `cfg.Token` of the PKCS#11
+// module `cfg.Path`, logging in with the PIN `cfg.Password`.
+func newPKCS11Signer(cfg Config) (Signer, error) {
+	if cfg.KeyID == "" {
+		return nil, errors.New("no key_id")
+	}
+	ctx, err := crypto11.Configure(&crypto11.Config{
+		Path:       cfg.Path,
+		TokenLabel: cfg.Token,
+		Pin:        cfg.Password,
+	})
+	if err != nil {
+		return nil, err
+	}
+	key, err := ctx.FindKeyPair(nil, []byte(cfg.KeyID))
+	if err == nil && key == nil {
+		err = fmt.Errorf("no key pair %q", cfg.KeyID)
+	}
+	if err != nil {
+		ctx.Close()
+		return nil, err
+	}
+	s := &pkcs11Signer{Signer: key}
+	if s.certs, err = certificatesOf(cfg.CertPath, key); err != nil {
+		ctx.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Certificates returns the signing certificate followed by its issuers.
+func (s *pkcs11Signer) Certificates() ([]*x509.Certificate, error) {
+	return s.certs, nil
+}
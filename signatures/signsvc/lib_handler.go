@@ -0,0 +1,223 @@
+/*
+ * Signature handlers signing with a Signer and timestamping with a TimestampClient.
+ */
+
+package signsvc
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+
+	"github.com/unidoc/pkcs7"
+	"github.com/unidoc/unipdf/v4/core"
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// Sizes of the signature contents reserved in the documents.
+const (
+	baseSignatureSize = 8192
+	timestampSize     = 8192
+)
+
+// signatureHandler is a model.SignatureHandler making the CMS signatures with a Signer:
+// ETSI.CAdES.detached signatures for PAdES, adbe.pkcs7.detached signatures otherwise.
+type signatureHandler struct {
+	signer Signer
+	certs  []*x509.Certificate
+	pades  bool
+	// tsa timestamps the signatures if not nil.
+	tsa *TimestampClient
+}
+
+// subFilter returns the /SubFilter of the signatures of `h`.
+func (h *signatureHandler) subFilter() string {
+	if h.pades {
+		return "ETSI.CAdES.detached"
+	}
+	return "adbe.pkcs7.detached"
+}
+
+// size returns the size of the signature contents: the signature itself, the certificates of the
+// chain (added twice by pkcs7 to PAdES signatures) and the timestamp token.
+func (h *signatureHandler) size() int {
+	size := baseSignatureSize
+	for _, cert := range h.certs {
+		size += 2 * len(cert.Raw)
+	}
+	if h.tsa != nil {
+		size += timestampSize
+	}
+	return size
+}
+
+// InitSignature sets the filter of the signature and reserves its contents. The certificates are
+// added to the /Cert of adbe.pkcs7.detached signatures, PAdES forbids it.
+func (h *signatureHandler) InitSignature(sig *model.PdfSignature) error {
+	sig.Filter = core.MakeName("Adobe.PPKLite")
+	sig.SubFilter = core.MakeName(h.subFilter())
+	if !h.pades {
+		certs := core.MakeArray()
+		for _, cert := range h.certs {
+			certs.Append(core.MakeString(string(cert.Raw)))
+		}
+		sig.Cert = certs
+	}
+	sig.Contents = core.MakeHexString(string(make([]byte, h.size())))
+	return nil
+}
+
+// NewDigest returns the buffer of the signed bytes of the document.
+func (h *signatureHandler) NewDigest(sig *model.PdfSignature) (model.Hasher, error) {
+	return bytes.NewBuffer(nil), nil
+}
+
+// Sign sets the contents of `sig` to the CMS signature of the bytes of `digest`.
+func (h *signatureHandler) Sign(sig *model.PdfSignature, digest model.Hasher) error {
+	if digest == nil {
+		sig.Contents = core.MakeHexString(string(make([]byte, h.size())))
+		return nil
+	}
+	buffer, ok := digest.(*bytes.Buffer)
+	if !ok {
+		return errors.New("unexpected digest type")
+	}
+	signedData, err := pkcs7.NewSignedData(buffer.Bytes())
+	if err != nil {
+		return err
+	}
+	signedData.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+
+	// PAdES signatures have no signing time attribute, the time is the /M of the signature.
+	key := cmsKey{h.signer}
+	if h.pades {
+		err = signedData.AddSignerChainPAdES(h.certs[0], key, h.certs[1:], pkcs7.SignerInfoConfig{})
+	} else {
+		err = signedData.AddSignerChain(h.certs[0], key, h.certs[1:], pkcs7.SignerInfoConfig{})
+	}
+	if err != nil {
+		return err
+	}
+	if h.tsa != nil {
+		if err := signedData.RequestSignerTimestampToken(0, h.tsa.Token); err != nil {
+			return err
+		}
+	}
+	signedData.Detach()
+	der, err := signedData.Finish()
+	if err != nil {
+		return err
+	}
+	return setContents(sig, der, h.size())
+}
+
+// IsApplicable returns true if `sig` is a signature of the /SubFilter of `h`.
+func (h *signatureHandler) IsApplicable(sig *model.PdfSignature) bool {
+	if sig == nil || sig.Filter == nil || sig.SubFilter == nil {
+		return false
+	}
+	return (*sig.Filter == "Adobe.PPKMS" || *sig.Filter == "Adobe.PPKLite") &&
+		string(*sig.SubFilter) == h.subFilter()
+}
+
+// Validate checks the CMS signature of `sig` over the bytes of `digest`.
+func (h *signatureHandler) Validate(sig *model.PdfSignature, digest model.Hasher) (model.SignatureValidationResult, error) {
+	buffer, ok := digest.(*bytes.Buffer)
+	if !ok {
+		return model.SignatureValidationResult{}, errors.New("unexpected digest type")
+	}
+	p7, err := parseContents(sig)
+	if err != nil {
+		return model.SignatureValidationResult{}, err
+	}
+	p7.Content = buffer.Bytes()
+	return model.SignatureValidationResult{IsSigned: true, IsVerified: p7.Verify() == nil}, nil
+}
+
+// docTimestampHandler is a model.SignatureHandler making document timestamps
+// (ETSI.RFC3161) with a TimestampClient.
+type docTimestampHandler struct {
+	tsa *TimestampClient
+}
+
+// InitSignature sets the type and filter of the document timestamp and reserves its contents.
+func (h *docTimestampHandler) InitSignature(sig *model.PdfSignature) error {
+	sig.Type = core.MakeName("DocTimeStamp")
+	sig.Filter = core.MakeName("Adobe.PPKLite")
+	sig.SubFilter = core.MakeName("ETSI.RFC3161")
+	sig.Contents = core.MakeHexString(string(make([]byte, 2*timestampSize)))
+	return nil
+}
+
+// NewDigest returns the buffer of the timestamped bytes of the document.
+func (h *docTimestampHandler) NewDigest(sig *model.PdfSignature) (model.Hasher, error) {
+	return bytes.NewBuffer(nil), nil
+}
+
+// Sign sets the contents of `sig` to the timestamp token of the bytes of `digest`.
+func (h *docTimestampHandler) Sign(sig *model.PdfSignature, digest model.Hasher) error {
+	if digest == nil {
+		sig.Contents = core.MakeHexString(string(make([]byte, 2*timestampSize)))
+		return nil
+	}
+	buffer, ok := digest.(*bytes.Buffer)
+	if !ok {
+		return errors.New("unexpected digest type")
+	}
+	token, err := h.tsa.Token(buffer.Bytes())
+	if err != nil {
+		return err
+	}
+	return setContents(sig, token, 2*timestampSize)
+}
+
+// IsApplicable returns true if `sig` is a document timestamp.
+func (h *docTimestampHandler) IsApplicable(sig *model.PdfSignature) bool {
+	return sig != nil && sig.SubFilter != nil && *sig.SubFilter == "ETSI.RFC3161"
+}
+
+// Validate checks the timestamp token of `sig` and its imprint of the bytes of `digest`.
+func (h *docTimestampHandler) Validate(sig *model.PdfSignature, digest model.Hasher) (model.SignatureValidationResult, error) {
+	buffer, ok := digest.(*bytes.Buffer)
+	if !ok {
+		return model.SignatureValidationResult{}, errors.New("unexpected digest type")
+	}
+	p7, err := parseContents(sig)
+	if err != nil {
+		return model.SignatureValidationResult{}, err
+	}
+	var info tstInfo
+	if _, err := asn1.Unmarshal(p7.Content, &info); err != nil {
+		return model.SignatureValidationResult{}, err
+	}
+	imprint := sha256.Sum256(buffer.Bytes())
+	verified := info.MessageImprint.HashAlgorithm.Algorithm.Equal(oidSHA256) &&
+		bytes.Equal(info.MessageImprint.HashedMessage, imprint[:]) && p7.Verify() == nil
+	return model.SignatureValidationResult{IsSigned: true, IsVerified: verified}, nil
+}
+
+// setContents sets the contents of `sig` to `der` padded to `size` bytes.
+func setContents(sig *model.PdfSignature, der []byte, size int) error {
+	if len(der) > size {
+		return fmt.Errorf("signature of %d bytes larger than the %d bytes reserved", len(der), size)
+	}
+	data := make([]byte, size)
+	copy(data, der)
+	sig.Contents = core.MakeHexString(string(data))
+	return nil
+}
+
+// parseContents parses the CMS signature of `sig`, without the padding of its contents.
+func parseContents(sig *model.PdfSignature) (*pkcs7.PKCS7, error) {
+	if sig.Contents == nil {
+		return nil, errors.New("no signature contents")
+	}
+	var raw asn1.RawValue
+	if _, err := asn1.Unmarshal(sig.Contents.Bytes(), &raw); err != nil {
+		return nil, err
+	}
+	return pkcs7.Parse(raw.FullBytes)
+}
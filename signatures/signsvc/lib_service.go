@@ -0,0 +1,578 @@
+/*
+ * Batch signing service: a queue of signing jobs processed by workers, and its local HTTP API.
+ */
+
+package signsvc
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Statuses of the jobs.
+const (
+	StatusQueued  = "queued"
+	StatusSigning = "signing"
+	StatusDone    = "done"
+	StatusFailed  = "failed"
+)
+
+// Errors of Submit.
+var (
+	ErrQueueFull = errors.New("signing queue full")
+	ErrClosed    = errors.New("signing service closed")
+)
+
+// Defaults of the ServiceConfig settings.
+const (
+	defaultWorkers     = 2
+	defaultQueueSize   = 100
+	defaultKeepJobs    = 1000
+	defaultMaxDocument = 64 << 20
+	defaultMaxResults  = 1 << 30
+)
+
+// ServiceConfig configures a Service.
+type ServiceConfig struct {
+	// Signers are the signer backends by name.
+	Signers map[string]Config `json:"signers"`
+	// Profiles are the signing profiles by name.
+	Profiles map[string]ServiceProfile `json:"profiles"`
+	// TSAURL is the URL of the timestamp authority of the B-T, B-LT and B-LTA profiles.
+	TSAURL string `json:"tsa_url,omitempty"`
+	// Workers is the number of documents signed concurrently, 2 by default.
+	Workers int `json:"workers,omitempty"`
+	// QueueSize is the number of jobs waiting for a worker above which submissions are refused,
+	// 100 by default.
+	QueueSize int `json:"queue_size,omitempty"`
+	// KeepJobs is the number of finished jobs kept with their results, the oldest are dropped.
+	// 1000 by default.
+	KeepJobs int `json:"keep_jobs,omitempty"`
+	// MaxResultBytes is the total size in bytes of the signed documents kept, the oldest finished
+	// jobs but the last are dropped above it. 1 GiB by default.
+	MaxResultBytes int64 `json:"max_result_bytes,omitempty"`
+	// MaxDocumentSize is the maximum size in bytes of the submitted documents, 64 MiB by default.
+	MaxDocumentSize int64 `json:"max_document_size,omitempty"`
+}
+
+// ServiceProfile is a signing profile of the service and the signer it signs with.
+type ServiceProfile struct {
+	Signer string `json:"signer"`
+	Profile
+}
+
+// LoadServiceConfig reads the JSON service configuration file `path`. The $VAR and ${VAR}
+// references to environment variables in the file are expanded, to keep secrets out of it.
+func LoadServiceConfig(path string) (ServiceConfig, error) {
+	var cfg ServiceConfig
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cfg, err
+	}
+	dec := json.NewDecoder(strings.NewReader(os.ExpandEnv(string(data))))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return cfg, fmt.Errorf("%s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// OpenSigners opens the signers configured by `configs`.
+func OpenSigners(configs map[string]Config) (map[string]Signer, error) {
+	signers := map[string]Signer{}
+	for name, cfg := range configs {
+		signer, err := NewSigner(cfg)
+		if err != nil {
+			return nil, fmt.Errorf("signer %q: %w", name, err)
+		}
+		signers[name] = signer
+	}
+	return signers, nil
+}
+
+// Job is the state of a signing job: a document to sign with a profile.
+type Job struct {
+	ID string `json:"id"`
+	// Batch is the ID of the submission of the job.
+	Batch     string    `json:"batch"`
+	File      string    `json:"file"`
+	Profile   string    `json:"profile"`
+	Status    string    `json:"status"`
+	Error     string    `json:"error,omitempty"`
+	Size      int       `json:"size"`
+	Submitted time.Time `json:"submitted"`
+	Finished  time.Time `json:"finished,omitzero"`
+}
+
+// job is a job and its documents.
+type job struct {
+	Job
+	profile ServiceProfile
+	input   []byte
+	output  []byte
+	done    chan struct{}
+}
+
+// Service signs the documents submitted to it with its profiles, by a pool of workers taking the
+// jobs from a queue. The results are kept in memory until fetched and deleted, or dropped.
+type Service struct {
+	signers  map[string]Signer
+	profiles map[string]ServiceProfile
+	opts     Options
+	cfg      ServiceConfig
+
+	mu       sync.Mutex
+	queue    chan *job
+	closed   bool
+	jobs     map[string]*job
+	finished []string // IDs of the finished jobs, oldest first.
+	retained int64    // Size of the signed documents of the finished jobs.
+	wg       sync.WaitGroup
+}
+
+// NewService returns a service signing with the profiles of `cfg` and the `signers` they name,
+// and starts its workers. `opts` are the signing options, its TSAURL defaults to that of `cfg`.
+func NewService(cfg ServiceConfig, signers map[string]Signer, opts Options) (*Service, error) {
+	if len(cfg.Profiles) == 0 {
+		return nil, errors.New("no signing profile")
+	}
+	if opts.TSAURL == "" {
+		opts.TSAURL = cfg.TSAURL
+	}
+	for name, profile := range cfg.Profiles {
+		if _, ok := signers[profile.Signer]; !ok {
+			return nil, fmt.Errorf("profile %q: unknown signer %q", name, profile.Signer)
+		}
+		if err := profile.Validate(); err != nil {
+			return nil, fmt.Errorf("profile %q: %w", name, err)
+		}
+		if profile.timestamped() && opts.TSAURL == "" {
+			return nil, fmt.Errorf("profile %q: level %s needs a tsa_url", name, profile.Level)
+		}
+	}
+	if cfg.Workers <= 0 {
+		cfg.Workers = defaultWorkers
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultQueueSize
+	}
+	if cfg.KeepJobs <= 0 {
+		cfg.KeepJobs = defaultKeepJobs
+	}
+	if cfg.MaxDocumentSize <= 0 {
+		cfg.MaxDocumentSize = defaultMaxDocument
+	}
+	if cfg.MaxResultBytes <= 0 {
+		cfg.MaxResultBytes = defaultMaxResults
+	}
+
+	s := &Service{
+		signers:  signers,
+		profiles: cfg.Profiles,
+		opts:     opts,
+		cfg:      cfg,
+		queue:    make(chan *job, cfg.QueueSize),
+		jobs:     map[string]*job{},
+	}
+	for i := 0; i < cfg.Workers; i++ {
+		s.wg.Add(1)
+		go s.work()
+	}
+	return s, nil
+}
+
+// Close stops accepting jobs and waits for the queued jobs to be signed.
+func (s *Service) Close() {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.queue)
+	}
+	s.mu.Unlock()
+	s.wg.Wait()
+}
+
+// Submit queues the document `data` named `file` to be signed with the profile `profileName`,
+// whose settings are overridden by the JSON object `overrides` if not empty. The signer of the
+// profile cannot be overridden.
+func (s *Service) Submit(batch, file string, data []byte, profileName string, overrides []byte) (Job, error) {
+	profile, ok := s.profiles[profileName]
+	if !ok {
+		return Job{}, fmt.Errorf("unknown profile %q", profileName)
+	}
+	if len(overrides) > 0 {
+		// Decode into a copy of the appearance, whose slices json reuses, shared with the profile
+		// otherwise.
+		if a := profile.Appearance; a != nil {
+			profile.Appearance = &Appearance{
+				Page:     a.Page,
+				Rect:     slices.Clone(a.Rect),
+				FontSize: a.FontSize,
+				Lines:    slices.Clone(a.Lines),
+			}
+		}
+		dec := json.NewDecoder(bytes.NewReader(overrides))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&profile.Profile); err != nil {
+			return Job{}, fmt.Errorf("invalid profile options: %w", err)
+		}
+		if err := profile.Validate(); err != nil {
+			return Job{}, err
+		}
+		if profile.timestamped() && s.opts.TSAURL == "" {
+			return Job{}, fmt.Errorf("level %s needs a timestamp authority", profile.Level)
+		}
+	}
+	if int64(len(data)) > s.cfg.MaxDocumentSize {
+		return Job{}, fmt.Errorf("%s: document larger than %d bytes", file, s.cfg.MaxDocumentSize)
+	}
+	if !isPDF(data) {
+		return Job{}, fmt.Errorf("%s: not a PDF document", file)
+	}
+
+	j := &job{
+		Job: Job{
+			ID:        newID(),
+			Batch:     batch,
+			File:      file,
+			Profile:   profileName,
+			Status:    StatusQueued,
+			Size:      len(data),
+			Submitted: time.Now(),
+		},
+		profile: profile,
+		input:   data,
+		done:    make(chan struct{}),
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return Job{}, ErrClosed
+	}
+	select {
+	case s.queue <- j:
+	default:
+		return Job{}, ErrQueueFull
+	}
+	s.jobs[j.ID] = j
+	return j.Job, nil
+}
+
+// Job returns the state of the job `id`.
+func (s *Service) Job(id string) (Job, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return Job{}, false
+	}
+	return j.Job, true
+}
+
+// Jobs returns the state of the jobs of the batch `batch`, of all the jobs if empty, in the order
+// of their submission.
+func (s *Service) Jobs(batch string) []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var jobs []Job
+	for _, j := range s.jobs {
+		if batch == "" || j.Batch == batch {
+			jobs = append(jobs, j.Job)
+		}
+	}
+	sort.Slice(jobs, func(a, b int) bool { return jobs[a].Submitted.Before(jobs[b].Submitted) })
+	return jobs
+}
+
+// Result returns the state of the job `id` and its signed document if it is done.
+func (s *Service) Result(id string) (Job, []byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return Job{}, nil, false
+	}
+	return j.Job, j.output, true
+}
+
+// Wait waits for the job `id` to finish and returns its state.
+func (s *Service) Wait(ctx context.Context, id string) (Job, error) {
+	s.mu.Lock()
+	j, ok := s.jobs[id]
+	s.mu.Unlock()
+	if !ok {
+		return Job{}, fmt.Errorf("unknown job %q", id)
+	}
+	select {
+	case <-j.done:
+	case <-ctx.Done():
+		return Job{}, ctx.Err()
+	}
+	job, _ := s.Job(id)
+	return job, nil
+}
+
+// Delete deletes the finished job `id` and its result.
+func (s *Service) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j, ok := s.jobs[id]
+	if !ok {
+		return fmt.Errorf("unknown job %q", id)
+	}
+	if j.Status != StatusDone && j.Status != StatusFailed {
+		return fmt.Errorf("job %q not finished", id)
+	}
+	s.drop(id)
+	s.finished = slices.DeleteFunc(s.finished, func(f string) bool { return f == id })
+	return nil
+}
+
+// drop removes the finished job `id` and releases its result. The caller holds s.mu.
+func (s *Service) drop(id string) {
+	if j, ok := s.jobs[id]; ok {
+		s.retained -= int64(len(j.output))
+		delete(s.jobs, id)
+	}
+}
+
+// work signs the documents of the queued jobs.
+func (s *Service) work() {
+	defer s.wg.Done()
+	for j := range s.queue {
+		s.mu.Lock()
+		j.Status = StatusSigning
+		s.mu.Unlock()
+
+		output, err := Sign(j.input, s.signers[j.profile.Signer], j.profile.Profile, s.opts)
+
+		s.mu.Lock()
+		j.input = nil
+		j.Finished = time.Now()
+		if err != nil {
+			j.Status, j.Error = StatusFailed, err.Error()
+		} else {
+			j.Status, j.output = StatusDone, output
+		}
+		s.finished = append(s.finished, j.ID)
+		s.retained += int64(len(j.output))
+		for len(s.finished) > s.cfg.KeepJobs || len(s.finished) > 1 && s.retained > s.cfg.MaxResultBytes {
+			s.drop(s.finished[0])
+			s.finished = s.finished[1:]
+		}
+		s.mu.Unlock()
+		close(j.done)
+	}
+}
+
+// Handler returns the HTTP API of the service:
+//
+//	GET    /profiles          the signing profiles
+//	POST   /jobs?profile=P    queue the PDF documents of a multipart form (file parts) or the
+//	                          application/pdf body; the profile settings are overridden by the JSON
+//	                          object of the options part or query parameter; with wait=true, wait
+//	                          for the jobs and return the signed document of a single job
+//	GET    /jobs?batch=B      the state of the jobs, of the batch B
+//	GET    /jobs/{id}         the state of a job
+//	GET    /jobs/{id}/result  the signed document of a done job
+//	DELETE /jobs/{id}         delete a finished job and its result
+func (s *Service) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /profiles", s.handleProfiles)
+	mux.HandleFunc("POST /jobs", s.handleSubmit)
+	mux.HandleFunc("GET /jobs", func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, http.StatusOK, s.Jobs(r.URL.Query().Get("batch")))
+	})
+	mux.HandleFunc("GET /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		job, ok := s.Job(r.PathValue("id"))
+		if !ok {
+			writeError(w, http.StatusNotFound, "unknown job")
+			return
+		}
+		writeJSON(w, http.StatusOK, job)
+	})
+	mux.HandleFunc("GET /jobs/{id}/result", s.handleResult)
+	mux.HandleFunc("DELETE /jobs/{id}", func(w http.ResponseWriter, r *http.Request) {
+		if err := s.Delete(r.PathValue("id")); err != nil {
+			writeError(w, http.StatusNotFound, err.Error())
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux
+}
+
+// handleProfiles writes the profiles with the backends of their signers.
+func (s *Service) handleProfiles(w http.ResponseWriter, r *http.Request) {
+	type profile struct {
+		Profile
+		Signer  string `json:"signer"`
+		Backend string `json:"backend,omitempty"`
+	}
+	profiles := map[string]profile{}
+	for name, p := range s.profiles {
+		profiles[name] = profile{Profile: p.Profile, Signer: p.Signer, Backend: s.cfg.Signers[p.Signer].Backend}
+	}
+	writeJSON(w, http.StatusOK, profiles)
+}
+
+// handleSubmit queues the submitted documents.
+func (s *Service) handleSubmit(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	profile := query.Get("profile")
+	overrides := []byte(query.Get("options"))
+	batch := newID()
+	// The limit of the body is that of a batch of 16 maximum size documents.
+	r.Body = http.MaxBytesReader(w, r.Body, 16*s.cfg.MaxDocumentSize)
+
+	type document struct {
+		name string
+		data []byte
+	}
+	var docs []document
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "multipart/form-data":
+		mr, err := r.MultipartReader()
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			data, err := io.ReadAll(io.LimitReader(part, s.cfg.MaxDocumentSize+1))
+			if err != nil {
+				writeError(w, http.StatusBadRequest, err.Error())
+				return
+			}
+			switch part.FormName() {
+			case "file":
+				docs = append(docs, document{name: part.FileName(), data: data})
+			case "options":
+				overrides = data
+			case "profile":
+				profile = string(data)
+			}
+		}
+	case "application/pdf":
+		data, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusRequestEntityTooLarge, err.Error())
+			return
+		}
+		docs = append(docs, document{name: query.Get("name"), data: data})
+	default:
+		writeError(w, http.StatusUnsupportedMediaType, "expected multipart/form-data or application/pdf")
+		return
+	}
+	if len(docs) == 0 {
+		writeError(w, http.StatusBadRequest, "no document")
+		return
+	}
+
+	var jobs []Job
+	for _, doc := range docs {
+		job, err := s.Submit(batch, doc.name, doc.data, profile, overrides)
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, ErrQueueFull) || errors.Is(err, ErrClosed) {
+				status = http.StatusServiceUnavailable
+			}
+			// The jobs of the documents already queued stay in the batch.
+			writeJSON(w, status, map[string]any{"error": err.Error(), "batch": batch, "jobs": jobs})
+			return
+		}
+		jobs = append(jobs, job)
+	}
+
+	if query.Get("wait") != "true" {
+		writeJSON(w, http.StatusAccepted, map[string]any{"batch": batch, "jobs": jobs})
+		return
+	}
+	for i := range jobs {
+		job, err := s.Wait(r.Context(), jobs[i].ID)
+		if err != nil {
+			writeError(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		jobs[i] = job
+	}
+	if len(jobs) == 1 {
+		s.writeResult(w, jobs[0].ID)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"batch": batch, "jobs": jobs})
+}
+
+// handleResult writes the signed document of a job.
+func (s *Service) handleResult(w http.ResponseWriter, r *http.Request) {
+	s.writeResult(w, r.PathValue("id"))
+}
+
+// writeResult writes the signed document of the job `id`, or its state if it is not done.
+func (s *Service) writeResult(w http.ResponseWriter, id string) {
+	job, output, ok := s.Result(id)
+	switch {
+	case !ok:
+		writeError(w, http.StatusNotFound, "unknown job")
+	case job.Status == StatusFailed:
+		writeJSON(w, http.StatusUnprocessableEntity, job)
+	case job.Status != StatusDone:
+		writeJSON(w, http.StatusConflict, job)
+	default:
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("X-Job-Id", job.ID)
+		w.WriteHeader(http.StatusOK)
+		w.Write(output)
+	}
+}
+
+// writeJSON writes `v` as the JSON response with the status `status`.
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.Encode(v)
+}
+
+// writeError writes the error `msg` as the JSON response with the status `status`.
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
+
+// isPDF returns true if `data` starts with a PDF header, possibly after some junk.
+func isPDF(data []byte) bool {
+	if len(data) > 1024 {
+		data = data[:1024]
+	}
+	return bytes.Contains(data, []byte("%PDF-"))
+}
+
+// newID returns a random job or batch ID.
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
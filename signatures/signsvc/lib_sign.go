@@ -0,0 +1,256 @@
+/*
+ * Signing of PDF documents according to signing profiles.
+ */
+
+package signsvc
+
+import (
+	"bytes"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/unidoc/unipdf/v4/annotator"
+	"github.com/unidoc/unipdf/v4/core"
+	"github.com/unidoc/unipdf/v4/model"
+	"github.com/unidoc/unipdf/v4/model/mdp"
+	"github.com/unidoc/unipdf/v4/model/sighandler"
+)
+
+// PAdES baseline levels of the signatures.
+const (
+	LevelBB   = "B-B"
+	LevelBT   = "B-T"
+	LevelBLT  = "B-LT"
+	LevelBLTA = "B-LTA"
+)
+
+// levelRank orders the levels, the adbe.pkcs7.detached signatures of the empty level first.
+var levelRank = map[string]int{"": 0, LevelBB: 1, LevelBT: 2, LevelBLT: 3, LevelBLTA: 4}
+
+// defaultField is the name of the signature fields of the profiles without a field name.
+const defaultField = "Signature"
+
+// Line is a line of text of the appearance of a signature.
+type Line struct {
+	Label string `json:"label"`
+	Text  string `json:"text"`
+}
+
+// Appearance is the visible appearance of a signature.
+type Appearance struct {
+	// Page is the number of the page of the signature, from 1. Defaults to 1.
+	Page int `json:"page,omitempty"`
+	// Rect is the rectangle of the signature on the page: llx, lly, urx, ury.
+	Rect     []float64 `json:"rect"`
+	FontSize float64   `json:"font_size,omitempty"`
+	// Lines are shown after the name, date, reason and location of the signature.
+	Lines []Line `json:"lines,omitempty"`
+}
+
+// Profile is how to sign documents.
+type Profile struct {
+	// Level is the PAdES baseline level of the ETSI.CAdES.detached signatures: B-B, B-T (with a
+	// signature timestamp), B-LT (and the validation data of the signer in the DSS) or B-LTA (and a
+	// document timestamp). Empty for adbe.pkcs7.detached signatures.
+	Level string `json:"level,omitempty"`
+	// DocMDP is the permission P of a certification signature: 1 for no changes, 2 for form
+	// filling and signing, 3 for annotations too. 0 for an approval signature.
+	DocMDP int `json:"docmdp,omitempty"`
+	// Field is the name of the signature field, "Signature" if empty.
+	Field    string `json:"field,omitempty"`
+	Name     string `json:"name,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+	Location string `json:"location,omitempty"`
+	// Appearance is the appearance of a visible signature, nil for an invisible signature.
+	Appearance *Appearance `json:"appearance,omitempty"`
+}
+
+// Validate checks the settings of `p`.
+func (p Profile) Validate() error {
+	if _, ok := levelRank[p.Level]; !ok {
+		return fmt.Errorf("invalid level %q", p.Level)
+	}
+	if p.DocMDP < 0 || p.DocMDP > 3 {
+		return fmt.Errorf("invalid DocMDP permission %d", p.DocMDP)
+	}
+	if a := p.Appearance; a != nil {
+		if len(a.Rect) != 4 {
+			return errors.New("the appearance rectangle needs 4 coordinates")
+		}
+		if a.Page < 0 {
+			return fmt.Errorf("invalid appearance page %d", a.Page)
+		}
+	}
+	return nil
+}
+
+// timestamped returns true if the signatures of `p` have a signature timestamp.
+func (p Profile) timestamped() bool {
+	return levelRank[p.Level] >= levelRank[LevelBT]
+}
+
+// Options are the services used for signing.
+type Options struct {
+	// TSAURL is the URL of the timestamp authority, required by the B-T, B-LT and B-LTA levels.
+	TSAURL string
+	// HTTPClient sends the requests to the timestamp authority, http.DefaultClient if nil. The
+	// validation data of B-LT is fetched from the OCSP responders and CRL distribution points of
+	// the certificates.
+	HTTPClient *http.Client
+}
+
+// Sign signs the PDF document `data` with `signer` according to `profile` and returns the signed
+// document. The signature is applied in an incremental update, B-LT and B-LTA add one more for the
+// validation data and B-LTA another for the document timestamp.
+func Sign(data []byte, signer Signer, profile Profile, opts Options) ([]byte, error) {
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+	if profile.timestamped() && opts.TSAURL == "" {
+		return nil, fmt.Errorf("level %s needs a timestamp authority", profile.Level)
+	}
+	certs, err := signer.Certificates()
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("no signing certificate")
+	}
+
+	tsa := &TimestampClient{URL: opts.TSAURL, HTTPClient: opts.HTTPClient}
+	sigHandler := &signatureHandler{signer: signer, certs: certs, pades: profile.Level != ""}
+	if profile.timestamped() {
+		sigHandler.tsa = tsa
+	}
+	var handler model.SignatureHandler = sigHandler
+	if profile.DocMDP > 0 {
+		handler, err = sighandler.NewDocMDPHandler(sigHandler, mdp.DocMDPPermission(profile.DocMDP))
+		if err != nil {
+			return nil, err
+		}
+	}
+	if data, err = appendSignature(data, handler, profile); err != nil {
+		return nil, err
+	}
+
+	if levelRank[profile.Level] >= levelRank[LevelBLT] {
+		if data, err = appendValidationData(data, certs); err != nil {
+			return nil, fmt.Errorf("validation data: %w", err)
+		}
+	}
+	if profile.Level == LevelBLTA {
+		timestamp := Profile{Field: fieldName(profile) + " Timestamp"}
+		if data, err = appendSignature(data, &docTimestampHandler{tsa: tsa}, timestamp); err != nil {
+			return nil, fmt.Errorf("document timestamp: %w", err)
+		}
+	}
+	return data, nil
+}
+
+// appendSignature appends the signature of `handler` to the document `data` in an incremental
+// update.
+func appendSignature(data []byte, handler model.SignatureHandler, profile Profile) ([]byte, error) {
+	reader, err := model.NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	appender, err := model.NewPdfAppender(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	signature := model.NewPdfSignature(handler)
+	if profile.Name != "" {
+		signature.SetName(profile.Name)
+	}
+	if profile.Reason != "" {
+		signature.SetReason(profile.Reason)
+	}
+	if profile.Location != "" {
+		signature.SetLocation(profile.Location)
+	}
+	signature.SetDate(now, "")
+	if err := signature.Initialize(); err != nil {
+		return nil, err
+	}
+
+	page := 1
+	var field *model.PdfFieldSignature
+	if a := profile.Appearance; a != nil {
+		if a.Page > 0 {
+			page = a.Page
+		}
+		opts := annotator.NewSignatureFieldOpts()
+		opts.Rect = a.Rect
+		if a.FontSize > 0 {
+			opts.FontSize = a.FontSize
+		}
+		var lines []*annotator.SignatureLine
+		for _, line := range []Line{
+			{"Name", profile.Name},
+			{"Date", now.Format("2006.01.02 15:04:05 -07:00")},
+			{"Reason", profile.Reason},
+			{"Location", profile.Location},
+		} {
+			if line.Text != "" {
+				lines = append(lines, annotator.NewSignatureLine(line.Label, line.Text))
+			}
+		}
+		for _, line := range a.Lines {
+			lines = append(lines, annotator.NewSignatureLine(line.Label, line.Text))
+		}
+		if field, err = annotator.NewSignatureField(signature, lines, opts); err != nil {
+			return nil, err
+		}
+	} else {
+		field = model.NewPdfFieldSignature(signature)
+		field.Rect = core.MakeArray(core.MakeInteger(0), core.MakeInteger(0), core.MakeInteger(0), core.MakeInteger(0))
+	}
+	field.T = core.MakeString(fieldName(profile))
+
+	if err := appender.Sign(page, field); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := appender.Write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// appendValidationData appends the certificates and revocation data of the signatures of the
+// document `data` to its DSS in an incremental update. `certs` help to build the chains.
+func appendValidationData(data []byte, certs []*x509.Certificate) ([]byte, error) {
+	reader, err := model.NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	appender, err := model.NewPdfAppender(reader)
+	if err != nil {
+		return nil, err
+	}
+	ltv, err := model.NewLTV(appender)
+	if err != nil {
+		return nil, err
+	}
+	if err := ltv.EnableAll(certs); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := appender.Write(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// fieldName returns the name of the signature field of `profile`.
+func fieldName(profile Profile) string {
+	if profile.Field != "" {
+		return profile.Field
+	}
+	return defaultField
+}
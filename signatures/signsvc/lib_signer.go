@@ -0,0 +1,215 @@
+/*
+ * Package signsvc signs PDF documents with pluggable signer backends (PKCS#12 and PEM files, AWS
+ * KMS, Google Cloud KMS, GlobalSign DSS and PKCS#11 tokens) according to signing profiles, and
+ * serves a local HTTP API that queues batches of documents to sign.
+ */
+
+package signsvc
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/unidoc/pkcs7"
+)
+
+// Signer is a signing key and its certificate chain. The signatures are made on SHA-256 digests.
+type Signer interface {
+	crypto.Signer
+	// Certificates returns the signing certificate followed by its issuers.
+	Certificates() ([]*x509.Certificate, error)
+}
+
+// Config configures a signer backend. The fields used depend on the backend, see NewSigner.
+type Config struct {
+	Backend string `json:"backend"`
+	// Path is the PKCS#12 file (pkcs12) or the PKCS#11 module (pkcs11).
+	Path string `json:"path,omitempty"`
+	// Password is the password of the PKCS#12 file (pkcs12) or the PIN of the token (pkcs11).
+	Password string `json:"password,omitempty"`
+	// KeyPath is the PEM private key (pem) or the mTLS client key (globalsign).
+	KeyPath string `json:"key_path,omitempty"`
+	// CertPath is the PEM certificate chain, signing certificate first (pem, awskms, gcpkms and
+	// pkcs11), or the mTLS client certificate (globalsign).
+	CertPath string `json:"cert_path,omitempty"`
+	// KeyID is the key ID (awskms), the key version name (gcpkms) or the key pair label (pkcs11).
+	KeyID string `json:"key_id,omitempty"`
+	// Region is the AWS region (awskms).
+	Region string `json:"region,omitempty"`
+	// CredentialsPath is the service account credentials file (gcpkms).
+	CredentialsPath string `json:"credentials_path,omitempty"`
+	// Token is the token label (pkcs11).
+	Token string `json:"token,omitempty"`
+	// APIKey and APISecret are the API credentials (globalsign).
+	APIKey    string `json:"api_key,omitempty"`
+	APISecret string `json:"api_secret,omitempty"`
+	// Identity names the signing identity (globalsign).
+	Identity string `json:"identity,omitempty"`
+}
+
+// Backend opens the signer configured by a Config.
+type Backend func(cfg Config) (Signer, error)
+
+var (
+	backendsMu sync.RWMutex
+	backends   = map[string]Backend{}
+)
+
+// RegisterBackend registers the signer backend `name`, replacing any backend of the same name.
+func RegisterBackend(name string, backend Backend) {
+	backendsMu.Lock()
+	defer backendsMu.Unlock()
+	backends[name] = backend
+}
+
+// Backends returns the names of the registered backends.
+func Backends() []string {
+	backendsMu.RLock()
+	defer backendsMu.RUnlock()
+	names := make([]string, 0, len(backends))
+	for name := range backends {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// NewSigner opens the signer configured by `cfg` with the backend `cfg.Backend`:
+//   - pkcs12: Path, Password,
+//   - pem: KeyPath, CertPath,
+//   - awskms: KeyID, Region, CertPath,
+//   - gcpkms: KeyID, CredentialsPath, CertPath,
+//   - globalsign: APIKey, APISecret, CertPath and KeyPath of the mTLS client, Identity,
+//   - pkcs11 (cgo builds): Path, Token, Password, KeyID, CertPath.
+//
+// Without a CertPath the KMS and PKCS#11 backends use a self-signed certificate of their key, for
+// testing only.
+func NewSigner(cfg Config) (Signer, error) {
+	backendsMu.RLock()
+	backend, ok := backends[cfg.Backend]
+	backendsMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown signer backend %q, have %v", cfg.Backend, Backends())
+	}
+	signer, err := backend(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("%s signer: %w", cfg.Backend, err)
+	}
+	return signer, nil
+}
+
+// keySigner is a Signer with a fixed certificate chain.
+type keySigner struct {
+	crypto.Signer
+	certs []*x509.Certificate
+}
+
+// NewKeySigner returns a Signer signing with `key`, whose certificate and issuers are `certs`.
+func NewKeySigner(key crypto.Signer, certs ...*x509.Certificate) (Signer, error) {
+	if len(certs) == 0 {
+		return nil, errors.New("no signing certificate")
+	}
+	return &keySigner{Signer: key, certs: certs}, nil
+}
+
+// Certificates returns the signing certificate followed by its issuers.
+func (s *keySigner) Certificates() ([]*x509.Certificate, error) {
+	return s.certs, nil
+}
+
+// cmsKey is the signing key of a CMS signature: it reports the signature algorithm of its key to
+// the pkcs7 package, which only knows the algorithms of the standard library private keys.
+type cmsKey struct {
+	crypto.Signer
+}
+
+// EncryptionAlgorithmOID returns the signature algorithm of the key.
+func (k cmsKey) EncryptionAlgorithmOID() asn1.ObjectIdentifier {
+	if _, ok := k.Public().(*ecdsa.PublicKey); ok {
+		return pkcs7.OIDDigestAlgorithmECDSASHA256
+	}
+	return pkcs7.OIDEncryptionAlgorithmRSA
+}
+
+// readCertificates returns the certificates of the PEM file `path`.
+func readCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("%s: no certificate", path)
+	}
+	return certs, nil
+}
+
+// certificatesOf returns the certificates of the PEM file `path`, or a self-signed certificate of
+// the key of `signer` without a path.
+func certificatesOf(path string, signer crypto.Signer) ([]*x509.Certificate, error) {
+	if path != "" {
+		return readCertificates(path)
+	}
+	cert, err := selfSignedCertificate(signer, "UniDoc test signer")
+	if err != nil {
+		return nil, err
+	}
+	return []*x509.Certificate{cert}, nil
+}
+
+// selfSignedCertificate returns a self-signed signing certificate of the key of `signer`.
+func selfSignedCertificate(signer crypto.Signer, commonName string) (*x509.Certificate, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 63))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject: pkix.Name{
+			CommonName:   commonName,
+			Organization: []string{"Test Company"},
+		},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(time.Hour * 24 * 365),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+		BasicConstraintsValid: true,
+	}
+	// The remote backends only sign SHA-256 digests.
+	template.SignatureAlgorithm = x509.SHA256WithRSA
+	if _, ok := signer.Public().(*ecdsa.PublicKey); ok {
+		template.SignatureAlgorithm = x509.ECDSAWithSHA256
+	}
+	certData, err := x509.CreateCertificate(rand.Reader, template, template, signer.Public(), signer)
+	if err != nil {
+		return nil, err
+	}
+	return x509.ParseCertificate(certData)
+}
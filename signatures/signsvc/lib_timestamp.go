@@ -0,0 +1,128 @@
+/*
+ * RFC 3161 timestamp client.
+ */
+
+package signsvc
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/unidoc/pkcs7"
+)
+
+// oidSHA256 identifies the SHA-256 digest algorithm.
+var oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+
+// maxTimestampResponse bounds the size of the responses of the timestamp authorities.
+const maxTimestampResponse = 1 << 20
+
+// TimestampClient requests RFC 3161 timestamp tokens of SHA-256 digests from a timestamp
+// authority (TSA).
+type TimestampClient struct {
+	URL string
+	// HTTPClient sends the requests, http.DefaultClient if nil.
+	HTTPClient *http.Client
+}
+
+// messageImprint is the digest of the timestamped data.
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+// timeStampReq is a TimeStampReq of RFC 3161.
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	Nonce          *big.Int `asn1:"optional"`
+	CertReq        bool     `asn1:"optional"`
+}
+
+// timeStampResp is a TimeStampResp of RFC 3161. The optional text and failure information of the
+// status are not parsed.
+type timeStampResp struct {
+	Status struct {
+		Status int
+	}
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+// tstInfo is the beginning of the TSTInfo content of a timestamp token.
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+}
+
+// Token returns a timestamp token of the SHA-256 digest of `data`.
+func (c *TimestampClient) Token(data []byte) ([]byte, error) {
+	digest := sha256.Sum256(data)
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 63))
+	if err != nil {
+		return nil, err
+	}
+	req, err := asn1.Marshal(timeStampReq{
+		Version: 1,
+		MessageImprint: messageImprint{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+			HashedMessage: digest[:],
+		},
+		Nonce:   nonce,
+		CertReq: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	client := c.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Post(c.URL, "application/timestamp-query", bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("timestamp authority %s: %s", c.URL, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxTimestampResponse))
+	if err != nil {
+		return nil, err
+	}
+
+	var tsResp timeStampResp
+	if _, err := asn1.Unmarshal(body, &tsResp); err != nil {
+		return nil, fmt.Errorf("timestamp authority %s: invalid response: %w", c.URL, err)
+	}
+	// 0 is granted, 1 granted with modifications.
+	if tsResp.Status.Status > 1 || len(tsResp.TimeStampToken.FullBytes) == 0 {
+		return nil, fmt.Errorf("timestamp authority %s: request rejected with status %d", c.URL, tsResp.Status.Status)
+	}
+	token := tsResp.TimeStampToken.FullBytes
+
+	// Check that the token timestamps the digest.
+	p7, err := pkcs7.Parse(token)
+	if err != nil {
+		return nil, fmt.Errorf("timestamp authority %s: invalid token: %w", c.URL, err)
+	}
+	var info tstInfo
+	if _, err := asn1.Unmarshal(p7.Content, &info); err != nil {
+		return nil, fmt.Errorf("timestamp authority %s: invalid token: %w", c.URL, err)
+	}
+	if !bytes.Equal(info.MessageImprint.HashedMessage, digest[:]) {
+		return nil, fmt.Errorf("timestamp authority %s: token of another digest", c.URL)
+	}
+	return token, nil
+}
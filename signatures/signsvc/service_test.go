@@ -0,0 +1,181 @@
+/*
+ * Tests of the HTTP API of the signing service: request validation, and signing single documents
+ * and batches with the signer and the TSA of the test PKI.
+ *
+ * Run as: go test ./signatures/signsvc/ -run TestService
+ *
+ * TestService signs documents and needs a license like the signing tests, see testing/testlicense.
+ * TestServiceRejects runs without.
+ */
+
+package signsvc_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/unidoc/unidoc-examples/signatures/signsvc"
+	"github.com/unidoc/unidoc-examples/testing/testlicense"
+)
+
+// newService returns a service with the profiles "approval" (B-T, visible) and "certify" (B-LTA,
+// DocMDP 2), and its HTTP server.
+func newService(t *testing.T) (*signsvc.Service, *httptest.Server) {
+	t.Helper()
+	cfg := signsvc.ServiceConfig{
+		TSAURL: testPKI(t).TSAURL(),
+		Profiles: map[string]signsvc.ServiceProfile{
+			"approval": {Signer: "test", Profile: signsvc.Profile{
+				Level:      signsvc.LevelBT,
+				Reason:     "Approved",
+				Appearance: &signsvc.Appearance{Rect: []float64{50, 50, 250, 120}},
+			}},
+			"certify": {Signer: "test", Profile: signsvc.Profile{Level: signsvc.LevelBLTA, DocMDP: 2}},
+		},
+	}
+	service, err := signsvc.NewService(cfg, map[string]signsvc.Signer{"test": newSigner(t, "Service Signer")}, signsvc.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(service.Handler())
+	t.Cleanup(func() {
+		server.Close()
+		service.Close()
+	})
+	return service, server
+}
+
+// do sends the request and returns the response status and body.
+func do(t *testing.T, method, url, contentType string, body io.Reader) (int, []byte) {
+	t.Helper()
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, data
+}
+
+func TestServiceRejects(t *testing.T) {
+	_, server := newService(t)
+	tests := []struct {
+		name        string
+		query       string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"not a PDF", "profile=approval", "application/pdf", "hello", http.StatusBadRequest},
+		{"unknown profile", "profile=missing", "application/pdf", "%PDF-1.7\n", http.StatusBadRequest},
+		{"invalid options", "profile=approval&options=" + `{"level":"B-X"}`, "application/pdf", "%PDF-1.7\n", http.StatusBadRequest},
+		{"unknown option", "profile=approval&options=" + `{"signer":"other"}`, "application/pdf", "%PDF-1.7\n", http.StatusBadRequest},
+		{"media type", "profile=approval", "text/plain", "%PDF-1.7\n", http.StatusUnsupportedMediaType},
+		{"no document", "profile=approval", "multipart/form-data; boundary=x", "--x--\r\n", http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			url := server.URL + "/jobs?" + strings.ReplaceAll(test.query, `"`, "%22")
+			status, body := do(t, http.MethodPost, url, test.contentType, strings.NewReader(test.body))
+			if status != test.status {
+				t.Errorf("status %d, want %d: %s", status, test.status, body)
+			}
+		})
+	}
+
+	status, body := do(t, http.MethodGet, server.URL+"/jobs/missing", "", nil)
+	if status != http.StatusNotFound {
+		t.Errorf("unknown job: status %d: %s", status, body)
+	}
+	status, body = do(t, http.MethodGet, server.URL+"/profiles", "", nil)
+	var profiles map[string]json.RawMessage
+	if err := json.Unmarshal(body, &profiles); status != http.StatusOK || err != nil || len(profiles) != 2 {
+		t.Errorf("profiles: status %d, %v: %s", status, err, body)
+	}
+}
+
+func TestService(t *testing.T) {
+	testlicense.Setup(t)
+	_, server := newService(t)
+	input := newDocument(t)
+
+	// A single document, waiting for the signed document.
+	status, signed := do(t, http.MethodPost, server.URL+"/jobs?profile=certify&wait=true", "application/pdf", bytes.NewReader(input))
+	if status != http.StatusOK {
+		t.Fatalf("status %d: %s", status, signed)
+	}
+	for _, sig := range verifyOffline(t, signed).Signatures {
+		if sig.Level != signsvc.LevelBLTA {
+			t.Errorf("signature %q: level %s, want %s", sig.Field, sig.Level, signsvc.LevelBLTA)
+		}
+	}
+
+	// A batch of documents, polling the jobs.
+	var form bytes.Buffer
+	mw := multipart.NewWriter(&form)
+	for _, name := range []string{"a.pdf", "b.pdf", "c.pdf"} {
+		part, err := mw.CreateFormFile("file", name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		part.Write(input)
+	}
+	mw.WriteField("options", `{"reason":"Reviewed"}`)
+	mw.Close()
+	status, body := do(t, http.MethodPost, server.URL+"/jobs?profile=approval", mw.FormDataContentType(), &form)
+	if status != http.StatusAccepted {
+		t.Fatalf("status %d: %s", status, body)
+	}
+	var batch struct {
+		Batch string
+		Jobs  []signsvc.Job
+	}
+	if err := json.Unmarshal(body, &batch); err != nil {
+		t.Fatal(err)
+	}
+	if len(batch.Jobs) != 3 {
+		t.Fatalf("%d jobs, want 3", len(batch.Jobs))
+	}
+	for _, job := range batch.Jobs {
+		deadline := time.Now().Add(time.Minute)
+		for job.Status == signsvc.StatusQueued || job.Status == signsvc.StatusSigning {
+			if time.Now().After(deadline) {
+				t.Fatalf("job %s: still %s", job.ID, job.Status)
+			}
+			time.Sleep(100 * time.Millisecond)
+			_, body := do(t, http.MethodGet, server.URL+"/jobs/"+job.ID, "", nil)
+			if err := json.Unmarshal(body, &job); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if job.Status != signsvc.StatusDone {
+			t.Fatalf("job %s: %s: %s", job.ID, job.Status, job.Error)
+		}
+		status, signed := do(t, http.MethodGet, server.URL+"/jobs/"+job.ID+"/result", "", nil)
+		if status != http.StatusOK {
+			t.Fatalf("result of %s: status %d: %s", job.File, status, signed)
+		}
+		verifyOffline(t, signed)
+
+		if status, body := do(t, http.MethodDelete, server.URL+"/jobs/"+job.ID, "", nil); status != http.StatusNoContent {
+			t.Errorf("delete %s: status %d: %s", job.ID, status, body)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+/*
+ * Offline end to end tests of the signing, with the local TSA, OCSP responder and CRL of the test
+ * PKI: the documents are signed at each PAdES level, timestamped and LTV enabled, then validated
+ * with the sigverify package. The tests of the signing service are in service_test.go.
+ *
+ * Run as: go test ./signatures/signsvc/ [-run TestSign/B-LTA]
+ *
+ * The signing tests need a license and are skipped without it, see testing/testlicense: set
+ * UNIDOC_LICENSE_FILE and UNIDOC_LICENSE_CUSTOMER to an offline license key to run them without
+ * network access. The timestamp client test runs without a license.
+ */
+
+package signsvc_test
+
+import (
+	"bytes"
+	"crypto/x509"
+	"sync"
+	"testing"
+
+	"github.com/unidoc/pkcs7"
+	"github.com/unidoc/unidoc-examples/signatures/signsvc"
+	"github.com/unidoc/unidoc-examples/signatures/sigverify"
+	"github.com/unidoc/unidoc-examples/signatures/testpki"
+	"github.com/unidoc/unidoc-examples/testing/testlicense"
+	"github.com/unidoc/unipdf/v4/creator"
+)
+
+var (
+	pkiOnce sync.Once
+	pki     *testpki.Server
+	pkiErr  error
+)
+
+// testPKI returns the test PKI server shared by the tests.
+func testPKI(t *testing.T) *testpki.Server {
+	t.Helper()
+	pkiOnce.Do(func() {
+		pki, pkiErr = testpki.Start()
+	})
+	if pkiErr != nil {
+		t.Fatal(pkiErr)
+	}
+	return pki
+}
+
+// newSigner returns a software signer whose certificate is issued by the test PKI.
+func newSigner(t *testing.T, commonName string) signsvc.Signer {
+	t.Helper()
+	key, chain, err := testPKI(t).IssueSigner(commonName)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := signsvc.NewKeySigner(key, chain...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// newDocument returns a one page PDF document.
+func newDocument(t *testing.T) []byte {
+	t.Helper()
+	c := creator.New()
+	c.NewPage()
+	p := c.NewStyledParagraph()
+	p.SetText("Document to sign")
+	if err := c.Draw(p); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := c.Write(&buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// verifyOffline checks that the signatures of `data` are valid with the validation data embedded
+// in it, and returns the PAdES report of its signatures.
+func verifyOffline(t *testing.T, data []byte) *sigverify.PAdESReport {
+	t.Helper()
+	opts := sigverify.Options{Trust: sigverify.NewTrustStore(testPKI(t).Root)}
+	report, err := sigverify.Verify(data, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Signatures) == 0 {
+		t.Fatal("no signature")
+	}
+	for _, sig := range report.Signatures {
+		if sig.Verdict != sigverify.VerdictValid {
+			t.Errorf("signature %q: %s: %v", sig.Field, sig.Verdict, sig.Reasons)
+		}
+	}
+	pades, err := sigverify.CheckPAdES(data, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pades
+}
+
+func TestTimestampClient(t *testing.T) {
+	s := testPKI(t)
+	client := &signsvc.TimestampClient{URL: s.TSAURL()}
+	token, err := client.Token([]byte("document"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p7, err := pkcs7.Parse(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(s.Root)
+	if err := p7.VerifyWithChain(pool); err != nil {
+		t.Errorf("verify: %v", err)
+	}
+
+	client.URL = s.URL + "/missing"
+	if _, err := client.Token([]byte("document")); err == nil {
+		t.Error("no error from a missing TSA")
+	}
+}
+
+func TestSign(t *testing.T) {
+	testlicense.Setup(t)
+	signer := newSigner(t, "Test Signer")
+	input := newDocument(t)
+	opts := signsvc.Options{TSAURL: testPKI(t).TSAURL()}
+	tests := []struct {
+		name    string
+		profile signsvc.Profile
+	}{
+		{"adbe.pkcs7.detached", signsvc.Profile{Name: "Test Signer", Reason: "Test"}},
+		{"B-B", signsvc.Profile{Level: signsvc.LevelBB}},
+		{"B-T", signsvc.Profile{Level: signsvc.LevelBT}},
+		{"B-LT", signsvc.Profile{Level: signsvc.LevelBLT}},
+		{"B-LTA", signsvc.Profile{Level: signsvc.LevelBLTA}},
+		{"B-LTA certification", signsvc.Profile{Level: signsvc.LevelBLTA, DocMDP: 2}},
+		{"B-T visible", signsvc.Profile{
+			Level:  signsvc.LevelBT,
+			Name:   "Test Signer",
+			Reason: "Approved",
+			Appearance: &signsvc.Appearance{
+				Rect:  []float64{50, 50, 250, 120},
+				Lines: []signsvc.Line{{Label: "Department", Text: "Testing"}},
+			},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			signed, err := signsvc.Sign(input, signer, test.profile, opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			pades := verifyOffline(t, signed)
+			if test.profile.Level == "" {
+				return
+			}
+			for _, sig := range pades.Signatures {
+				if sig.Level != test.profile.Level {
+					t.Errorf("signature %q: level %s, want %s", sig.Field, sig.Level, test.profile.Level)
+					for _, req := range sig.Requirements {
+						if !req.Passed && !req.Optional {
+							t.Logf("  %s %s: %s", req.Level, req.Name, req.Detail)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestSignRevoked(t *testing.T) {
+	testlicense.Setup(t)
+	s := testPKI(t)
+	key, chain, err := s.IssueSigner("Revoked Signer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := signsvc.NewKeySigner(key, chain...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Revoke(chain[0])
+
+	signed, err := signsvc.Sign(newDocument(t), signer, signsvc.Profile{Level: signsvc.LevelBB}, signsvc.Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := sigverify.Verify(signed, sigverify.Options{
+		Trust: sigverify.NewTrustStore(s.Root),
+		Fetch: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Verdict == sigverify.VerdictValid {
+		t.Error("signature of a revoked certificate valid")
+	}
+}
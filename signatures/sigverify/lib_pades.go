@@ -0,0 +1,521 @@
+/*
+ * PAdES baseline conformance (ETSI EN 319 142-1): the highest level, B-B, B-T, B-LT or B-LTA,
+ * that each signature of a document reaches, with the requirements checked for each level.
+ */
+
+package sigverify
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/unidoc/pkcs7"
+	"github.com/unidoc/unipdf/v4/core"
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// PAdES baseline levels, in increasing order.
+const (
+	LevelNone = "none"
+	LevelBB   = "B-B"
+	LevelBT   = "B-T"
+	LevelBLT  = "B-LT"
+	LevelBLTA = "B-LTA"
+)
+
+// levels are the PAdES baseline levels in increasing order.
+var levels = []string{LevelNone, LevelBB, LevelBT, LevelBLT, LevelBLTA}
+
+// maxChainLength is the largest number of certificates of a chain walked for the validation data.
+const maxChainLength = 10
+
+// oidSigningCertificate is the OID of the ESS signing-certificate attribute (v1, SHA-1).
+var oidSigningCertificate = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 12}
+
+// LevelAtLeast returns true if the level `level` is `min` or higher.
+func LevelAtLeast(level, min string) bool {
+	return levelRank(level) >= levelRank(min)
+}
+
+// ValidLevel returns true if `level` is the name of a PAdES baseline level.
+func ValidLevel(level string) bool {
+	return levelRank(level) > 0 || level == LevelNone
+}
+
+func levelRank(level string) int {
+	for i, l := range levels {
+		if l == level {
+			return i
+		}
+	}
+	return 0
+}
+
+// Requirement is a requirement of a PAdES level and whether a signature meets it.
+type Requirement struct {
+	Level  string `json:"level"`
+	Name   string `json:"name"`
+	Passed bool   `json:"passed"`
+	// Optional requirements are recommendations that do not change the level.
+	Optional bool   `json:"optional,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+}
+
+// PAdESSignature is the PAdES conformance of a signature.
+type PAdESSignature struct {
+	Field        string        `json:"field"`
+	SubFilter    string        `json:"sub_filter"`
+	Level        string        `json:"level"` // The highest level all of whose requirements are met.
+	Requirements []Requirement `json:"requirements"`
+}
+
+// PAdESReport is the PAdES conformance of the signatures of a document. The document timestamps
+// are not signatures of their own but count for the B-T and B-LTA levels of the signatures they
+// cover.
+type PAdESReport struct {
+	File       string           `json:"file,omitempty"`
+	Checked    time.Time        `json:"checked"`
+	Signatures []PAdESSignature `json:"signatures"`
+	// Level is the lowest level of the signatures.
+	Level string `json:"level"`
+}
+
+// CheckPAdESFile determines the PAdES levels of the signatures of the PDF file `path`.
+func CheckPAdESFile(path string, opts Options) (*PAdESReport, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	report, err := CheckPAdES(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	report.File = path
+	return report, nil
+}
+
+// CheckPAdES determines the PAdES levels of the signatures of the PDF document `data`. The trust
+// store of `opts` is only used to locate the trust anchors of the chains, which need no validation
+// data, and no revocation data is fetched.
+func CheckPAdES(data []byte, opts Options) (*PAdESReport, error) {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	opts.Fetch = false
+	v, results, err := newVerifier(data, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var docTimestamps []*docTimestamp
+	for _, res := range results {
+		if dt := v.docTimestamp(res); dt != nil {
+			docTimestamps = append(docTimestamps, dt)
+		}
+	}
+
+	report := &PAdESReport{Checked: opts.Now, Signatures: []PAdESSignature{}, Level: LevelNone}
+	for _, res := range results {
+		if !res.IsSigned || len(res.Fields) == 0 {
+			continue
+		}
+		dict, ok := core.GetDict(res.Fields[0].V)
+		if !ok {
+			continue
+		}
+		if subFilter, _ := core.GetNameVal(dict.Get("SubFilter")); subFilter == "ETSI.RFC3161" {
+			continue
+		}
+		sig := v.checkPAdES(res, dict, docTimestamps)
+		sig.Level = sig.level()
+		if len(report.Signatures) == 0 || levelRank(sig.Level) < levelRank(report.Level) {
+			report.Level = sig.Level
+		}
+		report.Signatures = append(report.Signatures, sig)
+	}
+	return report, nil
+}
+
+// docTimestamp is a document timestamp (ETSI.RFC3161 signature).
+type docTimestamp struct {
+	field    string
+	end      int64     // Offset of the end of its revision.
+	valid    bool      // The bytes of its revision match the token.
+	time     time.Time // Of the token.
+	tsa      *x509.Certificate
+	tsaCerts []*x509.Certificate
+	data     []byte    // Its revision.
+	v        *verifier // Of its revision, loaded by revision.
+}
+
+// docTimestamp returns the document timestamp of the validation result `res`, nil if it is not
+// one.
+func (v *verifier) docTimestamp(res model.SignatureValidationResult) *docTimestamp {
+	if !res.IsSigned || len(res.Fields) == 0 {
+		return nil
+	}
+	dict, ok := core.GetDict(res.Fields[0].V)
+	if !ok {
+		return nil
+	}
+	if subFilter, _ := core.GetNameVal(dict.Get("SubFilter")); subFilter != "ETSI.RFC3161" {
+		return nil
+	}
+	dt := &docTimestamp{}
+	dt.field, _ = res.Fields[0].FullName()
+	dt.end = revisionEnd(dict)
+	ranges, err := v.signedRanges(dict)
+	contents, ok := core.GetString(dict.Get("Contents"))
+	if err != nil || !ok || dt.end < 0 {
+		return dt
+	}
+	token, err := parseTimestamp(contents.Bytes())
+	if err != nil {
+		return dt
+	}
+	ts := v.verifyTimestamp(token, &v.dss, ranges...)
+	dt.valid = res.IsVerified && ts.SignatureValid && ts.ImprintValid
+	dt.time = ts.Time
+	dt.tsa, dt.tsaCerts = signerCertificate(token.p7), token.p7.Certificates
+	dt.data = v.data[:dt.end]
+	return dt
+}
+
+// revision returns the verifier of the revision of the document timestamp, with the DSS as it was
+// when it was timestamped.
+func (dt *docTimestamp) revision(opts *Options) *verifier {
+	if dt.v == nil {
+		dt.v = &verifier{opts: opts, data: dt.data}
+		if reader, err := model.NewPdfReader(bytes.NewReader(dt.data)); err == nil {
+			dt.v.loadDSS(reader)
+		}
+	}
+	return dt.v
+}
+
+// checkPAdES checks the requirements of the PAdES levels for the signature of the validation
+// result `res` and dictionary `dict`, with the document timestamps `docTimestamps`. The
+// requirements of the higher levels are not checked without a CMS signature.
+func (v *verifier) checkPAdES(res model.SignatureValidationResult, dict *core.PdfObjectDictionary,
+	docTimestamps []*docTimestamp) PAdESSignature {
+	sig := PAdESSignature{}
+	sig.Field, _ = res.Fields[0].FullName()
+	sig.SubFilter, _ = core.GetNameVal(dict.Get("SubFilter"))
+	require := func(level, name string, passed bool, detail string, args ...any) {
+		sig.Requirements = append(sig.Requirements, Requirement{
+			Level:  level,
+			Name:   name,
+			Passed: passed,
+			Detail: fmt.Sprintf(detail, args...),
+		})
+	}
+	// B-B: a CAdES signature of the whole revision with the signing certificate attribute.
+	require(LevelBB, "sub filter", sig.SubFilter == "ETSI.CAdES.detached", "/SubFilter %s", sig.SubFilter)
+	require(LevelBB, "integrity", res.IsVerified, "%s", strings.Join(res.Errors, "; "))
+	ok, detail := v.checkByteRange(dict)
+	require(LevelBB, "byte range", ok, "%s", detail)
+	require(LevelBB, "no /Cert entry", dict.Get("Cert") == nil, "")
+
+	var contents []byte
+	if s, ok := core.GetString(dict.Get("Contents")); ok {
+		contents = s.Bytes()
+	}
+	p7, err := pkcs7.Parse(trimDER(contents))
+	if err != nil || len(p7.Signers) != 1 {
+		if err == nil {
+			err = fmt.Errorf("%d signers", len(p7.Signers))
+		}
+		require(LevelBB, "CMS signature", false, "%v", err)
+		return sig
+	}
+	signer := p7.Signers[0]
+	cert := signerCertificate(p7)
+	require(LevelBB, "signer certificate", cert != nil, "in the CMS certificates")
+	signed := map[string]bool{}
+	for _, attr := range signer.AuthenticatedAttributes {
+		signed[attr.Type.String()] = true
+	}
+	require(LevelBB, "content type attribute", signed[pkcs7.OIDAttributeContentType.String()], "")
+	require(LevelBB, "message digest attribute", signed[pkcs7.OIDAttributeMessageDigest.String()], "")
+	if cert != nil {
+		ok, detail := checkSigningCertificate(p7, cert)
+		require(LevelBB, "signing certificate attribute", ok, "%s", detail)
+	}
+	require(LevelBB, "no signing time attribute", !signed[pkcs7.OIDAttributeSigningTime.String()],
+		"the claimed signing time must be the /M entry")
+
+	// B-T: a signature timestamp, or a document timestamp of a later revision.
+	end := revisionEnd(dict)
+	var sigTS *Timestamp
+	var tsa *x509.Certificate
+	var tsaCerts []*x509.Certificate
+	for _, attr := range signer.UnauthenticatedAttributes {
+		if !attr.Type.Equal(pkcs7.OIDAttributeTimeStampToken) {
+			continue
+		}
+		if token, err := parseTimestamp(attr.Value.Bytes); err == nil {
+			sigTS = v.verifyTimestamp(token, &v.dss, signer.EncryptedDigest)
+			tsa, tsaCerts = signerCertificate(token.p7), token.p7.Certificates
+		}
+		break
+	}
+	var later []*docTimestamp
+	for _, dt := range docTimestamps {
+		if dt.valid && dt.end > end {
+			later = append(later, dt)
+		}
+	}
+	// The validation data must be current at the time of the timestamp, or issued after it.
+	at := v.opts.Now
+	switch {
+	case sigTS != nil && sigTS.SignatureValid && sigTS.ImprintValid:
+		require(LevelBT, "signature timestamp", true, "signature timestamp of %s", formatTime(sigTS.Time))
+		at = sigTS.Time
+	case len(later) > 0:
+		require(LevelBT, "signature timestamp", true, "document timestamp %q", later[0].field)
+		tsa, tsaCerts = later[0].tsa, later[0].tsaCerts
+		at = later[0].time
+	case sigTS != nil:
+		require(LevelBT, "signature timestamp", false, "invalid signature timestamp: %s",
+			strings.Join(sigTS.Errors, "; "))
+	default:
+		require(LevelBT, "signature timestamp", false, "no signature timestamp or later document timestamp")
+	}
+
+	// B-LT: the certificates and revocation data of the chains of the signer and of the TSA in
+	// the DSS.
+	require(LevelBLT, "DSS", v.hasDSS, "document security store")
+	gaps := func(v *verifier) []string {
+		if cert == nil {
+			return []string{"no signer certificate"}
+		}
+		g := v.validationGaps(cert, p7.Certificates, at)
+		if tsa != nil {
+			g = append(g, v.validationGaps(tsa, tsaCerts, at)...)
+		}
+		return g
+	}
+	missing := gaps(v)
+	require(LevelBLT, "validation data", len(missing) == 0, "%s", strings.Join(missing, "; "))
+	key := vriKey(contents)
+	sig.Requirements = append(sig.Requirements, Requirement{
+		Level:    LevelBLT,
+		Name:     "VRI entry",
+		Passed:   v.vri[key] || v.vri[vriKey(trimDER(contents))],
+		Optional: true,
+		Detail:   key,
+	})
+
+	// B-LTA: a document timestamp of a revision whose DSS has the validation data.
+	lta := ""
+	for _, dt := range later {
+		rv := dt.revision(v.opts)
+		if rv.hasDSS && len(gaps(rv)) == 0 {
+			lta = dt.field
+			break
+		}
+	}
+	if lta != "" {
+		require(LevelBLTA, "archive timestamp", true, "document timestamp %q covers the validation data", lta)
+	} else {
+		require(LevelBLTA, "archive timestamp", false,
+			"no valid document timestamp of a revision with the validation data")
+	}
+	return sig
+}
+
+// level returns the highest level of `sig` all of whose required requirements, and those of the
+// lower levels, are met.
+func (sig *PAdESSignature) level() string {
+	reached := LevelBLTA
+	for _, r := range sig.Requirements {
+		if r.Passed || r.Optional {
+			continue
+		}
+		if below := levels[levelRank(r.Level)-1]; levelRank(below) < levelRank(reached) {
+			reached = below
+		}
+	}
+	return reached
+}
+
+// checkByteRange returns true if the /ByteRange of `dict` covers its whole revision except the
+// /Contents string.
+func (v *verifier) checkByteRange(dict *core.PdfObjectDictionary) (bool, string) {
+	arr, ok := core.GetArray(dict.Get("ByteRange"))
+	if !ok {
+		return false, "no /ByteRange"
+	}
+	br, err := arr.ToInt64Array()
+	if err != nil || len(br) != 4 {
+		return false, fmt.Sprintf("/ByteRange %s is not 2 ranges", arr)
+	}
+	if br[0] != 0 || br[1] < 0 || br[2] <= br[1] || br[3] < 0 || br[2]+br[3] > int64(len(v.data)) {
+		return false, fmt.Sprintf("/ByteRange %v is out of the file", br)
+	}
+	if v.data[br[1]] != '<' || v.data[br[2]-1] != '>' {
+		return false, fmt.Sprintf("/ByteRange %v excludes more than the /Contents string", br)
+	}
+	return true, fmt.Sprintf("%v", br)
+}
+
+// checkSigningCertificate returns true if the signing certificate attribute of `p7` references
+// the signer certificate `cert`.
+func checkSigningCertificate(p7 *pkcs7.PKCS7, cert *x509.Certificate) (bool, string) {
+	// SigningCertificate(V2) ::= SEQUENCE { certs SEQUENCE OF ESSCertID(v2), policies OPTIONAL }
+	var sc struct {
+		Certs    []asn1.RawValue
+		Policies asn1.RawValue `asn1:"optional"`
+	}
+	name, alg := "signing-certificate-v2", "2.16.840.1.101.3.4.2.1"
+	err := p7.UnmarshalSignedAttribute(pkcs7.OIDAttributeSigningCertificateV2, &sc)
+	if err != nil {
+		name, alg = "signing-certificate", "1.3.14.3.2.26"
+		if p7.UnmarshalSignedAttribute(oidSigningCertificate, &sc) != nil {
+			return false, "no signing-certificate-v2 attribute"
+		}
+	}
+	// ESSCertIDv2 ::= SEQUENCE { hashAlgorithm DEFAULT sha256, certHash, issuerSerial OPTIONAL }
+	// ESSCertID ::= SEQUENCE { certHash (SHA-1), issuerSerial OPTIONAL }
+	var fields []asn1.RawValue
+	if len(sc.Certs) == 0 {
+		return false, name + " has no certificate"
+	}
+	if _, err := asn1.Unmarshal(sc.Certs[0].FullBytes, &fields); err != nil || len(fields) == 0 {
+		return false, "malformed " + name
+	}
+	hash := fields[0]
+	if hash.Tag == asn1.TagSequence && len(fields) > 1 {
+		var id pkix.AlgorithmIdentifier
+		if _, err := asn1.Unmarshal(hash.FullBytes, &id); err != nil {
+			return false, "malformed " + name
+		}
+		alg, hash = id.Algorithm.String(), fields[1]
+	}
+	h, ok := hashes[alg]
+	if !ok || !h.Available() {
+		return false, fmt.Sprintf("unsupported digest algorithm %s", alg)
+	}
+	hasher := h.New()
+	hasher.Write(cert.Raw)
+	if !bytes.Equal(hasher.Sum(nil), hash.Bytes) {
+		return false, name + " does not match the signer certificate"
+	}
+	return true, name
+}
+
+// validationGaps returns the validation data missing from the CMS certificates `certs` and the
+// DSS to validate `cert` at `at`: the certificates of its chain, up to a trust anchor or a
+// self-signed root, and the revocation data of the certificates other than the root, current at
+// `at` or issued after it.
+func (v *verifier) validationGaps(cert *x509.Certificate, certs []*x509.Certificate, at time.Time) []string {
+	pool := append(append([]*x509.Certificate{}, certs...), v.dssCerts...)
+	var gaps []string
+	for i := 0; i < maxChainLength; i++ {
+		if isSelfSigned(cert) || v.opts.Trust.contains(cert) {
+			return gaps
+		}
+		issuer := findIssuer(cert, pool)
+		if issuer == nil && v.opts.Trust != nil {
+			// The trust anchors need not be embedded.
+			issuer = findIssuer(cert, v.opts.Trust.Certs)
+		}
+		if issuer == nil {
+			return append(gaps, fmt.Sprintf("issuer of %s not in the signature or the DSS", cert.Subject))
+		}
+		_, ocspOK := checkOCSPs(cert, issuer, at, v.dss.ocsps)
+		_, crlOK := checkCRLs(cert, issuer, at, v.dss.crls)
+		if !ocspOK && !crlOK {
+			gaps = append(gaps, fmt.Sprintf("no OCSP response or CRL for %s in the DSS", cert.Subject))
+		}
+		cert = issuer
+	}
+	return append(gaps, "certificate chain too long")
+}
+
+// findIssuer returns the certificate of `certs` that issued `cert`, nil if none did.
+func findIssuer(cert *x509.Certificate, certs []*x509.Certificate) *x509.Certificate {
+	for _, c := range certs {
+		if bytes.Equal(c.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(c) == nil {
+			return c
+		}
+	}
+	return nil
+}
+
+// isSelfSigned returns true if `cert` is a self-signed root.
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(cert) == nil
+}
+
+// revisionEnd returns the offset of the end of the revision signed by the signature of `dict`, -1
+// if its /ByteRange is malformed.
+func revisionEnd(dict *core.PdfObjectDictionary) int64 {
+	arr, ok := core.GetArray(dict.Get("ByteRange"))
+	if !ok {
+		return -1
+	}
+	br, err := arr.ToInt64Array()
+	if err != nil || len(br) < 2 || len(br)%2 != 0 {
+		return -1
+	}
+	return br[len(br)-2] + br[len(br)-1]
+}
+
+// vriKey returns the key of the VRI entry of the signature value `contents`.
+func vriKey(contents []byte) string {
+	sum := sha1.Sum(contents)
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// WriteText writes a summary of `r` for the console.
+func (r *PAdESReport) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "%s: %s, %d signatures\n", r.File, r.Level, len(r.Signatures))
+	for i, sig := range r.Signatures {
+		fmt.Fprintf(w, "  %d. %q (%s): %s\n", i+1, sig.Field, sig.SubFilter, sig.Level)
+		for _, req := range sig.Requirements {
+			status := "ok  "
+			switch {
+			case !req.Passed && req.Optional:
+				status = "warn"
+			case !req.Passed:
+				status = "FAIL"
+			}
+			line := fmt.Sprintf("     %s %-5s %s", status, req.Level, req.Name)
+			if req.Detail != "" && (!req.Passed || req.Level != LevelBB) {
+				line += ": " + req.Detail
+			}
+			fmt.Fprintln(w, line)
+		}
+	}
+}
+
+// WritePAdESJSON writes the reports `reports` as an indented JSON array.
+func WritePAdESJSON(w io.Writer, reports []*PAdESReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(reports)
+}
+
+// contains returns true if `cert` is in the trust store.
+func (ts *TrustStore) contains(cert *x509.Certificate) bool {
+	if ts == nil {
+		return false
+	}
+	for _, c := range ts.Certs {
+		if bytes.Equal(c.Raw, cert.Raw) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,131 @@
+/*
+ * Output of the verification reports as text, JSON and HTML.
+ */
+
+package sigverify
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"strings"
+	"time"
+)
+
+// WriteJSON writes the reports `reports` as an indented JSON array.
+func WriteJSON(w io.Writer, reports []*Report) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(reports)
+}
+
+// WriteText writes a summary of `r` for the console.
+func (r *Report) WriteText(w io.Writer) {
+	fmt.Fprintf(w, "%s: %s, %d signatures\n", r.File, strings.ToUpper(r.Verdict), len(r.Signatures))
+	for i, sig := range r.Signatures {
+		fmt.Fprintf(w, "  %d. %q (%s): %s\n", i+1, sig.Field, sig.SubFilter, strings.ToUpper(sig.Verdict))
+		if sig.Signer != nil {
+			fmt.Fprintf(w, "     signer:    %s\n", sig.Signer.Subject)
+		}
+		if !sig.SigningTime.IsZero() {
+			fmt.Fprintf(w, "     signed:    %s (claimed)\n", formatTime(sig.SigningTime))
+		}
+		if ts := sig.Timestamp; ts != nil {
+			fmt.Fprintf(w, "     timestamp: %s, trusted: %t\n", formatTime(ts.Time), ts.Trusted)
+		}
+		fmt.Fprintf(w, "     chain:     %d certificates, trusted: %t\n", len(sig.Chain), sig.ChainTrusted)
+		for _, c := range sig.Chain {
+			if c.Revocation != nil {
+				fmt.Fprintf(w, "       %s: %s\n", c.Subject, revocationText(c.Revocation))
+			}
+		}
+		for _, reason := range sig.Reasons {
+			fmt.Fprintf(w, "     - %s\n", reason)
+		}
+	}
+}
+
+// revocationText describes `rev` in a few words.
+func revocationText(rev *Revocation) string {
+	s := rev.Status
+	if rev.Method != "" {
+		s += fmt.Sprintf(" (%s, %s)", rev.Method, rev.Source)
+	}
+	return s
+}
+
+// formatTime formats `t` for the reports.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format("2006-01-02 15:04:05 UTC")
+}
+
+// WriteHTML writes the reports `reports` as a standalone HTML page.
+func WriteHTML(w io.Writer, reports []*Report) error {
+	return htmlReport.Execute(w, reports)
+}
+
+var htmlReport = template.Must(template.New("report").Funcs(template.FuncMap{
+	"time":       formatTime,
+	"revocation": revocationText,
+	"upper":      strings.ToUpper,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Signature verification report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin: 0.5em 0 1.5em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #f4f4f4; }
+.valid { color: #17803d; font-weight: bold; }
+.invalid { color: #c0262d; font-weight: bold; }
+.indeterminate { color: #b26b00; font-weight: bold; }
+.small { font-size: 0.85em; color: #555; }
+</style>
+</head>
+<body>
+<h1>Signature verification report</h1>
+{{range .}}
+<h2>{{.File}} <span class="{{.Verdict}}">{{upper .Verdict}}</span></h2>
+<p class="small">Checked {{time .Checked}}, {{.Size}} bytes{{with .TrustStore}}, trust store {{.}}{{end}}.</p>
+{{if not .Signatures}}<p>No signatures.</p>{{end}}
+{{range $i, $sig := .Signatures}}
+<h3>{{$sig.Field}} <span class="{{$sig.Verdict}}">{{upper $sig.Verdict}}</span></h3>
+<table>
+<tr><th>Type</th><td>{{$sig.SubFilter}}</td></tr>
+{{with $sig.Name}}<tr><th>Name</th><td>{{.}}</td></tr>{{end}}
+{{with $sig.Reason}}<tr><th>Reason</th><td>{{.}}</td></tr>{{end}}
+{{with $sig.Location}}<tr><th>Location</th><td>{{.}}</td></tr>{{end}}
+<tr><th>Integrity</th><td>{{if $sig.Integrity}}signed bytes unmodified{{else}}signed bytes modified{{end}}</td></tr>
+<tr><th>Covers document</th><td>{{if $sig.CoversDocument}}yes{{else}}no, later revisions{{end}} (byte range {{$sig.ByteRange}})</td></tr>
+<tr><th>Claimed signing time</th><td>{{time $sig.SigningTime}}</td></tr>
+{{with $sig.Timestamp}}<tr><th>Timestamp</th><td>{{time .Time}}{{with .TSA}} by {{.Subject}}{{end}}<br>
+signature {{if .SignatureValid}}valid{{else}}invalid{{end}}, imprint {{if .ImprintValid}}valid{{else}}invalid{{end}},
+{{if .Trusted}}trusted{{else}}not trusted{{end}}
+{{range .Errors}}<br><span class="small">{{.}}</span>{{end}}</td></tr>{{end}}
+<tr><th>Validation time</th><td>{{time $sig.ValidationTime}}</td></tr>
+<tr><th>Chain</th><td>{{if $sig.ChainTrusted}}trusted{{else}}not trusted{{end}}</td></tr>
+</table>
+{{if $sig.Chain}}
+<table>
+<tr><th>Certificate</th><th>Issuer</th><th>Validity</th><th>Revocation</th></tr>
+{{range $sig.Chain}}<tr>
+<td>{{.Subject}}<br><span class="small">serial {{.Serial}}<br>SHA-256 {{.SHA256}}</span></td>
+<td>{{.Issuer}}</td>
+<td>{{time .NotBefore}} to<br>{{time .NotAfter}}</td>
+<td>{{if .Revocation}}{{with .Revocation}}{{revocation .}}{{if not .RevokedAt.IsZero}}<br>revoked {{time .RevokedAt}}{{end}}{{with .URL}}<br><span class="small">{{.}}</span>{{end}}{{range .Errors}}<br><span class="small">{{.}}</span>{{end}}{{end}}{{else if $sig.ChainTrusted}}trust anchor{{else}}not checked{{end}}</td>
+</tr>{{end}}
+</table>
+{{end}}
+{{if $sig.Reasons}}<p>Reasons:</p><ul>{{range $sig.Reasons}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{if $sig.Notes}}<p class="small">Notes:</p><ul class="small">{{range $sig.Notes}}<li>{{.}}</li>{{end}}</ul>{{end}}
+{{end}}
+{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,259 @@
+/*
+ * Revocation checking of the certificates of the chains, with the OCSP responses and CRLs embedded
+ * in the document security store (DSS) and in the signatures, or fetched from the responders.
+ */
+
+package sigverify
+
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/asn1"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// Revocation statuses.
+const (
+	StatusGood    = "good"
+	StatusRevoked = "revoked"
+	StatusUnknown = "unknown" // No usable revocation data.
+)
+
+// Sources of the revocation data.
+const (
+	SourceDSS       = "dss"       // Document security store.
+	SourceSignature = "signature" // Adobe revocation attribute of the signature.
+	SourceFetched   = "fetched"   // Fetched from the OCSP responder or CRL distribution point.
+)
+
+// maxResponseSize is the largest OCSP response or CRL fetched.
+const maxResponseSize = 16 << 20
+
+// Revocation is the revocation status of a certificate.
+type Revocation struct {
+	Status     string    `json:"status"`
+	Method     string    `json:"method,omitempty"` // "ocsp" or "crl".
+	Source     string    `json:"source,omitempty"`
+	URL        string    `json:"url,omitempty"` // Of the fetched data.
+	ThisUpdate time.Time `json:"this_update,omitzero"`
+	NextUpdate time.Time `json:"next_update,omitzero"`
+	RevokedAt  time.Time `json:"revoked_at,omitzero"`
+	Errors     []string  `json:"errors,omitempty"` // Problems with the data, e.g. failed fetches.
+}
+
+// revocationData is the revocation data available to check a chain.
+type revocationData struct {
+	ocsps []revocationItem
+	crls  []revocationItem
+}
+
+// revocationItem is a DER encoded OCSP response or CRL with its source.
+type revocationItem struct {
+	der    []byte
+	source string
+}
+
+// add appends the OCSP responses `ocsps` and CRLs `crls` from `source`.
+func (d *revocationData) add(ocsps, crls [][]byte, source string) {
+	for _, der := range ocsps {
+		d.ocsps = append(d.ocsps, revocationItem{der, source})
+	}
+	for _, der := range crls {
+		d.crls = append(d.crls, revocationItem{der, source})
+	}
+}
+
+// revocationInfoArchival is the value of the Adobe revocation attribute of a CMS signature.
+type revocationInfoArchival struct {
+	CRL   []asn1.RawValue `asn1:"explicit,tag:0,optional"`
+	OCSP  []asn1.RawValue `asn1:"explicit,tag:1,optional"`
+	Other []asn1.RawValue `asn1:"explicit,tag:2,optional"`
+}
+
+// rawBytes returns the DER encodings of `values`.
+func rawBytes(values []asn1.RawValue) [][]byte {
+	ders := make([][]byte, len(values))
+	for i, v := range values {
+		ders[i] = v.FullBytes
+	}
+	return ders
+}
+
+// checkRevocation returns the revocation status of `cert`, issued by `issuer`, at time `at`, from
+// the embedded data `data`, then fetched if `opts` allow it. A certificate revoked after `at` is
+// good.
+func checkRevocation(cert, issuer *x509.Certificate, at time.Time, data *revocationData, opts *Options) Revocation {
+	if rev, ok := checkOCSPs(cert, issuer, at, data.ocsps); ok {
+		return rev
+	}
+	if rev, ok := checkCRLs(cert, issuer, at, data.crls); ok {
+		return rev
+	}
+	rev := Revocation{Status: StatusUnknown}
+	if !opts.Fetch {
+		return rev
+	}
+
+	ocspURLs := cert.OCSPServer
+	if opts.OCSPServer != "" {
+		ocspURLs = []string{opts.OCSPServer}
+	}
+	for _, url := range ocspURLs {
+		der, err := fetchOCSP(opts.httpClient(), url, cert, issuer)
+		if err != nil {
+			rev.Errors = append(rev.Errors, fmt.Sprintf("ocsp %s: %v", url, err))
+			continue
+		}
+		if r, ok := checkOCSPs(cert, issuer, at, []revocationItem{{der, SourceFetched}}); ok {
+			r.URL = url
+			return r
+		}
+		rev.Errors = append(rev.Errors, fmt.Sprintf("ocsp %s: no usable response", url))
+	}
+
+	crlURLs := cert.CRLDistributionPoints
+	if opts.CRLURL != "" {
+		crlURLs = []string{opts.CRLURL}
+	}
+	for _, url := range crlURLs {
+		der, err := fetch(opts.httpClient(), http.MethodGet, url, "", nil)
+		if err != nil {
+			rev.Errors = append(rev.Errors, fmt.Sprintf("crl %s: %v", url, err))
+			continue
+		}
+		if r, ok := checkCRLs(cert, issuer, at, []revocationItem{{der, SourceFetched}}); ok {
+			r.URL = url
+			return r
+		}
+		rev.Errors = append(rev.Errors, fmt.Sprintf("crl %s: no usable list", url))
+	}
+	return rev
+}
+
+// checkOCSPs returns the status of `cert` at `at` in the best (see better) of `items` which are
+// valid OCSP responses of its issuer for it, current at `at` or produced after it.
+func checkOCSPs(cert, issuer *x509.Certificate, at time.Time, items []revocationItem) (Revocation, bool) {
+	var best Revocation
+	found := false
+	for _, item := range items {
+		resp, err := ocsp.ParseResponseForCert(item.der, cert, issuer)
+		if err != nil {
+			continue
+		}
+		if !current(resp.ThisUpdate, resp.NextUpdate, at) && !resp.ProducedAt.After(at) {
+			continue
+		}
+		rev := Revocation{
+			Method:     "ocsp",
+			Source:     item.source,
+			ThisUpdate: resp.ThisUpdate,
+			NextUpdate: resp.NextUpdate,
+		}
+		switch resp.Status {
+		case ocsp.Good:
+			rev.Status = StatusGood
+		case ocsp.Revoked:
+			rev.RevokedAt = resp.RevokedAt
+			rev.Status = StatusRevoked
+			if resp.RevokedAt.After(at) {
+				rev.Status = StatusGood
+			}
+		default:
+			continue
+		}
+		if !found || better(rev, best) {
+			best, found = rev, true
+		}
+	}
+	return best, found
+}
+
+// checkCRLs returns the status of `cert` at `at` in the best (see better) of `items` which are
+// valid CRLs of its issuer, current at `at` or issued after it.
+func checkCRLs(cert, issuer *x509.Certificate, at time.Time, items []revocationItem) (Revocation, bool) {
+	var best Revocation
+	found := false
+	for _, item := range items {
+		crl, err := x509.ParseRevocationList(item.der)
+		if err != nil {
+			continue
+		}
+		if !bytes.Equal(crl.RawIssuer, cert.RawIssuer) || crl.CheckSignatureFrom(issuer) != nil {
+			continue
+		}
+		if !current(crl.ThisUpdate, crl.NextUpdate, at) && !crl.ThisUpdate.After(at) {
+			continue
+		}
+		rev := Revocation{
+			Status:     StatusGood,
+			Method:     "crl",
+			Source:     item.source,
+			ThisUpdate: crl.ThisUpdate,
+			NextUpdate: crl.NextUpdate,
+		}
+		for _, entry := range crl.RevokedCertificateEntries {
+			if entry.SerialNumber.Cmp(cert.SerialNumber) != 0 {
+				continue
+			}
+			rev.RevokedAt = entry.RevocationTime
+			if !entry.RevocationTime.After(at) {
+				rev.Status = StatusRevoked
+			}
+			break
+		}
+		if !found || better(rev, best) {
+			best, found = rev, true
+		}
+	}
+	return best, found
+}
+
+// current returns true if revocation data issued at `thisUpdate` with the next update at
+// `nextUpdate`, zero if unknown, is current at `at`.
+func current(thisUpdate, nextUpdate, at time.Time) bool {
+	return !thisUpdate.After(at) && (nextUpdate.IsZero() || !nextUpdate.Before(at))
+}
+
+// better returns true if the status `a` prevails over `b`: a revocation prevails, then the most
+// recent data.
+func better(a, b Revocation) bool {
+	if revoked := a.Status == StatusRevoked; revoked != (b.Status == StatusRevoked) {
+		return revoked
+	}
+	return a.ThisUpdate.After(b.ThisUpdate)
+}
+
+// fetchOCSP requests the status of `cert` from the OCSP responder at `url`.
+func fetchOCSP(client *http.Client, url string, cert, issuer *x509.Certificate) ([]byte, error) {
+	req, err := ocsp.CreateRequest(cert, issuer, nil)
+	if err != nil {
+		return nil, err
+	}
+	return fetch(client, http.MethodPost, url, "application/ocsp-request", req)
+}
+
+// fetch sends a `method` request with `body` of type `contentType` to `url` and returns the
+// response body.
+func fetch(client *http.Client, method, url, contentType string, body []byte) ([]byte, error) {
+	req, err := http.NewRequest(method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+}
@@ -0,0 +1,164 @@
+/*
+ * RFC 3161 timestamp tokens: of the signatures (unsigned attribute of the CMS signer) and of the
+ * document timestamps (ETSI.RFC3161 signatures).
+ */
+
+package sigverify
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/unidoc/pkcs7"
+)
+
+// Timestamp is the verification of a timestamp token.
+type Timestamp struct {
+	Time   time.Time `json:"time"`
+	Serial string    `json:"serial,omitempty"`
+	Policy string    `json:"policy,omitempty"`
+	TSA    *Cert     `json:"tsa,omitempty"` // Certificate of the timestamp authority.
+	// Chain is the certificate chain of the TSA up to a trusted root, or only the TSA certificate
+	// if it could not be built.
+	Chain        []Cert `json:"chain,omitempty"`
+	ChainTrusted bool   `json:"chain_trusted"`
+	// SignatureValid is true if the token is signed by the TSA certificate.
+	SignatureValid bool `json:"signature_valid"`
+	// ImprintValid is true if the token timestamps the signature or the document.
+	ImprintValid bool `json:"imprint_valid"`
+	// Trusted is true if the token is valid and its TSA chain is trusted and not revoked.
+	Trusted bool     `json:"trusted"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// tstInfo is the content of a timestamp token. The fields after genTime are not needed.
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+}
+
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+// hashes are the digest algorithms of the message imprints.
+var hashes = map[string]crypto.Hash{
+	"1.3.14.3.2.26":          crypto.SHA1,
+	"2.16.840.1.101.3.4.2.1": crypto.SHA256,
+	"2.16.840.1.101.3.4.2.2": crypto.SHA384,
+	"2.16.840.1.101.3.4.2.3": crypto.SHA512,
+}
+
+// parsedTimestamp is a parsed timestamp token.
+type parsedTimestamp struct {
+	p7   *pkcs7.PKCS7
+	info tstInfo
+}
+
+// parseTimestamp parses the DER encoded timestamp token `der`.
+func parseTimestamp(der []byte) (*parsedTimestamp, error) {
+	p7, err := pkcs7.Parse(trimDER(der))
+	if err != nil {
+		return nil, err
+	}
+	var info tstInfo
+	if _, err := asn1.Unmarshal(p7.Content, &info); err != nil {
+		return nil, fmt.Errorf("timestamp info: %w", err)
+	}
+	return &parsedTimestamp{p7: p7, info: info}, nil
+}
+
+// hashImprint returns the digest of the message imprint algorithm of the token of `data`.
+func (ts *parsedTimestamp) hashImprint(data ...[]byte) ([]byte, error) {
+	oid := ts.info.MessageImprint.HashAlgorithm.Algorithm.String()
+	h, ok := hashes[oid]
+	if !ok || !h.Available() {
+		return nil, fmt.Errorf("unsupported imprint digest algorithm %s", oid)
+	}
+	hasher := h.New()
+	for _, d := range data {
+		hasher.Write(d)
+	}
+	return hasher.Sum(nil), nil
+}
+
+// verifyTimestamp verifies the token `ts` of `imprinted` (the signature value or the signed byte
+// ranges), and the chain of its TSA at the time of the token with the revocation data `data`.
+func (v *verifier) verifyTimestamp(ts *parsedTimestamp, data *revocationData, imprinted ...[]byte) *Timestamp {
+	res := &Timestamp{
+		Time:   ts.info.GenTime,
+		Policy: ts.info.Policy.String(),
+	}
+	if ts.info.SerialNumber != nil {
+		res.Serial = ts.info.SerialNumber.Text(16)
+	}
+
+	if err := ts.p7.Verify(); err != nil {
+		res.Errors = append(res.Errors, fmt.Sprintf("token signature: %v", err))
+	} else {
+		res.SignatureValid = true
+	}
+
+	digest, err := ts.hashImprint(imprinted...)
+	switch {
+	case err != nil:
+		res.Errors = append(res.Errors, err.Error())
+	case !bytes.Equal(digest, ts.info.MessageImprint.HashedMessage):
+		res.Errors = append(res.Errors, "message imprint does not match the timestamped data")
+	default:
+		res.ImprintValid = true
+	}
+
+	tsa := signerCertificate(ts.p7)
+	if tsa == nil {
+		res.Errors = append(res.Errors, "TSA certificate not found in the token")
+		return res
+	}
+	cert := newCert(tsa)
+	res.TSA = &cert
+	chain, ok, errs := v.verifyChain(tsa, ts.p7.Certificates, res.Time, x509.ExtKeyUsageTimeStamping, data)
+	res.Chain = chain
+	res.ChainTrusted = ok
+	res.Errors = append(res.Errors, errs...)
+	res.Trusted = ok && res.SignatureValid && res.ImprintValid
+	for _, c := range chain {
+		if c.Revocation != nil && c.Revocation.Status == StatusRevoked {
+			res.Trusted = false
+		}
+	}
+	return res
+}
+
+// signerCertificate returns the certificate of the first signer of `p7`.
+func signerCertificate(p7 *pkcs7.PKCS7) *x509.Certificate {
+	if len(p7.Signers) == 0 {
+		return nil
+	}
+	ias := p7.Signers[0].IssuerAndSerialNumber
+	for _, cert := range p7.Certificates {
+		if cert.SerialNumber.Cmp(ias.SerialNumber) == 0 && bytes.Equal(cert.RawIssuer, ias.IssuerName.FullBytes) {
+			return cert
+		}
+	}
+	return nil
+}
+
+// trimDER returns `b` without the padding after its first DER element, as the signature values of
+// the /Contents entries are padded with zeros. `b` is returned whole if it is not DER.
+func trimDER(b []byte) []byte {
+	var rv asn1.RawValue
+	if _, err := asn1.Unmarshal(b, &rv); err != nil {
+		return b
+	}
+	return rv.FullBytes
+}
@@ -0,0 +1,98 @@
+/*
+ * Trust store of the signature verification: the root certificates the certificate chains of the
+ * signers and timestamp authorities must lead to.
+ */
+
+package sigverify
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// TrustStore is a set of trusted root certificates.
+type TrustStore struct {
+	Dir   string              // Directory the certificates were loaded from.
+	Certs []*x509.Certificate // Trusted certificates, sorted by file name.
+	pool  *x509.CertPool
+}
+
+// LoadTrustStore loads the trusted certificates of the PEM files (.pem, .crt, .cer) of `dir`.
+// A file can hold several certificates. It is an error if `dir` has none.
+func LoadTrustStore(dir string) (*TrustStore, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, e := range entries {
+		switch filepath.Ext(e.Name()) {
+		case ".pem", ".crt", ".cer":
+			if !e.IsDir() {
+				names = append(names, e.Name())
+			}
+		}
+	}
+	sort.Strings(names)
+
+	var all []*x509.Certificate
+	for _, name := range names {
+		certs, err := readPEMCertificates(filepath.Join(dir, name))
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, certs...)
+	}
+	if len(all) == 0 {
+		return nil, fmt.Errorf("no certificates in %s", dir)
+	}
+	ts := NewTrustStore(all...)
+	ts.Dir = dir
+	return ts, nil
+}
+
+// NewTrustStore returns a trust store of `certs`.
+func NewTrustStore(certs ...*x509.Certificate) *TrustStore {
+	ts := &TrustStore{Certs: certs, pool: x509.NewCertPool()}
+	for _, cert := range certs {
+		ts.pool.AddCert(cert)
+	}
+	return ts
+}
+
+// Pool returns the trusted certificates as a pool, empty for a nil store.
+func (ts *TrustStore) Pool() *x509.CertPool {
+	if ts == nil {
+		return x509.NewCertPool()
+	}
+	return ts.pool
+}
+
+// readPEMCertificates returns the certificates of the PEM file `path`.
+func readPEMCertificates(path string) ([]*x509.Certificate, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
@@ -0,0 +1,606 @@
+/*
+ * Package sigverify verifies the digital signatures of PDF documents for audit reports: for each
+ * signature, the integrity of the signed bytes, the certificate chain of the signer up to a trust
+ * store, the revocation status of the chain, and the timestamp token with the claimed signing time.
+ */
+
+package sigverify
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/unidoc/pkcs7"
+	"github.com/unidoc/unipdf/v4/core"
+	"github.com/unidoc/unipdf/v4/model"
+	"github.com/unidoc/unipdf/v4/model/sighandler"
+)
+
+// Verdicts of the signatures and of the documents.
+const (
+	// VerdictValid is a signature whose integrity, chain, revocation status and timestamp are
+	// verified, or a document all of whose signatures are valid.
+	VerdictValid = "valid"
+	// VerdictInvalid is a signature that is modified, revoked or inconsistent, or a document with an
+	// invalid signature.
+	VerdictInvalid = "invalid"
+	// VerdictIndeterminate is a signature that cannot be verified with the available data, e.g. an
+	// untrusted chain or an unknown revocation status, or a document without signatures.
+	VerdictIndeterminate = "indeterminate"
+)
+
+// defaultFetchTimeout is the timeout of the fetches of the revocation data if Options.HTTPClient
+// is not set.
+const defaultFetchTimeout = 10 * time.Second
+
+// maxSigningTimeSkew is the largest accepted difference by which the claimed signing time of a
+// signature can be after the time of its timestamp, for the clock skews of the signer and the TSA.
+const maxSigningTimeSkew = 5 * time.Minute
+
+// Options are the options of the verification.
+type Options struct {
+	// Trust is the trust store of the chains. No chain is trusted if nil.
+	Trust *TrustStore
+	// Fetch enables the fetching of the revocation data missing from the document from the OCSP
+	// responders and CRL distribution points of the certificates.
+	Fetch bool
+	// OCSPServer and CRLURL replace the OCSP responders and CRL distribution points of the
+	// certificates when fetching, e.g. with a local stand-in responder.
+	OCSPServer string
+	CRLURL     string
+	// HTTPClient fetches the revocation data. Defaults to a client with a 10 s timeout.
+	HTTPClient *http.Client
+	// Now is the validation time of the signatures without a trusted timestamp. Defaults to the
+	// current time.
+	Now time.Time
+}
+
+func (opts *Options) httpClient() *http.Client {
+	if opts.HTTPClient != nil {
+		return opts.HTTPClient
+	}
+	return &http.Client{Timeout: defaultFetchTimeout}
+}
+
+// Cert describes a certificate of a chain.
+type Cert struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	Serial    string    `json:"serial"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+	SHA256    string    `json:"sha256"`
+	// Revocation is the revocation status of the certificate, nil for the trusted root.
+	Revocation *Revocation `json:"revocation,omitempty"`
+}
+
+// Signature is the verification of a signature.
+type Signature struct {
+	Field     string  `json:"field"`
+	SubFilter string  `json:"sub_filter"`
+	Name      string  `json:"name,omitempty"`
+	Reason    string  `json:"reason,omitempty"`
+	Location  string  `json:"location,omitempty"`
+	ByteRange []int64 `json:"byte_range"`
+	// CoversDocument is true if the signed byte ranges extend to the end of the file, i.e. the
+	// document has no later revision.
+	CoversDocument bool `json:"covers_document"`
+	// Integrity is true if the signed bytes match the signature value.
+	Integrity bool `json:"integrity"`
+	// SigningTime is the claimed signing time, of the signed attributes or of the /M entry.
+	SigningTime time.Time `json:"signing_time,omitzero"`
+	Signer      *Cert     `json:"signer,omitempty"`
+	// Chain is the certificate chain of the signer up to a trusted root, from the signer, or only
+	// the signer if it could not be built.
+	Chain        []Cert `json:"chain,omitempty"`
+	ChainTrusted bool   `json:"chain_trusted"`
+	// ValidationTime is the time at which the chain and revocation status are evaluated: the time
+	// of a trusted timestamp or the current time.
+	ValidationTime time.Time `json:"validation_time"`
+	// Timestamp is the timestamp token of the signature, or of the document for a document
+	// timestamp.
+	Timestamp *Timestamp `json:"timestamp,omitempty"`
+	Verdict   string     `json:"verdict"`
+	// Reasons explain a verdict other than valid.
+	Reasons []string `json:"reasons,omitempty"`
+	// Notes are observations that do not change the verdict.
+	Notes []string `json:"notes,omitempty"`
+}
+
+// Report is the verification of the signatures of a document.
+type Report struct {
+	File       string      `json:"file,omitempty"`
+	Size       int64       `json:"size"`
+	Checked    time.Time   `json:"checked"`
+	TrustStore string      `json:"trust_store,omitempty"`
+	Signatures []Signature `json:"signatures"`
+	Verdict    string      `json:"verdict"`
+}
+
+// VerifyFile verifies the signatures of the PDF file `path`.
+func VerifyFile(path string, opts Options) (*Report, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	report, err := Verify(data, opts)
+	if err != nil {
+		return nil, err
+	}
+	report.File = path
+	return report, nil
+}
+
+// Verify verifies the signatures of the PDF document `data`.
+func Verify(data []byte, opts Options) (*Report, error) {
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	v, results, err := newVerifier(data, &opts)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		Size:       int64(len(data)),
+		Checked:    opts.Now,
+		Signatures: []Signature{},
+		Verdict:    VerdictValid,
+	}
+	if opts.Trust != nil {
+		report.TrustStore = opts.Trust.Dir
+	}
+	for _, res := range results {
+		if !res.IsSigned || len(res.Fields) == 0 {
+			continue
+		}
+		sig := v.verifySignature(res)
+		report.Signatures = append(report.Signatures, sig)
+		report.Verdict = worse(report.Verdict, sig.Verdict)
+	}
+	if len(report.Signatures) == 0 {
+		report.Verdict = VerdictIndeterminate
+	}
+	return report, nil
+}
+
+// newVerifier opens the PDF document `data` and returns its verifier with the integrity validation
+// results of its signatures.
+func newVerifier(data []byte, opts *Options) (*verifier, []model.SignatureValidationResult, error) {
+	reader, err := model.NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, nil, err
+	}
+	handlers, err := signatureHandlers()
+	if err != nil {
+		return nil, nil, err
+	}
+	results, err := reader.ValidateSignatures(handlers)
+	if err != nil {
+		return nil, nil, err
+	}
+	v := &verifier{opts: opts, data: data}
+	v.loadDSS(reader)
+	return v, results, nil
+}
+
+// signatureHandlers returns the handlers verifying the integrity of the signatures of the
+// supported sub filters.
+func signatureHandlers() ([]model.SignatureHandler, error) {
+	x509RSASHA1, err := sighandler.NewAdobeX509RSASHA1(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	pkcs7Detached, err := sighandler.NewAdobePKCS7Detached(nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	pades, err := sighandler.NewEtsiPAdESLevelB(nil, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	docTimeStamp, err := sighandler.NewDocTimeStamp("", 0)
+	if err != nil {
+		return nil, err
+	}
+	return []model.SignatureHandler{x509RSASHA1, pkcs7Detached, pades, docTimeStamp}, nil
+}
+
+// verifier verifies the signatures of a document.
+type verifier struct {
+	opts *Options
+	data []byte
+	// dssCerts and dss are the certificates and revocation data of the document security store,
+	// global and of its VRI entries.
+	dssCerts []*x509.Certificate
+	dss      revocationData
+	hasDSS   bool
+	// vri are the keys of the VRI entries: the uppercase hex SHA-1 digests of the signature values.
+	vri map[string]bool
+}
+
+// loadDSS loads the certificates and revocation data of the document security store of `reader`.
+func (v *verifier) loadDSS(reader *model.PdfReader) {
+	trailer, err := reader.GetTrailer()
+	if err != nil || trailer == nil {
+		return
+	}
+	root, ok := core.GetDict(trailer.Get("Root"))
+	if !ok {
+		return
+	}
+	dss, ok := core.GetDict(root.Get("DSS"))
+	if !ok {
+		return
+	}
+	v.hasDSS = true
+	v.addDSSData(dss, "Certs", "OCSPs", "CRLs")
+
+	v.vri = map[string]bool{}
+	vri, ok := core.GetDict(dss.Get("VRI"))
+	if !ok {
+		return
+	}
+	for _, key := range vri.Keys() {
+		v.vri[strings.ToUpper(string(key))] = true
+		if entry, ok := core.GetDict(vri.Get(key)); ok {
+			v.addDSSData(entry, "Cert", "OCSP", "CRL")
+		}
+	}
+}
+
+// addDSSData adds the certificates, OCSP responses and CRLs of the arrays `certs`, `ocsps` and
+// `crls` of the DSS or VRI dictionary `dict`.
+func (v *verifier) addDSSData(dict *core.PdfObjectDictionary, certs, ocsps, crls core.PdfObjectName) {
+	for _, der := range dssStreams(dict, certs) {
+		if cert, err := x509.ParseCertificate(der); err == nil {
+			v.dssCerts = append(v.dssCerts, cert)
+		}
+	}
+	v.dss.add(dssStreams(dict, ocsps), dssStreams(dict, crls), SourceDSS)
+}
+
+// dssStreams returns the decoded streams of the array `key` of the DSS or VRI dictionary `dict`.
+func dssStreams(dict *core.PdfObjectDictionary, key core.PdfObjectName) [][]byte {
+	arr, ok := core.GetArray(dict.Get(key))
+	if !ok {
+		return nil
+	}
+	var streams [][]byte
+	for _, obj := range arr.Elements() {
+		stream, ok := core.GetStream(obj)
+		if !ok {
+			continue
+		}
+		if b, err := core.DecodeStream(stream); err == nil {
+			streams = append(streams, b)
+		}
+	}
+	return streams
+}
+
+// verifySignature verifies the signature of the validation result `res`.
+func (v *verifier) verifySignature(res model.SignatureValidationResult) Signature {
+	field := res.Fields[0]
+	sig := Signature{
+		Integrity:      res.IsVerified,
+		ValidationTime: v.opts.Now,
+	}
+	sig.Field, _ = field.FullName()
+	if !res.IsVerified {
+		sig.fail("the signed bytes do not match the signature value")
+	}
+	for _, e := range res.Errors {
+		sig.Notes = append(sig.Notes, "integrity: "+e)
+	}
+
+	dict, ok := core.GetDict(field.V)
+	if !ok {
+		sig.indeterminate("no signature dictionary")
+		sig.Verdict = sig.verdict()
+		return sig
+	}
+	sig.SubFilter, _ = core.GetNameVal(dict.Get("SubFilter"))
+	sig.Name = decodedString(dict.Get("Name"))
+	sig.Reason = decodedString(dict.Get("Reason"))
+	sig.Location = decodedString(dict.Get("Location"))
+	if m := decodedString(dict.Get("M")); m != "" {
+		if date, err := model.NewPdfDate(m); err == nil {
+			sig.SigningTime = date.ToGoTime()
+		}
+	}
+
+	ranges, err := v.signedRanges(dict)
+	if err != nil {
+		sig.fail(err.Error())
+	}
+	if arr, ok := core.GetArray(dict.Get("ByteRange")); ok {
+		sig.ByteRange, _ = arr.ToInt64Array()
+	}
+	if n := len(sig.ByteRange); n > 0 && n%2 == 0 {
+		sig.CoversDocument = sig.ByteRange[n-2]+sig.ByteRange[n-1] == int64(len(v.data))
+	}
+	if !sig.CoversDocument {
+		sig.Notes = append(sig.Notes, "the document was updated after the signature")
+	}
+
+	var contents []byte
+	if s, ok := core.GetString(dict.Get("Contents")); ok {
+		contents = s.Bytes()
+	}
+	switch sig.SubFilter {
+	case "adbe.x509.rsa_sha1":
+		v.verifyX509(&sig, dict)
+	case "ETSI.RFC3161":
+		v.verifyDocTimestamp(&sig, contents, ranges)
+	default:
+		v.verifyCMS(&sig, contents)
+	}
+	sig.Verdict = sig.verdict()
+	return sig
+}
+
+// verifyCMS verifies the chain, revocation status and timestamp of the CMS signature value
+// `contents` (adbe.pkcs7.detached, adbe.pkcs7.sha1, ETSI.CAdES.detached).
+func (v *verifier) verifyCMS(sig *Signature, contents []byte) {
+	p7, err := pkcs7.Parse(trimDER(contents))
+	if err != nil {
+		sig.fail(fmt.Sprintf("signature value: %v", err))
+		return
+	}
+	if len(p7.Signers) != 1 {
+		sig.fail(fmt.Sprintf("%d signers in the signature value", len(p7.Signers)))
+		return
+	}
+	signer := p7.Signers[0]
+	var signingTime time.Time
+	if err := p7.UnmarshalSignedAttribute(pkcs7.OIDAttributeSigningTime, &signingTime); err == nil {
+		sig.SigningTime = signingTime
+	}
+
+	data := &revocationData{}
+	data.ocsps = append(data.ocsps, v.dss.ocsps...)
+	data.crls = append(data.crls, v.dss.crls...)
+	var archival revocationInfoArchival
+	if err := p7.UnmarshalSignedAttribute(pkcs7.OIDAttributeAdobeRevocation, &archival); err == nil {
+		data.add(rawBytes(archival.OCSP), rawBytes(archival.CRL), SourceSignature)
+	}
+
+	for _, attr := range signer.UnauthenticatedAttributes {
+		if !attr.Type.Equal(pkcs7.OIDAttributeTimeStampToken) {
+			continue
+		}
+		token, err := parseTimestamp(attr.Value.Bytes)
+		if err != nil {
+			sig.fail(fmt.Sprintf("timestamp token: %v", err))
+			break
+		}
+		sig.Timestamp = v.verifyTimestamp(token, data, signer.EncryptedDigest)
+		break
+	}
+	if ts := sig.Timestamp; ts != nil {
+		switch {
+		case !ts.SignatureValid || !ts.ImprintValid:
+			sig.fail("the timestamp token is not valid for the signature")
+		case !ts.Trusted:
+			sig.indeterminate("the timestamp authority is not trusted")
+		default:
+			sig.ValidationTime = ts.Time
+			if !sig.SigningTime.IsZero() && sig.SigningTime.Sub(ts.Time) > maxSigningTimeSkew {
+				sig.fail(fmt.Sprintf("the claimed signing time %s is after the timestamp time %s",
+					sig.SigningTime.UTC().Format(time.RFC3339), ts.Time.UTC().Format(time.RFC3339)))
+			}
+		}
+	}
+
+	cert := signerCertificate(p7)
+	if cert == nil {
+		sig.indeterminate("signer certificate not found in the signature value")
+		return
+	}
+	v.verifySigner(sig, cert, p7.Certificates, data)
+}
+
+// verifyX509 verifies the chain and revocation status of an adbe.x509.rsa_sha1 signature, whose
+// certificates are in the /Cert entry of the signature dictionary `dict`.
+func (v *verifier) verifyX509(sig *Signature, dict *core.PdfObjectDictionary) {
+	var certs []*x509.Certificate
+	var ders []core.PdfObject
+	if arr, ok := core.GetArray(dict.Get("Cert")); ok {
+		ders = arr.Elements()
+	} else if obj := dict.Get("Cert"); obj != nil {
+		ders = []core.PdfObject{obj}
+	}
+	for _, obj := range ders {
+		s, ok := core.GetString(obj)
+		if !ok {
+			continue
+		}
+		cert, err := x509.ParseCertificate(s.Bytes())
+		if err != nil {
+			sig.Notes = append(sig.Notes, fmt.Sprintf("certificate: %v", err))
+			continue
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		sig.indeterminate("signer certificate not found in the signature dictionary")
+		return
+	}
+	v.verifySigner(sig, certs[0], certs[1:], &v.dss)
+}
+
+// verifyDocTimestamp verifies the document timestamp token `contents` of the signed byte ranges
+// `ranges`. The TSA is the signer.
+func (v *verifier) verifyDocTimestamp(sig *Signature, contents []byte, ranges [][]byte) {
+	token, err := parseTimestamp(contents)
+	if err != nil {
+		sig.fail(fmt.Sprintf("timestamp token: %v", err))
+		return
+	}
+	ts := v.verifyTimestamp(token, &v.dss, ranges...)
+	sig.Timestamp = ts
+	sig.Signer = ts.TSA
+	sig.SigningTime = ts.Time
+	sig.ChainTrusted = ts.ChainTrusted
+	sig.Chain = ts.Chain
+	switch {
+	case !ts.SignatureValid || !ts.ImprintValid:
+		sig.fail("the timestamp token is not valid for the document")
+	case ts.TSA == nil:
+		sig.indeterminate("TSA certificate not found in the timestamp token")
+	default:
+		sig.checkChain(ts.Chain, ts.ChainTrusted, nil)
+	}
+}
+
+// verifySigner verifies the chain of the signer `cert`, with the intermediate certificates
+// `certs`, and its revocation status with `data` at the validation time of `sig`.
+func (v *verifier) verifySigner(sig *Signature, cert *x509.Certificate, certs []*x509.Certificate,
+	data *revocationData) {
+	signer := newCert(cert)
+	sig.Signer = &signer
+	chain, trusted, errs := v.verifyChain(cert, certs, sig.ValidationTime, x509.ExtKeyUsageAny, data)
+	sig.Chain = chain
+	sig.ChainTrusted = trusted
+	sig.checkChain(chain, trusted, errs)
+}
+
+// verifyChain builds the chain of `cert` up to the trust store at time `at` with the intermediate
+// certificates `certs` and those of the DSS, and checks the revocation status of its certificates
+// with `data`. It returns the chain, or only `cert` if the chain cannot be built, whether it was
+// built, and the problems found.
+func (v *verifier) verifyChain(cert *x509.Certificate, certs []*x509.Certificate, at time.Time,
+	usage x509.ExtKeyUsage, data *revocationData) ([]Cert, bool, []string) {
+	intermediates := x509.NewCertPool()
+	for _, c := range certs {
+		intermediates.AddCert(c)
+	}
+	for _, c := range v.dssCerts {
+		intermediates.AddCert(c)
+	}
+	chains, err := cert.Verify(x509.VerifyOptions{
+		Roots:         v.opts.Trust.Pool(),
+		Intermediates: intermediates,
+		CurrentTime:   at,
+		KeyUsages:     []x509.ExtKeyUsage{usage},
+	})
+	if err != nil {
+		return []Cert{newCert(cert)}, false, []string{fmt.Sprintf("certificate chain: %v", err)}
+	}
+
+	path := chains[0]
+	chain := make([]Cert, len(path))
+	var errs []string
+	for i, c := range path {
+		chain[i] = newCert(c)
+		if i == len(path)-1 {
+			// The trust anchor.
+			break
+		}
+		rev := checkRevocation(c, path[i+1], at, data, v.opts)
+		chain[i].Revocation = &rev
+		for _, e := range rev.Errors {
+			errs = append(errs, fmt.Sprintf("%s: %s", c.Subject.CommonName, e))
+		}
+	}
+	return chain, true, errs
+}
+
+// checkChain records the problems of the chain `chain` of the signature, built if `trusted`.
+func (sig *Signature) checkChain(chain []Cert, trusted bool, errs []string) {
+	if !trusted {
+		sig.indeterminate("the certificate chain does not lead to a trusted root")
+	}
+	sig.Notes = append(sig.Notes, errs...)
+	for _, c := range chain {
+		if c.Revocation == nil {
+			continue
+		}
+		switch c.Revocation.Status {
+		case StatusRevoked:
+			sig.fail(fmt.Sprintf("certificate %s is revoked since %s", c.Subject,
+				c.Revocation.RevokedAt.UTC().Format(time.RFC3339)))
+		case StatusUnknown:
+			sig.indeterminate(fmt.Sprintf("no revocation data for certificate %s", c.Subject))
+		}
+	}
+}
+
+// signedRanges returns the byte ranges of the document signed by the signature of `dict`.
+func (v *verifier) signedRanges(dict *core.PdfObjectDictionary) ([][]byte, error) {
+	arr, ok := core.GetArray(dict.Get("ByteRange"))
+	if !ok {
+		return nil, fmt.Errorf("no /ByteRange")
+	}
+	br, err := arr.ToInt64Array()
+	if err != nil || len(br)%2 != 0 {
+		return nil, fmt.Errorf("malformed /ByteRange")
+	}
+	var ranges [][]byte
+	for i := 0; i < len(br); i += 2 {
+		start, n := br[i], br[i+1]
+		if start < 0 || n < 0 || start+n > int64(len(v.data)) {
+			return nil, fmt.Errorf("/ByteRange out of the file")
+		}
+		ranges = append(ranges, v.data[start:start+n])
+	}
+	return ranges, nil
+}
+
+// fail records that the signature is invalid for `reason`.
+func (sig *Signature) fail(reason string) {
+	sig.Reasons = append(sig.Reasons, reason)
+	sig.Verdict = VerdictInvalid
+}
+
+// indeterminate records that the signature cannot be verified for `reason`.
+func (sig *Signature) indeterminate(reason string) {
+	sig.Reasons = append(sig.Reasons, reason)
+	if sig.Verdict != VerdictInvalid {
+		sig.Verdict = VerdictIndeterminate
+	}
+}
+
+// verdict returns the verdict of the signature from the recorded problems.
+func (sig *Signature) verdict() string {
+	if sig.Verdict == "" {
+		return VerdictValid
+	}
+	return sig.Verdict
+}
+
+// worse returns the worse of the verdicts `a` and `b`.
+func worse(a, b string) string {
+	rank := map[string]int{VerdictValid: 0, VerdictIndeterminate: 1, VerdictInvalid: 2}
+	if rank[b] > rank[a] {
+		return b
+	}
+	return a
+}
+
+// newCert describes `cert`.
+func newCert(cert *x509.Certificate) Cert {
+	return Cert{
+		Subject:   cert.Subject.String(),
+		Issuer:    cert.Issuer.String(),
+		Serial:    cert.SerialNumber.Text(16),
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+		SHA256:    fmt.Sprintf("%x", sha256.Sum256(cert.Raw)),
+	}
+}
+
+// decodedString returns the text of the string object `obj`, empty if it is not a string.
+func decodedString(obj core.PdfObject) string {
+	if s, ok := core.GetString(obj); ok {
+		return s.Decoded()
+	}
+	return ""
+}
@@ -0,0 +1,284 @@
+/*
+ * Package testpki is a local test PKI for signing without internet access: a generated root CA
+ * issuing signing certificates, an RFC 3161 timestamp authority (TSA), an OCSP responder and a CRL,
+ * served in-process or by a local server. It is for tests and examples only: the keys are
+ * generated at startup and kept in memory, except the key of the root CA, which can be loaded from
+ * files with LoadCA so that the certificates trusted by the validations stay the same across runs.
+ *
+ * The issued certificates point to the OCSP responder, CRL and CA certificate of the authority at
+ * its URL, so the LTV enabling of signatures fetches their validation data from it:
+ *
+ *	POST /tsr          RFC 3161 timestamp requests
+ *	POST /ocsp         OCSP requests, also GET /ocsp/{base64 request}
+ *	GET  /crl          the CRL of the root CA
+ *	GET  /ca.crt       the root CA certificate
+ */
+
+package testpki
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sync"
+	"time"
+
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// Object identifiers of the extended key usage extension and of the timestamping usage.
+var (
+	oidExtKeyUsage  = asn1.ObjectIdentifier{2, 5, 29, 37}
+	oidTimeStamping = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 3, 8}
+)
+
+// Validity of the certificates.
+const (
+	caValidity   = 10 * 365 * 24 * time.Hour
+	certValidity = 365 * 24 * time.Hour
+)
+
+// Authority is a root CA, with its TSA, OCSP responder and CRL, whose URLs are under URL.
+type Authority struct {
+	// URL is the base URL at which Handler is served, e.g. http://127.0.0.1:8079.
+	URL string
+	// Root is the self-signed root CA certificate, which signs the OCSP responses and the CRL.
+	Root    *x509.Certificate
+	rootKey crypto.Signer
+	// TSACert is the certificate of the TSA, issued by Root.
+	TSACert *x509.Certificate
+	tsaKey  *rsa.PrivateKey
+
+	mu      sync.Mutex
+	serial  int64
+	revoked map[string]time.Time // Revocation times by certificate serial number.
+}
+
+// New returns an authority with a generated root CA and TSA certificate, to be served at `url`.
+func New(url string) (*Authority, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject: pkix.Name{
+			CommonName:   "Test Root CA",
+			Organization: []string{"Test Company"},
+		},
+		NotBefore:             now.Add(-time.Hour).UTC(),
+		NotAfter:              now.Add(caValidity).UTC(),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		return nil, err
+	}
+	root, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return newAuthority(url, root, key, 1)
+}
+
+// NewWithCA returns an authority with the root CA certificate `root` and its key `rootKey`, e.g.
+// loaded by LoadCA, and a generated TSA certificate, to be served at `url`.
+func NewWithCA(url string, root *x509.Certificate, rootKey crypto.Signer) (*Authority, error) {
+	if !root.IsCA || root.KeyUsage&x509.KeyUsageCertSign == 0 {
+		return nil, errors.New("the root certificate is not a CA certificate")
+	}
+	pub, ok := rootKey.Public().(interface{ Equal(crypto.PublicKey) bool })
+	if !ok || !pub.Equal(root.PublicKey) {
+		return nil, errors.New("the key does not match the root certificate")
+	}
+	// The serial numbers start from the current time, so that they differ from those of the
+	// certificates issued by previous runs with the same CA.
+	return newAuthority(url, root, rootKey, time.Now().UnixNano())
+}
+
+// newAuthority returns an authority with the root CA `root` and its key `rootKey`, whose last
+// issued serial number is `serial`, and issues its TSA certificate.
+func newAuthority(url string, root *x509.Certificate, rootKey crypto.Signer, serial int64) (*Authority, error) {
+	a := &Authority{URL: url, Root: root, rootKey: rootKey, serial: serial, revoked: map[string]time.Time{}}
+	var err error
+	a.tsaKey, a.TSACert, err = a.issue("Test Timestamp Authority", true)
+	if err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// LoadCA reads a CA certificate from the PEM file `certPath` and its private key from the PEM
+// file `keyPath`, in PKCS#8, PKCS#1 (RSA) or SEC 1 (EC) form.
+func LoadCA(certPath, keyPath string) (*x509.Certificate, crypto.Signer, error) {
+	data, err := os.ReadFile(certPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != "CERTIFICATE" {
+		return nil, nil, fmt.Errorf("%s: no PEM certificate", certPath)
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", certPath, err)
+	}
+
+	if data, err = os.ReadFile(keyPath); err != nil {
+		return nil, nil, err
+	}
+	if block, _ = pem.Decode(data); block == nil {
+		return nil, nil, fmt.Errorf("%s: no PEM private key", keyPath)
+	}
+	var key any
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		key, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	default:
+		return nil, nil, fmt.Errorf("%s: unsupported PEM block %q", keyPath, block.Type)
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("%s: %w", keyPath, err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return nil, nil, fmt.Errorf("%s: unsupported key type %T", keyPath, key)
+	}
+	return cert, signer, nil
+}
+
+// TSAURL returns the URL of the TSA.
+func (a *Authority) TSAURL() string {
+	return a.URL + "/tsr"
+}
+
+// OCSPURL returns the URL of the OCSP responder.
+func (a *Authority) OCSPURL() string {
+	return a.URL + "/ocsp"
+}
+
+// CRLURL returns the URL of the CRL.
+func (a *Authority) CRLURL() string {
+	return a.URL + "/crl"
+}
+
+// IssueSigner returns a generated key and its document signing certificate `commonName` issued by
+// the root CA, followed by the root CA certificate.
+func (a *Authority) IssueSigner(commonName string) (*rsa.PrivateKey, []*x509.Certificate, error) {
+	key, cert, err := a.issue(commonName, false)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, []*x509.Certificate{cert, a.Root}, nil
+}
+
+// Revoke revokes `cert` at the current time, for the OCSP responses and the CRL.
+func (a *Authority) Revoke(cert *x509.Certificate) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.revoked[cert.SerialNumber.String()] = time.Now().Add(-time.Second)
+}
+
+// revocation returns the revocation time of the certificate of serial number `serial`.
+func (a *Authority) revocation(serial *big.Int) (time.Time, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	t, ok := a.revoked[serial.String()]
+	return t, ok
+}
+
+// issue returns a generated key and its certificate `commonName` issued by the root CA, of a TSA
+// if `tsa` is true: with the critical timestamping extended key usage RFC 3161 requires.
+func (a *Authority) issue(commonName string, tsa bool) (*rsa.PrivateKey, *x509.Certificate, error) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		return nil, nil, err
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: a.nextSerial(),
+		Subject: pkix.Name{
+			CommonName:   commonName,
+			Organization: []string{"Test Company"},
+		},
+		NotBefore:             now.Add(-time.Hour).UTC(),
+		NotAfter:              now.Add(certValidity).UTC(),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageContentCommitment,
+		BasicConstraintsValid: true,
+		OCSPServer:            []string{a.OCSPURL()},
+		CRLDistributionPoints: []string{a.CRLURL()},
+		IssuingCertificateURL: []string{a.URL + "/ca.crt"},
+	}
+	if tsa {
+		// The x509 package does not mark the extended key usage extension critical.
+		value, err := asn1.Marshal([]asn1.ObjectIdentifier{oidTimeStamping})
+		if err != nil {
+			return nil, nil, err
+		}
+		template.ExtraExtensions = []pkix.Extension{{Id: oidExtKeyUsage, Critical: true, Value: value}}
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, a.Root, key.Public(), a.rootKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, nil, err
+	}
+	return key, cert, nil
+}
+
+// nextSerial returns the serial number of the next certificate.
+func (a *Authority) nextSerial() *big.Int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.serial++
+	return big.NewInt(a.serial)
+}
+
+// WriteCertificates writes `certs` to the PEM file `path`.
+func WriteCertificates(path string, certs ...*x509.Certificate) error {
+	var data []byte
+	for _, cert := range certs {
+		data = append(data, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// WriteKey writes `key` to the PKCS#8 PEM file `path`.
+func WriteKey(path string, key crypto.PrivateKey) error {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0600)
+}
+
+// WritePKCS12 writes `key` and its certificate `cert` to the PKCS#12 file `path` protected by
+// `password`. The file has the legacy encryption that golang.org/x/crypto/pkcs12 decodes, and no
+// CA certificates, which it does not accept either.
+func WritePKCS12(path string, key crypto.PrivateKey, cert *x509.Certificate, password string) error {
+	if cert == nil {
+		return errors.New("no certificate")
+	}
+	data, err := pkcs12.LegacyDES.Encode(key, cert, nil, password)
+	if err != nil {
+		return fmt.Errorf("pkcs12: %w", err)
+	}
+	return os.WriteFile(path, data, 0600)
+}
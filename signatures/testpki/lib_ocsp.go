@@ -0,0 +1,131 @@
+/*
+ * OCSP responder and CRL of the root CA.
+ */
+
+package testpki
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+// revocationValidity is the time from the issuance of the OCSP responses and CRLs to their next
+// update.
+const revocationValidity = 24 * time.Hour
+
+// OCSP returns the DER encoded OCSP response of the DER encoded OCSP request `req`, signed by the
+// root CA. The certificates it did not issue get an unauthorized response.
+func (a *Authority) OCSP(req []byte) ([]byte, error) {
+	ocspReq, err := ocsp.ParseRequest(req)
+	if err != nil {
+		return ocsp.MalformedRequestErrorResponse, nil
+	}
+	if !a.issued(ocspReq) {
+		return ocsp.UnauthorizedErrorResponse, nil
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	template := ocsp.Response{
+		Status:       ocsp.Good,
+		SerialNumber: ocspReq.SerialNumber,
+		ThisUpdate:   now,
+		NextUpdate:   now.Add(revocationValidity),
+	}
+	// The root CA only issued the certificates of serial numbers up to the current one.
+	a.mu.Lock()
+	known := ocspReq.SerialNumber.Cmp(big.NewInt(a.serial)) <= 0 && ocspReq.SerialNumber.Sign() > 0
+	a.mu.Unlock()
+	if !known {
+		template.Status = ocsp.Unknown
+	} else if revokedAt, ok := a.revocation(ocspReq.SerialNumber); ok {
+		template.Status = ocsp.Revoked
+		template.RevokedAt = revokedAt
+		template.RevocationReason = ocsp.Unspecified
+	}
+	return ocsp.CreateResponse(a.Root, a.Root, template, a.rootKey)
+}
+
+// issued returns true if `req` is about a certificate issued by the root CA.
+func (a *Authority) issued(req *ocsp.Request) bool {
+	if !req.HashAlgorithm.Available() {
+		return false
+	}
+	var publicKey struct {
+		Algorithm pkix.AlgorithmIdentifier
+		PublicKey asn1.BitString
+	}
+	if _, err := asn1.Unmarshal(a.Root.RawSubjectPublicKeyInfo, &publicKey); err != nil {
+		return false
+	}
+	h := req.HashAlgorithm.New()
+	h.Write(publicKey.PublicKey.RightAlign())
+	keyHash := h.Sum(nil)
+	h = req.HashAlgorithm.New()
+	h.Write(a.Root.RawSubject)
+	nameHash := h.Sum(nil)
+	return bytes.Equal(req.IssuerKeyHash, keyHash) && bytes.Equal(req.IssuerNameHash, nameHash)
+}
+
+// CRL returns the DER encoded CRL of the root CA.
+func (a *Authority) CRL() ([]byte, error) {
+	now := time.Now().UTC().Truncate(time.Second)
+	template := &x509.RevocationList{
+		Number:     big.NewInt(now.Unix()),
+		ThisUpdate: now,
+		NextUpdate: now.Add(revocationValidity),
+	}
+	a.mu.Lock()
+	for serial, revokedAt := range a.revoked {
+		n, _ := new(big.Int).SetString(serial, 10)
+		template.RevokedCertificateEntries = append(template.RevokedCertificateEntries,
+			x509.RevocationListEntry{SerialNumber: n, RevocationTime: revokedAt})
+	}
+	a.mu.Unlock()
+	return x509.CreateRevocationList(rand.Reader, template, a.Root, a.rootKey)
+}
+
+// handleOCSP answers the OCSP requests, of the request bodies or base64 encoded in the paths.
+func (a *Authority) handleOCSP(w http.ResponseWriter, r *http.Request) {
+	var req []byte
+	var err error
+	if r.Method == http.MethodGet {
+		var encoded string
+		if encoded, err = url.PathUnescape(r.PathValue("request")); err == nil {
+			req, err = base64.StdEncoding.DecodeString(encoded)
+		}
+	} else {
+		req, err = io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	resp, err := a.OCSP(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/ocsp-response")
+	w.Write(resp)
+}
+
+// handleCRL serves the CRL.
+func (a *Authority) handleCRL(w http.ResponseWriter, r *http.Request) {
+	crl, err := a.CRL()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/pkix-crl")
+	w.Write(crl)
+}
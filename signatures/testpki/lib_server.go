@@ -0,0 +1,86 @@
+/*
+ * HTTP server of the authority.
+ */
+
+package testpki
+
+import (
+	"crypto"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Handler returns the HTTP handler of the TSA, OCSP responder, CRL and CA certificate.
+func (a *Authority) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /tsr", a.handleTimestamp)
+	mux.HandleFunc("POST /ocsp", a.handleOCSP)
+	// The base64 encoding of the requests can contain slashes.
+	mux.HandleFunc("GET /ocsp/{request...}", a.handleOCSP)
+	mux.HandleFunc("GET /crl", a.handleCRL)
+	mux.HandleFunc("GET /ca.crt", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/pkix-cert")
+		w.Write(a.Root.Raw)
+	})
+	return mux
+}
+
+// Server is an authority served over HTTP.
+type Server struct {
+	*Authority
+	server *http.Server
+}
+
+// Start returns a new authority served in-process on a free port of the loopback interface. Close
+// it when done.
+func Start() (*Server, error) {
+	return Listen("127.0.0.1:0")
+}
+
+// Listen returns a new authority served on `addr`, e.g. localhost:8079. Close it when done.
+func Listen(addr string) (*Server, error) {
+	return listen(addr, New)
+}
+
+// ListenWithCA is like Listen, with the root CA certificate `root` and its key `rootKey`, see
+// NewWithCA.
+func ListenWithCA(addr string, root *x509.Certificate, rootKey crypto.Signer) (*Server, error) {
+	return listen(addr, func(url string) (*Authority, error) {
+		return NewWithCA(url, root, rootKey)
+	})
+}
+
+// listen serves on `addr` the authority returned by `newAuthority` for its URL.
+func listen(addr string, newAuthority func(url string) (*Authority, error)) (*Server, error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	// The URL is known once listening, as the port of `addr` can be 0.
+	authority, err := newAuthority("http://" + listener.Addr().String())
+	if err != nil {
+		listener.Close()
+		return nil, err
+	}
+	s := &Server{
+		Authority: authority,
+		server: &http.Server{
+			Handler:           authority.Handler(),
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+	}
+	go s.server.Serve(listener)
+	return s, nil
+}
+
+// Close stops the server.
+func (s *Server) Close() error {
+	err := s.server.Close()
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,191 @@
+/*
+ * RFC 3161 timestamp authority.
+ */
+
+package testpki
+
+import (
+	"crypto"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/unidoc/pkcs7"
+)
+
+// Object identifiers of the timestamps.
+var (
+	oidTSTInfo = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	// oidTSAPolicy is the policy of the timestamps, under the OID arc reserved for examples.
+	oidTSAPolicy = asn1.ObjectIdentifier{2, 999, 1}
+)
+
+// digestOIDs are the supported digest algorithms of the timestamped imprints.
+var digestOIDs = map[string]crypto.Hash{
+	"1.3.14.3.2.26":          crypto.SHA1,
+	"2.16.840.1.101.3.4.2.1": crypto.SHA256,
+	"2.16.840.1.101.3.4.2.2": crypto.SHA384,
+	"2.16.840.1.101.3.4.2.3": crypto.SHA512,
+}
+
+// PKIStatus values and PKIFailureInfo bits of the timestamp responses.
+const (
+	statusGranted  = 0
+	statusRejected = 2
+
+	failBadAlg        = 0
+	failBadRequest    = 2
+	failBadDataFormat = 5
+)
+
+// maxRequestSize bounds the size of the timestamp and OCSP requests.
+const maxRequestSize = 64 << 10
+
+// messageImprint is the digest of the timestamped data.
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+// timeStampReq is a TimeStampReq of RFC 3161.
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	ReqPolicy      asn1.ObjectIdentifier `asn1:"optional"`
+	Nonce          *big.Int              `asn1:"optional"`
+	CertReq        bool                  `asn1:"optional"`
+	Extensions     []pkix.Extension      `asn1:"optional,tag:0"`
+}
+
+// pkiStatusInfo is the status of a timestamp response.
+type pkiStatusInfo struct {
+	Status   int
+	FailInfo asn1.BitString `asn1:"optional"`
+}
+
+// timeStampResp is a TimeStampResp of RFC 3161.
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+// accuracy is the accuracy of the time of a timestamp.
+type accuracy struct {
+	Seconds int `asn1:"optional"`
+}
+
+// tstInfo is the TSTInfo content of a timestamp token.
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+	Accuracy       accuracy  `asn1:"optional"`
+	Nonce          *big.Int  `asn1:"optional"`
+}
+
+// Timestamp returns the DER encoded timestamp response of the DER encoded timestamp request
+// `req`. The invalid requests get a rejection response.
+func (a *Authority) Timestamp(req []byte) ([]byte, error) {
+	var tsReq timeStampReq
+	if rest, err := asn1.Unmarshal(req, &tsReq); err != nil || len(rest) > 0 {
+		return rejection(failBadDataFormat)
+	}
+	hash, ok := digestOIDs[tsReq.MessageImprint.HashAlgorithm.Algorithm.String()]
+	if !ok {
+		return rejection(failBadAlg)
+	}
+	if tsReq.Version != 1 || len(tsReq.MessageImprint.HashedMessage) != hash.Size() {
+		return rejection(failBadRequest)
+	}
+
+	info, err := asn1.Marshal(tstInfo{
+		Version:        1,
+		Policy:         oidTSAPolicy,
+		MessageImprint: tsReq.MessageImprint,
+		SerialNumber:   a.nextSerial(),
+		GenTime:        time.Now().UTC().Truncate(time.Second),
+		Accuracy:       accuracy{Seconds: 1},
+		Nonce:          tsReq.Nonce,
+	})
+	if err != nil {
+		return nil, err
+	}
+	signedData, err := pkcs7.NewSignedDataWithContentType(oidTSTInfo, info)
+	if err != nil {
+		return nil, err
+	}
+	signedData.SetDigestAlgorithm(pkcs7.OIDDigestAlgorithmSHA256)
+	// The certificates of the TSA are only included if requested.
+	var token []byte
+	if tsReq.CertReq {
+		if err := signedData.AddSigner(a.TSACert, a.tsaKey, pkcs7.SignerInfoConfig{}); err != nil {
+			return nil, err
+		}
+		signedData.AddCertificate(a.Root)
+		token, err = signedData.Finish()
+	} else {
+		if err := signedData.AddSignerNoChain(a.TSACert, a.tsaKey, pkcs7.SignerInfoConfig{}); err != nil {
+			return nil, err
+		}
+		token, err = finishWithoutCertificates(signedData)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(timeStampResp{
+		Status:         pkiStatusInfo{Status: statusGranted},
+		TimeStampToken: asn1.RawValue{FullBytes: token},
+	})
+}
+
+// contentInfo is a CMS ContentInfo.
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+// finishWithoutCertificates returns the DER encoding of `signedData` without its certificates
+// field. Finish writes an empty certificates field, which pkcs7.Parse then rejects.
+func finishWithoutCertificates(signedData *pkcs7.SignedData) ([]byte, error) {
+	inner, err := asn1.Marshal(*signedData.GetSignedData())
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(contentInfo{
+		ContentType: pkcs7.OIDSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, Bytes: inner, IsCompound: true},
+	})
+}
+
+// rejection returns a rejection response with the failure information bit `failure`.
+func rejection(failure int) ([]byte, error) {
+	bits := make([]byte, 1)
+	bits[0] = 0x80 >> failure
+	return asn1.Marshal(timeStampResp{
+		Status: pkiStatusInfo{
+			Status:   statusRejected,
+			FailInfo: asn1.BitString{Bytes: bits, BitLength: failure + 1},
+		},
+	})
+}
+
+// handleTimestamp answers the timestamp requests.
+func (a *Authority) handleTimestamp(w http.ResponseWriter, r *http.Request) {
+	req, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxRequestSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	resp, err := a.Timestamp(req)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/timestamp-reply")
+	w.Write(resp)
+}
@@ -0,0 +1,364 @@
+/*
+ * Tests of the TSA, OCSP responder and CRL of the test PKI, served by a local server. They need no
+ * license key nor internet access.
+ *
+ * Run as: go test ./signatures/testpki/
+ */
+
+package testpki
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"net/http"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/unidoc/pkcs7"
+	"golang.org/x/crypto/ocsp"
+)
+
+var (
+	serverOnce sync.Once
+	server     *Server
+	serverErr  error
+)
+
+// testServer returns the server shared by the tests, as generating the keys takes a while.
+func testServer(t *testing.T) *Server {
+	t.Helper()
+	serverOnce.Do(func() {
+		server, serverErr = Start()
+	})
+	if serverErr != nil {
+		t.Fatal(serverErr)
+	}
+	return server
+}
+
+// post sends `body` to `url` and returns the response body.
+func post(t *testing.T, url, contentType string, body []byte) []byte {
+	t.Helper()
+	resp, err := http.Post(url, contentType, bytes.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return readBody(t, resp)
+}
+
+// get returns the response body of `url`.
+func get(t *testing.T, url string) []byte {
+	t.Helper()
+	resp, err := http.Get(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return readBody(t, resp)
+}
+
+// readBody returns the body of the successful response `resp`.
+func readBody(t *testing.T, resp *http.Response) []byte {
+	t.Helper()
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("%s: %s", resp.Status, body)
+	}
+	return body
+}
+
+// rootPool returns a pool of the root CA certificate of `s`.
+func rootPool(s *Server) *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(s.Root)
+	return pool
+}
+
+func TestTimestamp(t *testing.T) {
+	s := testServer(t)
+	sha256Digest := sha256.Sum256([]byte("document"))
+	sha512Digest := sha512.Sum512([]byte("document"))
+	tests := []struct {
+		name      string
+		algorithm asn1.ObjectIdentifier
+		digest    []byte
+		certReq   bool
+	}{
+		{"sha256", asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}, sha256Digest[:], true},
+		{"sha512", asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}, sha512Digest[:], true},
+		{"no certificate", asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}, sha256Digest[:], false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			nonce := big.NewInt(time.Now().UnixNano())
+			req, err := asn1.Marshal(timeStampReq{
+				Version: 1,
+				MessageImprint: messageImprint{
+					HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: test.algorithm, Parameters: asn1.NullRawValue},
+					HashedMessage: test.digest,
+				},
+				Nonce:   nonce,
+				CertReq: test.certReq,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			body := post(t, s.TSAURL(), "application/timestamp-query", req)
+
+			var resp timeStampResp
+			if _, err := asn1.Unmarshal(body, &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Status.Status != statusGranted {
+				t.Fatalf("status %d, want %d", resp.Status.Status, statusGranted)
+			}
+			p7, err := pkcs7.Parse(resp.TimeStampToken.FullBytes)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var info tstInfo
+			if _, err := asn1.Unmarshal(p7.Content, &info); err != nil {
+				t.Fatal(err)
+			}
+			if !bytes.Equal(info.MessageImprint.HashedMessage, test.digest) {
+				t.Error("token of another digest")
+			}
+			if info.Nonce == nil || info.Nonce.Cmp(nonce) != 0 {
+				t.Errorf("nonce %v, want %v", info.Nonce, nonce)
+			}
+			if d := time.Since(info.GenTime); d < -time.Second || d > time.Minute {
+				t.Errorf("generation time %s, now %s", info.GenTime, time.Now())
+			}
+			if !test.certReq {
+				if len(p7.Certificates) != 0 {
+					t.Errorf("%d certificates without certReq", len(p7.Certificates))
+				}
+				p7.Certificates = []*x509.Certificate{s.TSACert}
+			}
+			if err := p7.VerifyWithChain(rootPool(s)); err != nil {
+				t.Errorf("verify: %v", err)
+			}
+		})
+	}
+}
+
+func TestTimestampRejection(t *testing.T) {
+	s := testServer(t)
+	tests := []struct {
+		name    string
+		req     []byte
+		failure int
+	}{
+		{"garbage", []byte("not a request"), failBadDataFormat},
+		{"md5", mustMarshal(t, timeStampReq{
+			Version: 1,
+			MessageImprint: messageImprint{
+				HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 5}},
+				HashedMessage: make([]byte, 16),
+			},
+		}), failBadAlg},
+		{"digest length", mustMarshal(t, timeStampReq{
+			Version: 1,
+			MessageImprint: messageImprint{
+				HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}},
+				HashedMessage: make([]byte, 20),
+			},
+		}), failBadRequest},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body := post(t, s.TSAURL(), "application/timestamp-query", test.req)
+			var resp timeStampResp
+			if _, err := asn1.Unmarshal(body, &resp); err != nil {
+				t.Fatal(err)
+			}
+			if resp.Status.Status != statusRejected {
+				t.Fatalf("status %d, want %d", resp.Status.Status, statusRejected)
+			}
+			if resp.Status.FailInfo.At(test.failure) != 1 {
+				t.Errorf("failure info %x, want bit %d", resp.Status.FailInfo.Bytes, test.failure)
+			}
+			if len(resp.TimeStampToken.FullBytes) != 0 {
+				t.Error("token in a rejection")
+			}
+		})
+	}
+}
+
+func mustMarshal(t *testing.T, v any) []byte {
+	t.Helper()
+	der, err := asn1.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return der
+}
+
+func TestOCSP(t *testing.T) {
+	s := testServer(t)
+	_, chain, err := s.IssueSigner("OCSP Test Signer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := chain[0]
+	if len(cert.OCSPServer) != 1 || cert.OCSPServer[0] != s.OCSPURL() {
+		t.Fatalf("OCSP server %v, want %s", cert.OCSPServer, s.OCSPURL())
+	}
+	req, err := ocsp.CreateRequest(cert, s.Root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// status returns the status of `cert` answered to a POST request, or a GET request if `useGET`.
+	status := func(useGET bool) int {
+		var body []byte
+		if useGET {
+			body = get(t, s.OCSPURL()+"/"+base64.StdEncoding.EncodeToString(req))
+		} else {
+			body = post(t, cert.OCSPServer[0], "application/ocsp-request", req)
+		}
+		resp, err := ocsp.ParseResponseForCert(body, cert, s.Root)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.NextUpdate.Before(time.Now()) {
+			t.Errorf("next update %s in the past", resp.NextUpdate)
+		}
+		return resp.Status
+	}
+	for _, useGET := range []bool{false, true} {
+		if got := status(useGET); got != ocsp.Good {
+			t.Errorf("GET %t: status %d, want good", useGET, got)
+		}
+	}
+	s.Revoke(cert)
+	for _, useGET := range []bool{false, true} {
+		if got := status(useGET); got != ocsp.Revoked {
+			t.Errorf("GET %t: status %d after revocation, want revoked", useGET, got)
+		}
+	}
+
+	// Another issuer's certificate.
+	other, err := New("http://localhost")
+	if err != nil {
+		t.Fatal(err)
+	}
+	req, err = ocsp.CreateRequest(other.TSACert, other.Root, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := post(t, s.OCSPURL(), "application/ocsp-request", req)
+	if !bytes.Equal(body, ocsp.UnauthorizedErrorResponse) {
+		t.Errorf("response %x to another issuer, want unauthorized", body)
+	}
+}
+
+func TestCRL(t *testing.T) {
+	s := testServer(t)
+	_, chain, err := s.IssueSigner("CRL Test Signer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert := chain[0]
+	s.Revoke(cert)
+
+	crl, err := x509.ParseRevocationList(get(t, cert.CRLDistributionPoints[0]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := crl.CheckSignatureFrom(s.Root); err != nil {
+		t.Fatal(err)
+	}
+	revoked := false
+	for _, entry := range crl.RevokedCertificateEntries {
+		if entry.SerialNumber.Cmp(cert.SerialNumber) == 0 {
+			revoked = true
+		}
+	}
+	if !revoked {
+		t.Error("revoked certificate not in the CRL")
+	}
+	if crl.NextUpdate.Before(time.Now()) {
+		t.Errorf("next update %s in the past", crl.NextUpdate)
+	}
+}
+
+func TestCertificates(t *testing.T) {
+	s := testServer(t)
+	_, chain, err := s.IssueSigner("Chain Test Signer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chain[0].Verify(x509.VerifyOptions{
+		Roots:     rootPool(s),
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}); err != nil {
+		t.Errorf("signer: %v", err)
+	}
+	if _, err := s.TSACert.Verify(x509.VerifyOptions{
+		Roots:     rootPool(s),
+		KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageTimeStamping},
+	}); err != nil {
+		t.Errorf("TSA: %v", err)
+	}
+	if root, err := x509.ParseCertificate(get(t, s.URL+"/ca.crt")); err != nil || !root.Equal(s.Root) {
+		t.Errorf("served CA certificate %v, %v", root, err)
+	}
+}
+
+func TestLoadCA(t *testing.T) {
+	s := testServer(t)
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "ca.pem"), filepath.Join(dir, "ca_key.pem")
+	if err := WriteCertificates(certPath, s.Root); err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteKey(keyPath, s.rootKey); err != nil {
+		t.Fatal(err)
+	}
+
+	root, key, err := LoadCA(certPath, keyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := NewWithCA("http://127.0.0.1:8079", root, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, chain, err := a.IssueSigner("Loaded CA Test Signer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The certificates of the new authority are trusted with the root CA of the first one.
+	roots := x509.NewCertPool()
+	roots.AddCert(s.Root)
+	for _, cert := range []*x509.Certificate{chain[0], a.TSACert} {
+		_, err := cert.Verify(x509.VerifyOptions{Roots: roots, KeyUsages: []x509.ExtKeyUsage{x509.ExtKeyUsageAny}})
+		if err != nil {
+			t.Errorf("%s: %v", cert.Subject.CommonName, err)
+		}
+	}
+
+	if _, err := NewWithCA("", chain[0], key); err == nil {
+		t.Error("a signing certificate was accepted as a CA")
+	}
+	otherKey, _, err := a.IssueSigner("Other Key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewWithCA("", root, otherKey); err == nil {
+		t.Error("a key not matching the CA certificate was accepted")
+	}
+}
@@ -0,0 +1,232 @@
+/*
+ * Comparison of two runs of a corpus benchmark.
+ */
+
+package corpus
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// CompareOptions are the thresholds of the regressions.
+type CompareOptions struct {
+	// MaxSlowdown is the largest accepted ratio of the head and base durations of a file, and of
+	// the total duration of the files passing in both runs. Defaults to 1.25.
+	MaxSlowdown float64
+	// MinSeconds is the duration below which the slowdowns of a file are ignored, as they are
+	// dominated by noise. Defaults to 0.1.
+	MinSeconds float64
+	// MaxHeapGrowth is the largest accepted ratio of the head and base peak heaps of a file.
+	// Defaults to 1.5.
+	MaxHeapGrowth float64
+	// MinHeap is the peak heap in bytes below which the heap growths of a file are ignored.
+	// Defaults to 16 MiB.
+	MinHeap uint64
+}
+
+// Change kinds.
+const (
+	ChangeNewlyFailing = "newly failing" // Passing in base, not in head, or abnormal in head only.
+	ChangeFixed        = "fixed"         // Not passing in base, passing in head.
+	ChangeSlower       = "slower"
+	ChangeFaster       = "faster"
+	ChangeMoreMemory   = "more memory"
+	ChangeTotalSlower  = "total slower"
+)
+
+// Change is a difference between the results of a file in two runs.
+type Change struct {
+	Kind  string  `json:"kind"`
+	File  string  `json:"file,omitempty"`
+	Base  *Result `json:"base,omitempty"`
+	Head  *Result `json:"head,omitempty"`
+	Ratio float64 `json:"ratio,omitempty"` // Head to base ratio of the duration or of the peak heap.
+}
+
+func (c Change) String() string {
+	switch c.Kind {
+	case ChangeNewlyFailing, ChangeFixed:
+		s := fmt.Sprintf("%s: %s (%s -> %s)", c.Kind, c.File, c.Base.Outcome, c.Head.Outcome)
+		if c.Head.Error != "" && c.Kind == ChangeNewlyFailing {
+			s += ": " + firstLine(c.Head.Error)
+		}
+		return s
+	case ChangeSlower, ChangeFaster:
+		return fmt.Sprintf("%s: %s %.3fs -> %.3fs (x%.2f)", c.Kind, c.File, c.Base.Seconds, c.Head.Seconds, c.Ratio)
+	case ChangeMoreMemory:
+		return fmt.Sprintf("%s: %s %.1f MiB -> %.1f MiB (x%.2f)", c.Kind, c.File, mib(c.Base.PeakHeap),
+			mib(c.Head.PeakHeap), c.Ratio)
+	}
+	return fmt.Sprintf("%s: x%.2f", c.Kind, c.Ratio)
+}
+
+// Comparison is the comparison of a head run with a base run.
+type Comparison struct {
+	Base         Summary  `json:"base"`
+	Head         Summary  `json:"head"`
+	BaseVersion  string   `json:"base_version"`
+	HeadVersion  string   `json:"head_version"`
+	BaseSeconds  float64  `json:"base_seconds"` // Total duration of the files passing in both runs.
+	HeadSeconds  float64  `json:"head_seconds"`
+	Regressions  []Change `json:"regressions"`
+	Improvements []Change `json:"improvements"`
+	Added        []string `json:"added"`   // Files only in head.
+	Removed      []string `json:"removed"` // Files only in base.
+}
+
+// HasRegressions returns true if `c` has regressions.
+func (c *Comparison) HasRegressions() bool {
+	return len(c.Regressions) > 0
+}
+
+// Compare compares the run `head` with the run `base`. The files are matched by path.
+func Compare(base, head *Run, opts CompareOptions) *Comparison {
+	if opts.MaxSlowdown <= 0 {
+		opts.MaxSlowdown = 1.25
+	}
+	if opts.MinSeconds <= 0 {
+		opts.MinSeconds = 0.1
+	}
+	if opts.MaxHeapGrowth <= 0 {
+		opts.MaxHeapGrowth = 1.5
+	}
+	if opts.MinHeap == 0 {
+		opts.MinHeap = 16 << 20
+	}
+
+	c := &Comparison{
+		Base:         base.Summary(),
+		Head:         head.Summary(),
+		BaseVersion:  base.Version,
+		HeadVersion:  head.Version,
+		Regressions:  []Change{},
+		Improvements: []Change{},
+		Added:        []string{},
+		Removed:      []string{},
+	}
+
+	baseResults := map[string]*Result{}
+	for i := range base.Results {
+		baseResults[base.Results[i].File] = &base.Results[i]
+	}
+	headFiles := map[string]bool{}
+	for i := range head.Results {
+		h := &head.Results[i]
+		headFiles[h.File] = true
+		b, ok := baseResults[h.File]
+		if !ok {
+			c.Added = append(c.Added, h.File)
+			continue
+		}
+		c.compareResults(b, h, opts)
+	}
+	for _, res := range base.Results {
+		if !headFiles[res.File] {
+			c.Removed = append(c.Removed, res.File)
+		}
+	}
+
+	if c.BaseSeconds > 0 {
+		ratio := c.HeadSeconds / c.BaseSeconds
+		if ratio > opts.MaxSlowdown {
+			c.Regressions = append(c.Regressions, Change{Kind: ChangeTotalSlower, Ratio: ratio})
+		}
+	}
+
+	// Worst first.
+	sort.SliceStable(c.Regressions, func(i, j int) bool {
+		return changeRank(c.Regressions[i]) < changeRank(c.Regressions[j])
+	})
+	return c
+}
+
+// compareResults adds the changes between the results `b` and `h` of a file.
+func (c *Comparison) compareResults(b, h *Result, opts CompareOptions) {
+	switch {
+	case b.Outcome == OutcomePass && h.Outcome != OutcomePass,
+		!b.Outcome.Abnormal() && h.Outcome.Abnormal():
+		c.Regressions = append(c.Regressions, Change{Kind: ChangeNewlyFailing, File: h.File, Base: b, Head: h})
+		return
+	case b.Outcome != OutcomePass && h.Outcome == OutcomePass:
+		c.Improvements = append(c.Improvements, Change{Kind: ChangeFixed, File: h.File, Base: b, Head: h})
+		return
+	case b.Outcome != OutcomePass:
+		return
+	}
+
+	c.BaseSeconds += b.Seconds
+	c.HeadSeconds += h.Seconds
+	if b.Seconds > 0 && max(b.Seconds, h.Seconds) >= opts.MinSeconds {
+		ratio := h.Seconds / b.Seconds
+		switch {
+		case ratio > opts.MaxSlowdown:
+			c.Regressions = append(c.Regressions, Change{Kind: ChangeSlower, File: h.File, Base: b, Head: h, Ratio: ratio})
+		case ratio < 1/opts.MaxSlowdown:
+			c.Improvements = append(c.Improvements, Change{Kind: ChangeFaster, File: h.File, Base: b, Head: h, Ratio: ratio})
+		}
+	}
+	if b.PeakHeap > 0 && h.PeakHeap >= opts.MinHeap {
+		ratio := float64(h.PeakHeap) / float64(b.PeakHeap)
+		if ratio > opts.MaxHeapGrowth {
+			c.Regressions = append(c.Regressions, Change{Kind: ChangeMoreMemory, File: h.File, Base: b, Head: h, Ratio: ratio})
+		}
+	}
+}
+
+func changeRank(c Change) int {
+	switch c.Kind {
+	case ChangeNewlyFailing:
+		return 0
+	case ChangeTotalSlower:
+		return 1
+	case ChangeSlower:
+		return 2
+	}
+	return 3
+}
+
+// WriteText writes `c` as text to `w`.
+func (c *Comparison) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "base: UniPDF %s, %s\n", c.BaseVersion, c.Base)
+	fmt.Fprintf(w, "head: UniPDF %s, %s\n", c.HeadVersion, c.Head)
+	if c.BaseSeconds > 0 {
+		fmt.Fprintf(w, "time of the files passing in both: %.1fs -> %.1fs (x%.2f)\n", c.BaseSeconds, c.HeadSeconds,
+			c.HeadSeconds/c.BaseSeconds)
+	}
+	if len(c.Added) > 0 || len(c.Removed) > 0 {
+		fmt.Fprintf(w, "%d files added, %d removed\n", len(c.Added), len(c.Removed))
+	}
+
+	fmt.Fprintf(w, "%d regressions\n", len(c.Regressions))
+	for _, change := range c.Regressions {
+		fmt.Fprintf(w, "  %s\n", change)
+	}
+	fmt.Fprintf(w, "%d improvements\n", len(c.Improvements))
+	for _, change := range c.Improvements {
+		fmt.Fprintf(w, "  %s\n", change)
+	}
+	return nil
+}
+
+// WriteJSON writes `c` as indented JSON to `w`.
+func (c *Comparison) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(c)
+}
+
+func mib(n uint64) float64 {
+	return float64(n) / (1 << 20)
+}
+
+func firstLine(s string) string {
+	for i, r := range s {
+		if r == '\n' {
+			return s[:i]
+		}
+	}
+	return s
+}
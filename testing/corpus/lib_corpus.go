@@ -0,0 +1,249 @@
+/*
+ * Machine readable results of the corpus benchmarks (testing/*_bench.go).
+ *
+ * A Run records for each file of a corpus its outcome, processing time and peak memory, with the
+ * versions of UniPDF and Go, and is saved as JSON. Two runs are compared with Compare, e.g. to gate
+ * a library upgrade on the absence of newly failing files and of slowdowns.
+ */
+
+package corpus
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/unidoc/unipdf/v4/common"
+)
+
+// Outcome is the outcome of the processing of a file.
+type Outcome string
+
+// Outcomes. The meaning of fail and bad depends on the benchmark, bad usually means that UniPDF
+// returned an error and fail that its output did not pass the checks.
+const (
+	OutcomePass  Outcome = "pass"
+	OutcomeFail  Outcome = "fail"
+	OutcomeBad   Outcome = "bad"
+	OutcomeCrash Outcome = "crash" // The processing panicked or the process died.
+
+	// Outcomes of isolated runs only, see MeasureIsolated.
+	OutcomeTimeout Outcome = "timeout" // The processing exceeded the time limit.
+	OutcomeMemory  Outcome = "memory"  // The processing exceeded the memory limit.
+)
+
+// Abnormal returns true if `o` is an outcome where the processing did not complete.
+func (o Outcome) Abnormal() bool {
+	return o == OutcomeCrash || o == OutcomeTimeout || o == OutcomeMemory
+}
+
+// Result is the result of the processing of a file.
+type Result struct {
+	File     string  `json:"file"`
+	Size     int64   `json:"size"`
+	Outcome  Outcome `json:"outcome"`
+	Error    string  `json:"error,omitempty"`
+	Seconds  float64 `json:"seconds"`
+	PeakHeap uint64  `json:"peak_heap"`          // Peak Go heap in bytes.
+	PeakRSS  uint64  `json:"peak_rss,omitempty"` // Peak resident set size in bytes, Linux only.
+	Stack    string  `json:"stack,omitempty"`    // Stack trace of a crash or timeout.
+	// CrashReport is the path of the crash report of an abnormal outcome, see Run.CrashDir.
+	CrashReport string `json:"crash_report,omitempty"`
+}
+
+// Run is the results of a benchmark over a corpus.
+type Run struct {
+	Tool      string    `json:"tool"`
+	Version   string    `json:"version"` // UniPDF version.
+	GoVersion string    `json:"go_version"`
+	OS        string    `json:"os"`
+	Arch      string    `json:"arch"`
+	Started   time.Time `json:"started"`
+	Args      []string  `json:"args"`
+	Results   []Result  `json:"results"`
+
+	// CrashDir is the directory where the crash reports of the files with an abnormal outcome are
+	// saved by Measure and MeasureIsolated. No reports are saved if empty.
+	CrashDir string `json:"crash_dir,omitempty"`
+}
+
+// Summary is the totals of a run.
+type Summary struct {
+	Files   int     `json:"files"`
+	Pass    int     `json:"pass"`
+	Fail    int     `json:"fail"`
+	Bad     int     `json:"bad"`
+	Crash   int     `json:"crash"`
+	Timeout int     `json:"timeout"`
+	Memory  int     `json:"memory"`
+	Seconds float64 `json:"seconds"`
+}
+
+// NewRun returns an empty run of the benchmark `tool`.
+func NewRun(tool string) *Run {
+	return &Run{
+		Tool:      tool,
+		Version:   common.Version,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+		Started:   time.Now().UTC(),
+		Args:      os.Args[1:],
+		Results:   []Result{},
+	}
+}
+
+// heapSampleInterval is the interval of the sampling of the heap size.
+const heapSampleInterval = 10 * time.Millisecond
+
+// Measure processes `file` with `task` and returns the result, measuring its duration and peak
+// memory use. A panic of `task` is recovered as a crash. The result is not added to the run, as
+// the caller may change its outcome after further checks.
+// Measure cannot stop a `task` that loops or exhausts the memory, use MeasureIsolated for untrusted
+// files.
+func (r *Run) Measure(file string, task func() (Outcome, error)) Result {
+	res := Result{File: file}
+	if fi, err := os.Stat(file); err == nil {
+		res.Size = fi.Size()
+	}
+
+	runtime.GC()
+	resetPeakRSS()
+	var mu sync.Mutex
+	var peakHeap uint64
+	sample := func() {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		mu.Lock()
+		peakHeap = max(peakHeap, ms.HeapAlloc)
+		mu.Unlock()
+	}
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(heapSampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				sample()
+			}
+		}
+	}()
+
+	start := time.Now()
+	outcome, stack, err := runTask(task)
+	res.Seconds = time.Since(start).Seconds()
+	close(done)
+	<-stopped
+	sample()
+
+	res.Outcome = outcome
+	if err != nil {
+		res.Error = err.Error()
+	}
+	res.Stack = stack
+	res.PeakHeap = peakHeap
+	res.PeakRSS = peakRSS()
+	r.saveCrashReport(&res)
+	return res
+}
+
+// runTask runs `task`, recovering a panic as a crash with its stack trace.
+func runTask(task func() (Outcome, error)) (outcome Outcome, stack string, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			outcome = OutcomeCrash
+			err = fmt.Errorf("panic: %v", p)
+			stack = string(debug.Stack())
+		}
+	}()
+	outcome, err = task()
+	return outcome, "", err
+}
+
+// saveCrashReport saves the crash report of `res` to r.CrashDir if it has an abnormal outcome.
+func (r *Run) saveCrashReport(res *Result) {
+	if r.CrashDir == "" || !res.Outcome.Abnormal() {
+		return
+	}
+	if err := os.MkdirAll(r.CrashDir, 0755); err != nil {
+		common.Log.Error("Crash report of %s not saved: %v", res.File, err)
+		return
+	}
+	f, err := os.CreateTemp(r.CrashDir, filepath.Base(res.File)+".*."+string(res.Outcome)+".txt")
+	if err != nil {
+		common.Log.Error("Crash report of %s not saved: %v", res.File, err)
+		return
+	}
+	fmt.Fprintf(f, "file: %s\noutcome: %s\nerror: %s\nversion: %s\nseconds: %.3f\npeak heap: %d\n\n%s",
+		res.File, res.Outcome, res.Error, r.Version, res.Seconds, res.PeakHeap, res.Stack)
+	if err := f.Close(); err != nil {
+		common.Log.Error("Crash report of %s not saved: %v", res.File, err)
+		return
+	}
+	res.CrashReport = f.Name()
+}
+
+// Add adds `res` to `r`.
+func (r *Run) Add(res Result) {
+	r.Results = append(r.Results, res)
+}
+
+func (s Summary) String() string {
+	return fmt.Sprintf("%d files, %d pass, %d fail, %d bad, %d crash, %d timeout, %d memory", s.Files, s.Pass,
+		s.Fail, s.Bad, s.Crash, s.Timeout, s.Memory)
+}
+
+// Summary returns the totals of `r`.
+func (r *Run) Summary() Summary {
+	s := Summary{Files: len(r.Results)}
+	for _, res := range r.Results {
+		switch res.Outcome {
+		case OutcomePass:
+			s.Pass++
+		case OutcomeFail:
+			s.Fail++
+		case OutcomeBad:
+			s.Bad++
+		case OutcomeCrash:
+			s.Crash++
+		case OutcomeTimeout:
+			s.Timeout++
+		case OutcomeMemory:
+			s.Memory++
+		}
+		s.Seconds += res.Seconds
+	}
+	return s
+}
+
+// WriteFile writes `r` as indented JSON to `path`.
+func (r *Run) WriteFile(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(data, '\n'), 0644)
+}
+
+// ReadRun reads a run saved by WriteFile from `path`.
+func ReadRun(path string) (*Run, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r Run
+	if err := json.Unmarshal(data, &r); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &r, nil
+}
@@ -0,0 +1,332 @@
+/*
+ * Isolation of the processing of the files of a corpus in subprocesses, with time and memory limits.
+ *
+ * A malformed file can make the processing panic, loop or exhaust the memory. Measure recovers the
+ * panics, but a loop or a fatal runtime error (stack overflow, out of memory, concurrent map writes)
+ * stops or hangs the whole run. With isolation the tool re-executes itself for each file, with the
+ * same arguments: the parent calls Run.MeasureIsolated and the child, detected by ServeWorker
+ * early in main, processes the file, reports its result and exits.
+ */
+
+package corpus
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Environment of the worker processes.
+const (
+	workerFileEnv    = "UNIDOC_CORPUS_WORKER_FILE"
+	workerResultEnv  = "UNIDOC_CORPUS_WORKER_RESULT"
+	workerTimeoutEnv = "UNIDOC_CORPUS_WORKER_TIMEOUT"
+	workerMemoryEnv  = "UNIDOC_CORPUS_WORKER_MAX_MEMORY"
+)
+
+// killGrace is the time given to a worker past its time limit before it is killed, and to a killed
+// worker to dump its stacks.
+const killGrace = 5 * time.Second
+
+// stderrTail is the number of bytes of the standard error of a worker kept for its stack trace.
+const stderrTail = 64 * 1024
+
+// Limits are the limits of the processing of a file in a worker.
+type Limits struct {
+	Timeout   time.Duration // No limit if 0.
+	MaxMemory uint64        // Go heap in bytes. No limit if 0.
+}
+
+// WorkerTask processes `file` in a worker. `data` is passed as JSON to the parent.
+type WorkerTask func(file string) (outcome Outcome, data any, err error)
+
+// workerResult is the message of a worker to its parent.
+type workerResult struct {
+	Result Result          `json:"result"`
+	Data   json.RawMessage `json:"data,omitempty"`
+}
+
+// IsWorker returns true if the process is a worker started by MeasureIsolated.
+func IsWorker() bool {
+	return os.Getenv(workerFileEnv) != ""
+}
+
+// ServeWorker processes the file of the worker with `task` and exits if the process is a worker,
+// and returns otherwise. It must be called in main after the arguments are parsed and before the
+// files are processed, and before any side effect that the workers must not repeat.
+func ServeWorker(task WorkerTask) {
+	if !IsWorker() {
+		return
+	}
+	file := os.Getenv(workerFileEnv)
+	resultPath := os.Getenv(workerResultEnv)
+	limits, err := workerLimits()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "corpus worker: %v\n", err)
+		os.Exit(2)
+	}
+
+	var once sync.Once
+	finish := func(res Result, data any) {
+		// The first call exits, a concurrent one blocks until then.
+		once.Do(func() {
+			if err := writeWorkerResult(resultPath, res, data); err != nil {
+				fmt.Fprintf(os.Stderr, "corpus worker: %v\n", err)
+				os.Exit(2)
+			}
+			os.Exit(0)
+		})
+	}
+
+	run := &Run{}
+	start := time.Now()
+	go watchLimits(limits, func(outcome Outcome, err error) {
+		var ms runtime.MemStats
+		runtime.ReadMemStats(&ms)
+		finish(Result{
+			File:     file,
+			Outcome:  outcome,
+			Error:    err.Error(),
+			Seconds:  time.Since(start).Seconds(),
+			PeakHeap: ms.HeapAlloc,
+			PeakRSS:  peakRSS(),
+			Stack:    allStacks(),
+		}, nil)
+	})
+
+	var data any
+	res := run.Measure(file, func() (Outcome, error) {
+		outcome, d, err := task(file)
+		data = d
+		return outcome, err
+	})
+	finish(res, data)
+}
+
+// watchLimits calls `exceeded` when the process exceeds `limits`.
+func watchLimits(limits Limits, exceeded func(Outcome, error)) {
+	if limits.MaxMemory > 0 {
+		// Make the garbage collector work harder before the limit is reached.
+		debug.SetMemoryLimit(int64(limits.MaxMemory))
+	}
+	var deadline <-chan time.Time
+	if limits.Timeout > 0 {
+		deadline = time.After(limits.Timeout)
+	}
+	ticker := time.NewTicker(heapSampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-deadline:
+			exceeded(OutcomeTimeout, fmt.Errorf("timeout after %s", limits.Timeout))
+		case <-ticker.C:
+			if limits.MaxMemory == 0 {
+				continue
+			}
+			var ms runtime.MemStats
+			runtime.ReadMemStats(&ms)
+			if ms.HeapAlloc > limits.MaxMemory {
+				exceeded(OutcomeMemory, fmt.Errorf("heap %d MiB exceeds the limit of %d MiB",
+					ms.HeapAlloc>>20, limits.MaxMemory>>20))
+			}
+		}
+	}
+}
+
+// MeasureIsolated processes `file` in a worker subprocess, re-executing the tool with the same
+// arguments, and returns the result. The worker is stopped when it exceeds `limits`. The data
+// returned by the task of the worker is unmarshaled into `data` if it is not nil.
+// Processes that die are classified as crashes, with the end of their standard error, where the Go
+// runtime writes the stack traces, as stack. The result is not added to the run.
+func (r *Run) MeasureIsolated(file string, limits Limits, data any) Result {
+	res := Result{File: file}
+	if fi, err := os.Stat(file); err == nil {
+		res.Size = fi.Size()
+	}
+
+	if err := runWorker(&res, limits, data); err != nil {
+		res.Outcome = OutcomeBad
+		res.Error = fmt.Sprintf("worker not started: %v", err)
+	}
+	r.saveCrashReport(&res)
+	return res
+}
+
+// runWorker runs a worker processing res.File and fills `res` with its result. It returns an error
+// if the worker cannot be started.
+func runWorker(res *Result, limits Limits, data any) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	resultFile, err := os.CreateTemp("", "corpus-result-*.json")
+	if err != nil {
+		return err
+	}
+	resultFile.Close()
+	defer os.Remove(resultFile.Name())
+
+	cmd := exec.Command(exe, os.Args[1:]...)
+	cmd.Env = append(os.Environ(),
+		workerFileEnv+"="+res.File,
+		workerResultEnv+"="+resultFile.Name(),
+		workerTimeoutEnv+"="+limits.Timeout.String(),
+		workerMemoryEnv+"="+strconv.FormatUint(limits.MaxMemory, 10),
+	)
+	stderr := &tailWriter{max: stderrTail}
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = stderr
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	waitErr := make(chan error, 1)
+	go func() { waitErr <- cmd.Wait() }()
+
+	// The worker enforces its limits, the parent only stops it if it fails to.
+	var backstop <-chan time.Time
+	if limits.Timeout > 0 {
+		timer := time.NewTimer(limits.Timeout + killGrace)
+		defer timer.Stop()
+		backstop = timer.C
+	}
+	killed := false
+	select {
+	case err = <-waitErr:
+	case <-backstop:
+		killed = true
+		// SIGQUIT makes the Go runtime dump the stacks of all goroutines and exit.
+		if cmd.Process.Signal(syscall.SIGQUIT) != nil {
+			cmd.Process.Kill()
+		}
+		select {
+		case err = <-waitErr:
+		case <-time.After(killGrace):
+			cmd.Process.Kill()
+			err = <-waitErr
+		}
+	}
+	res.Seconds = time.Since(start).Seconds()
+	res.Stack = stderr.String()
+
+	if msg, rerr := os.ReadFile(resultFile.Name()); rerr == nil && len(msg) > 0 {
+		var wr workerResult
+		if rerr := json.Unmarshal(msg, &wr); rerr != nil {
+			res.Outcome = OutcomeCrash
+			res.Error = fmt.Sprintf("worker result: %v", rerr)
+			return nil
+		}
+		if data != nil && len(wr.Data) > 0 {
+			if rerr := json.Unmarshal(wr.Data, data); rerr != nil {
+				res.Outcome = OutcomeCrash
+				res.Error = fmt.Sprintf("worker data: %v", rerr)
+				return nil
+			}
+		}
+		wr.Result.Size = res.Size
+		*res = wr.Result
+		return nil
+	}
+
+	switch {
+	case killed:
+		res.Outcome = OutcomeTimeout
+		res.Error = fmt.Sprintf("timeout after %s, worker killed", limits.Timeout)
+	case strings.Contains(res.Stack, "out of memory"):
+		res.Outcome = OutcomeMemory
+		res.Error = firstFatal(res.Stack, err)
+	default:
+		res.Outcome = OutcomeCrash
+		res.Error = firstFatal(res.Stack, err)
+	}
+	return nil
+}
+
+// workerLimits returns the limits of the worker.
+func workerLimits() (Limits, error) {
+	var limits Limits
+	var err error
+	if s := os.Getenv(workerTimeoutEnv); s != "" {
+		if limits.Timeout, err = time.ParseDuration(s); err != nil {
+			return limits, err
+		}
+	}
+	if s := os.Getenv(workerMemoryEnv); s != "" {
+		if limits.MaxMemory, err = strconv.ParseUint(s, 10, 64); err != nil {
+			return limits, err
+		}
+	}
+	return limits, nil
+}
+
+// writeWorkerResult writes `res` and `data` to `path`.
+func writeWorkerResult(path string, res Result, data any) error {
+	wr := workerResult{Result: res}
+	if data != nil {
+		b, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		wr.Data = b
+	}
+	b, err := json.Marshal(wr)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
+
+// allStacks returns the stack traces of all goroutines.
+func allStacks() string {
+	buf := make([]byte, 1<<20)
+	return string(buf[:runtime.Stack(buf, true)])
+}
+
+// firstFatal returns the first "fatal error" or "panic" line of the standard error `stderr` of a
+// worker that died, or its exit status `err`.
+func firstFatal(stderr string, err error) string {
+	for _, line := range strings.Split(stderr, "\n") {
+		if strings.HasPrefix(line, "fatal error: ") || strings.HasPrefix(line, "panic: ") ||
+			strings.HasPrefix(line, "runtime: ") {
+			return line
+		}
+	}
+	if err == nil {
+		err = errors.New("worker exited without a result")
+	}
+	return err.Error()
+}
+
+// tailWriter passes what is written to os.Stderr and keeps its last `max` bytes.
+type tailWriter struct {
+	mu  sync.Mutex
+	max int
+	buf bytes.Buffer
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf.Write(p)
+	if extra := w.buf.Len() - w.max; extra > 0 {
+		w.buf.Next(extra)
+	}
+	return os.Stderr.Write(p)
+}
+
+func (w *tailWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.String()
+}
@@ -0,0 +1,47 @@
+/*
+ * Peak resident set size of the process, from /proc on Linux.
+ */
+
+package corpus
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// resetPeakRSS resets the peak resident set size of the process (VmHWM), so that peakRSS returns
+// the peak since the call. It does nothing where /proc/self/clear_refs is not available.
+func resetPeakRSS() {
+	f, err := os.OpenFile("/proc/self/clear_refs", os.O_WRONLY, 0)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	f.WriteString("5")
+}
+
+// peakRSS returns the peak resident set size of the process in bytes, 0 where /proc/self/status is
+// not available.
+func peakRSS() uint64 {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		// VmHWM:    123456 kB
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "VmHWM:" && fields[2] == "kB" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0
+			}
+			return kb * 1024
+		}
+	}
+	return 0
+}
@@ -0,0 +1,87 @@
+/*
+ * Compares two machine readable results of a corpus benchmark, written with the -json option of the
+ * benchmarks, e.g. before and after a UniPDF upgrade.
+ *
+ * Run as: go run corpus_compare.go [options] base.json head.json
+ *
+ *     -max-slowdown <ratio>: Largest accepted slowdown of a file or of the total (default 1.25)
+ *     -min-seconds <secs>: Ignore the slowdowns of files faster than this (default 0.1)
+ *     -max-heap-growth <ratio>: Largest accepted growth of the peak heap of a file (default 1.5)
+ *     -min-heap <MiB>: Ignore the heap growths of files with a smaller peak heap (default 16)
+ *     -json <path>: Write the comparison as JSON
+ *
+ * The regressions are the files passing in base and not in head (or crashing in head only), the
+ * files slower or using more memory than accepted, and a total slowdown of the files passing in
+ * both. Exits with status 1 if there are regressions, so that it can gate a CI job.
+ */
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/unidoc/unidoc-examples/testing/corpus"
+)
+
+const usage = `Usage:
+corpus_compare [options] base.json head.json
+`
+
+func main() {
+	var opts corpus.CompareOptions
+	var minHeapMiB uint64
+	var jsonPath string
+	flag.Float64Var(&opts.MaxSlowdown, "max-slowdown", 1.25, "Largest accepted slowdown ratio")
+	flag.Float64Var(&opts.MinSeconds, "min-seconds", 0.1, "Ignore the slowdowns of files faster than this")
+	flag.Float64Var(&opts.MaxHeapGrowth, "max-heap-growth", 1.5, "Largest accepted peak heap growth ratio")
+	flag.Uint64Var(&minHeapMiB, "min-heap", 16, "Ignore the heap growths of files with a smaller peak heap (MiB)")
+	flag.StringVar(&jsonPath, "json", "", "Write the comparison as JSON to this file (optional)")
+	flag.Usage = func() {
+		fmt.Fprint(os.Stderr, usage)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 2 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	opts.MinHeap = minHeapMiB << 20
+
+	base, err := corpus.ReadRun(flag.Arg(0))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	head, err := corpus.ReadRun(flag.Arg(1))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(2)
+	}
+	if base.Tool != head.Tool {
+		fmt.Fprintf(os.Stderr, "Warning: comparing %q results with %q results\n", head.Tool, base.Tool)
+	}
+
+	c := corpus.Compare(base, head, opts)
+	c.WriteText(os.Stdout)
+	if len(jsonPath) > 0 {
+		f, err := os.Create(jsonPath)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		err = c.WriteJSON(f)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	if c.HasRegressions() {
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,304 @@
+/*
+ * Fuzz targets of the PDF reader, the content stream parser, the text and image extractors and the
+ * form field listing, which must return errors and not panic on malformed input.
+ *
+ * Run as: go test ./testing/fuzz/ -run '^$' -fuzz FuzzReader -fuzztime 10m
+ *
+ * The PDF targets are seeded with the PDF files of the repository and FuzzContentStream with their
+ * content streams. The inputs of testdata/fuzz/<target> form the regression corpus, run by plain
+ * `go test ./testing/fuzz/`: the crashers found by the fuzzer are minimized and written there by
+ * the go tool, and files crashing the corpus tools (see testing/corpus) are added with
+ * `go test ./testing/fuzz/ -run TestAddRegression -add crash1.pdf,crash2.pdf`.
+ *
+ * The targets need a license and are skipped without it, see testing/testlicense.
+ */
+
+package fuzz
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/unidoc/unidoc-examples/testing/testlicense"
+	"github.com/unidoc/unipdf/v4/contentstream"
+	"github.com/unidoc/unipdf/v4/core"
+	"github.com/unidoc/unipdf/v4/extractor"
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+var addInputs = flag.String("add", "", "comma separated PDF files to add to the regression corpus of the PDF targets")
+
+// Limits of the processing of an input, to keep the fuzzer fast and its memory bounded.
+const (
+	maxInputSize   = 4 << 20 // Larger inputs are skipped.
+	maxSeedSize    = 1 << 20 // Larger repository PDF files are not used as seeds.
+	maxPages       = 20      // Pages processed per document.
+	maxImagePixels = 1 << 24 // Larger images are not decoded.
+)
+
+// pdfTargets are the targets taking PDF files as input.
+var pdfTargets = []string{"FuzzReader", "FuzzExtractText", "FuzzFormFields", "FuzzImages"}
+
+// seedPDFs returns the contents of the PDF files of the repository up to maxSeedSize bytes.
+func seedPDFs(tb testing.TB) [][]byte {
+	var paths []string
+	err := filepath.WalkDir(filepath.Join("..", ".."), func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() && (d.Name() == ".git" || d.Name() == "testdata") {
+			return filepath.SkipDir
+		}
+		if d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".pdf") {
+			return nil
+		}
+		if fi, err := d.Info(); err == nil && fi.Size() <= maxSeedSize {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	sort.Strings(paths)
+
+	seeds := make([][]byte, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		seeds = append(seeds, data)
+	}
+	return seeds
+}
+
+// fuzzPDF seeds `f` with the repository PDF files and fuzzes `process` with the documents that can
+// be opened.
+func fuzzPDF(f *testing.F, process func(t *testing.T, pdfReader *model.PdfReader)) {
+	testlicense.Setup(f)
+	for _, seed := range seedPDFs(f) {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if len(data) > maxInputSize {
+			t.Skip()
+		}
+		pdfReader, err := openPDF(data)
+		if err != nil {
+			return
+		}
+		process(t, pdfReader)
+	})
+}
+
+// openPDF opens the PDF document `data`, decrypting it with an empty password if needed.
+func openPDF(data []byte) (*model.PdfReader, error) {
+	pdfReader, err := model.NewPdfReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := pdfReader.IsEncrypted()
+	if err != nil {
+		return nil, err
+	}
+	if encrypted {
+		ok, err := pdfReader.Decrypt([]byte(""))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("encrypted")
+		}
+	}
+	return pdfReader, nil
+}
+
+// pages calls `process` for the first maxPages pages of `pdfReader` that load.
+func pages(pdfReader *model.PdfReader, process func(page *model.PdfPage)) {
+	numPages, err := pdfReader.GetNumPages()
+	if err != nil {
+		return
+	}
+	for pageNum := 1; pageNum <= min(numPages, maxPages); pageNum++ {
+		page, err := pdfReader.GetPage(pageNum)
+		if err != nil {
+			continue
+		}
+		process(page)
+	}
+}
+
+// FuzzReader opens documents, iterates their pages and parses their content streams, as
+// analysis/pdf_print_content_streams.go does.
+func FuzzReader(f *testing.F) {
+	fuzzPDF(f, func(t *testing.T, pdfReader *model.PdfReader) {
+		pages(pdfReader, func(page *model.PdfPage) {
+			page.GetMediaBox()
+			contents, err := page.GetAllContentStreams()
+			if err != nil {
+				return
+			}
+			parseContentStream(contents, page.Resources)
+		})
+	})
+}
+
+// FuzzContentStream parses and processes content streams.
+func FuzzContentStream(f *testing.F) {
+	testlicense.Setup(f)
+	for _, s := range []string{
+		"BT /F1 12 Tf 72 712 Td (Hello) Tj ET",
+		"q 1 0 0 1 0 0 cm 0 0 m 100 100 l S Q",
+		"/CS0 cs 0.5 0.2 0.1 scn 0 0 10 10 re f",
+		"BI /W 2 /H 2 /BPC 8 /CS /G ID \x00\xff\x00\xff EI",
+		"q q q Q",
+		"[(A) -120 (B)] TJ",
+		"<< /MCID 0 >> BDC EMC",
+	} {
+		f.Add(s)
+	}
+	for _, seed := range seedPDFs(f) {
+		pdfReader, err := openPDF(seed)
+		if err != nil {
+			continue
+		}
+		pages(pdfReader, func(page *model.PdfPage) {
+			if contents, err := page.GetAllContentStreams(); err == nil && len(contents) <= maxInputSize {
+				f.Add(contents)
+			}
+		})
+	}
+	f.Fuzz(func(t *testing.T, contents string) {
+		if len(contents) > maxInputSize {
+			t.Skip()
+		}
+		parseContentStream(contents, model.NewPdfPageResources())
+	})
+}
+
+// parseContentStream parses `contents` and runs its operations through the content stream
+// processor, which tracks the graphics state and the color spaces.
+func parseContentStream(contents string, resources *model.PdfPageResources) {
+	operations, err := contentstream.NewContentStreamParser(contents).Parse()
+	if err != nil {
+		return
+	}
+	operations.Bytes()
+	processor := contentstream.NewContentStreamProcessor(*operations)
+	processor.AddHandler(contentstream.HandlerConditionEnumAllOperands, "",
+		func(op *contentstream.ContentStreamOperation, gs contentstream.GraphicsState,
+			resources *model.PdfPageResources) error {
+			return nil
+		})
+	if resources == nil {
+		resources = model.NewPdfPageResources()
+	}
+	processor.Process(resources)
+}
+
+// FuzzExtractText extracts the text of the pages.
+func FuzzExtractText(f *testing.F) {
+	fuzzPDF(f, func(t *testing.T, pdfReader *model.PdfReader) {
+		pages(pdfReader, func(page *model.PdfPage) {
+			ex, err := extractor.New(page)
+			if err != nil {
+				return
+			}
+			ex.ExtractText()
+			if pageText, _, _, err := ex.ExtractPageText(); err == nil {
+				pageText.Marks()
+			}
+		})
+	})
+}
+
+// FuzzFormFields lists the form fields with their names, flags, values and widgets, as
+// forms/pdf_form_list_fields.go does.
+func FuzzFormFields(f *testing.F) {
+	fuzzPDF(f, func(t *testing.T, pdfReader *model.PdfReader) {
+		acroForm := pdfReader.AcroForm
+		if acroForm == nil {
+			return
+		}
+		for _, field := range acroForm.AllFields() {
+			field.FullName()
+			field.Flags()
+			switch ctx := field.GetContext().(type) {
+			case *model.PdfFieldText:
+				if s, ok := core.GetString(ctx.V); ok {
+					s.Decoded()
+				}
+			case *model.PdfFieldButton:
+				ctx.IsCheckbox()
+			}
+			for _, wa := range field.Annotations {
+				if pageind, ok := wa.P.(*core.PdfIndirectObject); ok {
+					pdfReader.PageFromIndirectObject(pageind)
+				}
+				if apDict, ok := core.GetDict(wa.AP); ok {
+					if n, ok := core.GetStream(apDict.Get("N")); ok {
+						core.DecodeStream(n)
+					}
+				}
+			}
+		}
+	})
+}
+
+// FuzzImages extracts and decodes the images of the pages, as extract/pdf_extract_images.go does.
+func FuzzImages(f *testing.F) {
+	fuzzPDF(f, func(t *testing.T, pdfReader *model.PdfReader) {
+		pages(pdfReader, func(page *model.PdfPage) {
+			ex, err := extractor.New(page)
+			if err != nil {
+				return
+			}
+			pimages, err := ex.ExtractPageImages(nil)
+			if err != nil {
+				return
+			}
+			for _, img := range pimages.Images {
+				if img.Image == nil || img.Image.Width*img.Image.Height > maxImagePixels {
+					continue
+				}
+				img.Image.ToGoImage()
+			}
+		})
+	})
+}
+
+// TestAddRegression adds the PDF files of -add to the regression corpus of the PDF targets.
+func TestAddRegression(t *testing.T) {
+	if *addInputs == "" {
+		t.Skip("no -add files")
+	}
+	for _, path := range strings.Split(*addInputs, ",") {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sum := sha256.Sum256(data)
+		name := hex.EncodeToString(sum[:8])
+		for _, target := range pdfTargets {
+			dir := filepath.Join("testdata", "fuzz", target)
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatal(err)
+			}
+			entry := fmt.Sprintf("go test fuzz v1\n[]byte(%q)\n", data)
+			if err := os.WriteFile(filepath.Join(dir, name), []byte(entry), 0644); err != nil {
+				t.Fatal(err)
+			}
+		}
+		t.Logf("%s added as %s", path, name)
+	}
+}
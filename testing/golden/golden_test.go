@@ -0,0 +1,384 @@
+/*
+ * Golden file regression tests of the template and report generators.
+ *
+ * Each generator of templates/* and report/* is built and run in a copy of its directory with a
+ * fixed date (SOURCE_DATE_EPOCH), which replaces time.Now in its source. Then the pages of the
+ * generated document are rendered and compared to the golden PNG files of testdata/<name>, and its
+ * text to testdata/<name>/text.txt, within tolerances. The comparison is done on the rendered pages and the text because the bytes
+ * of the documents differ between runs (document ID, font subset names).
+ *
+ * Run as: go test ./testing/golden/ [-update] [-dpi 50] [-min-similarity 0.995] [-diffs dir] [-run TestGolden/receipt]
+ *
+ * The tests need a metered license key in UNIDOC_LICENSE_API_KEY, loaded by the generators, and are
+ * skipped without it or with -short. The rendering is licensed with testing/testlicense.
+ * Run with -update to regenerate the golden files after an intended change of the output, e.g. of
+ * a template, and review the changed PNG files before committing them. A case without golden files
+ * fails until they are created with -update.
+ */
+
+package golden
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/unidoc/unidoc-examples/diff/diffops"
+	"github.com/unidoc/unidoc-examples/testing/testlicense"
+	"github.com/unidoc/unipdf/v4/extractor"
+	"github.com/unidoc/unipdf/v4/model"
+	"github.com/unidoc/unipdf/v4/render"
+)
+
+var (
+	update         = flag.Bool("update", false, "regenerate the golden files")
+	dpi            = flag.Float64("dpi", 50, "rendering resolution of the pages")
+	tolerance      = flag.Uint("tolerance", 24, "largest color component difference (0-255) of unchanged pixels")
+	minSimilarity  = flag.Float64("min-similarity", 0.995, "smallest fraction of unchanged pixels of a page")
+	maxWordChanges = flag.Int("max-word-changes", 0, "largest number of changed runs of words of a document")
+	diffsDir       = flag.String("diffs", "", "directory to write the diff images of the failing pages to")
+)
+
+// sourceDateEpoch is the fixed date of the generated documents: 2024-01-01T00:00:00Z.
+const sourceDateEpoch = "1704067200"
+
+// goldenCase is a generator program and the document it writes.
+type goldenCase struct {
+	name    string
+	dir     string // Directory of the program, relative to the repository root.
+	program string
+	output  string
+}
+
+var goldenCases = []goldenCase{
+	{"airplane-ticket", "templates/airplane-ticket", "pdf_airplane_ticket.go", "unipdf-airplain-ticket.pdf"},
+	{"aviation-checklist", "templates/aviation-checklist", "pdf_aviation_checklist.go", "unipdf-aviation-checklist.pdf"},
+	{"bank-account-statement", "templates/bank-account-statement", "pdf_bank_account_statement.go", "unipdf-bank-account-statement.pdf"},
+	{"boarding-pass", "templates/boarding-pass", "pdf_boarding_pass.go", "unipdf-boarding-pass.pdf"},
+	{"concert-ticket", "templates/concert-ticket", "pdf_concert_ticket.go", "unipdf-ticket.pdf"},
+	{"documentation", "templates/documentation", "pdf_templates_documentation.go", "unipdf-templates-documentation.pdf"},
+	{"lab-results", "templates/lab-results", "pdf_lab_results.go", "unipdf-lab-results.pdf"},
+	{"log-book-report", "templates/log-book-report", "pdf_log_book.go", "unipdf-log-book.pdf"},
+	{"medical-bill", "templates/medical-bill", "pdf_medical_bill.go", "unipdf-medical-bill.pdf"},
+	{"medication-schedule-report", "templates/medication-schedule-report", "pdf_medication_schedule.go", "unipdf-medication-schedule.pdf"},
+	{"receipt", "templates/receipt", "pdf_receipt.go", "unipdf-receipt.pdf"},
+	{"rental-agreement", "templates/rental-agreement", "pdf_rental_agreement.go", "unipdf-rental-agreement.pdf"},
+	{"security-report", "templates/security-report", "pdf_security_report.go", "unipdf-security-report.pdf"},
+	{"trade-confirmation", "templates/trade-confirmation", "pdf_trade_confirmation.go", "unipdf-trade-confirmation.pdf"},
+	{"warehouse-shipment-report", "templates/warehouse-shipment-report", "pdf_warehouse_shipment_report.go", "unipdf-warehouse-shipment-report.pdf"},
+	{"report-custom-toc", "report", "pdf_custom_toc.go", "pdf-custom-toc.pdf"},
+	{"report-custom-toc-with-content", "report", "pdf_custom_toc_with_content.go", "pdf-custom-toc_with_content.pdf"},
+	{"report", "report", "pdf_report.go", "unidoc-report.pdf"},
+	{"report-from-csv", "report", "pdf_report_from_csv.go", "report_from_csv.pdf"},
+	{"report-landscape", "report", "pdf_report_landscape.go", "unidoc-report-landscape.pdf"},
+	{"report-tables", "report", "pdf_tables.go", "unipdf-tables.pdf"},
+}
+
+// fixedDateSource is added to the generator programs to set the creation and modification dates
+// of the documents from SOURCE_DATE_EPOCH. The calls of time.Now in the programs are replaced by
+// fixedNow.
+const fixedDateSource = `package main
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+func init() {
+	if _, ok := os.LookupEnv("SOURCE_DATE_EPOCH"); ok {
+		model.SetPdfCreationDate(fixedNow())
+		model.SetPdfModifiedDate(fixedNow())
+	}
+}
+
+// fixedNow returns the time set by SOURCE_DATE_EPOCH, the current time if not set.
+func fixedNow() time.Time {
+	if epoch, err := strconv.ParseInt(os.Getenv("SOURCE_DATE_EPOCH"), 10, 64); err == nil {
+		return time.Unix(epoch, 0).UTC()
+	}
+	return time.Now()
+}
+`
+
+func TestGolden(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the golden file tests in short mode")
+	}
+	if os.Getenv("UNIDOC_LICENSE_API_KEY") == "" {
+		t.Skip("UNIDOC_LICENSE_API_KEY is not set")
+	}
+	testlicense.Setup(t)
+
+	for _, tc := range goldenCases {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			goldenDir := filepath.Join("testdata", tc.name)
+			if _, err := os.Stat(filepath.Join(goldenDir, "text.txt")); os.IsNotExist(err) && !*update {
+				t.Fatalf("no golden files in %s, run the tests with -update to create them", goldenDir)
+			}
+
+			outputPath := generate(t, tc)
+			pages, text := renderDocument(t, outputPath)
+			if *update {
+				writeGolden(t, goldenDir, pages, text)
+				return
+			}
+			compareGolden(t, tc.name, goldenDir, pages, text)
+		})
+	}
+}
+
+// generate builds and runs the program of `tc` in a copy of its directory and returns the path
+// of the generated document.
+func generate(t *testing.T, tc goldenCase) string {
+	t.Helper()
+	srcDir := filepath.Join("..", "..", tc.dir)
+	workDir := t.TempDir()
+	if err := copyResources(srcDir, workDir); err != nil {
+		t.Fatalf("copy %s: %v", srcDir, err)
+	}
+
+	// The program is built from the module of the repository, with the fixed date source file.
+	program, err := os.ReadFile(filepath.Join(srcDir, tc.program))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The dates shown in the document are fixed by replacing time.Now with fixedNow. The program
+	// keeps using the time package if time.Now was its only use.
+	if bytes.Contains(program, []byte("time.Now")) {
+		program = bytes.ReplaceAll(program, []byte("time.Now"), []byte("fixedNow"))
+		program = append(program, "\nvar _ time.Time\n"...)
+	}
+	files := []string{filepath.Join(workDir, tc.program), filepath.Join(workDir, "zz_fixed_date.go")}
+	if err := os.WriteFile(files[0], program, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(files[1], []byte(fixedDateSource), 0644); err != nil {
+		t.Fatal(err)
+	}
+	binPath := filepath.Join(workDir, "generator")
+	if runtime.GOOS == "windows" {
+		binPath += ".exe"
+	}
+	build := exec.Command("go", append([]string{"build", "-o", binPath}, files...)...)
+	if out, err := build.CombinedOutput(); err != nil {
+		t.Fatalf("build %s: %v\n%s", tc.program, err, out)
+	}
+
+	run := exec.Command(binPath)
+	run.Dir = workDir
+	run.Env = append(os.Environ(), "SOURCE_DATE_EPOCH="+sourceDateEpoch)
+	if out, err := run.CombinedOutput(); err != nil {
+		t.Fatalf("run %s: %v\n%s", tc.program, err, lastLines(out, 20))
+	}
+	return filepath.Join(workDir, tc.output)
+}
+
+// copyResources copies the files of `srcDir` except the Go sources and the PDF outputs to
+// `dstDir`.
+func copyResources(srcDir, dstDir string) error {
+	return filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		dst := filepath.Join(dstDir, rel)
+		if d.IsDir() {
+			return os.MkdirAll(dst, 0755)
+		}
+		if ext := filepath.Ext(path); ext == ".go" || ext == ".pdf" {
+			return nil
+		}
+
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+		f, err := os.Create(dst)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(f, src); err != nil {
+			f.Close()
+			return err
+		}
+		return f.Close()
+	})
+}
+
+// renderDocument returns the rendered pages and the text of the pages of the document
+// `inputPath`, with the words of each page on a line.
+func renderDocument(t *testing.T, inputPath string) ([]image.Image, string) {
+	t.Helper()
+	pdfReader, f, err := model.NewPdfReaderFromFile(inputPath, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	numPages, err := pdfReader.GetNumPages()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	device := render.NewImageDevice()
+	var pages []image.Image
+	var sb strings.Builder
+	for i := 0; i < numPages; i++ {
+		pageNum := i + 1
+		page, err := pdfReader.GetPage(pageNum)
+		if err != nil {
+			t.Fatalf("page %d: %v", pageNum, err)
+		}
+		mbox, err := page.GetMediaBox()
+		if err != nil {
+			t.Fatalf("page %d: %v", pageNum, err)
+		}
+		device.OutputWidth = int(mbox.Width()**dpi/72 + 0.5)
+		img, err := device.Render(page)
+		if err != nil {
+			t.Fatalf("page %d: %v", pageNum, err)
+		}
+		pages = append(pages, img)
+
+		ex, err := extractor.New(page)
+		if err != nil {
+			t.Fatalf("page %d: %v", pageNum, err)
+		}
+		text, err := ex.ExtractText()
+		if err != nil {
+			t.Fatalf("page %d: %v", pageNum, err)
+		}
+		sb.WriteString(strings.Join(strings.Fields(text), " "))
+		sb.WriteByte('\n')
+	}
+	return pages, sb.String()
+}
+
+// writeGolden replaces the golden files of `goldenDir` by `pages` and `text`.
+func writeGolden(t *testing.T, goldenDir string, pages []image.Image, text string) {
+	t.Helper()
+	if err := os.RemoveAll(goldenDir); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(goldenDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	for i, img := range pages {
+		if err := writePNG(filepath.Join(goldenDir, pageFile(i+1)), img); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(goldenDir, "text.txt"), []byte(text), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// compareGolden compares `pages` and `text` to the golden files of `goldenDir`.
+func compareGolden(t *testing.T, name, goldenDir string, pages []image.Image, text string) {
+	t.Helper()
+	goldenText, err := os.ReadFile(filepath.Join(goldenDir, "text.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	golden, err := filepath.Glob(filepath.Join(goldenDir, "page_*.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(golden) != len(pages) {
+		t.Errorf("%d pages, golden: %d", len(pages), len(golden))
+	}
+
+	for i := 0; i < len(pages) && i < len(golden); i++ {
+		pageNum := i + 1
+		want, err := readPNG(filepath.Join(goldenDir, pageFile(pageNum)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		similarity, changed, diffImg := diffops.CompareImages(want, pages[i], uint8(min(*tolerance, 255)))
+		if similarity >= *minSimilarity {
+			continue
+		}
+		t.Errorf("page %d: similarity %.4f < %.4f, %d pixels changed", pageNum, similarity, *minSimilarity, changed)
+		if *diffsDir != "" {
+			diffPath := filepath.Join(*diffsDir, name, pageFile(pageNum))
+			if err := os.MkdirAll(filepath.Dir(diffPath), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := writePNG(diffPath, diffImg); err != nil {
+				t.Fatal(err)
+			}
+			t.Logf("page %d: diff image written to %s", pageNum, diffPath)
+		}
+	}
+
+	changes := diffops.DiffWords(textWords(string(goldenText)), textWords(text))
+	if len(changes) > *maxWordChanges {
+		t.Errorf("%d text changes, at most %d allowed", len(changes), *maxWordChanges)
+		for _, c := range changes {
+			t.Logf("  page %d: %s %q -> %q", max(c.OldPage, c.NewPage), c.Kind, c.OldText, c.NewText)
+		}
+	}
+}
+
+// textWords returns the words of `text`, with the words of each page on a line.
+func textWords(text string) []diffops.Word {
+	var words []diffops.Word
+	for i, line := range strings.Split(strings.TrimSuffix(text, "\n"), "\n") {
+		for _, w := range strings.Fields(line) {
+			words = append(words, diffops.Word{Text: w, Page: i + 1})
+		}
+	}
+	return words
+}
+
+func pageFile(pageNum int) string {
+	return fmt.Sprintf("page_%03d.png", pageNum)
+}
+
+func readPNG(path string) (image.Image, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return png.Decode(f)
+}
+
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// lastLines returns the last `n` lines of `out`, the generators log at debug level.
+func lastLines(out []byte, n int) string {
+	lines := strings.Split(strings.TrimRight(string(out), "\n"), "\n")
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return strings.Join(lines, "\n")
+}
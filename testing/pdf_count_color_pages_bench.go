@@ -1,6 +1,7 @@
 /*
  * Detect the number of pages and the color pages (1-offset) all pages in a list of PDF files.
- * Compares these results to running Ghostscript on the PDF files and reports an error if the results don't match.
+ * Compares these results to the color pixels of the pages rendered in-process and reports an error if the results
+ * don't match.
  *
  * Run as: ./pdf_count_color_pages_bench [-o processDir] [-d] [-a] testdata/*.pdf > blah
  *
@@ -14,12 +15,12 @@
  *      -min <val>: Minimum PDF file size to test
  *      -max <val>: Maximum PDF file size to test
  *      -r <name>: Name of results file
+ *      -json <name>: Name of the machine readable results file (see corpus_compare.go)
  */
 
 package main
 
 import (
-	"bytes"
 	"errors"
 	"flag"
 	"fmt"
@@ -28,14 +29,14 @@ import (
 	"math"
 	"math/rand"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
-	"runtime"
 	"sort"
 	"strconv"
 	"time"
 
+	"github.com/unidoc/unidoc-examples/testing/corpus"
+	"github.com/unidoc/unidoc-examples/testing/validate"
 	"github.com/unidoc/unipdf/v4/common"
 	"github.com/unidoc/unipdf/v4/common/license"
 	"github.com/unidoc/unipdf/v4/contentstream"
@@ -69,6 +70,7 @@ pdf_count_color_pages_bench [-o <processDir>] [-d][-a][-min <val>][-max <val>] <
 -min <val>: Minimum PDF file size to test
 -max <val>: Maximum PDF file size to test
 -r <name>: Name of results file
+-json <name>: Name of the machine readable results file
 `
 
 func main() {
@@ -79,9 +81,10 @@ func main() {
 	var minSize int64 = -1    // Minimum size for an input PDF to be processed.
 	var maxSize int64 = -1    // Maximum size for an input PDF to be processed.
 	var results string        // Results file
+	var jsonResults string    // Machine readable results file
 	var outputDir string
 
-	flag.StringVar(&outputDir, "o", "compare.pdfs", "Set output dir for ghostscript")
+	flag.StringVar(&outputDir, "o", "compare.pdfs", "Set output dir for the page rasters")
 	flag.BoolVar(&debug, "d", false, "Enable debug logging")
 	flag.BoolVar(&strict, "s", false, "Enable strict checking")
 	flag.BoolVar(&compareGrayscale, "g", false, "Do PDF raster comparison on grayscale rasters")
@@ -89,6 +92,7 @@ func main() {
 	flag.Int64Var(&minSize, "min", -1, "Minimum size of files to process (bytes)")
 	flag.Int64Var(&maxSize, "max", -1, "Maximum size of files to process (bytes)")
 	flag.StringVar(&results, "r", "", "Results file")
+	flag.StringVar(&jsonResults, "json", "", "Machine readable results file")
 
 	flag.Parse()
 	args := flag.Args()
@@ -124,6 +128,7 @@ func main() {
 	passFiles := []string{}
 	badFiles := []string{}
 	failFiles := []string{}
+	run := corpus.NewRun("count_color_pages")
 
 	for idx, inputPath := range pdfList {
 
@@ -141,17 +146,24 @@ func main() {
 		inputSize := fileSize(inputPath)
 		report(writers, "%3d of %d %#-30q  (%6d)", idx, len(pdfList), name, inputSize)
 
-		result := "pass"
-		t0 := time.Now()
+		var numPages int
+		var colorPages []int
+		res := run.Measure(inputPath, func() (corpus.Outcome, error) {
+			var err error
+			numPages, colorPages, err = describePdf(inputPath, strictColorPages)
+			if err != nil {
+				common.Log.Error("describePdf failed. err=%v", err)
+				return corpus.OutcomeBad, err
+			}
+			return corpus.OutcomePass, nil
+		})
+		report(writers, " %d pages %d color %.3f sec", numPages, len(colorPages), res.Seconds)
 
-		numPages, colorPages, err := describePdf(inputPath, strictColorPages)
-		dt := time.Since(t0)
-		if err != nil {
-			common.Log.Error("describePdf failed. err=%v", err)
+		result := string(res.Outcome)
+		if res.Outcome == corpus.OutcomeCrash {
+			common.Log.Error("describePdf crashed. %s", res.Error)
 			result = "bad"
 		}
-		report(writers, " %d pages %d color %.3f sec", numPages, len(colorPages), dt.Seconds())
-
 		if result == "pass" {
 			if !equalSlices(colorPagesIn, colorPages) {
 				common.Log.Error("pdfColorPages: \ncolorPagesIn=%d %v\ncolorPages  =%d %v",
@@ -165,8 +177,11 @@ func main() {
 					common.Log.Error("False negatives=%d %+v", len(fn), fn)
 				}
 				result = "fail"
+				res.Outcome = corpus.OutcomeFail
+				res.Error = fmt.Sprintf("color pages %v, expected %v", colorPages, colorPagesIn)
 			}
 		}
+		run.Add(res)
 		report(writers, ", %s\n", result)
 
 		switch result {
@@ -200,6 +215,13 @@ func main() {
 	for i, path := range failFiles {
 		report(writers, "%3d %#q\n", i, path)
 	}
+
+	if len(jsonResults) > 0 {
+		if err := run.WriteFile(jsonResults); err != nil {
+			common.Log.Error("WriteFile failed. err=%v", err)
+			os.Exit(1)
+		}
+	}
 }
 
 // describePdf reads PDF `inputPath` and returns number of pages, slice of color page numbers (1-offset)
@@ -832,51 +854,26 @@ func (x byFile) Less(i, j int) bool {
 }
 
 const (
-	gsImageFormat  = "doc-%03d.png"
-	gsImagePattern = `doc-(\d+).png$`
+	rasterImageFormat  = "doc-%03d.png"
+	rasterImagePattern = `doc-(\d+).png$`
 )
 
-var gsImageRegex = regexp.MustCompile(gsImagePattern)
-
-// runGhostscript runs Ghostscript on file `pdf` to create file one png file per page in directory
-// `outputDir`
-func runGhostscript(pdf, outputDir string) error {
-	common.Log.Debug("runGhostscript: pdf=%#q outputDir=%#q", pdf, outputDir)
-	outputPath := filepath.Join(outputDir, gsImageFormat)
-	output := fmt.Sprintf("-sOutputFile=%s", outputPath)
-
-	cmd := exec.Command(
-		ghostscriptName(),
-		"-dSAFER",
-		"-dBATCH",
-		"-dNOPAUSE",
-		"-r150",
-		fmt.Sprintf("-sDEVICE=png16m"),
-		"-dTextAlphaBits=1",
-		"-dGraphicsAlphaBits=1",
-		output,
-		pdf)
-	common.Log.Debug("runGhostscript: cmd=%#q", cmd.Args)
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	err := cmd.Run()
+var rasterImageRegex = regexp.MustCompile(rasterImagePattern)
+
+// rasterDPI is the resolution of the page rasters.
+const rasterDPI = 150
+
+// rasterizePdf renders the pages of file `pdf` with the in-process renderer to one png file per
+// page in directory `outputDir`
+func rasterizePdf(pdf, outputDir string) error {
+	common.Log.Debug("rasterizePdf: pdf=%#q outputDir=%#q", pdf, outputDir)
+	_, err := validate.RenderPages(pdf, rasterDPI, outputDir, rasterImageFormat)
 	if err != nil {
-		common.Log.Error("runGhostscript: Could not process pdf=%q err=%v\nstdout=\n%s\nstderr=\n%s\n",
-			pdf, err, stdout, stderr)
+		common.Log.Error("rasterizePdf: Could not process pdf=%q err=%v", pdf, err)
 	}
 	return err
 }
 
-// ghostscriptName returns the name of the Ghostscript binary on this OS
-func ghostscriptName() string {
-	if runtime.GOOS == "windows" {
-		return "gswin64c.exe"
-	}
-	return "gs"
-}
-
 // pdfColorPages returns a list of the (1-offset) page numbers of the colored pages in PDF at `path`
 func pdfColorPages(path, temp string) ([]int, error) {
 	dir := filepath.Join(temp, "color")
@@ -886,7 +883,7 @@ func pdfColorPages(path, temp string) ([]int, error) {
 	}
 	defer removeDir(dir)
 
-	err = runGhostscript(path, dir)
+	err = rasterizePdf(path, dir)
 	if err != nil {
 		return nil, err
 	}
@@ -906,7 +903,7 @@ func colorDirectoryPages(mask, dir string) ([]int, error) {
 
 	colorPages := []int{}
 	for _, path := range files {
-		matches := gsImageRegex.FindStringSubmatch(path)
+		matches := rasterImageRegex.FindStringSubmatch(path)
 		if len(matches) == 0 {
 			continue
 		}
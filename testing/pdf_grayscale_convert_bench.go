@@ -12,6 +12,7 @@
  *      -min <val>: Minimum PDF file size to test
  *      -max <val>: Maximum PDF file size to test
  *      -r <name>: Name of results file
+ *      -json <name>: Name of the machine readable results file (see corpus_compare.go)
  *
  * The grayscale transform
  *	- converts PDF files into our internal representation
@@ -28,7 +29,6 @@
 package main
 
 import (
-	"bytes"
 	"errors"
 	"flag"
 	"fmt"
@@ -39,15 +39,15 @@ import (
 	"math"
 	"math/rand"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
-	"runtime"
 	"sort"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/unidoc/unidoc-examples/testing/corpus"
+	"github.com/unidoc/unidoc-examples/testing/validate"
 	"github.com/unidoc/unipdf/v4/common"
 	"github.com/unidoc/unipdf/v4/common/license"
 	"github.com/unidoc/unipdf/v4/contentstream"
@@ -75,6 +75,7 @@ pdf_grayscale_convert_bench -g <output directory> [-r <results>][-d][-k][-a][-mi
 -min <val>: Minimum PDF file size to test
 -max <val>: Maximum PDF file size to test
 -k: Keep temp PNG files used for PDF grayscale test
+-json <name>: Name of the machine readable results file
 `
 
 // Ignore CCITTFaxDecode, JBIG2 - that are always grayscale.
@@ -105,17 +106,19 @@ func main() {
 	var minSize int64 = -1   // Minimum size for an input PDF to be processed
 	var maxSize int64 = -1   // Maximum size for an input PDF to be processed
 	results := ""            // Results are written here
+	jsonResults := ""        // Machine readable results are written here
 	outputDir := ""          // Transformed PDFs are written here
 	keep := false            // Keep the rasters used for PDF comparison
 	ignoreGrayFilters = true // Ignore CCITTFaxDecode, JBIG2 - that are always grayscale.
 
 	flag.BoolVar(&debug, "d", false, "Enable debug logging")
 	flag.BoolVar(&runAllTests, "a", false, "Run all tests. Don't stop at first failure")
-	flag.StringVar(&compRoot, "o", "compare.pdfs", "Set output dir for ghostscript")
+	flag.StringVar(&compRoot, "o", "compare.pdfs", "Set output dir for the page rasters")
 	flag.Int64Var(&minSize, "min", -1, "Minimum size of files to process (bytes)")
 	flag.Int64Var(&maxSize, "max", -1, "Maximum size of files to process (bytes)")
 	flag.StringVar(&outputDir, "g", "", "Output directory")
 	flag.StringVar(&results, "r", "", "Results file")
+	flag.StringVar(&jsonResults, "json", "", "Machine readable results file")
 	flag.BoolVar(&keep, "k", false, "Keep the rasters used for PDF comparison")
 	flag.BoolVar(&ignoreGrayFilters, "ignoregrayfilters", true, "Ignore gray filters (CCITTFaxDecode, JPXDecode)")
 
@@ -161,6 +164,7 @@ func main() {
 	failFiles := []string{}
 	failErrors := []string{}
 	passTotalTime := float64(0)
+	run := corpus.NewRun("grayscale_convert")
 
 	startT := time.Now()
 
@@ -172,30 +176,37 @@ func main() {
 		report(writers, "%3d of %d %#-30q  (%6d->", idx, len(pdfList), name, inputSize)
 		outputPath := modifyPath(inputPath, outputDir)
 
-		t0 := time.Now()
 		result := "pass"
 
 		// 1. Transforms the pdf to grayscale pdf.
-		numPages, err := transformPdfFile(inputPath, outputPath)
-		dt := time.Since(t0)
-		if err != nil {
-			common.Log.Error("transformPdfFile failed. err=%v", err)
+		var numPages int
+		res := run.Measure(inputPath, func() (corpus.Outcome, error) {
+			var err error
+			numPages, err = transformPdfFile(inputPath, outputPath)
+			if err != nil {
+				return corpus.OutcomeBad, err
+			}
+			return corpus.OutcomePass, nil
+		})
+		dt := time.Duration(res.Seconds * float64(time.Second))
+		if res.Outcome != corpus.OutcomePass {
+			common.Log.Error("transformPdfFile failed. err=%s", res.Error)
 			failFiles = append(failFiles, inputPath)
-			failErrors = append(failErrors, fmt.Sprintf("%v", err))
+			failErrors = append(failErrors, res.Error)
 			result = "bad"
 		}
 
 		// Reason for failure.
 		errStr := ""
 
-		// 2. Runs pdftops on the transformed file to validate if OK.
+		// 2. Validates the structure of the transformed file.
 		if result == "pass" {
 			outputSize := fileSize(outputPath)
 			report(writers, "%6d %3d%%) %d pages %.3f sec => %#q",
 				outputSize, int(float64(outputSize)/float64(inputSize)*100.0+0.5),
 				numPages, dt.Seconds(), outputPath)
 
-			err = runPdfToPs(outputPath, compDir)
+			err := validatePdf(outputPath)
 			if err != nil {
 				common.Log.Error("Transform has damaged PDF. err=%v\n\tinputPath=%#q\n\toutputPath=%#q",
 					err, inputPath, outputPath)
@@ -222,6 +233,12 @@ func main() {
 		}
 		report(writers, ", %s\n", result)
 
+		if result == "fail" {
+			res.Outcome = corpus.OutcomeFail
+			res.Error = errStr
+		}
+		run.Add(res)
+
 		switch result {
 		case "pass":
 			passFiles = append(passFiles, inputPath)
@@ -287,6 +304,13 @@ func main() {
 	for _, entry := range entries {
 		report(writers, "%d - %s\n", entry.count, entry.errmsg)
 	}
+
+	if len(jsonResults) > 0 {
+		if err := run.WriteFile(jsonResults); err != nil {
+			common.Log.Error("WriteFile failed. err=%v", err)
+			os.Exit(1)
+		}
+	}
 }
 
 type ObjCounts struct {
@@ -1095,65 +1119,35 @@ func (x byFile) Less(i, j int) bool {
 }
 
 const (
-	gsImageFormat  = "doc-%03d.png"
-	gsImagePattern = `doc-(\d+).png$`
+	rasterImageFormat  = "doc-%03d.png"
+	rasterImagePattern = `doc-(\d+).png$`
 )
 
-var gsImageRegex = regexp.MustCompile(gsImagePattern)
-
-// runGhostscript runs Ghostscript on file `pdf` to create file one png file per page in directory
-// `outputDir`
-func runGhostscript(pdf, outputDir string) error {
-	common.Log.Trace("runGhostscript: pdf=%#q outputDir=%#q", pdf, outputDir)
-	outputPath := filepath.Join(outputDir, gsImageFormat)
-	output := fmt.Sprintf("-sOutputFile=%s", outputPath)
-
-	cmd := exec.Command(
-		ghostscriptName(),
-		"-dSAFER",
-		"-dBATCH",
-		"-dNOPAUSE",
-		"-r150",
-		fmt.Sprintf("-sDEVICE=png16m"),
-		"-dTextAlphaBits=1",
-		"-dGraphicsAlphaBits=1",
-		output,
-		pdf)
-	common.Log.Trace("runGhostscript: cmd=%#q", cmd.Args)
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	err := cmd.Run()
+var rasterImageRegex = regexp.MustCompile(rasterImagePattern)
+
+// rasterDPI is the resolution of the page rasters.
+const rasterDPI = 150
+
+// rasterizePdf renders the pages of file `pdf` with the in-process renderer to one png file per
+// page in directory `outputDir`
+func rasterizePdf(pdf, outputDir string) error {
+	common.Log.Trace("rasterizePdf: pdf=%#q outputDir=%#q", pdf, outputDir)
+	_, err := validate.RenderPages(pdf, rasterDPI, outputDir, rasterImageFormat)
 	if err != nil {
-		common.Log.Error("runGhostscript: Could not process pdf=%q err=%v\nstdout=\n%s\nstderr=\n%s\n",
-			pdf, err, stdout, stderr)
+		common.Log.Error("rasterizePdf: Could not process pdf=%q err=%v", pdf, err)
 	}
 	return err
 }
 
-// ghostscriptName returns the name of the Ghostscript binary on this OS
-func ghostscriptName() string {
-	if runtime.GOOS == "windows" {
-		return "gswin64c.exe"
+// validatePdf checks the structure of file `pdf` and returns an error if it has structural errors
+func validatePdf(pdf string) error {
+	common.Log.Trace("validatePdf: pdf=%#q", pdf)
+	report, err := validate.ValidateFile(pdf, "")
+	if err == nil {
+		err = report.Err()
 	}
-	return "gs"
-}
-
-// runPdfToPs runs pdftops on file `pdf` to create a PostScript file in directory `outputDir`
-func runPdfToPs(pdf, outputDir string) error {
-	common.Log.Trace("pdf=%#q outputDir=%#q", pdf, outputDir)
-	ps := changeDir(pdf, outputDir)
-	cmd := exec.Command("pdftops", pdf, ps)
-	common.Log.Trace("cmd=%#q", cmd.Args)
-
-	var stdout, stderr bytes.Buffer
-	cmd.Stdout = &stdout
-	cmd.Stderr = &stderr
-	err := cmd.Run()
 	if err != nil {
-		common.Log.Error("Could not process pdf=%q err=%v\nstdout=\n%s\nstderr=\n%s\n",
-			pdf, err, stdout, stderr)
+		common.Log.Error("Could not validate pdf=%q err=%v", pdf, err)
 	}
 	return err
 }
@@ -1170,7 +1164,7 @@ func isPdfColor(path, temp string, showPages, keep bool) (bool, []int, error) {
 		defer removeDir(dir)
 	}
 
-	err = runGhostscript(path, dir)
+	err = rasterizePdf(path, dir)
 	if err != nil {
 		return false, nil, err
 	}
@@ -1215,7 +1209,7 @@ func colorDirectoryPages(mask, dir string, keep bool) ([]int, error) {
 
 	colorPages := []int{}
 	for _, path := range files {
-		matches := gsImageRegex.FindStringSubmatch(path)
+		matches := rasterImageRegex.FindStringSubmatch(path)
 		if len(matches) == 0 {
 			continue
 		}
@@ -1402,11 +1396,6 @@ func fileSize(path string) int64 {
 	return fi.Size()
 }
 
-func changeDir(path, dir string) string {
-	_, name := filepath.Split(path)
-	return filepath.Join(dir, name)
-}
-
 // report writes Sprintf formatted `format` ... to all writers in `writers`
 func report(writers []io.Writer, format string, a ...interface{}) {
 	msg := fmt.Sprintf(format, a...)
@@ -10,35 +10,42 @@
  *     -odir <outputdir> - Output directory path (Optional, overrides -o)
  *     -d: Debug level logging
  *     -a: Run all tests. Don't stop at first failure (This flag isn't used here)
- *     -gsv: Validate with ghostscript
+ *     -validate: Validate the input and output files (pure Go, see the validate package)
  *     -hang: Hang when completed (no exit) - for memory profiling
  *     -rmlist: Print out a list of files to rm to make fully compliant
  *     -optimize: Use Use Pdf compression and optimization
  *     -pprof: Run with profiling enabled.
  *     -lazy: Use lazy loading.
+ *     -json <results.json>: Write the machine readable results (see corpus_compare.go)
+ *     -isolate: Process each file in a subprocess, so that crashes and hangs do not stop the run
+ *     -timeout <duration>: Time limit per file, e.g. 2m (implies -isolate)
+ *     -max-mem <MiB>: Heap limit per file (implies -isolate)
+ *     -crash-dir <dir>: Save the path and stack trace of the crashing, timing out and memory exceeding files
  *
  * The passthrough benchmark
  * - Loads the input PDF with unipdf
  * - Writes the output PDF
- * - Runs ghostscript (gs) on both input and output and checks for errors
- * - Invalid if unipdf returns an error or if gs on output has more errors than gs on input PDF.
+ * - Validates the structure of both input and output and checks for errors
+ * - Invalid if unipdf returns an error or if the output has more issues than the input PDF.
+ *
+ * The validation is done in-process by the validate package, Ghostscript is not needed.
  */
 
 package main
 
 import (
-	"bytes"
+	"errors"
 	"flag"
 	"fmt"
 	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
-	"runtime"
 	"runtime/pprof"
 	"strings"
 	"time"
 
+	"github.com/unidoc/unidoc-examples/testing/corpus"
+	"github.com/unidoc/unidoc-examples/testing/validate"
 	"github.com/unidoc/unipdf/v4/common"
 	"github.com/unidoc/unipdf/v4/common/license"
 	"github.com/unidoc/unipdf/v4/model"
@@ -74,45 +81,53 @@ Options:
 -o <processPath> - Temporary output file path (default /tmp/test.pdf)
 -odir <outputdir> - Output directory path (Optional, overrides -o)
 -d: Debug level logging
--gsv: Validate with ghostscript
+-validate: Validate the input and output files
 -hang: Hang when completed (no exit) - for memory profiling
 -rmlist: Print out a list of files to rm to make fully compliant
 -optimize: Use Pdf compression and optimization
 -lazy: Use lazy loading.
+-json <results.json>: Write the machine readable results
+-isolate: Process each file in a subprocess
+-timeout <duration>: Time limit per file (implies -isolate)
+-max-mem <MiB>: Heap limit per file (implies -isolate)
+-crash-dir <dir>: Save the path and stack trace of the crashing, timing out and memory exceeding files
 
-Example: pdf_passthrough_bench -gsv ~/pdfdb/* >results_YYYY_MM_DD
+Example: pdf_passthrough_bench -validate ~/pdfdb/* >results_YYYY_MM_DD
 `
 
 type benchParams struct {
-	runAllTests  bool
-	processPath  string
-	outputDir    string
-	gsValidation bool
-	hangOnExit   bool
-	printRmList  bool
-	optimize     bool
-	lazyLoading  bool
-	profilePath  string
-	loglevel     string
+	runAllTests bool
+	processPath string
+	outputDir   string
+	validation  bool
+	hangOnExit  bool
+	printRmList bool
+	optimize    bool
+	lazyLoading bool
+	profilePath string
+	loglevel    string
+	jsonPath    string
+	isolate     bool
+	limits      corpus.Limits
+	crashDir    string
 }
 
 func main() {
 	params := benchParams{}
 
-	fmt.Printf("UniPDF version %s\n", common.Version)
-
 	//params.debug = false       // Write debug level info to stdout?
 	params.runAllTests = false // Don't stop when a PDF file fails to process?
 	params.processPath = ""    // Transformed PDFs are written here
 	params.outputDir = ""      // Alternatively, can store output files in an output directory.
-	params.gsValidation = false
+	params.validation = false
 	params.hangOnExit = false
 	params.printRmList = false
 	params.optimize = false
 	params.loglevel = "info"
 
 	flag.StringVar(&params.loglevel, "loglevel", "info", "Set loglevel: info (default), debug, trace, none")
-	flag.BoolVar(&params.gsValidation, "gsv", false, "Enable ghostscript validation")
+	flag.BoolVar(&params.validation, "validate", false, "Enable validation of the input and output files")
+	flag.BoolVar(&params.validation, "gsv", false, "Alias of -validate")
 	flag.BoolVar(&params.runAllTests, "a", false, "Run all tests. Don't stop at first failure")
 	flag.BoolVar(&params.hangOnExit, "hang", false, "Hang when completed without exiting (memory profiling)")
 	flag.BoolVar(&params.printRmList, "rmlist", false, "Print rm list at end")
@@ -121,6 +136,11 @@ func main() {
 	flag.BoolVar(&params.optimize, "optimize", false, "Use Pdf compression and optimization")
 	flag.BoolVar(&params.lazyLoading, "lazy", false, "Use lazy loading")
 	flag.StringVar(&params.profilePath, "pprof", "", "Pprof output for profiling (optional)")
+	flag.StringVar(&params.jsonPath, "json", "", "Write the machine readable results to this file (optional)")
+	flag.BoolVar(&params.isolate, "isolate", false, "Process each file in a subprocess")
+	flag.DurationVar(&params.limits.Timeout, "timeout", 0, "Time limit per file (implies -isolate)")
+	maxMemMiB := flag.Uint64("max-mem", 0, "Heap limit per file in MiB (implies -isolate)")
+	flag.StringVar(&params.crashDir, "crash-dir", "", "Crash reports directory (optional)")
 
 	flag.Parse()
 	args := flag.Args()
@@ -128,6 +148,32 @@ func main() {
 		fmt.Fprintln(os.Stderr, usage)
 		os.Exit(1)
 	}
+	params.limits.MaxMemory = *maxMemMiB << 20
+	if params.limits.Timeout > 0 || params.limits.MaxMemory > 0 {
+		params.isolate = true
+	}
+
+	switch params.loglevel {
+	case "none":
+		common.SetLogger(common.DummyLogger{})
+	case "info":
+		common.SetLogger(common.NewConsoleLogger(common.LogLevelInfo))
+	case "debug":
+		common.SetLogger(common.NewConsoleLogger(common.LogLevelDebug))
+	case "trace":
+		common.SetLogger(common.NewConsoleLogger(common.LogLevelTrace))
+	default:
+		fmt.Printf("Unknown loglevel: %v\n", params.loglevel)
+		os.Exit(1)
+	}
+
+	// With -isolate, the subprocesses processing the files stop here.
+	corpus.ServeWorker(func(path string) (corpus.Outcome, any, error) {
+		outcome, err := passthroughOutcome(path, params)
+		return outcome, nil, err
+	})
+
+	fmt.Printf("UniPDF version %s\n", common.Version)
 
 	if len(params.profilePath) > 0 {
 		fmt.Printf("Profiling to %s\n", params.profilePath)
@@ -145,21 +191,10 @@ func main() {
 		defer pprof.StopCPUProfile()
 	}
 
-	fmt.Printf("With GS validation: %t\n", params.gsValidation)
+	fmt.Printf("With validation: %t\n", params.validation)
 	fmt.Printf("With compression and optimization: %t\n", params.optimize)
-
-	switch params.loglevel {
-	case "none":
-		common.SetLogger(common.DummyLogger{})
-	case "info":
-		common.SetLogger(common.NewConsoleLogger(common.LogLevelInfo))
-	case "debug":
-		common.SetLogger(common.NewConsoleLogger(common.LogLevelDebug))
-	case "trace":
-		common.SetLogger(common.NewConsoleLogger(common.LogLevelTrace))
-	default:
-		fmt.Printf("Unknown loglevel: %v\n", params.loglevel)
-		os.Exit(1)
+	if params.isolate {
+		fmt.Printf("With isolation: timeout %s, heap limit %d MiB\n", params.limits.Timeout, *maxMemMiB)
 	}
 
 	pdfList, err := patternsToPaths(args)
@@ -232,55 +267,29 @@ func regularFile(path string) bool {
 	return fi.Mode().IsRegular()
 }
 
-// validatePdf a pdf file using Ghostscript, returns an error if unable to execute.
-// Also returns the number of output warnings, which can be used as some sort of measure
-// of validity, especially when comparing with a transformed version of same file.
+// validatePdf validates a pdf file, returns an error if it has structural errors or cannot be
+// parsed. Also returns the number of issues (errors and warnings), which can be used as some sort
+// of measure of validity, especially when comparing with a transformed version of same file.
 func validatePdf(path string, password string) (error, int) {
 	common.Log.Debug("Validating: %s", path)
 
-	var cmd *exec.Cmd
-	if len(password) > 0 {
-		option := fmt.Sprintf("-sPDFPassword=%s", password)
-		cmd = exec.Command(ghostscriptName(), "-dBATCH", "-dNODISPLAY", "-dNOPAUSE", "-dPDFSTOPONERROR", option, path)
-	} else {
-		cmd = exec.Command(ghostscriptName(), "-dBATCH", "-dNODISPLAY", "-dNOPAUSE", "-dPDFSTOPONERROR", path)
-	}
-
-	var out bytes.Buffer
-	var errOut bytes.Buffer
-	cmd.Stdout = &out
-	cmd.Stderr = &errOut
-
-	err := cmd.Run()
+	report, err := validate.ValidateFile(path, password)
 	if err != nil {
-		common.Log.Debug("%s", out.String())
-		common.Log.Debug("%s", errOut.String())
-		common.Log.Error("GS failed with error %s", err)
-		return fmt.Errorf("GS failed with error (%s)", err), 0
+		common.Log.Error("Validation failed with error %s", err)
+		return fmt.Errorf("Validation failed with error (%s)", err), 0
 	}
 
-	outputErr := errOut.String()
-	warnings := strings.Count(outputErr, "****")
-	common.Log.Error(": - %d warnings %s", warnings, outputErr)
-
-	if warnings > 1 {
-		if len(outputErr) > 80 {
-			outputErr = outputErr[:80] // Trim the output.
-		}
-		common.Log.Error("Invalid - %d warnings %s", warnings, outputErr)
-		return fmt.Errorf("Invalid - %d warnings (%s)", warnings, outputErr), warnings
+	issues := len(report.Issues)
+	for _, issue := range report.Issues {
+		common.Log.Debug("%s", issue)
+	}
+	if err := report.Err(); err != nil {
+		common.Log.Error("Invalid - %d issues: %v", issues, err)
+		return err, issues
 	}
 
 	// Valid if no error.
-	return nil, 0
-}
-
-// ghostscriptName returns the name of the Ghostscript binary on this OS
-func ghostscriptName() string {
-	if runtime.GOOS == "windows" {
-		return "gswin64c.exe"
-	}
-	return "gs"
+	return nil, issues
 }
 
 // testPassthroughSinglePdf tests loading a pdf file, and writing it back out (passthrough).
@@ -395,16 +404,16 @@ func testPassthroughSinglePdf(inputPath string, params benchParams) error {
 		return err
 	}
 
-	// GS validation of input, output pdfs.
-	if params.gsValidation {
+	// Validation of input, output pdfs.
+	if params.validation {
 		common.Log.Debug("Validating input file")
-		_, inputWarnings := validatePdf(inputPath, "")
+		_, inputIssues := validatePdf(inputPath, "")
 		common.Log.Debug("Validating output file")
 
-		err, warnings := validatePdf(outputPath, "")
-		if err != nil && warnings > inputWarnings {
-			common.Log.Error("Input warnings %d vs output %d", inputWarnings, warnings)
-			return fmt.Errorf("GS: Invalid PDF input %d/ output %d warnings", inputWarnings, warnings)
+		err, issues := validatePdf(outputPath, "")
+		if err != nil && issues > inputIssues {
+			common.Log.Error("Input issues %d vs output %d", inputIssues, issues)
+			return fmt.Errorf("Validation: Invalid PDF input %d/ output %d issues", inputIssues, issues)
 		}
 		common.Log.Debug("Valid PDF!")
 	}
@@ -418,13 +427,13 @@ func TestSinglePdf(target string, params benchParams) error {
 
 	if err != nil {
 		// If unidoc fails the file, check the input file.  Do not count as error
-		// if ghostscript has issues with the file.  Ensure not processed by ghostscript already (GS: error prefix).
-		if params.gsValidation && !strings.HasPrefix(err.Error(), "GS: ") {
-			fmt.Printf("Error, lets doa ghostscript check of input\n")
+		// if the validation has issues with the file.  Ensure not validated already (Validation: error prefix).
+		if params.validation && !strings.HasPrefix(err.Error(), "Validation: ") {
+			fmt.Printf("Error, lets do a validation of input\n")
 			err, _ := validatePdf(target, "")
 			fmt.Println(err)
 			if err != nil {
-				common.Log.Debug("GS fails processing input file %s - not counting as problematic", target)
+				common.Log.Debug("Validation fails on input file %s - not counting as problematic", target)
 				return nil
 			}
 		}
@@ -433,6 +442,19 @@ func TestSinglePdf(target string, params benchParams) error {
 	return err
 }
 
+// passthroughOutcome tests the pdf file `path` and classifies the outcome: fail if the output
+// does not pass the validation, bad for the other errors.
+func passthroughOutcome(path string, params benchParams) (corpus.Outcome, error) {
+	err := TestSinglePdf(path, params)
+	switch {
+	case err == nil:
+		return corpus.OutcomePass, nil
+	case strings.HasPrefix(err.Error(), "Validation: "):
+		return corpus.OutcomeFail, err
+	}
+	return corpus.OutcomeBad, err
+}
+
 // printResults prints a summary of the benchmark results.
 func (this benchmarkResults) printResults(params benchParams) {
 	succeeded := 0
@@ -513,6 +535,8 @@ func isDirectory(path string) (bool, error) {
 // benchmarkPDFs runs a benchmark on a list of PDF files specified by path with a specified set of parameters.
 func benchmarkPDFs(paths []string, params benchParams) error {
 	benchmarkResults := benchmarkResults{}
+	run := corpus.NewRun("passthrough")
+	run.CrashDir = params.crashDir
 
 	for _, path := range paths {
 		benchmark := benchmarkResult{}
@@ -525,10 +549,22 @@ func benchmarkPDFs(paths []string, params benchParams) error {
 		benchmark.sizeMB = fileSizeMB
 
 		fmt.Printf("Testing %s\n", path)
-		start := time.Now()
-		err = TestSinglePdf(path, params)
-		elapsed := time.Since(start)
-		benchmark.processTime = elapsed.Seconds()
+		var res corpus.Result
+		if params.isolate {
+			res = run.MeasureIsolated(path, params.limits, nil)
+		} else {
+			res = run.Measure(path, func() (corpus.Outcome, error) {
+				return passthroughOutcome(path, params)
+			})
+		}
+		run.Add(res)
+		benchmark.processTime = res.Seconds
+		if res.Error != "" {
+			err = errors.New(res.Error)
+			if res.Outcome.Abnormal() {
+				err = fmt.Errorf("%s: %s", res.Outcome, res.Error)
+			}
+		}
 		if err == nil {
 			benchmark.passed = true
 			fmt.Printf("%s - pass\n", path)
@@ -536,6 +572,9 @@ func benchmarkPDFs(paths []string, params benchParams) error {
 			benchmark.passed = false
 			benchmark.errorMessage = fmt.Sprintf("%s", err)
 			fmt.Printf("%s - fail %s\n", path, err)
+			if len(res.CrashReport) > 0 {
+				fmt.Printf("Crash report: %s\n", res.CrashReport)
+			}
 		}
 		if benchmark.passed && params.optimize {
 			outputPath := params.processPath
@@ -558,5 +597,11 @@ func benchmarkPDFs(paths []string, params benchParams) error {
 
 	benchmarkResults.printResults(params)
 
+	if len(params.jsonPath) > 0 {
+		if err := run.WriteFile(params.jsonPath); err != nil {
+			return err
+		}
+		fmt.Printf("Results written to %s\n", params.jsonPath)
+	}
 	return nil
 }
@@ -0,0 +1,48 @@
+/*
+ * License setup of the tests of the repository.
+ *
+ * A metered license key in UNIDOC_LICENSE_API_KEY is checked online. To run the tests without
+ * network access, set UNIDOC_LICENSE_FILE to the path of an offline license key and
+ * UNIDOC_LICENSE_CUSTOMER to its customer name instead.
+ */
+
+package testlicense
+
+import (
+	"os"
+	"sync"
+	"testing"
+
+	"github.com/unidoc/unipdf/v4/common"
+	"github.com/unidoc/unipdf/v4/common/license"
+)
+
+var (
+	once       sync.Once
+	licenseErr error
+)
+
+// Setup loads the license and silences the logging of the library, or skips the test if there is
+// no license key: the offline license key of the file UNIDOC_LICENSE_FILE if set, otherwise the
+// metered license key UNIDOC_LICENSE_API_KEY. The license is loaded once per test binary.
+func Setup(tb testing.TB) {
+	tb.Helper()
+	keyPath, key := os.Getenv("UNIDOC_LICENSE_FILE"), os.Getenv("UNIDOC_LICENSE_API_KEY")
+	if keyPath == "" && key == "" {
+		tb.Skip("neither UNIDOC_LICENSE_FILE nor UNIDOC_LICENSE_API_KEY is set")
+	}
+	once.Do(func() {
+		common.SetLogger(common.DummyLogger{})
+		if keyPath == "" {
+			licenseErr = license.SetMeteredKey(key)
+			return
+		}
+		var data []byte
+		if data, licenseErr = os.ReadFile(keyPath); licenseErr == nil {
+			licenseErr = license.SetLicenseKey(string(data), os.Getenv("UNIDOC_LICENSE_CUSTOMER"))
+		}
+	})
+	if licenseErr != nil {
+		tb.Fatal(licenseErr)
+	}
+}
@@ -0,0 +1,70 @@
+/*
+ * In-process rasterization of PDF pages for the raster comparisons of the benchmarks.
+ */
+
+package validate
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+
+	"github.com/unidoc/unipdf/v4/model"
+	"github.com/unidoc/unipdf/v4/render"
+)
+
+// RenderPages renders the pages of the PDF file `inputPath` at `dpi` to PNG files in `outputDir`,
+// named by `nameFormat` with the page number, e.g. "doc-%03d.png". It returns the number of pages.
+func RenderPages(inputPath string, dpi float64, outputDir, nameFormat string) (int, error) {
+	pdfReader, f, err := model.NewPdfReaderFromFile(inputPath, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	numPages, err := pdfReader.GetNumPages()
+	if err != nil {
+		return 0, err
+	}
+
+	device := render.NewImageDevice()
+	for i := 0; i < numPages; i++ {
+		pageNum := i + 1
+		page, err := pdfReader.GetPage(pageNum)
+		if err != nil {
+			return 0, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+		img, err := RenderPage(device, page, dpi)
+		if err != nil {
+			return 0, fmt.Errorf("page %d: %w", pageNum, err)
+		}
+		if err := writePNG(filepath.Join(outputDir, fmt.Sprintf(nameFormat, pageNum)), img); err != nil {
+			return 0, err
+		}
+	}
+	return numPages, nil
+}
+
+// RenderPage renders `page` at `dpi` with `device`.
+func RenderPage(device *render.ImageDevice, page *model.PdfPage, dpi float64) (image.Image, error) {
+	mbox, err := page.GetMediaBox()
+	if err != nil {
+		return nil, err
+	}
+	device.OutputWidth = int(mbox.Width()*dpi/72 + 0.5)
+	return device.Render(page)
+}
+
+func writePNG(path string, img image.Image) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := png.Encode(f, img); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
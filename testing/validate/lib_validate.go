@@ -0,0 +1,672 @@
+/*
+ * Pure Go structural validation of PDF files, used by the benchmarks instead of Ghostscript and
+ * pdftops so that they run where these tools are not installed.
+ *
+ * The checks are:
+ *  - xref: the cross-reference entries point to the objects they declare.
+ *  - object: the objects can be loaded.
+ *  - reachability: the objects are reachable from the trailer.
+ *  - stream: the stream lengths match their /Length and the streams decode with their filters.
+ *  - pagetree: the page tree has no cycles, consistent /Count and /Parent entries and inherits
+ *    a MediaBox on every page.
+ *  - resources: the fonts, XObjects, graphics states, color spaces, patterns and shadings used by
+ *    the content streams of the pages and their forms are defined and can be loaded.
+ *
+ * Problems the reader repairs or viewers tolerate are warnings, the others errors.
+ */
+
+package validate
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+
+	"github.com/unidoc/unipdf/v4/contentstream"
+	"github.com/unidoc/unipdf/v4/core"
+	"github.com/unidoc/unipdf/v4/model"
+)
+
+// Severity is the severity of an issue.
+type Severity string
+
+// Severities.
+const (
+	SeverityError   Severity = "error"
+	SeverityWarning Severity = "warning"
+)
+
+// Checks.
+const (
+	CheckXref         = "xref"
+	CheckObject       = "object"
+	CheckReachability = "reachability"
+	CheckStream       = "stream"
+	CheckPageTree     = "pagetree"
+	CheckResources    = "resources"
+)
+
+// Issue is a problem found in a document.
+type Issue struct {
+	Check    string   `json:"check"`
+	Severity Severity `json:"severity"`
+	Object   int      `json:"object,omitempty"` // Object number, 0 if not specific to an object.
+	Page     int      `json:"page,omitempty"`   // Page number, 0 if not specific to a page.
+	Message  string   `json:"message"`
+}
+
+func (i Issue) String() string {
+	s := fmt.Sprintf("%s %s", i.Severity, i.Check)
+	if i.Page > 0 {
+		s += fmt.Sprintf(" page %d", i.Page)
+	}
+	if i.Object > 0 {
+		s += fmt.Sprintf(" object %d", i.Object)
+	}
+	return s + ": " + i.Message
+}
+
+// Report is the result of the validation of a document.
+type Report struct {
+	Path    string  `json:"path"`
+	Objects int     `json:"objects"`
+	Pages   int     `json:"pages"`
+	Issues  []Issue `json:"issues"`
+}
+
+// Errors returns the number of error issues of `r`.
+func (r *Report) Errors() int {
+	return r.count(SeverityError)
+}
+
+// Warnings returns the number of warning issues of `r`.
+func (r *Report) Warnings() int {
+	return r.count(SeverityWarning)
+}
+
+// Valid returns true if `r` has no error issues.
+func (r *Report) Valid() bool {
+	return r.Errors() == 0
+}
+
+// Err returns an error summarizing the error issues of `r`, nil if it is valid.
+func (r *Report) Err() error {
+	for _, issue := range r.Issues {
+		if issue.Severity == SeverityError {
+			return fmt.Errorf("invalid PDF: %d errors, %d warnings, first: %s", r.Errors(), r.Warnings(), issue)
+		}
+	}
+	return nil
+}
+
+func (r *Report) count(severity Severity) int {
+	n := 0
+	for _, issue := range r.Issues {
+		if issue.Severity == severity {
+			n++
+		}
+	}
+	return n
+}
+
+// ValidateFile validates the PDF file `path`, decrypted with `password` if it is encrypted. It
+// only returns an error if the file cannot be opened, parsed or decrypted at all.
+func ValidateFile(path, password string) (*Report, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	r, err := Validate(f, password)
+	if err != nil {
+		return nil, err
+	}
+	r.Path = path
+	return r, nil
+}
+
+// Validate validates the PDF document read from `rs`, decrypted with `password` if it is
+// encrypted.
+func Validate(rs io.ReadSeeker, password string) (*Report, error) {
+	parser, err := core.NewParser(rs)
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := parser.IsEncrypted()
+	if err != nil {
+		return nil, err
+	}
+	if encrypted {
+		ok, err := parser.Decrypt([]byte(password))
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, fmt.Errorf("cannot decrypt with the password")
+		}
+	}
+
+	v := &validator{
+		rs:        rs,
+		parser:    parser,
+		encrypted: encrypted,
+		objects:   map[int]core.PdfObject{},
+		report:    &Report{Issues: []Issue{}},
+	}
+	v.checkXref()
+	v.loadObjects()
+	v.checkReachability()
+	v.checkStreams()
+	v.checkPageTree()
+
+	if _, err := rs.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	pdfReader, err := model.NewPdfReaderWithOpts(rs, &model.ReaderOpts{Password: password})
+	if err != nil {
+		v.add(CheckObject, SeverityError, 0, 0, "cannot load the document: %v", err)
+		return v.report, nil
+	}
+	v.checkResources(pdfReader)
+	return v.report, nil
+}
+
+// validator holds the state of a validation.
+type validator struct {
+	rs        io.ReadSeeker
+	parser    *core.PdfParser
+	encrypted bool
+	objects   map[int]core.PdfObject // The loaded objects by object number.
+	report    *Report
+}
+
+func (v *validator) add(check string, severity Severity, objNum, pageNum int, format string, a ...interface{}) {
+	v.report.Issues = append(v.report.Issues, Issue{
+		Check:    check,
+		Severity: severity,
+		Object:   objNum,
+		Page:     pageNum,
+		Message:  fmt.Sprintf(format, a...),
+	})
+}
+
+// objHeaderRegex matches the "N G obj" header of an indirect object.
+var objHeaderRegex = regexp.MustCompile(`^\s*(\d+)\s+(\d+)\s+obj`)
+
+// checkXref checks that the cross-reference table entries point to the object headers they
+// declare and that compressed objects are stored in object streams.
+func (v *validator) checkXref() {
+	size, err := v.rs.Seek(0, io.SeekEnd)
+	if err != nil {
+		v.add(CheckXref, SeverityError, 0, 0, "cannot get the file size: %v", err)
+		return
+	}
+
+	xref := v.parser.GetXrefTable()
+	for _, objNum := range sortedKeys(xref.ObjectMap) {
+		entry := xref.ObjectMap[objNum]
+		switch entry.XType {
+		case core.XrefTypeTableEntry:
+			if entry.Offset <= 0 || entry.Offset >= size {
+				v.add(CheckXref, SeverityError, objNum, 0, "offset %d outside of the file (%d bytes)", entry.Offset, size)
+				continue
+			}
+			header := make([]byte, 64)
+			if _, err := v.rs.Seek(entry.Offset, io.SeekStart); err != nil {
+				v.add(CheckXref, SeverityError, objNum, 0, "cannot seek to offset %d: %v", entry.Offset, err)
+				continue
+			}
+			n, _ := io.ReadFull(v.rs, header)
+			m := objHeaderRegex.FindSubmatch(header[:n])
+			if m == nil {
+				v.add(CheckXref, SeverityWarning, objNum, 0, "no object at offset %d", entry.Offset)
+				continue
+			}
+			if num, _ := strconv.Atoi(string(m[1])); num != objNum {
+				v.add(CheckXref, SeverityWarning, objNum, 0, "offset %d points to object %d", entry.Offset, num)
+			} else if gen, _ := strconv.Atoi(string(m[2])); gen != entry.Generation {
+				v.add(CheckXref, SeverityWarning, objNum, 0, "generation %d, xref entry: %d", gen, entry.Generation)
+			}
+		case core.XrefTypeObjectStream:
+			container, ok := xref.ObjectMap[entry.OsObjNumber]
+			if !ok || container.XType != core.XrefTypeTableEntry {
+				v.add(CheckXref, SeverityError, objNum, 0, "object stream %d not found", entry.OsObjNumber)
+			}
+		}
+	}
+}
+
+// loadObjects loads the objects of the cross-reference table.
+func (v *validator) loadObjects() {
+	xref := v.parser.GetXrefTable()
+	for _, objNum := range sortedKeys(xref.ObjectMap) {
+		obj, err := v.parser.LookupByNumber(objNum)
+		if err != nil {
+			v.add(CheckObject, SeverityError, objNum, 0, "cannot load: %v", err)
+			continue
+		}
+		v.objects[objNum] = obj
+	}
+	v.report.Objects = len(v.objects)
+}
+
+// resolve returns the object referenced by `obj` if it is a reference, or `obj`.
+func (v *validator) resolve(obj core.PdfObject) core.PdfObject {
+	ref, ok := obj.(*core.PdfObjectReference)
+	if !ok {
+		return obj
+	}
+	target, ok := v.objects[int(ref.ObjectNumber)]
+	if !ok {
+		return nil
+	}
+	if ind, ok := target.(*core.PdfIndirectObject); ok {
+		return ind.PdfObject
+	}
+	return target
+}
+
+// checkReachability checks that the objects are reachable from the trailer.
+func (v *validator) checkReachability() {
+	trailer := v.parser.GetTrailer()
+	if trailer == nil {
+		v.add(CheckReachability, SeverityError, 0, 0, "no trailer")
+		return
+	}
+
+	reached := map[int]bool{}
+	stack := []core.PdfObject{trailer}
+	for len(stack) > 0 {
+		obj := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		switch t := obj.(type) {
+		case *core.PdfObjectReference:
+			objNum := int(t.ObjectNumber)
+			if reached[objNum] {
+				continue
+			}
+			reached[objNum] = true
+			target, ok := v.objects[objNum]
+			if !ok {
+				// References to missing objects are null objects, but dangling references
+				// in the catalog or the page tree usually mean a broken document.
+				continue
+			}
+			stack = append(stack, target)
+		case *core.PdfIndirectObject:
+			stack = append(stack, t.PdfObject)
+		case *core.PdfObjectStream:
+			stack = append(stack, t.PdfObjectDictionary)
+		case *core.PdfObjectDictionary:
+			for _, key := range t.Keys() {
+				stack = append(stack, t.Get(key))
+			}
+		case *core.PdfObjectArray:
+			stack = append(stack, t.Elements()...)
+		}
+	}
+
+	var unreachable []int
+	for _, objNum := range sortedKeys(v.objects) {
+		if reached[objNum] || isStructural(v.objects[objNum]) {
+			continue
+		}
+		unreachable = append(unreachable, objNum)
+	}
+	if len(unreachable) > 0 {
+		v.add(CheckReachability, SeverityWarning, 0, 0, "%d unreachable objects: %s",
+			len(unreachable), formatNums(unreachable, 10))
+	}
+}
+
+// isStructural returns true if `obj` is part of the file structure rather than of the document:
+// object streams, cross-reference streams and linearization dictionaries.
+func isStructural(obj core.PdfObject) bool {
+	var dict *core.PdfObjectDictionary
+	switch t := obj.(type) {
+	case *core.PdfObjectStream:
+		dict = t.PdfObjectDictionary
+	case *core.PdfIndirectObject:
+		dict, _ = core.GetDict(t.PdfObject)
+	}
+	if dict == nil {
+		return false
+	}
+	typ, _ := core.GetNameVal(dict.Get("Type"))
+	return typ == "ObjStm" || typ == "XRef" || dict.Get("Linearized") != nil
+}
+
+// imageFilters are the filters of image codecs. The streams using them are not decoded as the
+// renderer decodes them.
+var imageFilters = map[string]bool{
+	"DCTDecode": true, "DCT": true,
+	"JPXDecode":      true,
+	"JBIG2Decode":    true,
+	"CCITTFaxDecode": true, "CCF": true,
+}
+
+// knownFilters are the standard filters.
+var knownFilters = map[string]bool{
+	"ASCIIHexDecode": true, "AHx": true,
+	"ASCII85Decode": true, "A85": true,
+	"LZWDecode": true, "LZW": true,
+	"FlateDecode": true, "Fl": true,
+	"RunLengthDecode": true, "RL": true,
+	"Crypt": true,
+}
+
+// checkStreams checks the lengths and the filters of the streams.
+func (v *validator) checkStreams() {
+	for _, objNum := range sortedKeys(v.objects) {
+		stream, ok := v.objects[objNum].(*core.PdfObjectStream)
+		if !ok {
+			continue
+		}
+
+		// The decrypted data of encrypted streams is shorter than /Length.
+		if !v.encrypted {
+			length, ok := core.GetIntVal(v.resolve(stream.Get("Length")))
+			switch {
+			case !ok:
+				v.add(CheckStream, SeverityWarning, objNum, 0, "missing or invalid /Length")
+			case length != len(stream.Stream):
+				v.add(CheckStream, SeverityWarning, objNum, 0, "/Length %d, data: %d bytes", length, len(stream.Stream))
+			}
+		}
+
+		decode := true
+		for _, filter := range streamFilters(v.resolve(stream.Get("Filter"))) {
+			switch {
+			case imageFilters[filter]:
+				decode = false
+			case !knownFilters[filter]:
+				v.add(CheckStream, SeverityError, objNum, 0, "unknown filter %s", filter)
+				decode = false
+			}
+		}
+		if !decode {
+			continue
+		}
+		if _, err := core.DecodeStream(stream); err != nil {
+			v.add(CheckStream, SeverityError, objNum, 0, "cannot decode: %v", err)
+		}
+	}
+}
+
+// streamFilters returns the names of the filters `filter`, a name or an array of names.
+func streamFilters(filter core.PdfObject) []string {
+	if name, ok := core.GetNameVal(filter); ok {
+		return []string{name}
+	}
+	arr, ok := core.GetArray(filter)
+	if !ok {
+		return nil
+	}
+	var names []string
+	for _, obj := range arr.Elements() {
+		if name, ok := core.GetNameVal(obj); ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// maxPageTreeDepth bounds the depth of the page tree walk.
+const maxPageTreeDepth = 64
+
+// checkPageTree checks the page tree of the catalog.
+func (v *validator) checkPageTree() {
+	trailer := v.parser.GetTrailer()
+	if trailer == nil {
+		return
+	}
+	catalog, ok := core.GetDict(v.resolve(trailer.Get("Root")))
+	if !ok {
+		v.add(CheckPageTree, SeverityError, 0, 0, "no document catalog")
+		return
+	}
+	rootRef, ok := catalog.Get("Pages").(*core.PdfObjectReference)
+	if !ok {
+		v.add(CheckPageTree, SeverityError, 0, 0, "no page tree reference in the catalog")
+		return
+	}
+
+	visited := map[int]bool{}
+	pages := v.walkPageTree(rootRef, 0, false, visited, 0)
+	v.report.Pages = pages
+}
+
+// walkPageTree checks the page tree node `ref` with parent object `parent` and returns its number
+// of pages. `hasMediaBox` tells if an ancestor defines a MediaBox.
+func (v *validator) walkPageTree(ref *core.PdfObjectReference, parent int, hasMediaBox bool,
+	visited map[int]bool, depth int) int {
+	objNum := int(ref.ObjectNumber)
+	if visited[objNum] {
+		v.add(CheckPageTree, SeverityError, objNum, 0, "node visited twice (cycle or shared node)")
+		return 0
+	}
+	visited[objNum] = true
+	if depth > maxPageTreeDepth {
+		v.add(CheckPageTree, SeverityError, objNum, 0, "page tree deeper than %d levels", maxPageTreeDepth)
+		return 0
+	}
+
+	node, ok := core.GetDict(v.resolve(ref))
+	if !ok {
+		v.add(CheckPageTree, SeverityError, objNum, 0, "page tree node is not a dictionary")
+		return 0
+	}
+	if parent > 0 {
+		parentRef, ok := node.Get("Parent").(*core.PdfObjectReference)
+		if !ok {
+			v.add(CheckPageTree, SeverityWarning, objNum, 0, "missing /Parent")
+		} else if int(parentRef.ObjectNumber) != parent {
+			v.add(CheckPageTree, SeverityWarning, objNum, 0, "/Parent is %d, expected %d", parentRef.ObjectNumber, parent)
+		}
+	}
+	hasMediaBox = hasMediaBox || node.Get("MediaBox") != nil
+
+	typ, _ := core.GetNameVal(node.Get("Type"))
+	kids, hasKids := core.GetArray(v.resolve(node.Get("Kids")))
+	if typ == "" {
+		v.add(CheckPageTree, SeverityWarning, objNum, 0, "missing /Type")
+		typ = "Page"
+		if hasKids {
+			typ = "Pages"
+		}
+	}
+
+	switch typ {
+	case "Page":
+		if !hasMediaBox {
+			v.add(CheckPageTree, SeverityError, objNum, 0, "page without MediaBox")
+		}
+		return 1
+	case "Pages":
+		if !hasKids {
+			v.add(CheckPageTree, SeverityError, objNum, 0, "missing /Kids")
+			return 0
+		}
+		count := 0
+		for _, kid := range kids.Elements() {
+			kidRef, ok := kid.(*core.PdfObjectReference)
+			if !ok {
+				v.add(CheckPageTree, SeverityError, objNum, 0, "kid is not an indirect reference")
+				continue
+			}
+			count += v.walkPageTree(kidRef, objNum, hasMediaBox, visited, depth+1)
+		}
+		declared, ok := core.GetIntVal(v.resolve(node.Get("Count")))
+		if !ok {
+			v.add(CheckPageTree, SeverityError, objNum, 0, "missing /Count")
+		} else if declared != count {
+			v.add(CheckPageTree, SeverityError, objNum, 0, "/Count %d, pages: %d", declared, count)
+		}
+		return count
+	}
+	v.add(CheckPageTree, SeverityError, objNum, 0, "invalid page tree node type %s", typ)
+	return 0
+}
+
+// deviceColorspaces are the color space names that need no resource.
+var deviceColorspaces = map[string]bool{
+	"DeviceGray": true, "DeviceRGB": true, "DeviceCMYK": true, "Pattern": true,
+	"G": true, "RGB": true, "CMYK": true,
+}
+
+// checkResources checks that the resources used by the content streams of the pages of
+// `pdfReader` are defined and can be loaded.
+func (v *validator) checkResources(pdfReader *model.PdfReader) {
+	numPages, err := pdfReader.GetNumPages()
+	if err != nil {
+		v.add(CheckResources, SeverityError, 0, 0, "cannot get the pages: %v", err)
+		return
+	}
+
+	rc := &resourceChecker{v: v, fonts: map[core.PdfObject]bool{}, forms: map[int64]bool{}}
+	for i := 0; i < numPages; i++ {
+		pageNum := i + 1
+		page, err := pdfReader.GetPage(pageNum)
+		if err != nil {
+			v.add(CheckResources, SeverityError, 0, pageNum, "cannot load the page: %v", err)
+			continue
+		}
+		contents, err := page.GetAllContentStreams()
+		if err != nil {
+			v.add(CheckResources, SeverityError, 0, pageNum, "cannot load the content streams: %v", err)
+			continue
+		}
+		rc.checkContent(pageNum, contents, page.Resources)
+	}
+}
+
+// resourceChecker checks the resources used by content streams.
+type resourceChecker struct {
+	v     *validator
+	fonts map[core.PdfObject]bool // The loaded fonts.
+	forms map[int64]bool          // The object numbers of the checked forms.
+}
+
+// checkContent checks the resources used by the content stream `contents` of page `pageNum`,
+// defined in `resources`.
+func (rc *resourceChecker) checkContent(pageNum int, contents string, resources *model.PdfPageResources) {
+	ops, err := contentstream.NewContentStreamParser(contents).Parse()
+	if err != nil {
+		rc.v.add(CheckResources, SeverityError, 0, pageNum, "cannot parse the content stream: %v", err)
+		return
+	}
+	if resources == nil {
+		resources = model.NewPdfPageResources()
+	}
+
+	missing := func(kind string, name core.PdfObjectName) {
+		rc.v.add(CheckResources, SeverityError, 0, pageNum, "%s /%s not found in the resources", kind, name)
+	}
+	for _, op := range *ops {
+		if len(op.Params) == 0 {
+			continue
+		}
+		name, isName := core.GetName(op.Params[0])
+		switch op.Operand {
+		case "Tf":
+			if !isName {
+				continue
+			}
+			fontObj, ok := resources.GetFontByName(*name)
+			if !ok {
+				missing("font", *name)
+				continue
+			}
+			if rc.fonts[fontObj] {
+				continue
+			}
+			rc.fonts[fontObj] = true
+			if _, err := model.NewPdfFontFromPdfObject(fontObj); err != nil {
+				rc.v.add(CheckResources, SeverityError, 0, pageNum, "cannot load font /%s: %v", *name, err)
+			}
+		case "Do":
+			if !isName {
+				continue
+			}
+			stream, xtype := resources.GetXObjectByName(*name)
+			if stream == nil {
+				missing("XObject", *name)
+				continue
+			}
+			if xtype == model.XObjectTypeForm && !rc.forms[stream.ObjectNumber] {
+				rc.forms[stream.ObjectNumber] = true
+				rc.checkForm(pageNum, *name, stream)
+			}
+		case "gs":
+			if isName {
+				if _, ok := resources.GetExtGState(*name); !ok {
+					missing("graphics state", *name)
+				}
+			}
+		case "cs", "CS":
+			if isName && !deviceColorspaces[string(*name)] {
+				if _, ok := resources.GetColorspaceByName(*name); !ok {
+					missing("color space", *name)
+				}
+			}
+		case "scn", "SCN":
+			// The pattern name is the last operand.
+			if last, ok := core.GetName(op.Params[len(op.Params)-1]); ok {
+				if _, ok := resources.GetPatternByName(*last); !ok {
+					missing("pattern", *last)
+				}
+			}
+		case "sh":
+			if isName {
+				if _, ok := resources.GetShadingByName(*name); !ok {
+					missing("shading", *name)
+				}
+			}
+		}
+	}
+}
+
+// checkForm checks the resources used by the form XObject `stream` named `name`.
+func (rc *resourceChecker) checkForm(pageNum int, name core.PdfObjectName, stream *core.PdfObjectStream) {
+	form, err := model.NewXObjectFormFromStream(stream)
+	if err != nil {
+		rc.v.add(CheckResources, SeverityError, int(stream.ObjectNumber), pageNum, "cannot load form /%s: %v", name, err)
+		return
+	}
+	contents, err := form.GetContentStream()
+	if err != nil {
+		rc.v.add(CheckResources, SeverityError, int(stream.ObjectNumber), pageNum, "cannot decode form /%s: %v", name, err)
+		return
+	}
+	rc.checkContent(pageNum, string(contents), form.Resources)
+}
+
+func sortedKeys[V any](m map[int]V) []int {
+	keys := make([]int, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Ints(keys)
+	return keys
+}
+
+// formatNums returns the first `limit` numbers of `nums` separated by spaces.
+func formatNums(nums []int, limit int) string {
+	var buf bytes.Buffer
+	for i, n := range nums {
+		if i == limit {
+			buf.WriteString(" ...")
+			break
+		}
+		if i > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(strconv.Itoa(n))
+	}
+	return buf.String()
+}
@@ -21,8 +21,8 @@ import (
 	"math"
 	"os"
 	"sort"
-	"strings"
 
+	"github.com/unidoc/unidoc-examples/extract/layout"
 	"github.com/unidoc/unipdf/v4/common"
 	"github.com/unidoc/unipdf/v4/common/license"
 	"github.com/unidoc/unipdf/v4/contentstream"
@@ -191,332 +191,62 @@ func extractTableData(inPath string, outPath string) error {
 	return ioutil.WriteFile(outPath, csvData.Bytes(), 0666)
 }
 
-func rectUnion(b1, b2 model.PdfRectangle) model.PdfRectangle {
-	return model.PdfRectangle{
-		Llx: math.Min(b1.Llx, b2.Llx),
-		Lly: math.Min(b1.Lly, b2.Lly),
-		Urx: math.Max(b1.Urx, b2.Urx),
-		Ury: math.Max(b1.Ury, b2.Ury),
+// pageMarksToCSV converts textMarks from a single page into CSV by grouping the marks into
+// words, lines and columns with the layout package and then writing the table cells data as
+// CSV output.
+func pageMarksToCSV(textMarks *extractor.TextMarkArray) (string, error) {
+	// STEP - Form words.
+	words := layout.WordsFromMarks(textMarks.Elements())
+	for i, word := range words {
+		common.Log.Debug("Word %d: '%s' %v", i, word.Text, word.BBox)
 	}
-}
-
-func bboxArea(bbox model.PdfRectangle) float64 {
-	return math.Abs(bbox.Urx-bbox.Llx) * math.Abs(bbox.Ury-bbox.Lly)
-}
-
-// Measure of the difference between areas of `bbox1` and `bbox2` individually
-// and that of the union of the two.
-func overlaps(bbox1, bbox2 model.PdfRectangle) float64 {
-	union := rectUnion(bbox1, bbox2)
-	a := bboxArea(union)
-	b := bboxArea(bbox1) + bboxArea(bbox2)
-	diff := (a - b) / (a + b)
-	return diff
-}
-
-// Measure of the vertical overlap of `bbox1` and `bbox2`, when the difference is 0
-// then they are exactly on top of each other, and there is overlap when < 0.
-func lineOverlap(bbox1, bbox2 model.PdfRectangle) float64 {
-	union := rectUnion(bbox1, bbox2)
-	a := math.Abs(union.Ury - union.Lly)
-	b := math.Abs(bbox1.Ury-bbox1.Lly) + math.Abs(bbox2.Ury-bbox2.Lly)
-	diff := (a - b) / (a + b)
-	return diff
-}
-
-// Measure of the horizontal overlap of `bbox1` and `bbox2`, when the difference is 0
-// then they are exactly on next to each other, and there is overlap when < 0.
-func columnOverlap(bbox1, bbox2 model.PdfRectangle) float64 {
-	union := rectUnion(bbox1, bbox2)
-	a := math.Abs(union.Urx - union.Llx)
-	b := math.Abs(bbox1.Urx-bbox1.Llx) + math.Abs(bbox2.Urx-bbox2.Llx)
-	diff := (a - b) / (a + b)
-	return diff
-}
-
-// Identify lines. - segment words into lines
-func identifyLines(words []segmentationWord) [][]segmentationWord {
-	lines := [][]segmentationWord{}
 
+	// Include the words in the markup.
+	wbboxes := []model.PdfRectangle{}
 	for _, word := range words {
-		wbbox, ok := word.BBox()
-		if !ok {
-			continue
-		}
-
-		match := false
-		for i, line := range lines {
-			firstWord := line[0]
-			firstBBox, ok := firstWord.BBox()
-			if !ok {
-				continue
-			}
-
-			overlap := lineOverlap(wbbox, firstBBox)
-			common.Log.Debug("'%s'/'%s' overlap: %v [%+v/%+v]", word.String(), firstWord.String(), overlap, wbbox, firstBBox)
-			if overlap < 0 {
-				lines[i] = append(lines[i], word)
-				match = true
-				break
-			}
-		}
-		if !match {
-			lines = append(lines, []segmentationWord{word})
-		}
-	}
-	sort.SliceStable(lines, func(i, j int) bool {
-		bboxi, _ := lines[i][0].BBox()
-		bboxj, _ := lines[j][0].BBox()
-		return bboxi.Lly >= bboxj.Lly
-	})
-	for li := range lines {
-		sort.SliceStable(lines[li], func(i, j int) bool {
-			bboxi, _ := lines[li][i].BBox()
-			bboxj, _ := lines[li][j].BBox()
-			return bboxi.Llx < bboxj.Llx
-		})
+		wbboxes = append(wbboxes, toRect(word.BBox))
 	}
+	saveParams.markups[saveParams.curPage] = append(saveParams.markups[saveParams.curPage], wbboxes)
+
+	// STEP - Identify lines.
+	lines := layout.Rows(words)
 
 	// Save the line bounding boxes for markup output.
 	lineGroups := []model.PdfRectangle{}
-	for li, line := range lines {
-		var lineRect model.PdfRectangle
-		common.Log.Trace("Line %d: ", li+1)
-		for i, word := range line {
-			wbbox, ok := word.BBox()
-			if !ok {
-				continue
-			}
-			common.Log.Trace("'%s' / ", word.String())
-
-			if i == 0 {
-				lineRect = wbbox
-			} else {
-				lineRect = rectUnion(lineRect, wbbox)
-			}
-		}
-		lineGroups = append(lineGroups, lineRect)
-	}
-	saveParams.markups[saveParams.curPage] = append(saveParams.markups[saveParams.curPage], lineGroups)
-	return lines
-}
-
-// Identify columns.
-func identifyColumns(words []segmentationWord) []model.PdfRectangle {
-	columns := [][]segmentationWord{}
-	for _, word := range words {
-		wbbox, ok := word.BBox()
-		if !ok {
-			continue
-		}
-
-		match := false
-		bestOverlap := 1.0
-		bestColumn := 0
-		for i, column := range columns {
-			firstWord := column[0]
-			firstBBox, ok := firstWord.BBox()
-			if !ok {
-				continue
-			}
-
-			overlap := columnOverlap(wbbox, firstBBox)
-			common.Log.Debug("column: '%s'/'%s' overlap: %v [%+v/%+v]", word.String(), firstWord.String(), overlap, wbbox, firstBBox)
-			if overlap < 0.0 {
-				if overlap < bestOverlap {
-					bestOverlap = overlap
-					bestColumn = i
-				}
-				match = true
-			}
-		}
-		if match {
-			columns[bestColumn] = append(columns[bestColumn], word)
-		} else {
-			columns = append(columns, []segmentationWord{word})
-		}
-	}
-	sort.SliceStable(columns, func(i, j int) bool {
-		bboxi, _ := columns[i][0].BBox()
-		bboxj, _ := columns[j][0].BBox()
-		return bboxi.Llx < bboxj.Llx
-	})
-	for li := range columns {
-		sort.SliceStable(columns[li], func(i, j int) bool {
-			bboxi, _ := columns[li][i].BBox()
-			bboxj, _ := columns[li][j].BBox()
-			return bboxi.Lly >= bboxj.Lly
-		})
-	}
-
-	colGroups := []model.PdfRectangle{}
-	for li, column := range columns {
-		var colRect model.PdfRectangle
-		common.Log.Debug("Column %d: ", li+1)
-		for i, word := range column {
-			wbbox, ok := word.BBox()
-			if !ok {
-				continue
-			}
-			common.Log.Debug("    '%s' / ", word.String())
-
-			if i == 0 {
-				colRect = wbbox
-			} else {
-				colRect = rectUnion(colRect, wbbox)
-			}
-		}
-		common.Log.Debug("Column %d: Bbox: %+v", li+1, colRect)
-		colGroups = append(colGroups, colRect)
-	}
-
-	// Filter by combining overlapping columns.
-	filtered := []model.PdfRectangle{}
-	for i := 0; i < len(colGroups); {
-		colgroup := colGroups[i]
-		j := i + 1
-		for ; j < len(colGroups); j++ {
-			overlap := columnOverlap(colgroup, colGroups[j])
-			common.Log.Debug("COLUMN overlap %d/%d: %v (%+v/%+v)", i+1, j+1, overlap, colgroup, colGroups[j])
-			if overlap > 0.0 {
-				break
-			}
-			colgroup = rectUnion(colgroup, colGroups[j])
-		}
-		i = j
-		filtered = append(filtered, colgroup)
-	}
-
-	saveParams.markups[saveParams.curPage] = append(saveParams.markups[saveParams.curPage], filtered)
-	return filtered
-}
-
-// getLineTableTextData converts the lines of words into table strings cells by accounting for
-// distribution of lines into columns as specified by `columnBBoxes`.
-func getLineTableTextData(lines [][]segmentationWord, columnBBoxes []model.PdfRectangle) [][]string {
-	tabledata := [][]string{}
 	for _, line := range lines {
-		linedata := make([]string, len(columnBBoxes))
-		for _, word := range line {
-			wordBBox, ok := word.BBox()
-			if !ok {
-				continue
+		lineBBox := line[0].BBox
+		for _, word := range line[1:] {
+			lineBBox = layout.BBox{
+				math.Min(lineBBox[0], word.BBox[0]),
+				math.Min(lineBBox[1], word.BBox[1]),
+				math.Max(lineBBox[2], word.BBox[2]),
+				math.Max(lineBBox[3], word.BBox[3]),
 			}
-
-			bestColumn := 0
-			bestOverlap := 1.0
-			for icol, colBBox := range columnBBoxes {
-				overlap := columnOverlap(wordBBox, colBBox)
-				if overlap < bestOverlap {
-					bestOverlap = overlap
-					bestColumn = icol
-				}
-			}
-			linedata[bestColumn] += word.String()
 		}
-		tabledata = append(tabledata, linedata)
+		lineGroups = append(lineGroups, toRect(lineBBox))
 	}
-	return tabledata
-}
-
-// segmentationWord represents a word that has been segmented in PDF text.
-type segmentationWord struct {
-	ma *extractor.TextMarkArray
-}
-
-func (w segmentationWord) Elements() []extractor.TextMark {
-	return w.ma.Elements()
-}
-
-func (w segmentationWord) BBox() (model.PdfRectangle, bool) {
-	return w.ma.BBox()
-}
-
-func (w segmentationWord) String() string {
-	if w.ma == nil {
-		return ""
-	}
-
-	var buf bytes.Buffer
-	for _, m := range w.Elements() {
-		buf.WriteString(m.Text)
-	}
-	return buf.String()
-}
-
-// pageMarksToCSV converts textMarks from a single page into CSV by grouping the marks into
-// words, lines and columns and then writing the table cells data as CSV output.
-func pageMarksToCSV(textMarks *extractor.TextMarkArray) (string, error) {
-	// STEP - Form words.
-	// Group the closest text marks that are overlapping.
-	words := []segmentationWord{}
-	word := segmentationWord{ma: &extractor.TextMarkArray{}}
-	var lastMark extractor.TextMark
-	isFirst := true
-	for i, mark := range textMarks.Elements() {
-		if mark.Text == "" {
-			continue
-		}
-
-		common.Log.Debug("Mark %d - '%s' (% X)", i, mark.Text, mark.Text)
-		if isFirst {
-			word = segmentationWord{ma: &extractor.TextMarkArray{}}
-			word.ma.Append(mark)
-			lastMark = mark
-			isFirst = false
-			continue
-		}
-		common.Log.Debug(" - overlaps: %f", overlaps(mark.BBox, lastMark.BBox))
-		overlap := overlaps(mark.BBox, lastMark.BBox)
-		if overlap > 0.1 {
-			if len(strings.TrimSpace(word.String())) > 0 {
-				common.Log.Debug("Appending word: '%s' (%d chars) (%d elements)", word.String(), len(word.String()), len(word.Elements()))
-				words = append(words, word)
-			}
-			word = segmentationWord{ma: &extractor.TextMarkArray{}}
-		}
-		word.ma.Append(mark)
-		lastMark = mark
-	}
-	if len(strings.TrimSpace(word.String())) > 0 {
-		common.Log.Debug("Appending word: '%s' (%d chars) (%d elements)", word.String(), len(word.String()), len(word.Elements()))
-		words = append(words, word)
-	}
-
-	// Include the words in the markup.
-	{
-		wbboxes := []model.PdfRectangle{}
-		for _, word := range words {
-			wbbox, ok := word.BBox()
-			if !ok {
-				continue
-			}
-			wbboxes = append(wbboxes, wbbox)
-		}
-		saveParams.markups[saveParams.curPage] = append(saveParams.markups[saveParams.curPage], wbboxes)
-	}
-
-	lines := identifyLines(words)
+	saveParams.markups[saveParams.curPage] = append(saveParams.markups[saveParams.curPage], lineGroups)
 
+	// STEP - Identify columns.
 	// Filter out words in lines with only 1 column.
-	tableLines := [][]segmentationWord{}
+	tableWords := []layout.Word{}
 	for _, line := range lines {
 		if len(line) <= 1 {
 			continue
 		}
-		tableLines = append(tableLines, line)
+		tableWords = append(tableWords, line...)
 	}
 
-	tableWords := []segmentationWord{}
-	for _, line := range tableLines {
-		for _, word := range line {
-			tableWords = append(tableWords, word)
-		}
-	}
+	columnBBoxes := layout.Columns(tableWords)
 
-	columnBBoxes := identifyColumns(tableWords)
+	colGroups := []model.PdfRectangle{}
+	for i, bbox := range columnBBoxes {
+		common.Log.Debug("Column %d: Bbox: %v", i+1, bbox)
+		colGroups = append(colGroups, toRect(bbox))
+	}
+	saveParams.markups[saveParams.curPage] = append(saveParams.markups[saveParams.curPage], colGroups)
 
-	tabledata := getLineTableTextData(lines, columnBBoxes)
+	tabledata := layout.TableCells(lines, columnBBoxes)
 
 	var buf bytes.Buffer
 	w := csv.NewWriter(&buf)
@@ -528,6 +258,11 @@ func pageMarksToCSV(textMarks *extractor.TextMarkArray) (string, error) {
 	return buf.String(), nil
 }
 
+// toRect converts `bbox` to a PdfRectangle.
+func toRect(bbox layout.BBox) model.PdfRectangle {
+	return model.PdfRectangle{Llx: bbox[0], Lly: bbox[1], Urx: bbox[2], Ury: bbox[3]}
+}
+
 type saveMarkedupParams struct {
 	pdfReader        *model.PdfReader
 	markups          map[int][][]model.PdfRectangle